@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/config"
+	"github.com/aruncs31s/skvms/internal/database"
+	"github.com/aruncs31s/skvms/internal/database/migrate"
+	"github.com/aruncs31s/skvms/internal/simulator"
+	"gorm.io/gorm"
+)
+
+const usage = `Usage: skvms [command]
+
+Commands:
+  serve                 run the HTTP server (default)
+  migrate status        list the schema migrations and whether they are applied
+  migrate up            apply every pending migration
+  migrate down [N]      roll back the last N applied migrations, 1 by default
+  migrate to VERSION    migrate up or down to VERSION, 0 rolls back everything
+  seed                  load the default device types, the admin user and demo devices
+  simulate [flags]      post readings from virtual devices to a running server,
+                        see simulate -h
+
+The configuration is read from $CONFIG_FILE or config/skvms.yaml and the
+environment, as for serve.
+`
+
+// runMigrate runs a migrate subcommand and returns the exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	db, err := openDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	migrator, err := migrate.New(db, migrate.All)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	ctx := context.Background()
+
+	var ran []migrate.Migration
+	switch {
+	case args[0] == "status" && len(args) == 1:
+		return printMigrationStatus(ctx, migrator)
+	case args[0] == "up" && len(args) == 1:
+		ran, err = migrator.Up(ctx)
+	case args[0] == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintf(os.Stderr, "invalid number of migrations %q\n", args[1])
+				return 2
+			}
+		}
+		ran, err = migrator.Down(ctx, steps)
+	case args[0] == "to" && len(args) == 2:
+		version, parseErr := strconv.ParseUint(args[1], 10, 0)
+		if parseErr != nil {
+			fmt.Fprintf(os.Stderr, "invalid migration version %q\n", args[1])
+			return 2
+		}
+		ran, err = migrator.To(ctx, uint(version))
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	for _, m := range ran {
+		fmt.Printf("migrated %s\n", m)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(ran) == 0 {
+		fmt.Println("nothing to migrate")
+	}
+	return 0
+}
+
+func printMigrationStatus(ctx context.Context, migrator *migrate.Migrator) int {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		status, appliedAt := "pending", ""
+		if s.Applied {
+			status, appliedAt = "applied", s.AppliedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+	}
+	if err := w.Flush(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// runSeed loads the seed data into a migrated database and returns the exit
+// code.
+func runSeed(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	db, err := openDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	migrator, err := migrate.New(db, migrate.All)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	pending, err := migrator.Pending(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(pending) > 0 {
+		fmt.Fprintf(os.Stderr, "%d migrations are pending, run migrate up first\n", len(pending))
+		return 1
+	}
+	if err := database.Seed(db); err != nil {
+		fmt.Fprintf(os.Stderr, "seed: %v\n", err)
+		return 1
+	}
+	fmt.Println("database seeded")
+	return 0
+}
+
+// runSimulate provisions a fleet of virtual devices on a running server and
+// posts their readings until the duration passes or SIGINT, then prints the
+// throughput and latency. It returns the exit code.
+func runSimulate(args []string) int {
+	cfg := simulator.Config{Out: os.Stdout, HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	flags.StringVar(&cfg.BaseURL, "url", "http://localhost:8080", "`URL` of the server")
+	flags.StringVar(&cfg.Username, "user", "admin", "`username` owning the devices")
+	flags.StringVar(&cfg.Password, "password", os.Getenv("SIMULATE_PASSWORD"), "`password` of the user, $SIMULATE_PASSWORD by default")
+	flags.IntVar(&cfg.Devices, "devices", 10, "number of virtual devices")
+	flags.StringVar(&cfg.NamePrefix, "prefix", "sim-", "name `prefix` of the devices, reused across runs")
+	deviceType := flags.Uint("type", 0, "device type `ID` of created devices, the first one by default")
+	flags.DurationVar(&cfg.Interval, "interval", 5*time.Second, "time between the readings of a device")
+	flags.DurationVar(&cfg.Duration, "duration", 0, "stop after this long, run until interrupted by default")
+	flags.Float64Var(&cfg.Speed, "speed", 60, "simulated seconds per second, for the solar cycle")
+	flags.Float64Var(&cfg.FaultRate, "faults", 0.05, "faults per device and simulated hour")
+	flags.Float64Var(&cfg.OfflineRate, "outages", 0.02, "outages per device and simulated hour")
+	flags.Int64Var(&cfg.Seed, "seed", 0, "random seed of the device curves, a new one by default")
+	flags.DurationVar(&cfg.ReportEvery, "report", 10*time.Second, "interval of progress reports, 0 for none")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+	cfg.DeviceType = *deviceType
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fleet, err := simulator.Provision(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	report := fleet.Run(ctx)
+	fmt.Printf("total %s\n", report)
+	if report.Sent > 0 && report.Failed == report.Sent {
+		return 1
+	}
+	return 0
+}
+
+// openDatabase connects to the configured database for a command.
+func openDatabase() (*gorm.DB, error) {
+	store, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	db, err := database.Open(store.Current())
+	if err != nil {
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,296 @@
+// Package app wires the repositories, services and handlers of the server
+// into its router. The server and the end-to-end tests share it.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/audittrail"
+	"github.com/aruncs31s/skvms/internal/codegen"
+	"github.com/aruncs31s/skvms/internal/codegen/builder"
+	"github.com/aruncs31s/skvms/internal/config"
+	exportpkg "github.com/aruncs31s/skvms/internal/export"
+	httpHandler "github.com/aruncs31s/skvms/internal/handler/http"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
+	"github.com/aruncs31s/skvms/internal/health"
+	"github.com/aruncs31s/skvms/internal/importer"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/mailer"
+	"github.com/aruncs31s/skvms/internal/repository"
+	"github.com/aruncs31s/skvms/internal/router"
+	"github.com/aruncs31s/skvms/internal/secrets"
+	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Options replace the external tools of the server, e.g. with fakes in
+// tests. Nil fields use the configured ones.
+type Options struct {
+	// PDFRenderer renders PDF exports instead of a pool of Chrome browsers
+	PDFRenderer exportpkg.PDFRenderer
+	// Toolchain builds firmware instead of the installed build tools
+	Toolchain builder.Toolchain
+	// Mailer sends scheduled reports instead of the SMTP server
+	Mailer mailer.Mailer
+}
+
+// App is the wired server.
+type App struct {
+	Handler *gin.Engine
+	Checker *health.Checker
+
+	CORS        *middleware.CORS
+	RateLimiter *middleware.RateLimiter
+
+	AuditService  service.AuditService
+	AuditOutbox   *audittrail.Outbox
+	DeviceService service.DeviceService
+	ExportService *exportpkg.Service
+	Codegen       *codegen.Service
+	Reports       service.ReportService
+
+	cfg     config.Config
+	closers []func()
+}
+
+// New wires the server on db. Its background jobs only run once Start is
+// called.
+func New(cfg config.Config, db *gorm.DB, opts Options) (*App, error) {
+	a := &App{cfg: cfg, Checker: health.NewChecker()}
+
+	userRepo := repository.NewUserRepository(db)
+	deviceRepo := repository.NewDeviceRepository(db)
+	readingRepo := repository.NewReadingRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	deviceTypesRepo := repository.NewDeviceTypesRepository(db)
+	versionRepo := repository.NewVersionRepository(db)
+	locationRepo := repository.NewLocationRepository(db)
+	boardProfileRepo := repository.NewBoardProfileRepository(db)
+
+	authService := service.NewAuthService(userRepo, cfg.Auth.JWTSecret, cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL)
+	deviceAuthService := service.NewDeviceAuthService(deviceRepo, userRepo, cfg.Auth.JWTSecret, cfg.Auth.DeviceTokenTTL)
+	// Audit entries are queued in a local outbox and appended to the hash
+	// chain in the background; checkpoints of the chain are signed and
+	// exported to a file
+	auditOutbox, err := audittrail.OpenOutbox(filepath.Join(cfg.Audit.Dir, "outbox"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit outbox: %w", err)
+	}
+	a.AuditOutbox = auditOutbox
+	auditSigner, err := audittrail.LoadSigner(cfg.Audit.SigningKey, filepath.Join(cfg.Audit.Dir, "signing.key"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit signing key: %w", err)
+	}
+	auditService := service.NewAuditService(
+		auditRepo,
+		auditOutbox,
+		auditSigner,
+		audittrail.NewCheckpointFile(filepath.Join(cfg.Audit.Dir, "checkpoints.jsonl")),
+	)
+	a.AuditService = auditService
+	deviceStateService := service.NewDeviceStateService(
+		repository.NewDeviceStateRepository(
+			db,
+		),
+		deviceRepo,
+		service.NewDeviceStateHistoryService(
+			repository.NewDeviceStateHistoryRepository(db),
+		),
+	)
+	deviceService := service.NewDeviceService(
+		deviceRepo,
+		userRepo,
+		deviceStateService,
+		auditService,
+		deviceTypesRepo,
+		repository.NewMicrocontrollersRepository(db),
+	)
+	a.DeviceService = deviceService
+	readingService := service.NewReadingService(readingRepo, deviceService)
+	userService := service.NewUserService(userRepo, deviceService, auditService)
+	deviceTypesService := service.NewDeviceTypesService(deviceTypesRepo, boardProfileRepo)
+	versionService := service.NewVersionService(versionRepo)
+	adminService := service.NewAdminService(userRepo, deviceRepo, readingRepo, auditRepo)
+	locationService := service.NewLocationService(locationRepo, deviceRepo, readingRepo)
+
+	secretsKey := cfg.Auth.SecretsKey
+	if secretsKey == "" {
+		logger.GetLogger().Warn("auth.secrets_key (SECRETS_KEY) is not set, deriving the secrets key from the JWT secret")
+		secretsKey = cfg.Auth.JWTSecret
+	}
+	cipher, err := secrets.NewCipher(secretsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize secrets cipher: %w", err)
+	}
+	wifiProfileService := service.NewWiFiProfileService(
+		repository.NewWiFiProfileRepository(db),
+		locationRepo,
+		userRepo,
+		cipher,
+	)
+
+	authHandler := httpHandler.NewAuthHandler(authService, auditService)
+	deviceAuthHandler := httpHandler.NewDeviceAuthHandler(deviceAuthService, auditService)
+	deviceHandler := httpHandler.NewDeviceHandler(deviceService, auditService)
+	readingHandler := httpHandler.NewReadingHandler(readingService)
+	userHandler := httpHandler.NewUserHandler(userService, auditService)
+	deviceTypesHandler := httpHandler.NewDeviceTypesHandler(deviceTypesService)
+	versionHandler := httpHandler.NewVersionHandler(versionService, auditService)
+	adminHandler := httpHandler.NewAdminHandler(adminService)
+	deviceStateHandler := httpHandler.NewDeviceStateHandler(deviceStateService, service.NewDeviceStateHistoryService(
+		repository.NewDeviceStateHistoryRepository(db),
+	), auditService)
+	locationHandler := httpHandler.NewLocationHandler(locationService, auditService)
+	wifiProfileHandler := httpHandler.NewWiFiProfileHandler(wifiProfileService, auditService)
+	featureFlagHandler := httpHandler.NewFeatureFlagHandler(
+		service.NewFeatureFlagService(repository.NewFeatureFlagRepository(db), versionRepo, userRepo),
+		auditService,
+	)
+	firmwareHandler := httpHandler.NewFirmwareHandler(
+		service.NewFirmwareService(repository.NewDeviceFirmwareRepository(db)),
+	)
+
+	// Initialize codegen service and handler
+	codegenService := codegen.NewService(
+		cfg.Codegen.WorkDir,
+		boardProfileRepo,
+		service.NewCodegenSecrets(wifiProfileService, deviceAuthService),
+	)
+	if opts.Toolchain != nil {
+		codegenService.UseToolchain(opts.Toolchain)
+	}
+	a.Codegen = codegenService
+	codegenHandler := httpHandler.NewCodeGenHandler(codegenService)
+
+	// Initialize export service and handler
+	pdfRenderer := opts.PDFRenderer
+	if pdfRenderer == nil {
+		// Warm Chrome browsers shared by every PDF export
+		pool := exportpkg.NewChromePool(exportpkg.ChromePoolConfig{
+			ExecPath:      cfg.PDF.ChromePath,
+			Size:          cfg.PDF.PoolSize,
+			RenderTimeout: cfg.PDF.RenderTimeout,
+		})
+		a.closers = append(a.closers, pool.Close)
+		pdfRenderer = pool
+	}
+	exportService := exportpkg.NewService(cfg.PDF.TemplatesDir, pdfRenderer)
+	a.ExportService = exportService
+	// Library of PDF templates, referenced by ID from exports and reports
+	exportTemplateService := service.NewExportTemplateService(
+		repository.NewExportTemplateRepository(db),
+		exportService,
+	)
+	exportHandler := httpHandler.NewExportHandler(
+		exportService,
+		readingService,
+		deviceService,
+		locationService,
+		service.NewSummaryService(readingRepo, locationRepo, deviceService),
+		exportTemplateService,
+	)
+	exportTemplateHandler := httpHandler.NewExportTemplateHandler(exportTemplateService, auditService)
+	// Audit log search and export
+	auditHandler := httpHandler.NewAuditHandler(auditService, exportService)
+
+	// Scheduled reports are rendered with the exporters and emailed
+	reportMailer := opts.Mailer
+	if reportMailer == nil {
+		reportMailer = mailer.NewSMTPMailer(mailer.Config{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.User,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+		})
+		if cfg.SMTP.Host == "" {
+			logger.GetLogger().Warn("SMTP_HOST is not set, scheduled reports will not be emailed")
+		}
+	}
+	reportService := service.NewReportService(
+		repository.NewReportRepository(db),
+		exportService,
+		readingService,
+		deviceService,
+		exportTemplateService,
+		reportMailer,
+		cfg.Reports.Dir,
+	)
+	a.Reports = reportService
+	reportHandler := httpHandler.NewReportHandler(reportService, auditService)
+
+	// Initialize import service and handler
+	importService := importer.NewService(repository.NewImportRepository(db))
+	importHandler := httpHandler.NewImportHandler(importService, auditService)
+
+	// Readiness checks besides the background workers. Without Chrome or
+	// disk space for builds the server still serves everything else.
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to access database pool: %w", err)
+	}
+	a.Checker.Add("database", true, sqlDB.PingContext)
+	a.Checker.Add("codegen_disk", false, health.DiskSpace(codegenService.WorkDir(), uint64(cfg.Codegen.MinFreeDiskMB)<<20))
+	a.Checker.Add("chrome", false, func(ctx context.Context) error {
+		if stats := exportService.RendererStats(); !stats.Available {
+			return errors.New(stats.Error)
+		}
+		return nil
+	})
+
+	a.CORS = middleware.NewCORS(cfg.Server.CORSOrigins)
+	a.RateLimiter = middleware.NewRateLimiter(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)
+
+	// Setup router with all routes
+	appRouter := router.NewRouter(
+		authHandler,
+		deviceHandler,
+		deviceAuthHandler,
+		readingHandler,
+		auditHandler,
+		userHandler,
+		deviceTypesHandler,
+		versionHandler,
+		deviceStateHandler,
+		adminHandler,
+		codegenHandler,
+		locationHandler,
+		exportHandler,
+		wifiProfileHandler,
+		firmwareHandler,
+		featureFlagHandler,
+		reportHandler,
+		exportTemplateHandler,
+		importHandler,
+		httpHandler.NewHealthHandler(a.Checker),
+		auditService,
+		deviceAuthService,
+		a.CORS,
+		a.RateLimiter,
+		cfg.Auth.JWTSecret,
+	)
+	a.Handler = appRouter.SetupRouter()
+	return a, nil
+}
+
+// Start runs the background jobs until ctx is done. Their workers report to
+// the health checks.
+func (a *App) Start(ctx context.Context) {
+	a.Checker.AddWorker(a.AuditService.StartWriter(ctx, 5*time.Second))
+	a.Checker.AddWorker(a.AuditService.StartCheckpoints(ctx, a.cfg.Audit.CheckpointInterval))
+	// Built binaries carry the device's secrets, wipe them after a while
+	a.Checker.AddWorker(a.Codegen.StartJanitor(ctx, a.cfg.Codegen.BuildTTL))
+	a.Checker.AddWorker(a.Reports.StartScheduler(ctx, time.Minute))
+}
+
+// Close releases the external tools, such as the Chrome browsers.
+func (a *App) Close() {
+	for _, closer := range a.closers {
+		closer()
+	}
+}
@@ -0,0 +1,182 @@
+// Package audittrail provides the pieces that make the audit log tamper
+// evident and durable: a local outbox entries are written to before they
+// reach the database, and the key that signs checkpoints of the hash chain.
+package audittrail
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRejected is wrapped by a Drain callback to report a record that can
+// never be delivered, such as one that does not decode. The record is moved
+// to the rejected directory instead of blocking the records behind it.
+var ErrRejected = errors.New("outbox record rejected")
+
+const (
+	recordExt   = ".json"
+	rejectedDir = "rejected"
+)
+
+// Outbox is a durable first-in first-out queue of records kept as one file
+// per record in a directory. Put returns once the record is on disk, so a
+// record survives a crash or a database outage until it is drained.
+type Outbox struct {
+	dir string
+
+	mu   sync.Mutex
+	next uint64
+
+	// drainMu serializes Drain, which must deliver records in order
+	drainMu sync.Mutex
+	wake    chan struct{}
+}
+
+// OpenOutbox opens the outbox in dir, creating it if needed. Records left
+// by a previous run are kept and delivered first.
+func OpenOutbox(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(filepath.Join(dir, rejectedDir), 0o700); err != nil {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+	o := &Outbox{
+		dir:  dir,
+		next: uint64(time.Now().UnixNano()),
+		wake: make(chan struct{}, 1),
+	}
+
+	names, err := o.records()
+	if err != nil {
+		return nil, err
+	}
+	// Keep the order across restarts even if the clock went back
+	if len(names) > 0 {
+		last, _ := strconv.ParseUint(strings.TrimSuffix(names[len(names)-1], recordExt), 10, 64)
+		if last >= o.next {
+			o.next = last + 1
+		}
+	}
+	// Remove records whose write was interrupted; Put had not returned
+	tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	for _, tmp := range tmps {
+		_ = os.Remove(tmp)
+	}
+	return o, nil
+}
+
+// Put writes a record and syncs it to disk.
+func (o *Outbox) Put(data []byte) error {
+	o.mu.Lock()
+	name := fmt.Sprintf("%020d%s", o.next, recordExt)
+	o.next++
+	o.mu.Unlock()
+
+	path := filepath.Join(o.dir, name)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("outbox: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("outbox: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("outbox: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("outbox: %w", err)
+	}
+	syncDir(o.dir)
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Wake receives after a Put, to start draining without waiting for a poll.
+func (o *Outbox) Wake() <-chan struct{} {
+	return o.wake
+}
+
+// Drain passes the records to deliver in the order they were put, removing
+// each once deliver returns nil. It stops at the first other error, leaving
+// that record and the ones after it for the next Drain. It returns the
+// number of records delivered.
+func (o *Outbox) Drain(deliver func(data []byte) error) (int, error) {
+	o.drainMu.Lock()
+	defer o.drainMu.Unlock()
+
+	names, err := o.records()
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, name := range names {
+		path := filepath.Join(o.dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return delivered, fmt.Errorf("outbox: %w", err)
+		}
+		if err := deliver(data); err != nil {
+			if !errors.Is(err, ErrRejected) {
+				return delivered, err
+			}
+			if err := os.Rename(path, filepath.Join(o.dir, rejectedDir, name)); err != nil {
+				return delivered, fmt.Errorf("outbox: %w", err)
+			}
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return delivered, fmt.Errorf("outbox: %w", err)
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+// Pending returns the number of records waiting to be delivered.
+func (o *Outbox) Pending() int {
+	names, _ := o.records()
+	return len(names)
+}
+
+// records lists the record files in order.
+func (o *Outbox) records() ([]string, error) {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), recordExt) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// syncDir flushes a directory so renames in it survive a crash.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+}
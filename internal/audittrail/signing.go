@@ -0,0 +1,176 @@
+package audittrail
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signer signs checkpoints of the audit chain with an ed25519 key.
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// LoadSigner returns the checkpoint signer. encoded is a base64 ed25519
+// seed, e.g. from AUDIT_SIGNING_KEY; when empty the seed is read from
+// keyFile, which is created with a new key on first use. The public key is
+// written next to keyFile with a .pub extension for external verifiers.
+func LoadSigner(encoded, keyFile string) (*Signer, error) {
+	if encoded == "" {
+		data, err := os.ReadFile(keyFile)
+		switch {
+		case err == nil:
+			encoded = strings.TrimSpace(string(data))
+		case errors.Is(err, os.ErrNotExist):
+			seed := make([]byte, ed25519.SeedSize)
+			if _, err := rand.Read(seed); err != nil {
+				return nil, err
+			}
+			encoded = base64.StdEncoding.EncodeToString(seed)
+			if err := os.MkdirAll(filepath.Dir(keyFile), 0o700); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(keyFile, []byte(encoded+"\n"), 0o600); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("audit signing key must be a base64 encoded %d-byte ed25519 seed", ed25519.SeedSize)
+	}
+	s := &Signer{key: ed25519.NewKeyFromSeed(seed)}
+
+	pub := base64.StdEncoding.EncodeToString(s.PublicKey())
+	pubFile := strings.TrimSuffix(keyFile, filepath.Ext(keyFile)) + ".pub"
+	if err := os.MkdirAll(filepath.Dir(pubFile), 0o700); err == nil {
+		_ = os.WriteFile(pubFile, []byte(pub+"\n"), 0o644)
+	}
+	return s, nil
+}
+
+// PublicKey returns the key checkpoints are verified with.
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// KeyID returns the hex of the first 8 bytes of the SHA-256 of the public key.
+func (s *Signer) KeyID() string {
+	sum := sha256.Sum256(s.PublicKey())
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sign returns the base64 signature of the checkpoint of the chain at seq.
+func (s *Signer) Sign(seq uint64, hash string, at time.Time) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, checkpointMessage(seq, hash, at)))
+}
+
+// Verify checks the signature of a checkpoint.
+func (s *Signer) Verify(seq uint64, hash string, at time.Time, signature string) bool {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(s.PublicKey(), checkpointMessage(seq, hash, at), sig)
+}
+
+// checkpointMessage is the signed statement of a checkpoint.
+func checkpointMessage(seq uint64, hash string, at time.Time) []byte {
+	return fmt.Appendf(nil, "skvms-audit-checkpoint:v1:%d:%s:%d", seq, hash, at.Unix())
+}
+
+// CheckpointRecord is one line of the checkpoint file.
+type CheckpointRecord struct {
+	Seq       uint64    `json:"seq"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	KeyID     string    `json:"key_id"`
+	PublicKey string    `json:"public_key"`
+	Signature string    `json:"signature"`
+}
+
+// CheckpointFile appends signed checkpoints to a JSON lines file.
+type CheckpointFile struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewCheckpointFile(path string) *CheckpointFile {
+	return &CheckpointFile{path: path}
+}
+
+// Path returns the location of the file.
+func (f *CheckpointFile) Path() string {
+	return f.path
+}
+
+// Append adds a checkpoint to the file and syncs it.
+func (f *CheckpointFile) Append(record CheckpointRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Read returns the checkpoints in the file, oldest first. A missing file
+// holds no checkpoints.
+func (f *CheckpointFile) Read() ([]CheckpointRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []CheckpointRecord
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record CheckpointRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", f.path, line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,194 @@
+package codegen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/codegen/builder"
+)
+
+// BuildCache keeps compiled objects and firmware images between codegen
+// requests. Objects are shared by every build of the same source revision,
+// board profile and build tool, so only changed sources are recompiled.
+// Images are additionally keyed by the non-secret config and are only stored
+// when the secret values are patched in per device. Firmware sources with the
+// legacy config.h and no schema file compile their secrets in, so they never
+// use the image cache.
+type BuildCache struct {
+	dir string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// CacheStats summarises the contents of the build cache.
+type CacheStats struct {
+	ObjectDirs int   `json:"object_dirs"`
+	Images     int   `json:"images"`
+	SizeBytes  int64 `json:"size_bytes"`
+}
+
+// imageMeta is stored next to a cached image to explain where it came from.
+type imageMeta struct {
+	Revision  string    `json:"revision"`
+	Board     string    `json:"board"`
+	Tool      string    `json:"tool"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewBuildCache creates a cache rooted at dir.
+func NewBuildCache(dir string) *BuildCache {
+	return &BuildCache{
+		dir:   dir,
+		locks: make(map[string]*sync.Mutex),
+	}
+}
+
+// LockObjectDir returns the object directory for a revision, board and tool,
+// locked for the caller. Call unlock once the build finished.
+func (c *BuildCache) LockObjectDir(revision string, profile builder.BoardProfile, tool string) (dir string, unlock func(), err error) {
+	key := hashJSON(struct {
+		Revision string
+		Profile  builder.BoardProfile
+		Tool     string
+	}{revision, profile, tool})
+	dir = filepath.Join(c.dir, "objects", key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create object cache: %w", err)
+	}
+
+	c.mu.Lock()
+	lock, ok := c.locks[dir]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.locks[dir] = lock
+	}
+	c.mu.Unlock()
+
+	lock.Lock()
+	return dir, lock.Unlock, nil
+}
+
+// ImageKey identifies a firmware image. It returns "" when the config cannot
+// be cached because a secret value would be compiled into the image.
+func (c *BuildCache) ImageKey(revision string, profile builder.BoardProfile, tool string, cfg *FirmwareConfig, patching bool) string {
+	fingerprint, ok := cfg.cacheFingerprint(patching)
+	if !ok || revision == "" {
+		return ""
+	}
+	return hashJSON(struct {
+		Revision string
+		Profile  builder.BoardProfile
+		Tool     string
+		Config   map[string]string
+		Patching bool
+	}{revision, profile, tool, fingerprint, patching})
+}
+
+// LoadImage returns a copy of a cached image.
+func (c *BuildCache) LoadImage(key string) ([]byte, bool) {
+	image, err := os.ReadFile(filepath.Join(c.dir, "images", key, "firmware.bin"))
+	if err != nil {
+		return nil, false
+	}
+	return image, true
+}
+
+// StoreImage saves the unpatched image of a build.
+func (c *BuildCache) StoreImage(key, binaryPath, revision, board, tool string) error {
+	image, err := os.ReadFile(binaryPath)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(c.dir, "images", key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(imageMeta{
+		Revision:  revision,
+		Board:     board,
+		Tool:      tool,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "meta.json"), meta, 0644); err != nil {
+		return err
+	}
+
+	// Write then rename so a concurrent LoadImage never sees a partial image
+	tmp := filepath.Join(dir, "firmware.bin.tmp")
+	if err := os.WriteFile(tmp, image, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, "firmware.bin"))
+}
+
+// Stats walks the cache and counts its entries.
+func (c *BuildCache) Stats() (CacheStats, error) {
+	var stats CacheStats
+	for kind, count := range map[string]*int{"objects": &stats.ObjectDirs, "images": &stats.Images} {
+		entries, err := os.ReadDir(filepath.Join(c.dir, kind))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return stats, err
+		}
+		*count = len(entries)
+	}
+
+	err := filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			stats.SizeBytes += info.Size()
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Purge removes every cached object dir and image. Object dirs in use by a
+// running build are skipped.
+func (c *BuildCache) Purge() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	objects := filepath.Join(c.dir, "objects")
+	entries, err := os.ReadDir(objects)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, e := range entries {
+		dir := filepath.Join(objects, e.Name())
+		if lock, ok := c.locks[dir]; ok {
+			if !lock.TryLock() {
+				continue
+			}
+			err := os.RemoveAll(dir)
+			lock.Unlock()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(filepath.Join(c.dir, "images"))
+}
+
+func hashJSON(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
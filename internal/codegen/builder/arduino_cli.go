@@ -22,14 +22,21 @@ const (
 
 // ArduinoCLIBuilder implements BuildStrategy using Arduino CLI.
 type ArduinoCLIBuilder struct {
-	FQBN string // Fully Qualified Board Name (e.g., "esp32:esp32:esp32")
+	FQBN    string // Fully Qualified Board Name (e.g., "esp32:esp32:esp32")
+	Profile BoardProfile
+	Options BuildOptions
 }
 
 func NewArduinoCLIBuilder(fqbn string) *ArduinoCLIBuilder {
-	if fqbn == "" {
-		fqbn = defaultESP32FQBN
+	return NewArduinoCLIBuilderForProfile(ProfileForFQBN(fqbn))
+}
+
+// NewArduinoCLIBuilderForProfile creates a builder for the given board profile.
+func NewArduinoCLIBuilderForProfile(profile BoardProfile) *ArduinoCLIBuilder {
+	if profile.FQBN == "" {
+		profile.FQBN = defaultESP32FQBN
 	}
-	return &ArduinoCLIBuilder{FQBN: fqbn}
+	return &ArduinoCLIBuilder{FQBN: profile.FQBN, Profile: profile}
 }
 
 func (a *ArduinoCLIBuilder) Name() string {
@@ -42,21 +49,21 @@ func (a *ArduinoCLIBuilder) IsAvailable() bool {
 }
 
 func (a *ArduinoCLIBuilder) Build(ctx context.Context, projectDir string) (*BuildResult, error) {
-	logger.GetLogger().Info("Building firmware with Arduino CLI",
+	logger.FromContext(ctx).Info("Building firmware with Arduino CLI",
 		zap.String("project_dir", projectDir),
 		zap.String("fqbn", a.FQBN),
 	)
 
 	// Ensure the board core is installed
 	if err := a.ensureCoreInstalled(ctx); err != nil {
-		logger.GetLogger().Warn("Failed to install board core (may already be installed)",
+		logger.FromContext(ctx).Warn("Failed to install board core (may already be installed)",
 			zap.Error(err),
 		)
 	}
 
 	// Install library dependencies
 	if err := a.installLibraries(ctx); err != nil {
-		logger.GetLogger().Warn("Failed to install libraries (may already be installed)",
+		logger.FromContext(ctx).Warn("Failed to install libraries (may already be installed)",
 			zap.Error(err),
 		)
 	}
@@ -73,25 +80,32 @@ func (a *ArduinoCLIBuilder) Build(ctx context.Context, projectDir string) (*Buil
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	cmd := exec.CommandContext(ctx,
-		"arduino-cli", "compile",
-		"--fqbn", a.FQBN,
+	args := []string{
+		"compile",
+		"--fqbn", a.Profile.BoardOptionsFQBN(),
 		"--output-dir", outputDir,
 		"--libraries", filepath.Join(projectDir, "lib"),
-		sketchDir,
-	)
+	}
+	if a.Options.ObjectDir != "" {
+		// Reuse compiled core and library objects from previous builds
+		args = append(args, "--build-path", a.Options.ObjectDir)
+	}
+	args = append(args, sketchDir)
+	cmd := exec.CommandContext(ctx, "arduino-cli", args...)
 
-	output, err := cmd.CombinedOutput()
+	output, err := combinedOutput(ctx, cmd)
+	// The output can echo the generated config, which holds the device's secrets
+	buildLog := a.Options.redact(string(output))
 	if err != nil {
-		logger.GetLogger().Error("Arduino CLI build failed",
-			zap.String("output", string(output)),
+		logger.FromContext(ctx).Error("Arduino CLI build failed",
+			zap.String("output", buildLog),
 			zap.Error(err),
 		)
-		return nil, fmt.Errorf("arduino-cli build failed: %w\nOutput: %s", err, string(output))
+		return nil, fmt.Errorf("arduino-cli build failed: %w\nOutput: %s", err, buildLog)
 	}
 
-	logger.GetLogger().Info("Arduino CLI build succeeded",
-		zap.String("output_tail", tailString(string(output), 500)),
+	logger.FromContext(ctx).Info("Arduino CLI build succeeded",
+		zap.String("output_tail", tailString(buildLog, 500)),
 	)
 
 	// Find the compiled binary
@@ -119,36 +133,45 @@ func (a *ArduinoCLIBuilder) ensureCoreInstalled(ctx context.Context) error {
 	}
 	core := parts[0] + ":" + parts[1]
 
-	// Add board manager URL for ESP boards
-	var boardURL string
-	switch parts[0] {
-	case "esp32":
-		boardURL = "https://raw.githubusercontent.com/espressif/arduino-esp32/gh-pages/package_esp32_index.json"
-	case "esp8266":
-		boardURL = "https://arduino.esp8266.com/stable/package_esp8266com_index.json"
-	}
+	return runSetupOnce("arduino-cli core "+core, func() error {
+		// Add board manager URL for ESP boards
+		var boardURL string
+		switch parts[0] {
+		case "esp32":
+			boardURL = "https://raw.githubusercontent.com/espressif/arduino-esp32/gh-pages/package_esp32_index.json"
+		case "esp8266":
+			boardURL = "https://arduino.esp8266.com/stable/package_esp8266com_index.json"
+		}
 
-	if boardURL != "" {
-		cmd := exec.CommandContext(ctx, "arduino-cli", "config", "add", "board_manager.additional_urls", boardURL)
-		_ = cmd.Run() // Ignore errors if already added
+		if boardURL != "" {
+			cmd := exec.CommandContext(ctx, "arduino-cli", "config", "add", "board_manager.additional_urls", boardURL)
+			_ = run(ctx, cmd) // Ignore errors if already added
 
-		cmd = exec.CommandContext(ctx, "arduino-cli", "core", "update-index")
-		_ = cmd.Run()
-	}
+			cmd = exec.CommandContext(ctx, "arduino-cli", "core", "update-index")
+			_ = run(ctx, cmd)
+		}
 
-	cmd := exec.CommandContext(ctx, "arduino-cli", "core", "install", core)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("core install failed: %w\nOutput: %s", err, string(output))
-	}
-	return nil
+		cmd := exec.CommandContext(ctx, "arduino-cli", "core", "install", core)
+		output, err := combinedOutput(ctx, cmd)
+		if err != nil {
+			return fmt.Errorf("core install failed: %w\nOutput: %s", err, string(output))
+		}
+		return nil
+	})
 }
 
 func (a *ArduinoCLIBuilder) installLibraries(ctx context.Context) error {
-	libs := []string{"ArduinoJson"}
-	for _, lib := range libs {
-		cmd := exec.CommandContext(ctx, "arduino-cli", "lib", "install", lib)
-		_ = cmd.Run() // Best effort
+	var failed []string
+	for _, lib := range a.Profile.Libraries {
+		err := runSetupOnce("arduino-cli lib "+lib, func() error {
+			return run(ctx, exec.CommandContext(ctx, "arduino-cli", "lib", "install", lib))
+		})
+		if err != nil {
+			failed = append(failed, lib)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to install libraries: %s", strings.Join(failed, ", "))
 	}
 	return nil
 }
@@ -210,7 +233,7 @@ func (a *ArduinoCLIBuilder) findBinary(outputDir string) (string, error) {
 }
 
 func (a *ArduinoCLIBuilder) Upload(ctx context.Context, projectDir string, deviceIP string) error {
-	logger.GetLogger().Info("Uploading firmware via Arduino CLI OTA",
+	logger.FromContext(ctx).Info("Uploading firmware via Arduino CLI OTA",
 		zap.String("project_dir", projectDir),
 		zap.String("device_ip", deviceIP),
 		zap.String("fqbn", a.FQBN),
@@ -226,8 +249,18 @@ func (a *ArduinoCLIBuilder) Upload(ctx context.Context, projectDir string, devic
 		return fmt.Errorf("build failed before upload: %w", err)
 	}
 
-	// Try espota.py for OTA upload
-	espotaPath, err := findEspota()
+	return a.UploadBinary(ctx, result.BinaryPath, deviceIP)
+}
+
+// UploadBinary flashes binaryPath with the espota.py of the board's core.
+func (a *ArduinoCLIBuilder) UploadBinary(ctx context.Context, binaryPath string, deviceIP string) error {
+	return uploadOTA(ctx, a.Profile.Family(), binaryPath, deviceIP)
+}
+
+// uploadOTA flashes an already built firmware image to the device at
+// deviceIP over ArduinoOTA, using the espota.py of the chip family.
+func uploadOTA(ctx context.Context, family string, binaryPath string, deviceIP string) error {
+	espotaPath, err := findEspota(family)
 	if err != nil {
 		return fmt.Errorf("espota.py not found, cannot perform OTA upload: %w", err)
 	}
@@ -235,33 +268,43 @@ func (a *ArduinoCLIBuilder) Upload(ctx context.Context, projectDir string, devic
 	cmd := exec.CommandContext(ctx,
 		"python3", espotaPath,
 		"-i", deviceIP,
-		"-f", result.BinaryPath,
+		"-f", binaryPath,
 	)
 
-	output, err := cmd.CombinedOutput()
+	output, err := combinedOutput(ctx, cmd)
 	if err != nil {
-		logger.GetLogger().Error("Arduino CLI OTA upload failed",
+		logger.FromContext(ctx).Error("OTA upload failed",
 			zap.String("output", string(output)),
 			zap.Error(err),
 		)
 		return fmt.Errorf("OTA upload failed: %w\nOutput: %s", err, string(output))
 	}
 
-	logger.GetLogger().Info("Arduino CLI OTA upload succeeded",
+	logger.FromContext(ctx).Info("OTA upload succeeded",
 		zap.String("device_ip", deviceIP),
+		zap.String("binary", binaryPath),
 	)
 	return nil
 }
 
-// findEspota looks for the espota.py script in common locations.
-func findEspota() (string, error) {
-	commonPaths := []string{
+// espotaDirs are the directories the Arduino cores and PlatformIO
+// frameworks of each chip family ship espota.py in.
+var espotaDirs = map[string][]string{
+	"esp32": {
 		filepath.Join(os.Getenv("HOME"), ".arduino15", "packages", "esp32", "hardware", "esp32"),
 		filepath.Join(os.Getenv("HOME"), ".platformio", "packages", "framework-arduinoespressif32", "tools"),
 		"/usr/share/arduino/hardware/espressif/esp32/tools",
-	}
+	},
+	"esp8266": {
+		filepath.Join(os.Getenv("HOME"), ".arduino15", "packages", "esp8266", "hardware", "esp8266"),
+		filepath.Join(os.Getenv("HOME"), ".platformio", "packages", "framework-arduinoespressif8266", "tools"),
+		"/usr/share/arduino/hardware/esp8266com/esp8266/tools",
+	},
+}
 
-	for _, basePath := range commonPaths {
+// findEspota looks for the espota.py script of a chip family in common locations.
+func findEspota(family string) (string, error) {
+	for _, basePath := range espotaDirs[family] {
 		var found string
 		_ = filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
@@ -284,5 +327,5 @@ func findEspota() (string, error) {
 		return path, nil
 	}
 
-	return "", fmt.Errorf("espota.py not found in common locations")
+	return "", fmt.Errorf("espota.py for %s not found in common locations", family)
 }
@@ -0,0 +1,42 @@
+package builder
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/aruncs31s/skvms/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// combinedOutput runs a build tool command in a span of its own and returns
+// its combined output.
+func combinedOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	span := startCommand(ctx, cmd)
+	output, err := cmd.CombinedOutput()
+	tracing.End(span, err)
+	return output, err
+}
+
+// run runs a build tool command in a span of its own.
+func run(ctx context.Context, cmd *exec.Cmd) error {
+	span := startCommand(ctx, cmd)
+	err := cmd.Run()
+	tracing.End(span, err)
+	return err
+}
+
+// startCommand starts the span of a command, named after the tool and its
+// subcommand, e.g. "exec arduino-cli compile".
+func startCommand(ctx context.Context, cmd *exec.Cmd) trace.Span {
+	name := "exec " + filepath.Base(cmd.Path)
+	if len(cmd.Args) > 1 {
+		name += " " + cmd.Args[1]
+	}
+	_, span := tracing.Start(ctx, name,
+		attribute.StringSlice("process.command_args", cmd.Args),
+		attribute.String("process.working_directory", cmd.Dir),
+	)
+	return span
+}
@@ -13,12 +13,53 @@ import (
 )
 
 // PlatformIOBuilder implements BuildStrategy using PlatformIO CLI (pio).
-type PlatformIOBuilder struct{}
+// When the profile has no PlatformIOEnv, the project's default environments are built.
+// The profile's partition scheme and flash size are written into the build's
+// platformio.ini, overriding those of the environments built.
+type PlatformIOBuilder struct {
+	Profile BoardProfile
+	Options BuildOptions
+}
 
 func NewPlatformIOBuilder() *PlatformIOBuilder {
 	return &PlatformIOBuilder{}
 }
 
+// NewPlatformIOBuilderForProfile creates a builder for the given board profile.
+func NewPlatformIOBuilderForProfile(profile BoardProfile) *PlatformIOBuilder {
+	return &PlatformIOBuilder{Profile: profile}
+}
+
+// envArgs returns the "-e <env>" arguments when the profile selects an environment.
+func (p *PlatformIOBuilder) envArgs() []string {
+	if p.Profile.PlatformIOEnv == "" {
+		return nil
+	}
+	return []string{"-e", p.Profile.PlatformIOEnv}
+}
+
+// command builds a pio command for the project. With an ObjectDir, build
+// output and library dependencies are kept there instead of in .pio.
+func (p *PlatformIOBuilder) command(ctx context.Context, projectDir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, p.pioBin(), args...)
+	cmd.Dir = projectDir
+	if p.Options.ObjectDir != "" {
+		cmd.Env = append(os.Environ(),
+			"PLATFORMIO_BUILD_DIR="+filepath.Join(p.Options.ObjectDir, "build"),
+			"PLATFORMIO_LIBDEPS_DIR="+filepath.Join(p.Options.ObjectDir, "libdeps"),
+		)
+	}
+	return cmd
+}
+
+// buildDir returns the directory PlatformIO writes environments to.
+func (p *PlatformIOBuilder) buildDir(projectDir string) string {
+	if p.Options.ObjectDir != "" {
+		return filepath.Join(p.Options.ObjectDir, "build")
+	}
+	return filepath.Join(projectDir, ".pio", "build")
+}
+
 func (p *PlatformIOBuilder) Name() string {
 	return "PlatformIO"
 }
@@ -45,24 +86,38 @@ func (p *PlatformIOBuilder) pioBin() string {
 }
 
 func (p *PlatformIOBuilder) Build(ctx context.Context, projectDir string) (*BuildResult, error) {
-	logger.GetLogger().Info("Building firmware with PlatformIO",
+	logger.FromContext(ctx).Info("Building firmware with PlatformIO",
 		zap.String("project_dir", projectDir),
+		zap.String("env", p.Profile.PlatformIOEnv),
 	)
 
-	cmd := exec.CommandContext(ctx, p.pioBin(), "run", "-d", projectDir)
-	cmd.Dir = projectDir
+	if err := p.applyBoardOptions(projectDir); err != nil {
+		return nil, fmt.Errorf("failed to apply board options: %w", err)
+	}
+
+	// Install library dependencies
+	if err := p.installLibraries(ctx, projectDir); err != nil {
+		logger.FromContext(ctx).Warn("Failed to install libraries (may already be installed)",
+			zap.Error(err),
+		)
+	}
+
+	args := append([]string{"run", "-d", projectDir}, p.envArgs()...)
+	cmd := p.command(ctx, projectDir, args...)
 
-	output, err := cmd.CombinedOutput()
+	output, err := combinedOutput(ctx, cmd)
+	// The output can echo the generated config, which holds the device's secrets
+	buildLog := p.Options.redact(string(output))
 	if err != nil {
-		logger.GetLogger().Error("PlatformIO build failed",
-			zap.String("output", string(output)),
+		logger.FromContext(ctx).Error("PlatformIO build failed",
+			zap.String("output", buildLog),
 			zap.Error(err),
 		)
-		return nil, fmt.Errorf("platformio build failed: %w\nOutput: %s", err, string(output))
+		return nil, fmt.Errorf("platformio build failed: %w\nOutput: %s", err, buildLog)
 	}
 
-	logger.GetLogger().Info("PlatformIO build succeeded",
-		zap.String("output_tail", tailString(string(output), 500)),
+	logger.FromContext(ctx).Info("PlatformIO build succeeded",
+		zap.String("output_tail", tailString(buildLog, 500)),
 	)
 
 	// Find the compiled binary. PlatformIO puts it in .pio/build/<env>/firmware.bin
@@ -71,6 +126,14 @@ func (p *PlatformIOBuilder) Build(ctx context.Context, projectDir string) (*Buil
 		return nil, fmt.Errorf("build succeeded but binary not found: %w", err)
 	}
 
+	// The object dir is shared with later builds, keep this build's binary with the project
+	if p.Options.ObjectDir != "" {
+		binaryPath, err = copyIntoProject(binaryPath, projectDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy binary: %w", err)
+		}
+	}
+
 	info, err := os.Stat(binaryPath)
 	if err != nil {
 		return nil, fmt.Errorf("cannot stat binary: %w", err)
@@ -78,12 +141,46 @@ func (p *PlatformIOBuilder) Build(ctx context.Context, projectDir string) (*Buil
 
 	return &BuildResult{
 		BinaryPath: binaryPath,
+		BoardFQBN:  p.Profile.FQBN,
 		Size:       info.Size(),
 	}, nil
 }
 
+func (p *PlatformIOBuilder) installLibraries(ctx context.Context, projectDir string) error {
+	var failed []string
+	for _, lib := range p.Profile.Libraries {
+		args := append([]string{"pkg", "install", "-d", projectDir}, p.envArgs()...)
+		args = append(args, "--library", lib)
+		install := func() error { return run(ctx, p.command(ctx, projectDir, args...)) }
+
+		var err error
+		if p.Options.ObjectDir != "" {
+			// Libraries live in the shared object dir, install them once
+			err = runSetupOnce("pio lib "+p.Options.ObjectDir+" "+lib, install)
+		} else {
+			err = install()
+		}
+		if err != nil {
+			failed = append(failed, lib)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to install libraries: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
 func (p *PlatformIOBuilder) findBinary(projectDir string) (string, error) {
-	pioBuildDir := filepath.Join(projectDir, ".pio", "build")
+	pioBuildDir := p.buildDir(projectDir)
+
+	// Prefer the environment selected by the profile
+	if env := p.Profile.PlatformIOEnv; env != "" {
+		firmwarePath := filepath.Join(pioBuildDir, env, "firmware.bin")
+		if _, err := os.Stat(firmwarePath); err == nil {
+			return firmwarePath, nil
+		}
+		return "", fmt.Errorf("no firmware.bin found for env %s in %s", env, pioBuildDir)
+	}
 
 	entries, err := os.ReadDir(pioBuildDir)
 	if err != nil {
@@ -105,33 +202,125 @@ func (p *PlatformIOBuilder) findBinary(projectDir string) (string, error) {
 }
 
 func (p *PlatformIOBuilder) Upload(ctx context.Context, projectDir string, deviceIP string) error {
-	logger.GetLogger().Info("Uploading firmware via PlatformIO OTA",
+	logger.FromContext(ctx).Info("Uploading firmware via PlatformIO OTA",
 		zap.String("project_dir", projectDir),
 		zap.String("device_ip", deviceIP),
 	)
 
-	cmd := exec.CommandContext(ctx,
-		p.pioBin(), "run", "-d", projectDir,
-		"--target", "upload",
-		"--upload-port", deviceIP,
-	)
-	cmd.Dir = projectDir
+	args := append([]string{"run", "-d", projectDir}, p.envArgs()...)
+	args = append(args, "--target", "upload", "--upload-port", deviceIP)
+	cmd := p.command(ctx, projectDir, args...)
 
-	output, err := cmd.CombinedOutput()
+	output, err := combinedOutput(ctx, cmd)
 	if err != nil {
-		logger.GetLogger().Error("PlatformIO upload failed",
+		logger.FromContext(ctx).Error("PlatformIO upload failed",
 			zap.String("output", string(output)),
 			zap.Error(err),
 		)
 		return fmt.Errorf("platformio OTA upload failed: %w\nOutput: %s", err, string(output))
 	}
 
-	logger.GetLogger().Info("PlatformIO OTA upload succeeded",
+	logger.FromContext(ctx).Info("PlatformIO OTA upload succeeded",
 		zap.String("device_ip", deviceIP),
 	)
 	return nil
 }
 
+// UploadBinary flashes binaryPath with the espota.py of the PlatformIO
+// framework, as "pio run --target upload" would after building it.
+func (p *PlatformIOBuilder) UploadBinary(ctx context.Context, binaryPath string, deviceIP string) error {
+	logger.FromContext(ctx).Info("Uploading firmware binary via PlatformIO OTA",
+		zap.String("binary", binaryPath),
+		zap.String("device_ip", deviceIP),
+	)
+	return uploadOTA(ctx, p.Profile.Family(), binaryPath, deviceIP)
+}
+
+// applyBoardOptions sets the profile's PlatformIO options in the
+// platformio.ini of the build copy, in the section of the selected
+// environment, or of every environment when none is selected. Options the
+// section already sets are replaced, as PlatformIO rejects duplicates.
+func (p *PlatformIOBuilder) applyBoardOptions(projectDir string) error {
+	opts := p.Profile.PlatformIOOptions()
+	if len(opts) == 0 {
+		return nil
+	}
+	path := filepath.Join(projectDir, "platformio.ini")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	target := func(section string) bool {
+		if p.Profile.PlatformIOEnv != "" {
+			return section == "env:"+p.Profile.PlatformIOEnv
+		}
+		return strings.HasPrefix(section, "env:")
+	}
+	var (
+		lines    []string
+		inTarget bool
+		found    bool
+	)
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			inTarget = target(strings.TrimSpace(trimmed[1 : len(trimmed)-1]))
+			lines = append(lines, line)
+			if inTarget {
+				found = true
+				for _, opt := range opts {
+					lines = append(lines, opt[0]+" = "+opt[1])
+				}
+			}
+			continue
+		}
+		if inTarget && overridesOption(trimmed, opts) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if !found {
+		env := p.Profile.PlatformIOEnv
+		if env == "" {
+			env = "*"
+		}
+		return fmt.Errorf("no [env:%s] section in platformio.ini", env)
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// overridesOption reports whether an ini line sets one of opts.
+func overridesOption(line string, opts [][2]string) bool {
+	name, _, ok := strings.Cut(line, "=")
+	if !ok {
+		return false
+	}
+	name = strings.TrimSpace(name)
+	for _, opt := range opts {
+		if name == opt[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// copyIntoProject copies a binary to <projectDir>/build/<name> and returns the new path.
+func copyIntoProject(binaryPath, projectDir string) (string, error) {
+	data, err := os.ReadFile(binaryPath)
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(projectDir, "build", filepath.Base(binaryPath))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
 func tailString(s string, maxLen int) string {
 	lines := strings.Split(s, "\n")
 	result := strings.Join(lines, "\n")
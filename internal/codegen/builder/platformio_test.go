@@ -0,0 +1,87 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyBoardOptions(t *testing.T) {
+	const ini = `[platformio]
+default_envs = esp32dev
+
+[env]
+framework = arduino
+
+[env:esp32dev]
+board = esp32dev
+board_build.partitions = huge_app.csv
+
+[env:esp32s3]
+board = esp32-s3-devkitc-1
+`
+	tests := []struct {
+		name string
+		env  string
+		want string
+	}{
+		{"selected env", "esp32dev", `[platformio]
+default_envs = esp32dev
+
+[env]
+framework = arduino
+
+[env:esp32dev]
+board_build.partitions = min_spiffs.csv
+board_upload.flash_size = 4MB
+board = esp32dev
+
+[env:esp32s3]
+board = esp32-s3-devkitc-1
+`},
+		{"every env", "", `[platformio]
+default_envs = esp32dev
+
+[env]
+framework = arduino
+
+[env:esp32dev]
+board_build.partitions = min_spiffs.csv
+board_upload.flash_size = 4MB
+board = esp32dev
+
+[env:esp32s3]
+board_build.partitions = min_spiffs.csv
+board_upload.flash_size = 4MB
+board = esp32-s3-devkitc-1
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "platformio.ini")
+			if err := os.WriteFile(path, []byte(ini), 0644); err != nil {
+				t.Fatal(err)
+			}
+			p := NewPlatformIOBuilderForProfile(BoardProfile{
+				PlatformIOEnv:   tt.env,
+				PartitionScheme: "min_spiffs",
+				FlashSize:       "4M",
+			})
+			if err := p.applyBoardOptions(dir); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := os.ReadFile(path)
+			if string(got) != tt.want {
+				t.Errorf("platformio.ini =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	p := NewPlatformIOBuilderForProfile(BoardProfile{PlatformIOEnv: "missing", FlashSize: "4M"})
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "platformio.ini"), []byte(ini), 0644)
+	if err := p.applyBoardOptions(dir); err == nil {
+		t.Error("applying options to a missing env succeeded")
+	}
+}
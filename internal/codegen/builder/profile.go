@@ -0,0 +1,87 @@
+package builder
+
+import "strings"
+
+// BoardProfile describes the target board for a single firmware build.
+// It is the builder-side view of model.BoardProfile so that strategies do
+// not depend on the database layer.
+type BoardProfile struct {
+	Name            string   // Display name, usually the device type name
+	FQBN            string   // Arduino CLI board name (e.g., "esp32:esp32:esp32")
+	PlatformIOEnv   string   // PlatformIO environment (e.g., "esp32dev")
+	PartitionScheme string   // Arduino board option PartitionScheme (e.g., "min_spiffs")
+	FlashSize       string   // Arduino board option FlashSize (e.g., "4M")
+	Libraries       []string // Libraries installed before compiling
+}
+
+// Family returns the chip family of the board, "esp8266" or "esp32".
+func (p BoardProfile) Family() string {
+	if strings.HasPrefix(p.FQBN, "esp8266:") {
+		return "esp8266"
+	}
+	return "esp32"
+}
+
+// DefaultProfile is used when no profile is configured for the target device.
+// It matches the ESP32 DevKit V1 the firmware was originally written for.
+func DefaultProfile() BoardProfile {
+	return BoardProfile{
+		Name:          "ESP32 (default)",
+		FQBN:          defaultESP32FQBN,
+		PlatformIOEnv: "",
+		Libraries:     []string{"ArduinoJson"},
+	}
+}
+
+// ProfileForFQBN returns a profile for a raw FQBN with the default libraries.
+func ProfileForFQBN(fqbn string) BoardProfile {
+	profile := DefaultProfile()
+	if fqbn != "" {
+		profile.Name = fqbn
+		profile.FQBN = fqbn
+	}
+	return profile
+}
+
+// BoardOptionsFQBN returns the FQBN with the partition scheme and flash size
+// appended as board options, e.g. "esp32:esp32:esp32:PartitionScheme=min_spiffs,FlashSize=4M".
+func (p BoardProfile) BoardOptionsFQBN() string {
+	var opts []string
+	if p.PartitionScheme != "" {
+		opts = append(opts, "PartitionScheme="+p.PartitionScheme)
+	}
+	if p.FlashSize != "" {
+		opts = append(opts, "FlashSize="+p.FlashSize)
+	}
+	if len(opts) == 0 {
+		return p.FQBN
+	}
+	// The FQBN may already carry options of its own
+	if strings.Count(p.FQBN, ":") >= 3 {
+		return p.FQBN + "," + strings.Join(opts, ",")
+	}
+	return p.FQBN + ":" + strings.Join(opts, ",")
+}
+
+// PlatformIOOptions returns the partition scheme and flash size as
+// platformio.ini options, e.g. board_build.partitions = min_spiffs.csv and
+// board_upload.flash_size = 4MB. Arduino partition schemes are named after
+// the partition tables of the framework, so the scheme gets a .csv suffix.
+func (p BoardProfile) PlatformIOOptions() [][2]string {
+	var opts [][2]string
+	if p.PartitionScheme != "" {
+		partitions := p.PartitionScheme
+		if !strings.HasSuffix(partitions, ".csv") {
+			partitions += ".csv"
+		}
+		opts = append(opts, [2]string{"board_build.partitions", partitions})
+	}
+	if p.FlashSize != "" {
+		flashSize := p.FlashSize
+		if strings.HasSuffix(flashSize, "M") {
+			flashSize += "B"
+		}
+		opts = append(opts, [2]string{"board_upload.flash_size", flashSize})
+	}
+	return opts
+}
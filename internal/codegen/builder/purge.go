@@ -0,0 +1,68 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aruncs31s/skvms/internal/secrets"
+)
+
+// PurgeSketchObjects wipes the objects and images compiled from the sketch
+// out of a shared ObjectDir, keeping the core and library objects. It is used
+// after builds that compiled secrets into the sketch instead of patching them.
+func PurgeSketchObjects(tool, objectDir string) error {
+	if objectDir == "" {
+		return nil
+	}
+	if tool == ToolPlatformIO {
+		return purgePlatformIOObjects(filepath.Join(objectDir, "build"))
+	}
+	return purgeArduinoObjects(objectDir)
+}
+
+// purgeArduinoObjects removes <build-path>/sketch and the linked sketch
+// images next to it (sketch.ino.elf, sketch.ino.bin, ...).
+func purgeArduinoObjects(buildPath string) error {
+	if err := secrets.WipeDir(filepath.Join(buildPath, "sketch"), nil); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(buildPath)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.Contains(e.Name(), ".ino.") {
+			continue
+		}
+		if err := secrets.WipeFile(filepath.Join(buildPath, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgePlatformIOObjects removes <build>/<env>/src and the firmware images of
+// every environment.
+func purgePlatformIOObjects(buildDir string) error {
+	envs, err := os.ReadDir(buildDir)
+	if err != nil {
+		return nil
+	}
+	for _, env := range envs {
+		if !env.IsDir() {
+			continue
+		}
+		envDir := filepath.Join(buildDir, env.Name())
+		if err := secrets.WipeDir(filepath.Join(envDir, "src"), nil); err != nil {
+			return err
+		}
+		images, _ := filepath.Glob(filepath.Join(envDir, "firmware.*"))
+		for _, image := range images {
+			if err := secrets.WipeFile(image); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
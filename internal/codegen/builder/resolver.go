@@ -7,23 +7,67 @@ import (
 	"go.uber.org/zap"
 )
 
-// Resolve returns the best available BuildStrategy.
+const (
+	ToolPlatformIO = "platformio"
+	ToolArduinoCLI = "arduino-cli"
+)
+
+// Toolchain selects and creates the build strategies of the codegen
+// service. Installed uses the build tools on PATH; tests substitute fakes.
+type Toolchain interface {
+	// Select returns the key of the best available build tool, trying the
+	// preferred tool first.
+	Select(preferred string) (string, error)
+	// New creates the strategy for a tool key returned by Select.
+	New(tool string, profile BoardProfile, opts BuildOptions) BuildStrategy
+	// Available returns the names of the installed build tools.
+	Available() []string
+}
+
+// Installed is the Toolchain of the installed PlatformIO and Arduino CLI.
+var Installed Toolchain = installed{}
+
+type installed struct{}
+
+func (installed) Select(preferred string) (string, error) {
+	return Select(preferred)
+}
+
+func (installed) New(tool string, profile BoardProfile, opts BuildOptions) BuildStrategy {
+	return New(tool, profile, opts)
+}
+
+func (installed) Available() []string {
+	return ListAvailable()
+}
+
+// Resolve returns the best available BuildStrategy configured for the given board profile.
 // It prefers PlatformIO over Arduino CLI if both are available.
 // If a preferred tool is specified, it tries that first.
-func Resolve(preferred string) (BuildStrategy, error) {
-	strategies := map[string]BuildStrategy{
-		"platformio":  NewPlatformIOBuilder(),
-		"arduino-cli": NewArduinoCLIBuilder(""),
+func Resolve(preferred string, profile BoardProfile, opts BuildOptions) (BuildStrategy, error) {
+	tool, err := Select(preferred)
+	if err != nil {
+		return nil, err
+	}
+	return New(tool, profile, opts), nil
+}
+
+// Select returns the key of the best available build tool (ToolPlatformIO or
+// ToolArduinoCLI), trying the preferred tool first.
+func Select(preferred string) (string, error) {
+	available := map[string]bool{
+		ToolPlatformIO: NewPlatformIOBuilder().IsAvailable(),
+		ToolArduinoCLI: NewArduinoCLIBuilder("").IsAvailable(),
 	}
 
 	// If user specified a preference, try that first
 	if preferred != "" {
-		if s, ok := strategies[preferred]; ok {
-			if s.IsAvailable() {
+		if ok, known := available[preferred]; known {
+			if ok {
 				logger.GetLogger().Info("Using preferred build strategy",
-					zap.String("strategy", s.Name()),
+					zap.String("strategy", preferred),
 				)
-				return s, nil
+				return preferred, nil
 			}
 			logger.GetLogger().Warn("Preferred build tool not available, trying alternatives",
 				zap.String("preferred", preferred),
@@ -32,18 +76,29 @@ func Resolve(preferred string) (BuildStrategy, error) {
 	}
 
 	// Auto-detect: prefer PlatformIO, fall back to Arduino CLI
-	order := []string{"platformio", "arduino-cli"}
+	order := []string{ToolPlatformIO, ToolArduinoCLI}
 	for _, key := range order {
-		s := strategies[key]
-		if s.IsAvailable() {
+		if available[key] {
 			logger.GetLogger().Info("Auto-detected build strategy",
-				zap.String("strategy", s.Name()),
+				zap.String("strategy", key),
 			)
-			return s, nil
+			return key, nil
 		}
 	}
 
-	return nil, fmt.Errorf("no build tool available: install PlatformIO CLI or Arduino CLI")
+	return "", fmt.Errorf("no build tool available: install PlatformIO CLI or Arduino CLI")
+}
+
+// New creates the strategy for a tool key returned by Select.
+func New(tool string, profile BoardProfile, opts BuildOptions) BuildStrategy {
+	if tool == ToolPlatformIO {
+		pio := NewPlatformIOBuilderForProfile(profile)
+		pio.Options = opts
+		return pio
+	}
+	arduino := NewArduinoCLIBuilderForProfile(profile)
+	arduino.Options = opts
+	return arduino
 }
 
 // ListAvailable returns which build strategies are currently installed.
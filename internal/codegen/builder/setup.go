@@ -0,0 +1,19 @@
+package builder
+
+import "sync"
+
+// setupDone records toolchain setup steps (core and library installs) that
+// already succeeded in this process, so repeated builds skip them.
+var setupDone sync.Map
+
+// runSetupOnce runs fn unless a previous call with the same key succeeded.
+func runSetupOnce(key string, fn func() error) error {
+	if _, ok := setupDone.Load(key); ok {
+		return nil
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	setupDone.Store(key, struct{}{})
+	return nil
+}
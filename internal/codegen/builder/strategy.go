@@ -9,6 +9,26 @@ type BuildResult struct {
 	Size       int64  // Binary file size in bytes
 }
 
+// BuildOptions configures where a strategy keeps intermediate build output.
+type BuildOptions struct {
+	// ObjectDir keeps compiled objects and library dependencies between builds
+	// so only changed sources are recompiled. Empty means a fresh build inside
+	// the project directory. Builds sharing an ObjectDir must not run concurrently.
+	ObjectDir string
+
+	// Redact removes secrets from build output before it is logged or
+	// returned in an error. Nil leaves the output unchanged.
+	Redact func(string) string
+}
+
+// redact applies the Redact option to tool output.
+func (o BuildOptions) redact(s string) string {
+	if o.Redact == nil {
+		return s
+	}
+	return o.Redact(s)
+}
+
 // BuildStrategy defines the interface for building ESP32 firmware.
 // Implementations can use PlatformIO or Arduino CLI.
 type BuildStrategy interface {
@@ -23,4 +43,8 @@ type BuildStrategy interface {
 
 	// Upload flashes the firmware binary to the ESP32 at the given IP via OTA.
 	Upload(ctx context.Context, projectDir string, deviceIP string) error
+
+	// UploadBinary flashes an already built binary, such as one patched from
+	// a cached image, to the device at the given IP via OTA.
+	UploadBinary(ctx context.Context, binaryPath string, deviceIP string) error
 }
@@ -0,0 +1,502 @@
+package codegen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SchemaFileName is the config schema a firmware repo declares at its root.
+//
+// Example:
+//
+//	{
+//	  "template": "include/config.h.in",
+//	  "output": "include/config.h",
+//	  "keys": [
+//	    {"name": "WIFI_SSID", "type": "string", "required": true},
+//	    {"name": "WIFI_PASSWORD", "type": "string", "required": true, "secret": true},
+//	    {"name": "BACKEND_PORT", "type": "int", "default": 8080}
+//	  ]
+//	}
+//
+// The template references keys as @KEY@ placeholders, e.g. `#define WIFI_SSID @WIFI_SSID@`.
+//
+// String keys marked "patchable" are compiled as a fixed size placeholder and
+// written into a cached firmware image per device instead of rebuilding. The
+// firmware must only use them as NUL terminated strings (not sizeof).
+const SchemaFileName = "skvms-config.json"
+
+// defaultPatchMaxLength is the slot size of a patchable key without max_length.
+const defaultPatchMaxLength = 64
+
+// ConfigType is the type of a firmware config key. It decides how a value is
+// validated and how it is written as a C literal.
+type ConfigType string
+
+const (
+	ConfigString ConfigType = "string"
+	ConfigInt    ConfigType = "int"
+	ConfigFloat  ConfigType = "float"
+	ConfigBool   ConfigType = "bool"
+	// ConfigIP is an IPv4 address written as comma separated octets for IPAddress(...)
+	ConfigIP ConfigType = "ip"
+)
+
+// ConfigKey declares one value of the firmware configuration.
+type ConfigKey struct {
+	Name        string     `json:"name"`
+	Type        ConfigType `json:"type"`
+	Default     any        `json:"default,omitempty"`
+	Required    bool       `json:"required"`
+	Secret      bool       `json:"secret"`
+	Patchable   bool       `json:"patchable,omitempty"`
+	MaxLength   int        `json:"max_length,omitempty"`
+	Description string     `json:"description,omitempty"`
+}
+
+// ConfigSchema is the typed configuration a firmware repo accepts.
+type ConfigSchema struct {
+	// Template is a header with @KEY@ placeholders, relative to the repo root.
+	// When empty, the header is generated from the keys alone.
+	Template string `json:"template,omitempty"`
+
+	// Output is the header written for the build, relative to the repo root.
+	Output string `json:"output"`
+
+	Keys []ConfigKey `json:"keys"`
+
+	// legacy marks the built-in schema used for repos without a schema file.
+	legacy bool
+}
+
+// ConfigError lists every problem found while validating a codegen request
+// against a schema, or a schema against its template.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid firmware config: " + strings.Join(e.Problems, "; ")
+}
+
+var (
+	configKeyPattern   = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+	placeholderPattern = regexp.MustCompile(`@([A-Z_][A-Z0-9_]*)@`)
+)
+
+// LegacySchema describes the keys of the original include/config.h, which
+// is rewritten in place with #define replacement. It is used when the firmware
+// repo has no schema file.
+func LegacySchema() *ConfigSchema {
+	return &ConfigSchema{
+		Output: filepath.Join("include", "config.h"),
+		Keys: []ConfigKey{
+			{Name: "BACKEND_HOST", Type: ConfigString, Required: true, Description: "Backend server address"},
+			{Name: "BACKEND_PORT", Type: ConfigInt, Default: 8080, Description: "Backend server port"},
+			{Name: "TOKEN", Type: ConfigString, Required: true, Secret: true, Description: "Device authentication token"},
+			{Name: "WIFI_SSID", Type: ConfigString, Required: true, Description: "WiFi network name"},
+			{Name: "WIFI_PASSWORD", Type: ConfigString, Required: true, Secret: true, Description: "WiFi password"},
+			{Name: "STATIC_IP_ADDRESS", Type: ConfigIP, Required: true, Description: "Static IP of the device"},
+			{Name: "DEVICE_NAME", Type: ConfigString, Description: "Device identifier"},
+		},
+		legacy: true,
+	}
+}
+
+// LoadSchema reads the schema file from the repo root. It returns the legacy
+// schema when the repo does not declare one.
+func LoadSchema(repoDir string) (*ConfigSchema, error) {
+	content, err := os.ReadFile(filepath.Join(repoDir, SchemaFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return LegacySchema(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", SchemaFileName, err)
+	}
+
+	var schema ConfigSchema
+	dec := json.NewDecoder(strings.NewReader(string(content)))
+	dec.UseNumber()
+	if err := dec.Decode(&schema); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", SchemaFileName, err)
+	}
+	if err := schema.validate(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// IsLegacy reports whether this is the built-in schema for repos without a schema file.
+func (s *ConfigSchema) IsLegacy() bool {
+	return s.legacy
+}
+
+// Key returns the key with the given name.
+func (s *ConfigSchema) Key(name string) (ConfigKey, bool) {
+	for _, k := range s.Keys {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return ConfigKey{}, false
+}
+
+// Redacted returns a copy of the schema with the defaults of secret keys removed.
+func (s *ConfigSchema) Redacted() *ConfigSchema {
+	out := *s
+	out.Keys = make([]ConfigKey, len(s.Keys))
+	for i, k := range s.Keys {
+		if k.Secret {
+			k.Default = nil
+		}
+		out.Keys[i] = k
+	}
+	return &out
+}
+
+// validate checks the schema file itself.
+func (s *ConfigSchema) validate() error {
+	var problems []string
+	if s.Output == "" {
+		problems = append(problems, "schema: output is required")
+	}
+	for _, p := range []string{s.Template, s.Output} {
+		if p != "" && (filepath.IsAbs(p) || strings.HasPrefix(filepath.Clean(p), "..")) {
+			problems = append(problems, fmt.Sprintf("schema: path %q must stay inside the repo", p))
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, k := range s.Keys {
+		if !configKeyPattern.MatchString(k.Name) {
+			problems = append(problems, fmt.Sprintf("schema: invalid key name %q", k.Name))
+			continue
+		}
+		if seen[k.Name] {
+			problems = append(problems, fmt.Sprintf("schema: duplicate key %s", k.Name))
+		}
+		seen[k.Name] = true
+
+		switch k.Type {
+		case ConfigString, ConfigInt, ConfigFloat, ConfigBool, ConfigIP:
+		default:
+			problems = append(problems, fmt.Sprintf("schema: key %s has unknown type %q", k.Name, k.Type))
+			continue
+		}
+		if k.Patchable {
+			if k.Type != ConfigString {
+				problems = append(problems, fmt.Sprintf("schema: patchable key %s must be a string", k.Name))
+			} else if k.maxLength() < len(k.sentinel()) {
+				problems = append(problems, fmt.Sprintf("schema: max_length of %s must be at least %d", k.Name, len(k.patchMarker())))
+			}
+		}
+		if k.Default != nil {
+			if _, err := k.literal(k.Default); err != nil {
+				problems = append(problems, fmt.Sprintf("schema: default of %s: %v", k.Name, err))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// Resolve validates values against the schema and returns the C literal of
+// every key that has a value or a default. All problems are reported together.
+func (s *ConfigSchema) Resolve(values map[string]any) (map[string]string, error) {
+	var problems []string
+	literals := make(map[string]string)
+
+	for _, k := range s.Keys {
+		value, ok := values[k.Name]
+		if !ok || value == nil {
+			value = k.Default
+		}
+		if value == nil {
+			if k.Required {
+				problems = append(problems, fmt.Sprintf("missing required key %s", k.Name))
+			}
+			continue
+		}
+
+		literal, err := k.literal(value)
+		if err != nil {
+			if k.Secret {
+				// The error quotes the value, which must not end up in a response
+				problems = append(problems, fmt.Sprintf("key %s: invalid %s value", k.Name, k.Type))
+			} else {
+				problems = append(problems, fmt.Sprintf("key %s: %v", k.Name, err))
+			}
+			continue
+		}
+		literals[k.Name] = literal
+	}
+
+	var unknown []string
+	for name := range values {
+		if _, ok := s.Key(name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, fmt.Sprintf("unknown key %s", name))
+	}
+
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	return literals, nil
+}
+
+// CheckTemplate makes sure the template of the repo at repoDir references
+// every required key, and nothing the schema does not declare.
+func (s *ConfigSchema) CheckTemplate(repoDir string) error {
+	if s.legacy {
+		return s.checkLegacyTemplate(repoDir)
+	}
+	if s.Template == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(repoDir, s.Template))
+	if err != nil {
+		return fmt.Errorf("failed to read config template %s: %w", s.Template, err)
+	}
+
+	referenced := make(map[string]bool)
+	for _, m := range placeholderPattern.FindAllStringSubmatch(string(content), -1) {
+		referenced[m[1]] = true
+	}
+
+	var problems []string
+	for _, k := range s.Keys {
+		if k.Required && !referenced[k.Name] {
+			problems = append(problems, fmt.Sprintf("template %s is missing required key %s", s.Template, k.Name))
+		}
+	}
+	for _, name := range sortedKeys(referenced) {
+		if _, ok := s.Key(name); !ok {
+			problems = append(problems, fmt.Sprintf("template %s references undeclared key %s", s.Template, name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// checkLegacyTemplate makes sure config.h defines every required key, so the
+// #define replacement cannot silently leave a value untouched.
+func (s *ConfigSchema) checkLegacyTemplate(repoDir string) error {
+	content, err := os.ReadFile(filepath.Join(repoDir, s.Output))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.Output, err)
+	}
+
+	var problems []string
+	for _, k := range s.Keys {
+		if !k.Required {
+			continue
+		}
+		pattern := regexp.MustCompile(fmt.Sprintf(`(?m)^\s*(//\s*)?#define\s+%s\s+`, regexp.QuoteMeta(k.Name)))
+		if !pattern.MatchString(string(content)) {
+			problems = append(problems, fmt.Sprintf("template %s is missing required key %s", s.Output, k.Name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// RenderHeader writes the output header into the build directory. With a
+// template, every @KEY@ placeholder is substituted and lines referencing a
+// key without a value are commented out. Without a template, the header is
+// generated from the keys.
+func (s *ConfigSchema) RenderHeader(buildDir string, literals map[string]string) error {
+	var rendered string
+
+	if s.Template != "" {
+		content, err := os.ReadFile(filepath.Join(buildDir, s.Template))
+		if err != nil {
+			return fmt.Errorf("failed to read config template %s: %w", s.Template, err)
+		}
+
+		lines := strings.Split(string(content), "\n")
+		for i, line := range lines {
+			missing := false
+			line = placeholderPattern.ReplaceAllStringFunc(line, func(m string) string {
+				literal, ok := literals[strings.Trim(m, "@")]
+				if !ok {
+					missing = true
+					return m
+				}
+				return literal
+			})
+			if missing {
+				line = "// " + line
+			}
+			lines[i] = line
+		}
+		rendered = strings.Join(lines, "\n")
+	} else {
+		var b strings.Builder
+		b.WriteString("// Generated by SKVMS codegen from " + SchemaFileName + ". Do not edit.\n")
+		b.WriteString("#pragma once\n\n")
+		for _, k := range s.Keys {
+			literal, ok := literals[k.Name]
+			if !ok {
+				continue
+			}
+			if k.Description != "" {
+				fmt.Fprintf(&b, "// %s\n", k.Description)
+			}
+			fmt.Fprintf(&b, "#define %s %s\n", k.Name, literal)
+		}
+		rendered = b.String()
+	}
+
+	outputPath := filepath.Join(buildDir, s.Output)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", s.Output, err)
+	}
+	if err := os.WriteFile(outputPath, []byte(rendered), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.Output, err)
+	}
+	return nil
+}
+
+// literal validates value against the key type and formats it as a C literal.
+func (k ConfigKey) literal(value any) (string, error) {
+	switch k.Type {
+	case ConfigString:
+		str, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("expected string, got %v", value)
+		}
+		if k.Patchable && len(str) > k.maxLength() {
+			return "", fmt.Errorf("longer than max_length %d", k.maxLength())
+		}
+		return cQuote(str), nil
+
+	case ConfigInt:
+		n, err := toFloat(value)
+		if err != nil || n != math.Trunc(n) {
+			return "", fmt.Errorf("expected int, got %v", value)
+		}
+		return strconv.FormatInt(int64(n), 10), nil
+
+	case ConfigFloat:
+		n, err := toFloat(value)
+		if err != nil {
+			return "", fmt.Errorf("expected float, got %v", value)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+
+	case ConfigBool:
+		switch v := value.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err == nil {
+				return strconv.FormatBool(b), nil
+			}
+		}
+		return "", fmt.Errorf("expected bool, got %v", value)
+
+	case ConfigIP:
+		str, ok := value.(string)
+		ip := net.ParseIP(str)
+		if !ok || ip == nil || ip.To4() == nil {
+			return "", fmt.Errorf("expected IPv4 address, got %v", value)
+		}
+		return ipToOctets(ip.To4().String()), nil
+	}
+	return "", fmt.Errorf("unknown type %q", k.Type)
+}
+
+func (k ConfigKey) maxLength() int {
+	if k.MaxLength > 0 {
+		return k.MaxLength
+	}
+	return defaultPatchMaxLength
+}
+
+func (k ConfigKey) patchMarker() string {
+	return "@@SKVMS:" + k.Name + "@@"
+}
+
+// sentinel is the placeholder compiled into a cacheable image for a
+// patchable key. It fills the whole slot so any value up to max_length fits.
+func (k ConfigKey) sentinel() string {
+	marker := k.patchMarker()
+	if len(marker) >= k.maxLength() {
+		return marker
+	}
+	return marker + strings.Repeat("#", k.maxLength()-len(marker))
+}
+
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("not a number")
+}
+
+// cQuote returns s as a double quoted C string literal.
+func cQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c < 0x20 || c >= 0x7f:
+			// Octal escapes cannot swallow following hex digits like \x can
+			fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,18 +1,25 @@
 package dto
 
 // CodeGenRequest holds the parameters needed to generate ESP32 firmware.
+// Which values are required is decided by the config schema of the firmware
+// source, not by this struct.
 type CodeGenRequest struct {
 	// IP is the static IP address to assign to the ESP32 device.
-	IP string `json:"ip" binding:"required"`
+	IP string `json:"ip"`
 
 	// HostIP is the backend server IP that the ESP32 will send data to.
-	HostIP string `json:"host_ip" binding:"required"`
+	HostIP string `json:"host_ip"`
 
 	// HOSTSSID is the WiFi network name the ESP32 should connect to.
-	HOSTSSID string `json:"host_ssid" binding:"required"`
+	HOSTSSID string `json:"host_ssid"`
 
 	// HOSTPASS is the WiFi password.
-	HOSTPASS string `json:"host_pass" binding:"required"`
+	HOSTPASS string `json:"host_pass"`
+
+	// WiFiProfileID refers to a stored WiFi profile of a location the user
+	// has access to. When set, its SSID and password replace host_ssid and
+	// host_pass.
+	WiFiProfileID uint `json:"wifi_profile_id"`
 
 	// Port is the backend server port.
 	Port int `json:"port"`
@@ -21,7 +28,14 @@ type CodeGenRequest struct {
 	Protocol string `json:"protocol"`
 
 	// Token is the JWT device authentication token.
-	Token string `json:"token" binding:"required"`
+	Token string `json:"token"`
+
+	// IssueToken issues a fresh device token for DeviceID instead of taking
+	// it from the request.
+	IssueToken bool `json:"issue_token"`
+
+	// UserID is the authenticated user making the request, set by the handler.
+	UserID uint `json:"-"`
 
 	// DeviceName is an optional device identifier.
 	DeviceName string `json:"device_name"`
@@ -31,17 +45,50 @@ type CodeGenRequest struct {
 	BuildTool string `json:"build_tool"`
 
 	// Board FQBN for Arduino CLI (e.g., "esp32:esp32:esp32").
-	// Only used when build_tool is "arduino-cli".
+	// Overrides the FQBN of the board profile picked for the build. Not
+	// allowed with DeviceTypeIDs, whose targets each use their own profile.
 	BoardFQBN string `json:"board_fqbn"`
+
+	// Config holds values for schema keys that have no dedicated field above,
+	// keyed by the schema key name (e.g. {"MQTT_ENABLED": true}).
+	Config map[string]any `json:"config"`
+
+	// DeviceID is the target device. When set, the board profile of its
+	// device type is used for the build.
+	DeviceID uint `json:"device_id"`
+
+	// DeviceTypeIDs lists the device types to build for in a single request.
+	// Only used by multi-target builds; each entry must have a board profile.
+	DeviceTypeIDs []uint `json:"device_type_ids"`
 }
 
 // CodeGenResponse is the API response after a successful codegen/build.
 type CodeGenResponse struct {
 	Message     string `json:"message"`
 	BuildTool   string `json:"build_tool"`
+	Board       string `json:"board,omitempty"`
+	BoardFQBN   string `json:"board_fqbn,omitempty"`
 	BinarySize  int64  `json:"binary_size_bytes,omitempty"`
 	BuildID     string `json:"build_id"`
 	DownloadURL string `json:"download_url,omitempty"`
+
+	// Cached is true when the binary came from the build cache instead of a compile.
+	Cached bool `json:"cached"`
+}
+
+// MultiBuildResponse is the API response of a multi-target build.
+// Targets that failed are reported in Failed; the others are still usable.
+type MultiBuildResponse struct {
+	Message string             `json:"message"`
+	Builds  []CodeGenResponse  `json:"builds"`
+	Failed  []TargetBuildError `json:"failed,omitempty"`
+}
+
+// TargetBuildError describes why one target of a multi-target build failed.
+type TargetBuildError struct {
+	DeviceTypeID uint   `json:"device_type_id"`
+	Board        string `json:"board,omitempty"`
+	Error        string `json:"error"`
 }
 
 // UploadRequest holds parameters for OTA firmware upload.
@@ -0,0 +1,174 @@
+package codegen
+
+import (
+	"strconv"
+
+	"github.com/aruncs31s/skvms/internal/codegen/dto"
+)
+
+// FirmwareConfig is a codegen request validated against the config schema
+// of the firmware source. It is prepared once per request and applied to
+// every build copy.
+type FirmwareConfig struct {
+	schema   *ConfigSchema
+	literals map[string]string
+	req      dto.CodeGenRequest
+
+	// patch holds the raw values of patchable keys, written into the image
+	// after the build instead of being compiled in.
+	patch map[string]string
+}
+
+// PrepareConfig loads the schema of the source repo, checks its template and
+// validates the request against it. Validation problems are returned as a
+// *ConfigError.
+func PrepareConfig(sourceDir string, req dto.CodeGenRequest) (*FirmwareConfig, error) {
+	schema, err := LoadSchema(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := schema.CheckTemplate(sourceDir); err != nil {
+		return nil, err
+	}
+
+	values := RequestValues(schema, req)
+	literals, err := schema.Resolve(values)
+	if err != nil {
+		return nil, err
+	}
+	if schema.IsLegacy() && req.Port == 0 {
+		// The legacy header is rewritten from the request, so it takes the
+		// port default of the schema
+		req.Port, _ = strconv.Atoi(literals["BACKEND_PORT"])
+	}
+
+	patch := make(map[string]string)
+	for _, k := range schema.Keys {
+		if !k.Patchable {
+			continue
+		}
+		if _, ok := literals[k.Name]; !ok {
+			continue
+		}
+		value, ok := values[k.Name].(string)
+		if !ok {
+			value, _ = k.Default.(string)
+		}
+		patch[k.Name] = value
+	}
+
+	return &FirmwareConfig{
+		schema:   schema,
+		literals: literals,
+		req:      req,
+		patch:    patch,
+	}, nil
+}
+
+// Patchable reports whether some values are patched into the built image.
+func (c *FirmwareConfig) Patchable() bool {
+	return len(c.patch) > 0
+}
+
+// PatchImage writes the patchable values into a built firmware image.
+func (c *FirmwareConfig) PatchImage(image []byte, esp32 bool) error {
+	return patchImage(image, c.schema, c.patch, esp32)
+}
+
+// imageLiterals returns the literals compiled into the image. When patching,
+// the sentinels of patchable keys take the place of their values.
+func (c *FirmwareConfig) imageLiterals(patching bool) map[string]string {
+	literals := make(map[string]string, len(c.literals))
+	for name, literal := range c.literals {
+		literals[name] = literal
+	}
+	if patching {
+		for name := range c.patch {
+			k, _ := c.schema.Key(name)
+			literals[name] = cQuote(k.sentinel())
+		}
+	}
+	return literals
+}
+
+// cacheFingerprint returns the config values that decide the content of the
+// image. It reports false when a secret would be compiled into the image,
+// since such images must not be cached.
+func (c *FirmwareConfig) cacheFingerprint(patching bool) (map[string]string, bool) {
+	if c.schema.IsLegacy() {
+		// The legacy header is rewritten from the request, so every value
+		// including the secrets ends up in the image. Sources without a
+		// schema file are therefore always compiled; they only reuse the
+		// object dir.
+		return nil, false
+	}
+
+	fingerprint := make(map[string]string, len(c.literals))
+	for name, literal := range c.literals {
+		k, _ := c.schema.Key(name)
+		switch {
+		case k.Patchable && patching:
+			fingerprint[name] = "patched"
+		case k.Secret:
+			return nil, false
+		default:
+			fingerprint[name] = literal
+		}
+	}
+	return fingerprint, true
+}
+
+// compilesSecrets reports whether a secret value is compiled into the image
+// rather than patched in afterwards.
+func (c *FirmwareConfig) compilesSecrets(patching bool) bool {
+	if c.schema.IsLegacy() {
+		return true
+	}
+	for name := range c.literals {
+		k, _ := c.schema.Key(name)
+		if k.Secret && !(k.Patchable && patching) {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema returns the schema the config was validated against.
+func (c *FirmwareConfig) Schema() *ConfigSchema {
+	return c.schema
+}
+
+// Apply writes the configuration into a build copy of the source repo.
+// When patching, patchable keys get their sentinel and PatchImage must be
+// called on the built image.
+func (c *FirmwareConfig) Apply(buildDir string, patching bool) error {
+	if c.schema.IsLegacy() {
+		return ReplaceConfig(buildDir, c.req)
+	}
+	return c.schema.RenderHeader(buildDir, c.imageLiterals(patching))
+}
+
+// RequestValues maps a codegen request to config key values. The dedicated
+// request fields map to the keys of the original config.h, when the schema
+// declares them; any other key is taken from req.Config. A dedicated field
+// wins over the same key in Config.
+func RequestValues(schema *ConfigSchema, req dto.CodeGenRequest) map[string]any {
+	values := make(map[string]any, len(req.Config)+7)
+	for k, v := range req.Config {
+		values[k] = v
+	}
+
+	set := func(key string, value any, ok bool) {
+		if _, declared := schema.Key(key); ok && declared {
+			values[key] = value
+		}
+	}
+	set("BACKEND_HOST", req.HostIP, req.HostIP != "")
+	set("BACKEND_PORT", req.Port, req.Port > 0)
+	set("TOKEN", req.Token, req.Token != "")
+	set("WIFI_SSID", req.HOSTSSID, req.HOSTSSID != "")
+	set("WIFI_PASSWORD", req.HOSTPASS, req.HOSTPASS != "")
+	set("STATIC_IP_ADDRESS", req.IP, req.IP != "")
+	set("DEVICE_NAME", req.DeviceName, req.DeviceName != "")
+	return values
+}
@@ -0,0 +1,98 @@
+package codegen
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	esp32ImageMagic      = 0xE9
+	esp32ImageHeaderSize = 24 // common header + extended header
+	esp32ChecksumSeed    = 0xEF
+)
+
+// patchImage replaces every occurrence of a patchable key's sentinel in a
+// firmware image with the device value, padded with NUL bytes to the slot size.
+// ESP32 images carry a checksum and an optional SHA-256 digest which are
+// recomputed afterwards.
+func patchImage(image []byte, schema *ConfigSchema, values map[string]string, esp32 bool) error {
+	for _, k := range schema.Keys {
+		value, ok := values[k.Name]
+		if !ok {
+			continue
+		}
+
+		sentinel := []byte(k.sentinel())
+		if len(value) > len(sentinel) {
+			return fmt.Errorf("value of %s does not fit the %d byte slot", k.Name, len(sentinel))
+		}
+		slot := make([]byte, len(sentinel))
+		copy(slot, value)
+
+		found := 0
+		for off := 0; ; {
+			i := bytes.Index(image[off:], sentinel)
+			if i < 0 {
+				break
+			}
+			copy(image[off+i:], slot)
+			off += i + len(slot)
+			found++
+		}
+		if found == 0 {
+			return fmt.Errorf("placeholder for %s not found in firmware image", k.Name)
+		}
+	}
+
+	if esp32 {
+		return fixESP32Image(image)
+	}
+	return nil
+}
+
+// fixESP32Image recomputes the checksum and, when present, the appended
+// SHA-256 digest of an ESP32 application image after it was modified.
+// Layout: 24 byte header, segments (8 byte header + data), padding so the
+// checksum byte ends a 16 byte block, then the optional 32 byte digest.
+func fixESP32Image(image []byte) error {
+	if len(image) < esp32ImageHeaderSize || image[0] != esp32ImageMagic {
+		return fmt.Errorf("not an ESP32 application image")
+	}
+	segments := int(image[1])
+	hashAppended := image[23] == 1
+
+	checksum := byte(esp32ChecksumSeed)
+	off := esp32ImageHeaderSize
+	for i := 0; i < segments; i++ {
+		if off+8 > len(image) {
+			return fmt.Errorf("truncated segment header %d", i)
+		}
+		size := int(binary.LittleEndian.Uint32(image[off+4 : off+8]))
+		off += 8
+		if size < 0 || off+size > len(image) {
+			return fmt.Errorf("segment %d exceeds image size", i)
+		}
+		for _, b := range image[off : off+size] {
+			checksum ^= b
+		}
+		off += size
+	}
+
+	pos := off + 15 - off%16
+	if pos >= len(image) {
+		return fmt.Errorf("image has no checksum byte")
+	}
+	image[pos] = checksum
+
+	if hashAppended {
+		end := pos + 1
+		if end+sha256.Size > len(image) {
+			return fmt.Errorf("image has no SHA-256 digest")
+		}
+		sum := sha256.Sum256(image[:end])
+		copy(image[end:], sum[:])
+	}
+	return nil
+}
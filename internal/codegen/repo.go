@@ -2,11 +2,15 @@ package codegen
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/secrets"
 	"go.uber.org/zap"
 )
 
@@ -62,8 +66,28 @@ func CloneOrPullRepo(baseDir string) (string, error) {
 	return repoDir, nil
 }
 
+// copySkipDirs are not needed to build and are left out of build copies.
+var copySkipDirs = map[string]bool{
+	".git": true,
+	".pio": true,
+}
+
+// SourceRevision returns the commit of the source repo, or "" if it cannot be
+// determined. Builds are only cached when the revision is known.
+func SourceRevision(repoDir string) string {
+	out, err := exec.Command("git", "-C", repoDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		logger.GetLogger().Warn("Cannot determine firmware source revision, build cache disabled",
+			zap.Error(err),
+		)
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
 // CopyRepoForBuild creates an isolated copy of the source repo for a specific build.
-// This prevents concurrent builds from interfering with each other.
+// This prevents concurrent builds from interfering with each other. The git
+// history and previous build output are not copied.
 func CopyRepoForBuild(sourceDir, buildID string) (string, error) {
 	buildDir := filepath.Join(filepath.Dir(sourceDir), "builds", buildID)
 
@@ -71,10 +95,9 @@ func CopyRepoForBuild(sourceDir, buildID string) (string, error) {
 		return "", fmt.Errorf("failed to create builds directory: %w", err)
 	}
 
-	cmd := exec.Command("cp", "-a", sourceDir, buildDir)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("failed to copy repo for build: %w\nOutput: %s", err, string(output))
+	if err := copyTree(sourceDir, buildDir); err != nil {
+		CleanupBuild(buildDir)
+		return "", fmt.Errorf("failed to copy repo for build: %w", err)
 	}
 
 	logger.GetLogger().Info("Created build copy",
@@ -85,9 +108,67 @@ func CopyRepoForBuild(sourceDir, buildID string) (string, error) {
 	return buildDir, nil
 }
 
-// CleanupBuild removes the build directory after use.
+// copyTree copies src to dst, keeping file modes and symlinks.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			if rel != "." && copySkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// CleanupBuild wipes and removes the build directory after use. Build
+// directories hold the device's secrets, so files are overwritten first.
 func CleanupBuild(buildDir string) {
-	if err := os.RemoveAll(buildDir); err != nil {
+	if err := secrets.WipeDir(buildDir, nil); err != nil {
 		logger.GetLogger().Warn("Failed to cleanup build directory",
 			zap.String("dir", buildDir),
 			zap.Error(err),
@@ -0,0 +1,156 @@
+package codegen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/codegen/builder"
+	"github.com/aruncs31s/skvms/internal/codegen/dto"
+	"github.com/aruncs31s/skvms/internal/health"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/secrets"
+	"go.uber.org/zap"
+)
+
+// DefaultBuildTTL is how long a build directory, and the binary with the
+// device's secrets in it, is kept for download before it is wiped.
+const DefaultBuildTTL = time.Hour
+
+// SecretResolver looks up secrets that a codegen request refers to instead
+// of carrying them.
+type SecretResolver interface {
+	// WiFiCredentials returns the SSID and password of a stored WiFi profile
+	// the user has access to.
+	WiFiCredentials(ctx context.Context, userID uint, profileID uint) (ssid string, password string, err error)
+
+	// DeviceToken issues a fresh device token for a device owned by the user.
+	DeviceToken(ctx context.Context, userID uint, deviceID uint) (string, error)
+}
+
+// resolveSecrets fills in the credentials referenced by the request. Raw
+// values in the request are kept when no reference is given.
+func (s *Service) resolveSecrets(ctx context.Context, req dto.CodeGenRequest) (dto.CodeGenRequest, error) {
+	if req.WiFiProfileID == 0 && !req.IssueToken {
+		return req, nil
+	}
+	if s.secrets == nil {
+		return req, errors.New("stored secrets are not configured")
+	}
+
+	if req.WiFiProfileID != 0 {
+		ssid, password, err := s.secrets.WiFiCredentials(ctx, req.UserID, req.WiFiProfileID)
+		if err != nil {
+			return req, fmt.Errorf("failed to load WiFi profile %d: %w", req.WiFiProfileID, err)
+		}
+		req.HOSTSSID = ssid
+		req.HOSTPASS = password
+	}
+
+	if req.IssueToken {
+		if req.DeviceID == 0 {
+			return req, &ConfigError{Problems: []string{"issue_token requires device_id"}}
+		}
+		token, err := s.secrets.DeviceToken(ctx, req.UserID, req.DeviceID)
+		if err != nil {
+			return req, fmt.Errorf("failed to issue device token: %w", err)
+		}
+		req.Token = token
+	}
+	return req, nil
+}
+
+// redactorFor returns a Redactor for every secret value of a request: the
+// WiFi password, the token and the values of secret schema keys.
+func redactorFor(req dto.CodeGenRequest, cfg *FirmwareConfig) *secrets.Redactor {
+	r := secrets.NewRedactor(req.HOSTPASS, req.Token)
+	if cfg == nil {
+		return r
+	}
+	values := RequestValues(cfg.schema, req)
+	for _, k := range cfg.schema.Keys {
+		if !k.Secret {
+			continue
+		}
+		if v, ok := values[k.Name].(string); ok {
+			r.Add(v)
+		}
+	}
+	return r
+}
+
+// scrubBuildDir wipes everything in a finished build except the binary, so
+// the generated config header with the secrets does not stay on disk.
+func scrubBuildDir(buildDir, binaryPath string) {
+	err := secrets.WipeDir(buildDir, func(path string) bool {
+		return path == binaryPath
+	})
+	if err != nil {
+		logger.GetLogger().Warn("Failed to scrub build directory",
+			zap.String("dir", buildDir),
+			zap.Error(err),
+		)
+	}
+}
+
+// scrubObjectDir drops the compiled sketch objects from a shared object dir
+// when secrets were compiled into them. Core and library objects stay cached.
+func scrubObjectDir(tool, objectDir string) {
+	if err := builder.PurgeSketchObjects(tool, objectDir); err != nil {
+		logger.GetLogger().Warn("Failed to scrub sketch objects",
+			zap.String("dir", objectDir),
+			zap.Error(err),
+		)
+	}
+}
+
+// StartJanitor wipes build directories older than ttl until ctx is done.
+// Binaries contain the device's secrets, so they are not kept forever.
+func (s *Service) StartJanitor(ctx context.Context, ttl time.Duration) *health.Worker {
+	if ttl <= 0 {
+		ttl = DefaultBuildTTL
+	}
+	interval := ttl / 4
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+
+	worker := health.NewWorker("codegen_janitor", interval)
+	go func() {
+		defer worker.Stop()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.wipeExpiredBuilds(ttl)
+			worker.Beat(nil)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return worker
+}
+
+func (s *Service) wipeExpiredBuilds(ttl time.Duration) {
+	buildsDir := filepath.Join(s.workDir, "builds")
+	entries, err := os.ReadDir(buildsDir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-ttl)
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		CleanupBuild(filepath.Join(buildsDir, e.Name()))
+		logger.GetLogger().Info("Wiped expired build",
+			zap.String("build_id", e.Name()),
+		)
+	}
+}
@@ -2,14 +2,24 @@ package codegen
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/aruncs31s/skvms/internal/codegen/builder"
 	"github.com/aruncs31s/skvms/internal/codegen/dto"
 	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/metrics"
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/repository"
+	"github.com/aruncs31s/skvms/internal/secrets"
+	"github.com/aruncs31s/skvms/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // Service orchestrates the ESP32 firmware code generation pipeline:
@@ -17,74 +27,340 @@ import (
 type Service struct {
 	// workDir is the base directory for repo clones and builds.
 	workDir string
+
+	// profiles looks up the board profile of the target device type.
+	profiles repository.BoardProfileRepository
+
+	// cache keeps compiled objects and firmware images between builds.
+	cache *BuildCache
+
+	// secrets resolves the WiFi profiles and device tokens a request refers to.
+	secrets SecretResolver
+
+	// toolchain selects and creates the build strategies.
+	toolchain builder.Toolchain
 }
 
 // NewService creates a new codegen Service.
 // workDir is the base directory where the firmware source will be stored.
-func NewService(workDir string) *Service {
+func NewService(
+	workDir string,
+	profiles repository.BoardProfileRepository,
+	secretResolver SecretResolver,
+) *Service {
 	if workDir == "" {
 		workDir = filepath.Join(os.TempDir(), "skvms-codegen")
 	}
-	return &Service{workDir: workDir}
+	return &Service{
+		workDir:   workDir,
+		profiles:  profiles,
+		cache:     NewBuildCache(filepath.Join(workDir, "cache")),
+		secrets:   secretResolver,
+		toolchain: builder.Installed,
+	}
 }
 
+// UseToolchain replaces the installed build tools, e.g. with a fake in
+// tests. It must be called before the first build.
+func (s *Service) UseToolchain(toolchain builder.Toolchain) {
+	s.toolchain = toolchain
+}
+
+// ErrFQBNWithTargets is returned for a multi-target build that names a board
+// FQBN, which only applies to single-target builds.
+var ErrFQBNWithTargets = errors.New("board_fqbn cannot be combined with device_type_ids")
+
 // GenerateResult holds the output of a successful firmware generation.
 type GenerateResult struct {
 	BuildID    string
 	BinaryPath string
 	BinarySize int64
 	BuildTool  string
+	Board      string
+	BoardFQBN  string
+
+	// Cached is true when the binary was patched from a cached image instead of compiled.
+	Cached bool
+
+	// tool and profile select the strategy that uploads the binary.
+	tool    string
+	profile builder.BoardProfile
+}
+
+// TargetError is returned for a single failed target of a multi-target build.
+type TargetError struct {
+	DeviceTypeID uint
+	Board        string
+	Err          error
+}
+
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("build for device type %d (%s) failed: %v", e.DeviceTypeID, e.Board, e.Err)
+}
+
+func (e *TargetError) Unwrap() error {
+	return e.Err
 }
 
 // Generate executes the full codegen pipeline:
-//  1. Ensure the firmware source repo is cloned/up-to-date
-//  2. Create an isolated copy for this build
-//  3. Replace config values with the request parameters
-//  4. Build the firmware using the selected strategy
-//  5. Return the path to the compiled binary
+//  1. Resolve the stored secrets the request refers to
+//  2. Ensure the firmware source repo is cloned/up-to-date
+//  3. Validate the request against the firmware config schema
+//  4. Pick the board profile of the target device
+//  5. Create an isolated copy for this build
+//  6. Write the config header with the request parameters
+//  7. Build the firmware using the selected strategy
+//  8. Return the path to the compiled binary
+//
+// Secret values are redacted from the returned errors.
 func (s *Service) Generate(ctx context.Context, req dto.CodeGenRequest) (*GenerateResult, error) {
-	logger.GetLogger().Info("Starting codegen pipeline",
+	logger.FromContext(ctx).Info("Starting codegen pipeline",
 		zap.String("device_ip", req.IP),
 		zap.String("host_ip", req.HostIP),
 		zap.String("wifi_ssid", req.HOSTSSID),
+		zap.Uint("wifi_profile_id", req.WiFiProfileID),
 		zap.String("build_tool", req.BuildTool),
+		zap.Uint("device_id", req.DeviceID),
 	)
 
-	// Step 1: Clone or pull the source repo
+	// Step 1: Resolve stored secrets
+	req, err := s.resolveSecrets(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 2: Clone or pull the source repo
 	sourceDir, err := CloneOrPullRepo(s.workDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare source repository: %w", err)
 	}
 
-	// Step 2: Create an isolated build copy
+	// Step 3: Validate the request against the firmware config schema
+	cfg, err := PrepareConfig(sourceDir, req)
+	if err != nil {
+		return nil, err
+	}
+	redactor := redactorFor(req, cfg)
+
+	// Step 4: Pick the board profile
+	profile, err := s.ResolveProfile(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.buildTarget(ctx, sourceDir, SourceRevision(sourceDir), cfg, profile, req.BuildTool, redactor)
+	if err != nil {
+		return nil, redactor.Error(err)
+	}
+	return result, nil
+}
+
+// GenerateMulti builds the same configuration for every device type in
+// req.DeviceTypeIDs with the board profile of each. The source repo is
+// prepared once and each target gets its own build copy. Failed targets are returned as TargetErrors alongside the
+// successful results; an error is only returned if nothing could be attempted.
+func (s *Service) GenerateMulti(ctx context.Context, req dto.CodeGenRequest) ([]*GenerateResult, []*TargetError, error) {
+	if len(req.DeviceTypeIDs) == 0 {
+		return nil, nil, fmt.Errorf("device_type_ids is required for a multi-target build")
+	}
+	if req.BoardFQBN != "" {
+		// One FQBN would build every device type for the same board
+		return nil, nil, ErrFQBNWithTargets
+	}
+
+	logger.FromContext(ctx).Info("Starting multi-target codegen pipeline",
+		zap.Uints("device_type_ids", req.DeviceTypeIDs),
+		zap.String("build_tool", req.BuildTool),
+	)
+
+	req, err := s.resolveSecrets(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sourceDir, err := CloneOrPullRepo(s.workDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to prepare source repository: %w", err)
+	}
+
+	cfg, err := PrepareConfig(sourceDir, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	redactor := redactorFor(req, cfg)
+	revision := SourceRevision(sourceDir)
+
+	var (
+		results []*GenerateResult
+		failed  []*TargetError
+	)
+	// Targets are built one after another: concurrent core and library
+	// installs of the same toolchain interfere with each other.
+	for _, deviceTypeID := range req.DeviceTypeIDs {
+		if err := ctx.Err(); err != nil {
+			return results, failed, err
+		}
+
+		profile, err := s.profileForDeviceType(ctx, deviceTypeID)
+		if err != nil {
+			failed = append(failed, &TargetError{DeviceTypeID: deviceTypeID, Err: err})
+			continue
+		}
+		result, err := s.buildTarget(ctx, sourceDir, revision, cfg, profile, req.BuildTool, redactor)
+		if err != nil {
+			failed = append(failed, &TargetError{DeviceTypeID: deviceTypeID, Board: profile.Name, Err: redactor.Error(err)})
+			continue
+		}
+		results = append(results, result)
+	}
+
+	return results, failed, nil
+}
+
+// ResolveProfile picks the board profile for a request. The device's type
+// profile is used when DeviceID is set, otherwise the default profile.
+// An explicit BoardFQBN always overrides the profile's FQBN.
+func (s *Service) ResolveProfile(ctx context.Context, req dto.CodeGenRequest) (builder.BoardProfile, error) {
+	profile := builder.DefaultProfile()
+
+	if req.DeviceID != 0 && s.profiles != nil {
+		p, err := s.profiles.GetByDeviceID(ctx, req.DeviceID)
+		switch {
+		case err == nil:
+			profile = toBuilderProfile(p)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			logger.FromContext(ctx).Warn("No board profile for device, using default",
+				zap.Uint("device_id", req.DeviceID),
+			)
+		default:
+			return profile, fmt.Errorf("failed to load board profile: %w", err)
+		}
+	}
+
+	if req.BoardFQBN != "" {
+		profile.FQBN = req.BoardFQBN
+	}
+	return profile, nil
+}
+
+func (s *Service) profileForDeviceType(ctx context.Context, deviceTypeID uint) (builder.BoardProfile, error) {
+	if s.profiles == nil {
+		return builder.BoardProfile{}, fmt.Errorf("board profiles are not configured")
+	}
+	p, err := s.profiles.GetByDeviceTypeID(ctx, deviceTypeID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return builder.BoardProfile{}, fmt.Errorf("no board profile for device type %d", deviceTypeID)
+		}
+		return builder.BoardProfile{}, fmt.Errorf("failed to load board profile: %w", err)
+	}
+	return toBuilderProfile(p), nil
+}
+
+// buildTarget builds the config for one board. A cached image is reused when
+// one exists for the same source revision, board, tool and non-secret config;
+// otherwise the source is copied and compiled against the shared object dir.
+// Everything but the binary is wiped from the build copy afterwards, and the
+// sketch objects are wiped from the object dir if secrets were compiled in.
+func (s *Service) buildTarget(
+	ctx context.Context,
+	sourceDir string,
+	revision string,
+	cfg *FirmwareConfig,
+	profile builder.BoardProfile,
+	buildTool string,
+	redactor *secrets.Redactor,
+) (_ *GenerateResult, err error) {
+	ctx, span := tracing.Start(ctx, "codegen.build",
+		attribute.String("codegen.board", profile.Name),
+		attribute.String("codegen.revision", revision),
+	)
+	start := time.Now()
+	cached := false
+	defer func() {
+		result := metrics.Result(err)
+		if cached && err == nil {
+			result = metrics.ResultCached
+		}
+		metrics.ObserveCodegenBuild(profile.Name, result, time.Since(start))
+		span.SetAttributes(attribute.String("codegen.result", result))
+		tracing.End(span, err)
+	}()
+
+	tool, err := s.toolchain.Select(buildTool)
+	if err != nil {
+		return nil, fmt.Errorf("no build tool available: %w", err)
+	}
+
+	// Values can only be patched into ESP32 application images
+	patching := cfg.Patchable() && isESP32(profile)
+
 	buildID := generateBuildID()
+	imageKey := s.cache.ImageKey(revision, profile, tool, cfg, patching)
+	if imageKey != "" {
+		if image, ok := s.cache.LoadImage(imageKey); ok {
+			cached = true
+			return s.buildFromImage(buildID, image, cfg, profile, tool, patching)
+		}
+	}
+
+	// Create an isolated build copy
 	buildDir, err := CopyRepoForBuild(sourceDir, buildID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create build copy: %w", err)
 	}
 
-	// Step 3: Replace config values
-	if err := ReplaceConfig(buildDir, req); err != nil {
+	// Write the config header
+	if err := cfg.Apply(buildDir, patching); err != nil {
 		CleanupBuild(buildDir)
-		return nil, fmt.Errorf("failed to replace config: %w", err)
+		return nil, fmt.Errorf("failed to apply config: %w", err)
 	}
 
-	// Step 4: Resolve the build strategy
-	strategy, err := builder.Resolve(req.BuildTool)
-	if err != nil {
-		CleanupBuild(buildDir)
-		return nil, fmt.Errorf("no build tool available: %w", err)
+	// Reuse compiled objects of earlier builds of the same source and board
+	opts := builder.BuildOptions{Redact: redactor.String}
+	if revision != "" {
+		objectDir, unlock, err := s.cache.LockObjectDir(revision, profile, tool)
+		if err != nil {
+			logger.FromContext(ctx).Warn("Build cache unavailable, doing a full build",
+				zap.Error(err),
+			)
+		} else {
+			defer unlock()
+			opts.ObjectDir = objectDir
+			if cfg.compilesSecrets(patching) {
+				defer scrubObjectDir(tool, objectDir)
+			}
+		}
 	}
+	strategy := s.toolchain.New(tool, profile, opts)
 
-	// Step 5: Build the firmware
+	// Build the firmware
 	result, err := strategy.Build(ctx, buildDir)
 	if err != nil {
 		CleanupBuild(buildDir)
 		return nil, fmt.Errorf("firmware build failed: %w", err)
 	}
 
-	logger.GetLogger().Info("Codegen pipeline completed successfully",
+	if imageKey != "" {
+		if err := s.cache.StoreImage(imageKey, result.BinaryPath, revision, profile.Name, tool); err != nil {
+			logger.FromContext(ctx).Warn("Failed to cache firmware image",
+				zap.String("build_id", buildID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if patching {
+		if err := s.patchBinary(result.BinaryPath, cfg); err != nil {
+			CleanupBuild(buildDir)
+			return nil, err
+		}
+	}
+	scrubBuildDir(buildDir, result.BinaryPath)
+
+	logger.FromContext(ctx).Info("Codegen pipeline completed successfully",
 		zap.String("build_id", buildID),
+		zap.String("board", profile.Name),
 		zap.String("binary_path", result.BinaryPath),
 		zap.Int64("binary_size", result.Size),
 		zap.String("build_tool", strategy.Name()),
@@ -95,9 +371,90 @@ func (s *Service) Generate(ctx context.Context, req dto.CodeGenRequest) (*Genera
 		BinaryPath: result.BinaryPath,
 		BinarySize: result.Size,
 		BuildTool:  strategy.Name(),
+		Board:      profile.Name,
+		BoardFQBN:  profile.FQBN,
+		tool:       tool,
+		profile:    profile,
+	}, nil
+}
+
+// buildFromImage writes a cached image, patched with the device values, as
+// the binary of a new build without compiling anything.
+func (s *Service) buildFromImage(
+	buildID string,
+	image []byte,
+	cfg *FirmwareConfig,
+	profile builder.BoardProfile,
+	tool string,
+	patching bool,
+) (*GenerateResult, error) {
+	if patching {
+		if err := cfg.PatchImage(image, isESP32(profile)); err != nil {
+			return nil, fmt.Errorf("failed to patch cached firmware image: %w", err)
+		}
+	}
+
+	binaryPath := filepath.Join(s.workDir, "builds", buildID, "build", "firmware.bin")
+	if err := os.MkdirAll(filepath.Dir(binaryPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create build directory: %w", err)
+	}
+	if err := os.WriteFile(binaryPath, image, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write firmware image: %w", err)
+	}
+
+	strategy := s.toolchain.New(tool, profile, builder.BuildOptions{})
+	logger.GetLogger().Info("Codegen served from build cache",
+		zap.String("build_id", buildID),
+		zap.String("board", profile.Name),
+		zap.Int("binary_size", len(image)),
+		zap.String("build_tool", strategy.Name()),
+	)
+
+	return &GenerateResult{
+		BuildID:    buildID,
+		BinaryPath: binaryPath,
+		BinarySize: int64(len(image)),
+		BuildTool:  strategy.Name(),
+		Board:      profile.Name,
+		BoardFQBN:  profile.FQBN,
+		Cached:     true,
+		tool:       tool,
+		profile:    profile,
 	}, nil
 }
 
+// patchBinary patches the device values into a freshly built binary in place.
+func (s *Service) patchBinary(binaryPath string, cfg *FirmwareConfig) error {
+	image, err := os.ReadFile(binaryPath)
+	if err != nil {
+		return fmt.Errorf("failed to read firmware image: %w", err)
+	}
+	if err := cfg.PatchImage(image, true); err != nil {
+		return fmt.Errorf("failed to patch firmware image: %w", err)
+	}
+	if err := os.WriteFile(binaryPath, image, 0644); err != nil {
+		return fmt.Errorf("failed to write firmware image: %w", err)
+	}
+	return nil
+}
+
+// isESP32 reports whether the profile targets an ESP32 family board.
+func isESP32(profile builder.BoardProfile) bool {
+	return strings.HasPrefix(profile.FQBN, "esp32:")
+}
+
+// toBuilderProfile converts a stored board profile to the builder's view of it.
+func toBuilderProfile(p *model.BoardProfile) builder.BoardProfile {
+	return builder.BoardProfile{
+		Name:            p.DeviceType.Name,
+		FQBN:            p.FQBN,
+		PlatformIOEnv:   p.PlatformIOEnv,
+		PartitionScheme: p.PartitionScheme,
+		FlashSize:       p.FlashSize,
+		Libraries:       p.LibraryList(),
+	}
+}
+
 // Upload compiles (if needed) and flashes firmware to the ESP32 via OTA.
 func (s *Service) Upload(ctx context.Context, req dto.CodeGenRequest, deviceIP string) error {
 	// First generate the firmware
@@ -107,17 +464,11 @@ func (s *Service) Upload(ctx context.Context, req dto.CodeGenRequest, deviceIP s
 	}
 	defer s.CleanupBuild(result.BuildID)
 
-	// Resolve build strategy
-	strategy, err := builder.Resolve(req.BuildTool)
-	if err != nil {
-		return err
-	}
-
-	// Get the build directory from the binary path
-	buildDir := filepath.Dir(filepath.Dir(result.BinaryPath))
-
-	// Upload via OTA
-	return strategy.Upload(ctx, buildDir, deviceIP)
+	// Flash the generated binary with the tool and board it was built for;
+	// it may have been patched from a cached image, so it must not be
+	// rebuilt from the build directory.
+	strategy := s.toolchain.New(result.tool, result.profile, builder.BuildOptions{})
+	return strategy.UploadBinary(ctx, result.BinaryPath, deviceIP)
 }
 
 // GetBinaryPath returns the path to a previously built binary.
@@ -153,9 +504,38 @@ func (s *Service) CleanupBuild(buildID string) {
 	CleanupBuild(buildDir)
 }
 
+// Schema returns the config schema of the current firmware source, with the
+// defaults of secret keys removed.
+func (s *Service) Schema() (*ConfigSchema, error) {
+	sourceDir, err := CloneOrPullRepo(s.workDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare source repository: %w", err)
+	}
+	schema, err := LoadSchema(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+	return schema.Redacted(), nil
+}
+
+// WorkDir returns the directory repo clones and builds are kept in.
+func (s *Service) WorkDir() string {
+	return s.workDir
+}
+
+// CacheStats reports the size of the build cache.
+func (s *Service) CacheStats() (CacheStats, error) {
+	return s.cache.Stats()
+}
+
+// PurgeCache removes all cached objects and images.
+func (s *Service) PurgeCache() error {
+	return s.cache.Purge()
+}
+
 // ListAvailableTools returns the names of available build tools.
 func (s *Service) ListAvailableTools() []string {
-	return builder.ListAvailable()
+	return s.toolchain.Available()
 }
 
 // generateBuildID creates a unique build identifier using timestamp and random suffix.
@@ -1,43 +1,268 @@
+// Package config loads the server configuration from a YAML file, overridden
+// by environment variables, and validates it. Settings that are safe to
+// change at runtime are reloaded by a Store.
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the configuration file read when CONFIG_FILE is not set. It
+// is optional: without it the defaults and the environment are used.
+const DefaultFile = "config/skvms.yaml"
+
+// Modes. Production refuses the default secrets.
+const (
+	ModeDevelopment = "development"
+	ModeProduction  = "production"
 )
 
 type Config struct {
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	JWTSecret  string
-	ServerPort string
-	LogDir     string
-	LogLevel   string
-}
-
-func Load() Config {
-	_ = godotenv.Load()
+	// Mode is development or production
+	Mode string `yaml:"mode"`
+
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Log       LogConfig       `yaml:"log"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	SMTP      SMTPConfig      `yaml:"smtp"`
+	Reports   ReportsConfig   `yaml:"reports"`
+	PDF       PDFConfig       `yaml:"pdf"`
+	Codegen   CodegenConfig   `yaml:"codegen"`
+	Audit     AuditConfig     `yaml:"audit"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+}
+
+type ServerConfig struct {
+	Port string `yaml:"port"`
+	// CORSOrigins are the browser origins allowed to call the API
+	CORSOrigins []string `yaml:"cors_origins"`
+	// ShutdownTimeout bounds the graceful shutdown: draining requests,
+	// stopping background jobs and flushing the audit trail
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// Database drivers.
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+type DatabaseConfig struct {
+	// Driver is mysql, postgres or sqlite
+	Driver string `yaml:"driver"`
+	Host   string `yaml:"host"`
+	// Port defaults to the driver's standard port
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	// SSLMode is the PostgreSQL sslmode, e.g. disable or require
+	SSLMode string `yaml:"sslmode"`
+	// Path is the SQLite database file
+	Path string `yaml:"path"`
+	// Timescale stores readings in a TimescaleDB hypertable on PostgreSQL
+	Timescale bool `yaml:"timescale"`
+	// AutoMigrate applies pending schema migrations on startup, otherwise
+	// the server refuses to start until they are applied with migrate up
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+type AuthConfig struct {
+	JWTSecret string `yaml:"jwt_secret"`
+	// SecretsKey encrypts stored secrets such as WiFi passwords. A base64
+	// encoded 32-byte key is used as is, anything else is hashed into one.
+	SecretsKey      string        `yaml:"secrets_key"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	DeviceTokenTTL  time.Duration `yaml:"device_token_ttl"`
+}
+
+type LogConfig struct {
+	Dir string `yaml:"dir"`
+	// Level is debug, info, warn or error
+	Level string `yaml:"level"`
+}
+
+// RateLimitConfig limits the API requests of each client IP. A zero
+// RequestsPerSecond disables the limit.
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+// SMTPConfig configures emailed reports. Without a Host reports are rendered
+// and stored but not delivered.
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+type ReportsConfig struct {
+	Dir string `yaml:"dir"`
+}
+
+// PDFConfig configures the PDF export renderer. ChromePath defaults to
+// searching the PATH; without Chrome the pdf format is reported as
+// unavailable.
+type PDFConfig struct {
+	ChromePath    string        `yaml:"chrome_path"`
+	PoolSize      int           `yaml:"pool_size"`
+	RenderTimeout time.Duration `yaml:"render_timeout"`
+	// TemplatesDir holds the built-in HTML templates of PDF exports
+	TemplatesDir string `yaml:"templates_dir"`
+}
 
+type CodegenConfig struct {
+	// WorkDir holds the firmware repo clone, builds and the build cache;
+	// empty selects a directory under the system temp dir
+	WorkDir string `yaml:"work_dir"`
+	// BuildTTL is how long built binaries, which carry device secrets, are
+	// kept
+	BuildTTL time.Duration `yaml:"build_ttl"`
+	// MinFreeDiskMB is the free space the work dir needs for the server to
+	// report itself healthy rather than degraded
+	MinFreeDiskMB int `yaml:"min_free_disk_mb"`
+}
+
+// AuditConfig configures the audit trail. Dir holds the outbox entries wait
+// in until they are chained, the signing key and the exported checkpoints.
+// SigningKey is a base64 ed25519 seed; without it a key is generated in Dir.
+type AuditConfig struct {
+	Dir                string        `yaml:"dir"`
+	SigningKey         string        `yaml:"signing_key"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval"`
+}
+
+// TracingConfig configures OpenTelemetry tracing. Exporter is otlp, stdout
+// or none; Endpoint is the host:port of an OTLP/HTTP collector.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Default returns the configuration used for anything neither the file nor
+// the environment sets.
+func Default() Config {
 	return Config{
-		DBHost:     getEnv("DB_HOST", "127.0.0.1"),
-		DBPort:     getEnv("DB_PORT", "3306"),
-		DBUser:     getEnv("DB_USER", "root"),
-		DBPassword: getEnv("DB_PASSWORD", ""),
-		DBName:     getEnv("DB_NAME", "skvms"),
-		JWTSecret:  getEnv("JWT_SECRET", "change-me"),
-		ServerPort: getEnv("PORT", "8080"),
-		LogDir:     getEnv("LOG_DIR", "./logs"),
-		LogLevel:   getEnv("LOG_LEVEL", "info"),
+		Mode: ModeDevelopment,
+		Server: ServerConfig{
+			Port:            "8080",
+			CORSOrigins:     []string{"http://localhost:5173", "http://localhost:3000"},
+			ShutdownTimeout: 60 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:      DriverMySQL,
+			Host:        "127.0.0.1",
+			User:        "root",
+			Name:        "skvms",
+			SSLMode:     "disable",
+			Path:        "./data/skvms.db",
+			AutoMigrate: true,
+		},
+		Auth: AuthConfig{
+			JWTSecret:       "change-me",
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 7 * 24 * time.Hour,
+			DeviceTokenTTL:  2400 * time.Hour,
+		},
+		Log: LogConfig{
+			Dir:   "./logs",
+			Level: "info",
+		},
+		SMTP: SMTPConfig{
+			Port: "25",
+			From: "skvms@localhost",
+		},
+		Reports: ReportsConfig{
+			Dir: "./reports",
+		},
+		PDF: PDFConfig{
+			PoolSize:      2,
+			RenderTimeout: 60 * time.Second,
+			TemplatesDir:  "templates/export",
+		},
+		Codegen: CodegenConfig{
+			BuildTTL:      time.Hour,
+			MinFreeDiskMB: 512,
+		},
+		Audit: AuditConfig{
+			Dir:                "./data/audit",
+			CheckpointInterval: time.Hour,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load reads the configuration file named by CONFIG_FILE, or DefaultFile if
+// it exists, applies the environment overrides, including those of a .env
+// file, and validates the result.
+func Load() (*Store, error) {
+	_ = godotenv.Load()
+
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		if _, err := os.Stat(DefaultFile); err == nil {
+			path = DefaultFile
+		}
+	}
+
+	cfg, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	return newStore(path, cfg), nil
+}
+
+// read builds a validated configuration from the defaults, the file at path,
+// if any, and the environment.
+func read(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("read config file: %w", err)
+		}
+		if err := decode(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
 	}
+	return cfg, nil
 }
 
-func getEnv(key, fallback string) string {
-	val := os.Getenv(key)
-	if val == "" {
-		return fallback
+// decode overlays the YAML in data on cfg. Unknown keys are errors, so a
+// misspelt setting is not silently ignored.
+func decode(data []byte, cfg *Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
 	}
-	return val
+	return nil
 }
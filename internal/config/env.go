@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides cfg with the environment variables that are set. The
+// variable names predate the configuration file and are kept as they were.
+func applyEnv(cfg *Config) error {
+	env := envOverrides{}
+
+	env.string("APP_MODE", &cfg.Mode)
+
+	env.string("PORT", &cfg.Server.Port)
+	env.list("CORS_ORIGINS", &cfg.Server.CORSOrigins)
+	env.duration("SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+
+	env.string("DB_DRIVER", &cfg.Database.Driver)
+	env.string("DB_HOST", &cfg.Database.Host)
+	env.string("DB_PORT", &cfg.Database.Port)
+	env.string("DB_USER", &cfg.Database.User)
+	env.string("DB_PASSWORD", &cfg.Database.Password)
+	env.string("DB_NAME", &cfg.Database.Name)
+	env.string("DB_SSLMODE", &cfg.Database.SSLMode)
+	env.string("DB_PATH", &cfg.Database.Path)
+	env.bool("DB_TIMESCALE", &cfg.Database.Timescale)
+	env.bool("DB_AUTO_MIGRATE", &cfg.Database.AutoMigrate)
+
+	env.string("JWT_SECRET", &cfg.Auth.JWTSecret)
+	env.string("SECRETS_KEY", &cfg.Auth.SecretsKey)
+	env.duration("ACCESS_TOKEN_TTL", &cfg.Auth.AccessTokenTTL)
+	env.duration("REFRESH_TOKEN_TTL", &cfg.Auth.RefreshTokenTTL)
+	env.duration("DEVICE_TOKEN_TTL", &cfg.Auth.DeviceTokenTTL)
+
+	env.string("LOG_DIR", &cfg.Log.Dir)
+	env.string("LOG_LEVEL", &cfg.Log.Level)
+
+	env.float("RATE_LIMIT_RPS", &cfg.RateLimit.RequestsPerSecond)
+	env.int("RATE_LIMIT_BURST", &cfg.RateLimit.Burst)
+
+	env.string("SMTP_HOST", &cfg.SMTP.Host)
+	env.string("SMTP_PORT", &cfg.SMTP.Port)
+	env.string("SMTP_USER", &cfg.SMTP.User)
+	env.string("SMTP_PASSWORD", &cfg.SMTP.Password)
+	env.string("SMTP_FROM", &cfg.SMTP.From)
+
+	env.string("REPORTS_DIR", &cfg.Reports.Dir)
+
+	env.string("CHROME_PATH", &cfg.PDF.ChromePath)
+	env.int("PDF_POOL_SIZE", &cfg.PDF.PoolSize)
+	env.duration("PDF_RENDER_TIMEOUT", &cfg.PDF.RenderTimeout)
+	env.string("PDF_TEMPLATES_DIR", &cfg.PDF.TemplatesDir)
+
+	env.string("CODEGEN_WORK_DIR", &cfg.Codegen.WorkDir)
+	env.duration("CODEGEN_BUILD_TTL", &cfg.Codegen.BuildTTL)
+	env.int("MIN_FREE_DISK_MB", &cfg.Codegen.MinFreeDiskMB)
+
+	env.string("AUDIT_DIR", &cfg.Audit.Dir)
+	env.string("AUDIT_SIGNING_KEY", &cfg.Audit.SigningKey)
+	env.duration("AUDIT_CHECKPOINT_INTERVAL", &cfg.Audit.CheckpointInterval)
+
+	env.string("TRACING_EXPORTER", &cfg.Tracing.Exporter)
+	env.string("TRACING_ENDPOINT", &cfg.Tracing.Endpoint)
+	env.bool("TRACING_INSECURE", &cfg.Tracing.Insecure)
+	env.float("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio)
+
+	return errors.Join(env.errs...)
+}
+
+// envOverrides sets values from the variables that are set and collects the
+// ones that cannot be parsed.
+type envOverrides struct {
+	errs []error
+}
+
+func (e *envOverrides) lookup(key string) (string, bool) {
+	val, ok := os.LookupEnv(key)
+	return val, ok && val != ""
+}
+
+func (e *envOverrides) fail(key, val string, err error) {
+	e.errs = append(e.errs, fmt.Errorf("%s=%q: %w", key, val, err))
+}
+
+func (e *envOverrides) string(key string, dst *string) {
+	if val, ok := e.lookup(key); ok {
+		*dst = val
+	}
+}
+
+// list reads a comma separated list.
+func (e *envOverrides) list(key string, dst *[]string) {
+	val, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+}
+
+func (e *envOverrides) int(key string, dst *int) {
+	val, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		e.fail(key, val, err)
+		return
+	}
+	*dst = n
+}
+
+func (e *envOverrides) float(key string, dst *float64) {
+	val, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		e.fail(key, val, err)
+		return
+	}
+	*dst = f
+}
+
+func (e *envOverrides) bool(key string, dst *bool) {
+	val, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		e.fail(key, val, err)
+		return
+	}
+	*dst = b
+}
+
+func (e *envOverrides) duration(key string, dst *time.Duration) {
+	val, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		e.fail(key, val, err)
+		return
+	}
+	*dst = d
+}
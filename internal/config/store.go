@@ -0,0 +1,188 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/health"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"go.uber.org/zap"
+)
+
+// reloadable are the settings applied without a restart. Changes to any
+// other setting are logged and wait for the next start.
+var reloadable = []string{
+	"log.level",
+	"server.cors_origins",
+	"rate_limit.requests_per_second",
+	"rate_limit.burst",
+}
+
+// Store holds the running configuration and reloads it when its file
+// changes or the process receives SIGHUP.
+type Store struct {
+	path string
+
+	mu          sync.RWMutex
+	current     Config
+	modTime     time.Time
+	subscribers []func(Config)
+}
+
+func newStore(path string, cfg Config) *Store {
+	s := &Store{path: path, current: cfg}
+	if info, err := os.Stat(path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return s
+}
+
+// Path returns the configuration file, empty when none is used.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Current returns the running configuration.
+func (s *Store) Current() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Subscribe calls fn with the new configuration after every reload that
+// changed a reloadable setting.
+func (s *Store) Subscribe(fn func(Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Reload reads the configuration again. An invalid configuration is
+// rejected as a whole and the running one is kept. Of a valid one only the
+// reloadable settings are applied; it returns the changed settings that
+// need a restart.
+func (s *Store) Reload() (pending []string, err error) {
+	next, err := read(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	applied := s.current
+	var changed bool
+	for _, key := range diff(s.current, next) {
+		if !slices.Contains(reloadable, key) {
+			pending = append(pending, key)
+			continue
+		}
+		changed = true
+	}
+	applied.Log.Level = next.Log.Level
+	applied.Server.CORSOrigins = next.Server.CORSOrigins
+	applied.RateLimit = next.RateLimit
+	s.current = applied
+	subscribers := slices.Clone(s.subscribers)
+	s.mu.Unlock()
+
+	if changed {
+		for _, fn := range subscribers {
+			fn(applied)
+		}
+	}
+	return pending, nil
+}
+
+// Watch reloads the configuration whenever its file is modified, checking
+// every interval, and on SIGHUP, until ctx is done.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) *health.Worker {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	worker := health.NewWorker("config_watcher", interval)
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		defer worker.Stop()
+		defer signal.Stop(hangup)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				s.reload("signal")
+			case <-ticker.C:
+				if s.modified() {
+					s.reload("file")
+				}
+			}
+			// A rejected file leaves the running configuration in place,
+			// it is not a failure of the watcher
+			worker.Beat(nil)
+		}
+	}()
+	return worker
+}
+
+// modified reports whether the file changed since it was last read.
+func (s *Store) modified() bool {
+	if s.path == "" {
+		return false
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if info.ModTime().Equal(s.modTime) {
+		return false
+	}
+	s.modTime = info.ModTime()
+	return true
+}
+
+func (s *Store) reload(trigger string) {
+	log := logger.GetLogger().With(zap.String("file", s.path), zap.String("trigger", trigger))
+	pending, err := s.Reload()
+	if err != nil {
+		log.Error("Rejected configuration reload, keeping the running configuration", zap.Error(err))
+		return
+	}
+	log.Info("Configuration reloaded")
+	if len(pending) > 0 {
+		log.Warn("Changed settings take effect after a restart", zap.Strings("settings", pending))
+	}
+}
+
+// diff returns the keys, e.g. "database.host", of the settings that differ.
+func diff(a, b Config) []string {
+	var keys []string
+	diffValue(reflect.ValueOf(a), reflect.ValueOf(b), "", &keys)
+	return keys
+}
+
+func diffValue(a, b reflect.Value, prefix string, keys *[]string) {
+	if a.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*keys = append(*keys, prefix)
+		}
+		return
+	}
+	for i := 0; i < a.NumField(); i++ {
+		name, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("yaml"), ",")
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		diffValue(a.Field(i), b.Field(i), name, keys)
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// insecureSecrets are placeholder secrets refused in production.
+var insecureSecrets = []string{"change-me", "changeme", "secret", "password"}
+
+// minProductionSecretLength is the shortest JWT secret accepted in
+// production.
+const minProductionSecretLength = 32
+
+var (
+	logLevels        = []string{"debug", "info", "warn", "error"}
+	tracingExporters = []string{"none", "otlp", "stdout"}
+)
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(key string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Mode != ModeDevelopment && c.Mode != ModeProduction {
+		fail("mode", "must be %s or %s, got %q", ModeDevelopment, ModeProduction, c.Mode)
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		fail("server.port", "must be a port number, got %q", c.Server.Port)
+	}
+	for _, origin := range c.Server.CORSOrigins {
+		if err := validateOrigin(origin); err != nil {
+			fail("server.cors_origins", "%v", err)
+		}
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		fail("server.shutdown_timeout", "must be positive")
+	}
+
+	switch c.Database.Driver {
+	case DriverMySQL, DriverPostgres:
+		if c.Database.Host == "" || c.Database.Name == "" {
+			fail("database", "host and name are required")
+		}
+	case DriverSQLite:
+		if c.Database.Path == "" {
+			fail("database.path", "is required with sqlite")
+		}
+	default:
+		fail("database.driver", "must be %s, %s or %s, got %q", DriverMySQL, DriverPostgres, DriverSQLite, c.Database.Driver)
+	}
+	if c.Database.Port != "" {
+		if port, err := strconv.Atoi(c.Database.Port); err != nil || port < 1 || port > 65535 {
+			fail("database.port", "must be a port number, got %q", c.Database.Port)
+		}
+	}
+	if c.Database.Timescale && c.Database.Driver != DriverPostgres {
+		fail("database.timescale", "needs the postgres driver")
+	}
+
+	if c.Auth.JWTSecret == "" {
+		fail("auth.jwt_secret", "is required")
+	}
+	if c.Auth.AccessTokenTTL <= 0 || c.Auth.RefreshTokenTTL <= 0 || c.Auth.DeviceTokenTTL <= 0 {
+		fail("auth", "token lifetimes must be positive")
+	}
+	if c.Auth.RefreshTokenTTL < c.Auth.AccessTokenTTL {
+		fail("auth.refresh_token_ttl", "must not be shorter than access_token_ttl")
+	}
+	if c.Mode == ModeProduction {
+		if slices.Contains(insecureSecrets, c.Auth.JWTSecret) || len(c.Auth.JWTSecret) < minProductionSecretLength {
+			fail("auth.jwt_secret", "must be a random secret of at least %d characters in production", minProductionSecretLength)
+		}
+		if slices.Contains(insecureSecrets, c.Auth.SecretsKey) {
+			fail("auth.secrets_key", "must not be a placeholder in production")
+		}
+	}
+
+	if !slices.Contains(logLevels, c.Log.Level) {
+		fail("log.level", "must be one of %v, got %q", logLevels, c.Log.Level)
+	}
+
+	if c.RateLimit.RequestsPerSecond < 0 {
+		fail("rate_limit.requests_per_second", "must not be negative")
+	}
+	if c.RateLimit.RequestsPerSecond > 0 && c.RateLimit.Burst < 1 {
+		fail("rate_limit.burst", "must be at least 1 when rate limiting is on")
+	}
+
+	if c.PDF.PoolSize < 1 {
+		fail("pdf.pool_size", "must be at least 1")
+	}
+	if c.PDF.RenderTimeout <= 0 {
+		fail("pdf.render_timeout", "must be positive")
+	}
+	if c.PDF.TemplatesDir == "" {
+		fail("pdf.templates_dir", "is required")
+	}
+
+	if c.Codegen.BuildTTL <= 0 {
+		fail("codegen.build_ttl", "must be positive")
+	}
+	if c.Codegen.MinFreeDiskMB < 0 {
+		fail("codegen.min_free_disk_mb", "must not be negative")
+	}
+
+	if c.Audit.Dir == "" {
+		fail("audit.dir", "is required")
+	}
+	if c.Audit.CheckpointInterval <= 0 {
+		fail("audit.checkpoint_interval", "must be positive")
+	}
+
+	if !slices.Contains(tracingExporters, c.Tracing.Exporter) {
+		fail("tracing.exporter", "must be one of %v, got %q", tracingExporters, c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("tracing.sample_ratio", "must be between 0 and 1")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validateOrigin accepts "*" or a scheme and host without a path.
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return fmt.Errorf("%q is not an origin such as https://example.com", origin)
+	}
+	return nil
+}
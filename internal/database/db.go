@@ -1,46 +1,76 @@
 package database
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/aruncs31s/skvms/internal/config"
-	"github.com/aruncs31s/skvms/internal/model"
-	"gorm.io/driver/mysql"
+	"github.com/aruncs31s/skvms/internal/database/dialect"
+	"github.com/aruncs31s/skvms/internal/database/migrate"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/tracing"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+// Open connects to the configured database without touching its schema.
+func Open(cfg config.Config) (*gorm.DB, error) {
+	d, err := dialect.New(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(d.Open(cfg.Database), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	// Repositories build their dialect-specific SQL through dialect.Of(db)
+	if err := dialect.Register(db, d); err != nil {
+		return nil, err
+	}
+	// Trace queries run with the context of a traced request
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// New connects to the configured database for serving. With
+// database.auto_migrate its schema is migrated to the latest version,
+// otherwise a schema that is not up to date is refused.
 func New(cfg config.Config) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=Local", cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := Open(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(
-		&model.User{},
-		&model.Location{},
-		&model.Device{},
-		&model.DeviceDetails{},
-		&model.DeviceAssignment{},
-		&model.Reading{},
-		&model.AuditLog{},
-		&model.DeviceTypes{},
-		&model.Version{},
-		&model.Feature{},
-		&model.VersionFeature{},
-		&model.ConnectedDevice{},
-		&model.DeviceState{},
-		&model.DeviceStateHistory{},
-		&model.Location{},
-	); err != nil {
+	ctx := context.Background()
+	migrator, err := migrate.New(db, migrate.All)
+	if err != nil {
 		return nil, err
 	}
+	if cfg.Database.AutoMigrate {
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			logger.GetLogger().Info("Applied migration", zap.Stringer("migration", m))
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(pending) > 0 {
+			return nil, fmt.Errorf("%d migrations are pending, starting with %s; run the migrate up command", len(pending), pending[0])
+		}
+	}
 
-	// Fix existing devices with invalid device_state after migration
-	if err := db.Exec("UPDATE devices SET current_state = 1 WHERE current_state = 0").Error; err != nil {
-		return nil, fmt.Errorf("failed to update device states: %w", err)
+	d := dialect.Of(db)
+	if err := d.Prepare(db); err != nil {
+		return nil, fmt.Errorf("failed to prepare %s database: %w", d.Name(), err)
 	}
 	DB = db
 	return db, nil
@@ -0,0 +1,104 @@
+// Package dialect hides the SQL differences between the supported database
+// backends. Repositories build their non-portable SQL, such as time buckets,
+// calendar dates and window functions, through the Dialect of their *gorm.DB.
+package dialect
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/config"
+	"gorm.io/gorm"
+)
+
+// Dialect builds the SQL of one database backend. The helpers return SQL
+// fragments with the given column expressions inlined; the columns must come
+// from code, never from user input.
+type Dialect interface {
+	// Name is the config driver name: mysql, postgres or sqlite.
+	Name() string
+	// Open returns the GORM dialector connecting to the configured database.
+	Open(cfg config.DatabaseConfig) gorm.Dialector
+	// Prepare runs after the schema is migrated, e.g. to create hypertables.
+	Prepare(db *gorm.DB) error
+
+	// TimeBucket truncates a timestamp column to buckets of width, aligned on
+	// the Unix epoch, and yields the start of the bucket as a timestamp.
+	TimeBucket(column string, width time.Duration) string
+	// Date yields the calendar date of a timestamp column.
+	Date(column string) string
+	// FormatDateTime yields a timestamp column as "2006-01-02 15:04:05" text.
+	FormatDateTime(column string) string
+	// ContainsFold is a condition matching column against a LIKE pattern
+	// argument regardless of case.
+	ContainsFold(column string) string
+	// RunningAvg yields the average of column over the rows up to the
+	// current one in orderBy order.
+	RunningAvg(column string, orderBy string) string
+}
+
+// New returns the dialect of a configured database.
+func New(cfg config.DatabaseConfig) (Dialect, error) {
+	switch cfg.Driver {
+	case config.DriverMySQL, "":
+		return MySQL{}, nil
+	case config.DriverPostgres:
+		return Postgres{Timescale: cfg.Timescale}, nil
+	case config.DriverSQLite:
+		return SQLite{}, nil
+	}
+	return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+}
+
+// pluginName keys the dialect among the GORM plugins of a database.
+const pluginName = "skvms:dialect"
+
+// plugin stores a Dialect with the *gorm.DB so that repositories, which only
+// hold the *gorm.DB, can find it.
+type plugin struct {
+	Dialect
+}
+
+func (plugin) Name() string {
+	return pluginName
+}
+
+func (plugin) Initialize(*gorm.DB) error {
+	return nil
+}
+
+// Register attaches d to db for Of.
+func Register(db *gorm.DB, d Dialect) error {
+	return db.Use(plugin{d})
+}
+
+// Of returns the dialect registered with db, or else the one matching its
+// GORM dialector.
+func Of(db *gorm.DB) Dialect {
+	if p, ok := db.Config.Plugins[pluginName].(plugin); ok {
+		return p.Dialect
+	}
+	switch db.Dialector.Name() {
+	case "postgres":
+		return Postgres{}
+	case "sqlite":
+		return SQLite{}
+	}
+	return MySQL{}
+}
+
+// standard holds the helpers written the same in every dialect.
+type standard struct{}
+
+func (standard) RunningAvg(column string, orderBy string) string {
+	return fmt.Sprintf("AVG(%s) OVER (ORDER BY %s ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW)", column, orderBy)
+}
+
+func (standard) Prepare(*gorm.DB) error {
+	return nil
+}
+
+// seconds returns a bucket width in whole seconds, at least one.
+func seconds(width time.Duration) int64 {
+	return max(int64(width/time.Second), 1)
+}
@@ -0,0 +1,46 @@
+package dialect
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/config"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// MySQL is the dialect of MySQL 8 and MariaDB 10.2 or later.
+type MySQL struct {
+	standard
+}
+
+func (MySQL) Name() string {
+	return config.DriverMySQL
+}
+
+func (MySQL) Open(cfg config.DatabaseConfig) gorm.Dialector {
+	port := cfg.Port
+	if port == "" {
+		port = "3306"
+	}
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=Local", cfg.User, cfg.Password, cfg.Host, port, cfg.Name)
+	return mysql.Open(dsn)
+}
+
+func (MySQL) TimeBucket(column string, width time.Duration) string {
+	n := seconds(width)
+	return fmt.Sprintf("FROM_UNIXTIME(FLOOR(UNIX_TIMESTAMP(%s) / %d) * %d)", column, n, n)
+}
+
+func (MySQL) Date(column string) string {
+	return fmt.Sprintf("DATE(%s)", column)
+}
+
+func (MySQL) FormatDateTime(column string) string {
+	return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:%%i:%%s')", column)
+}
+
+// ContainsFold relies on the case-insensitive collation MySQL uses by default.
+func (MySQL) ContainsFold(column string) string {
+	return column + " LIKE ?"
+}
@@ -0,0 +1,92 @@
+package dialect
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/config"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Postgres is the dialect of PostgreSQL 12 or later. With Timescale the
+// readings table is a TimescaleDB hypertable and buckets use time_bucket.
+type Postgres struct {
+	standard
+	Timescale bool
+}
+
+func (Postgres) Name() string {
+	return config.DriverPostgres
+}
+
+func (Postgres) Open(cfg config.DatabaseConfig) gorm.Dialector {
+	port := cfg.Port
+	if port == "" {
+		port = "5432"
+	}
+	query := url.Values{}
+	if cfg.SSLMode != "" {
+		query.Set("sslmode", cfg.SSLMode)
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, port),
+		Path:     "/" + cfg.Name,
+		RawQuery: query.Encode(),
+	}
+	return postgres.Open(dsn.String())
+}
+
+// Prepare turns readings into a hypertable partitioned by created_at. The
+// partitioning column has to be part of every unique index, so the primary
+// key is widened to (id, created_at) first.
+func (p Postgres) Prepare(db *gorm.DB) error {
+	if !p.Timescale {
+		return nil
+	}
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS timescaledb").Error; err != nil {
+		return fmt.Errorf("enable timescaledb: %w", err)
+	}
+
+	var exists bool
+	err := db.Raw(`SELECT EXISTS (
+		SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = 'readings'
+	)`).Scan(&exists).Error
+	if err != nil || exists {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE readings DROP CONSTRAINT IF EXISTS readings_pkey").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("ALTER TABLE readings ADD PRIMARY KEY (id, created_at)").Error; err != nil {
+			return err
+		}
+		return tx.Exec(`SELECT create_hypertable('readings', 'created_at',
+			if_not_exists => TRUE, migrate_data => TRUE)`).Error
+	})
+}
+
+func (p Postgres) TimeBucket(column string, width time.Duration) string {
+	n := seconds(width)
+	if p.Timescale {
+		return fmt.Sprintf("time_bucket(INTERVAL '%d seconds', %s)", n, column)
+	}
+	return fmt.Sprintf("TO_TIMESTAMP(FLOOR(EXTRACT(EPOCH FROM %s) / %d) * %d)", column, n, n)
+}
+
+func (Postgres) Date(column string) string {
+	return fmt.Sprintf("CAST(%s AS DATE)", column)
+}
+
+func (Postgres) FormatDateTime(column string) string {
+	return fmt.Sprintf("TO_CHAR(%s, 'YYYY-MM-DD HH24:MI:SS')", column)
+}
+
+func (Postgres) ContainsFold(column string) string {
+	return column + " ILIKE ?"
+}
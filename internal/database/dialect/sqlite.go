@@ -0,0 +1,58 @@
+package dialect
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/config"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// SQLite is the dialect of SQLite 3.25 or later, for edge gateways and
+// tests. The driver uses cgo, so SQLite needs a build with CGO_ENABLED=1.
+//
+// Timestamps are stored as UTC text and read back in the server's local
+// time, which the helpers convert them to as well, like the MySQL
+// connection does.
+type SQLite struct {
+	standard
+}
+
+func (SQLite) Name() string {
+	return config.DriverSQLite
+}
+
+// Open enables foreign keys, and WAL with a busy timeout so that readers do
+// not block the writer.
+func (SQLite) Open(cfg config.DatabaseConfig) gorm.Dialector {
+	if dir := filepath.Dir(cfg.Path); dir != "." {
+		// The driver creates the file but not its directory; a failure
+		// surfaces when connecting
+		_ = os.MkdirAll(dir, 0755)
+	}
+	return sqlite.New(sqlite.Config{
+		DriverName: sqliteDriverName,
+		DSN:        cfg.Path + "?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_loc=auto",
+	})
+}
+
+func (SQLite) TimeBucket(column string, width time.Duration) string {
+	n := seconds(width)
+	return fmt.Sprintf("DATETIME(CAST(STRFTIME('%%s', %s) AS INTEGER) / %d * %d, 'unixepoch', 'localtime')", column, n, n)
+}
+
+func (SQLite) Date(column string) string {
+	return fmt.Sprintf("DATE(%s, 'localtime')", column)
+}
+
+func (SQLite) FormatDateTime(column string) string {
+	return fmt.Sprintf("STRFTIME('%%Y-%%m-%%d %%H:%%M:%%S', %s, 'localtime')", column)
+}
+
+// ContainsFold relies on LIKE ignoring the case of ASCII letters in SQLite.
+func (SQLite) ContainsFold(column string) string {
+	return column + " LIKE ?"
+}
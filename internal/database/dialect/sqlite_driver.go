@@ -0,0 +1,49 @@
+package dialect
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriverName is the SQLite driver storing timestamps in UTC.
+const sqliteDriverName = "sqlite3_utc"
+
+func init() {
+	sql.Register(sqliteDriverName, utcDriver{&sqlite3.SQLiteDriver{}})
+}
+
+// utcDriver is the SQLite driver with every timestamp argument converted to
+// UTC. SQLite keeps timestamps as text, so they only compare correctly when
+// they are all written in the same zone.
+type utcDriver struct {
+	*sqlite3.SQLiteDriver
+}
+
+func (d utcDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return utcConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+type utcConn struct {
+	*sqlite3.SQLiteConn
+}
+
+// CheckNamedValue converts arguments like database/sql does by default, then
+// moves timestamps to UTC.
+func (c utcConn) CheckNamedValue(nv *driver.NamedValue) error {
+	value, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC()
+	}
+	nv.Value = value
+	return nil
+}
@@ -0,0 +1,69 @@
+package dialect
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// TimeSerializer is the name of the GORM serializer for time.Time fields
+// scanned from TimeBucket or Date. SQLite yields those as text, which
+// database/sql cannot scan into a time.Time; tag such fields with
+// `gorm:"serializer:dbtime"`.
+const TimeSerializer = "dbtime"
+
+// timeLayouts are the text forms of timestamps produced by the dialects.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	time.DateOnly,
+}
+
+func init() {
+	schema.RegisterSerializer(TimeSerializer, timeSerializer{})
+}
+
+// timeSerializer reads timestamps given as time.Time or as text in the
+// server's local time.
+type timeSerializer struct{}
+
+func (timeSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) error {
+	var t time.Time
+	switch v := dbValue.(type) {
+	case nil:
+	case time.Time:
+		t = v
+	case []byte:
+		parsed, err := parseTime(string(v))
+		if err != nil {
+			return err
+		}
+		t = parsed
+	case string:
+		parsed, err := parseTime(v)
+		if err != nil {
+			return err
+		}
+		t = parsed
+	default:
+		return fmt.Errorf("cannot scan %T into %s", dbValue, field.Name)
+	}
+	return field.Set(ctx, dst, t)
+}
+
+func (timeSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue any) (any, error) {
+	return fieldValue, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", s)
+}
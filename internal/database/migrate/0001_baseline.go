@@ -0,0 +1,406 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// baselineModels are the models of the schema the migrations start from.
+// Databases created before versioned migrations already have it, so Up has
+// to succeed on them too.
+//
+// They are frozen copies of the models at the time of the baseline, so
+// later changes to the models do not change what the baseline creates. The
+// type names match the models' because GORM derives the join table columns
+// of many2many relations from them.
+var baselineModels = []any{
+	&user{},
+	&location{},
+	&device{},
+	&deviceDetails{},
+	&deviceAssignment{},
+	&reading{},
+	&auditLog{},
+	&auditCheckpoint{},
+	&deviceTypes{},
+	&boardProfile{},
+	&wiFiProfile{},
+	&deviceFirmware{},
+	&version{},
+	&feature{},
+	&versionFeature{},
+	&featureOverride{},
+	&reportDefinition{},
+	&reportRun{},
+	&exportTemplate{},
+	&exportTemplateVersion{},
+	&importJob{},
+	&connectedDevice{},
+	&deviceState{},
+	&deviceStateHistory{},
+}
+
+var baseline = Migration{
+	Version: 1,
+	Name:    "baseline",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(baselineModels...)
+	},
+	Down: func(tx *gorm.DB) error {
+		// DropTable orders the tables by their foreign keys
+		return tx.Migrator().DropTable(baselineModels...)
+	},
+}
+
+type user struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement"`
+	LocationID  *uint     `gorm:"column:location_id"`
+	Name        string    `gorm:"column:name"`
+	Username    string    `gorm:"column:username;unique"`
+	Email       string    `gorm:"column:email"`
+	Password    string    `gorm:"column:passsword;not null"`
+	Role        string    `gorm:"column:role;default:'user'"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime"`
+	CreatedByID *uint     `gorm:"column:created_by"`
+	UpdatedBy   uint      `gorm:"column:updated_by"`
+	Devices     []device  `gorm:"foreignKey:CreatedBy;references:ID"`
+}
+
+func (user) TableName() string { return "users" }
+
+type location struct {
+	ID          uint           `gorm:"primaryKey;column:id"`
+	Code        string         `gorm:"column:code;type:varchar(50);unique;not null"`
+	Name        string         `gorm:"column:name;type:varchar(255);not null"`
+	Description string         `gorm:"column:description;type:text"`
+	Latitude    float64        `gorm:"column:latitude;type:decimal(10,8)"`
+	Longitude   float64        `gorm:"column:longitude;type:decimal(11,8)"`
+	State       string         `gorm:"column:state;type:varchar(100)"`
+	City        string         `gorm:"column:city;type:varchar(100)"`
+	PinCode     string         `gorm:"column:pin_code;type:varchar(20)"`
+	CreatedAt   time.Time      `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   time.Time      `gorm:"column:updated_at;autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt `gorm:"column:deleted_at;index"`
+}
+
+func (location) TableName() string { return "locations" }
+
+type device struct {
+	ID               uint              `gorm:"column:id;primaryKey;autoIncrement"`
+	Name             string            `gorm:"column:name"`
+	DeviceTypeID     uint              `gorm:"column:device_type"`
+	VersionID        *uint             `gorm:"column:version_id;index;default:1"`
+	CurrentState     uint              `gorm:"column:current_state;index"`
+	Details          deviceDetails     `gorm:"foreignKey:DeviceID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Assignment       deviceAssignment  `gorm:"foreignKey:DeviceID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	DeviceType       deviceTypes       `gorm:"foreignKey:DeviceTypeID;references:ID"`
+	Version          version           `gorm:"foreignKey:VersionID;references:ID"`
+	Readings         []reading         `gorm:"foreignKey:DeviceID;references:ID"`
+	ConnectedDevices []connectedDevice `gorm:"foreignKey:ParentID;references:ID"`
+	DeviceState      deviceState       `gorm:"foreignKey:CurrentState;references:ID"`
+	CreatedBy        uint              `gorm:"column:created_by"`
+	UpdatedBy        uint              `gorm:"column:updated_by"`
+	ImportJobID      *uint             `gorm:"column:import_job_id;index"`
+	CreatedAt        time.Time         `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt        time.Time         `gorm:"column:updated_at;autoUpdateTime"`
+	User             user              `gorm:"foreignKey:CreatedBy;references:ID;constraint:-"`
+	Deleted          gorm.DeletedAt    `gorm:"column:deleted_at;index;default:null"`
+}
+
+func (device) TableName() string { return "devices" }
+
+type deviceDetails struct {
+	ID         uint       `gorm:"column:id;primaryKey;autoIncrement"`
+	DeviceID   uint       `gorm:"column:device_id;index;not null"`
+	IPAddress  string     `gorm:"column:ip_address;index"`
+	MACAddress string     `gorm:"column:mac_address"`
+	LastSeenAt *time.Time `gorm:"column:last_seen_at"`
+}
+
+func (deviceDetails) TableName() string { return "device_details" }
+
+type deviceAssignment struct {
+	ID           uint       `gorm:"column:id;primaryKey;autoIncrement"`
+	LocationID   uint       `gorm:"column:location_id;index;not null"`
+	DeviceID     uint       `gorm:"column:device_id;index;not null"`
+	AssignedAt   time.Time  `gorm:"column:assigned_at;not null"`
+	UnassignedAt *time.Time `gorm:"column:unassigned_at"`
+}
+
+func (deviceAssignment) TableName() string { return "device_assignments" }
+
+type reading struct {
+	ID          uint      `gorm:"column:id;primaryKey;autoIncrement"`
+	DeviceID    uint      `gorm:"column:device_id;index;not null"`
+	Voltage     float64   `gorm:"column:voltage"`
+	Current     float64   `gorm:"column:current"`
+	CreatedAt   time.Time `gorm:"column:created_at;index;autoCreateTime"`
+	Device      device    `gorm:"foreignKey:DeviceID;references:ID"`
+	ImportJobID *uint     `gorm:"column:import_job_id;index"`
+}
+
+func (reading) TableName() string { return "readings" }
+
+type auditLog struct {
+	ID           uint      `gorm:"column:id;primaryKey;autoIncrement"`
+	UserID       uint      `gorm:"column:user_id;index"`
+	Username     string    `gorm:"column:username"`
+	Action       string    `gorm:"column:action;index"`
+	Details      string    `gorm:"column:details"`
+	IPAddress    string    `gorm:"column:ip_address;index"`
+	DeviceID     *uint     `gorm:"column:device_id;index"`
+	ResourceType string    `gorm:"column:resource_type;type:varchar(50);index:idx_audit_resource"`
+	ResourceID   string    `gorm:"column:resource_id;type:varchar(64);index:idx_audit_resource"`
+	Changes      string    `gorm:"column:changes;type:text"`
+	RequestID    string    `gorm:"column:request_id;type:varchar(64);index"`
+	Status       string    `gorm:"column:status;type:varchar(20);index;default:success"`
+	StatusCode   int       `gorm:"column:status_code"`
+	Method       string    `gorm:"column:method;type:varchar(10)"`
+	Path         string    `gorm:"column:path;type:varchar(255)"`
+	EventID      string    `gorm:"column:event_id;type:varchar(32);index"`
+	Seq          *uint64   `gorm:"column:seq;uniqueIndex"`
+	PrevHash     string    `gorm:"column:prev_hash;type:varchar(64)"`
+	Hash         string    `gorm:"column:hash;type:varchar(64)"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime;index"`
+	Device       *device   `gorm:"foreignKey:DeviceID"`
+	User         *user     `gorm:"foreignKey:UserID"`
+}
+
+func (auditLog) TableName() string { return "audit_logs" }
+
+type auditCheckpoint struct {
+	ID        uint      `gorm:"column:id;primaryKey;autoIncrement"`
+	Seq       uint64    `gorm:"column:seq;index"`
+	Hash      string    `gorm:"column:hash;type:varchar(64)"`
+	KeyID     string    `gorm:"column:key_id;type:varchar(16)"`
+	Signature string    `gorm:"column:signature;type:varchar(128)"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (auditCheckpoint) TableName() string { return "audit_checkpoints" }
+
+type deviceTypes struct {
+	ID           uint      `gorm:"column:id;primaryKey;autoIncrement"`
+	Name         string    `gorm:"column:name;uniqueIndex"`
+	HardwareType uint8     `gorm:"column:hardware_type"`
+	CreatedBy    uint      `gorm:"column:created_by"`
+	UpdatedBy    uint      `gorm:"column:updated_by"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (deviceTypes) TableName() string { return "device_types" }
+
+type boardProfile struct {
+	ID              uint        `gorm:"column:id;primaryKey;autoIncrement"`
+	DeviceTypeID    uint        `gorm:"column:device_type_id;uniqueIndex;not null"`
+	FQBN            string      `gorm:"column:fqbn;type:varchar(255);not null"`
+	PlatformIOEnv   string      `gorm:"column:platformio_env;type:varchar(100)"`
+	PartitionScheme string      `gorm:"column:partition_scheme;type:varchar(100)"`
+	FlashSize       string      `gorm:"column:flash_size;type:varchar(20)"`
+	Libraries       string      `gorm:"column:libraries;type:text"`
+	CreatedBy       uint        `gorm:"column:created_by"`
+	UpdatedBy       uint        `gorm:"column:updated_by"`
+	CreatedAt       time.Time   `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt       time.Time   `gorm:"column:updated_at;autoUpdateTime"`
+	DeviceType      deviceTypes `gorm:"foreignKey:DeviceTypeID;references:ID"`
+}
+
+func (boardProfile) TableName() string { return "board_profiles" }
+
+type wiFiProfile struct {
+	ID                 uint      `gorm:"column:id;primaryKey;autoIncrement"`
+	LocationID         uint      `gorm:"column:location_id;index;not null"`
+	Name               string    `gorm:"column:name;type:varchar(100);not null"`
+	SSID               string    `gorm:"column:ssid;type:varchar(64);not null"`
+	PasswordCiphertext string    `gorm:"column:password_ciphertext;type:text;not null"`
+	CreatedBy          uint      `gorm:"column:created_by"`
+	UpdatedBy          uint      `gorm:"column:updated_by"`
+	CreatedAt          time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt          time.Time `gorm:"column:updated_at;autoUpdateTime"`
+	Location           location  `gorm:"foreignKey:LocationID;references:ID"`
+}
+
+func (wiFiProfile) TableName() string { return "wifi_profiles" }
+
+type deviceFirmware struct {
+	ID         uint       `gorm:"column:id;primaryKey;autoIncrement"`
+	DeviceID   uint       `gorm:"column:device_id;uniqueIndex;not null"`
+	Version    string     `gorm:"column:version;type:varchar(100);not null"`
+	BuildID    string     `gorm:"column:build_id;type:varchar(100)"`
+	Checksum   string     `gorm:"column:checksum;type:varchar(64)"`
+	LastEvent  string     `gorm:"column:last_event;type:varchar(20)"`
+	BootedAt   *time.Time `gorm:"column:booted_at"`
+	ReportedAt time.Time  `gorm:"column:reported_at;index"`
+	Device     device     `gorm:"foreignKey:DeviceID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (deviceFirmware) TableName() string { return "device_firmware" }
+
+type version struct {
+	ID                uint   `gorm:"column:id;primaryKey;autoIncrement"`
+	Name              string `gorm:"column:name;unique;not null"`
+	DeviceID          uint   `gorm:"column:device_id;not null;index"`
+	PreviousVersionID *uint  `gorm:"column:previous_version_id;index;default:null"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Features          []feature `gorm:"many2many:version_features;"`
+	PreviousVersion   *version  `gorm:"foreignKey:PreviousVersionID"`
+}
+
+func (version) TableName() string { return "versions" }
+
+type feature struct {
+	ID          uint      `gorm:"column:id;primaryKey;autoIncrement"`
+	FeatureName string    `gorm:"column:feature_name;unique;not null"`
+	Enabled     bool      `gorm:"column:enabled;not null;default:false"`
+	Versions    []version `gorm:"many2many:version_features;"`
+}
+
+func (feature) TableName() string { return "features" }
+
+type versionFeature struct {
+	VersionID uint  `gorm:"column:version_id;primaryKey;not null"`
+	FeatureID uint  `gorm:"column:feature_id;primaryKey;not null"`
+	Enabled   *bool `gorm:"column:enabled;default:null"`
+}
+
+func (versionFeature) TableName() string { return "version_features" }
+
+type featureOverride struct {
+	ID        uint      `gorm:"column:id;primaryKey;autoIncrement"`
+	FeatureID uint      `gorm:"column:feature_id;not null;uniqueIndex:idx_feature_override_scope"`
+	Scope     string    `gorm:"column:scope;type:varchar(20);not null;uniqueIndex:idx_feature_override_scope"`
+	ScopeID   uint      `gorm:"column:scope_id;not null;uniqueIndex:idx_feature_override_scope"`
+	Enabled   bool      `gorm:"column:enabled;not null"`
+	CreatedBy uint      `gorm:"column:created_by"`
+	UpdatedBy uint      `gorm:"column:updated_by"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+	Feature   feature   `gorm:"foreignKey:FeatureID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (featureOverride) TableName() string { return "feature_overrides" }
+
+type reportDefinition struct {
+	ID         uint       `gorm:"column:id;primaryKey;autoIncrement"`
+	Name       string     `gorm:"column:name;type:varchar(255);not null"`
+	DataType   string     `gorm:"column:data_type;type:varchar(50);not null"`
+	DeviceID   *uint      `gorm:"column:device_id;index"`
+	Period     string     `gorm:"column:period;type:varchar(20)"`
+	Format     string     `gorm:"column:format;type:varchar(20);not null"`
+	TemplateID *uint      `gorm:"column:template_id"`
+	Schedule   string     `gorm:"column:schedule;type:varchar(100);not null"`
+	Recipients string     `gorm:"column:recipients;type:text"`
+	Enabled    bool       `gorm:"column:enabled;not null;default:true"`
+	NextRunAt  *time.Time `gorm:"column:next_run_at;index"`
+	LastRunAt  *time.Time `gorm:"column:last_run_at"`
+	CreatedBy  uint       `gorm:"column:created_by"`
+	UpdatedBy  uint       `gorm:"column:updated_by"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (reportDefinition) TableName() string { return "report_definitions" }
+
+type reportRun struct {
+	ID             uint             `gorm:"column:id;primaryKey;autoIncrement"`
+	DefinitionID   uint             `gorm:"column:definition_id;index;not null"`
+	Trigger        string           `gorm:"column:trigger_type;type:varchar(20)"`
+	Status         string           `gorm:"column:status;type:varchar(20);not null"`
+	Error          string           `gorm:"column:error;type:text"`
+	FilePath       string           `gorm:"column:file_path;type:varchar(512)"`
+	FileSize       int64            `gorm:"column:file_size"`
+	PeriodStart    time.Time        `gorm:"column:period_start"`
+	PeriodEnd      time.Time        `gorm:"column:period_end"`
+	DeliveryStatus string           `gorm:"column:delivery_status;type:varchar(20)"`
+	DeliveryError  string           `gorm:"column:delivery_error;type:text"`
+	Recipients     string           `gorm:"column:recipients;type:text"`
+	StartedAt      time.Time        `gorm:"column:started_at;index"`
+	FinishedAt     *time.Time       `gorm:"column:finished_at"`
+	Definition     reportDefinition `gorm:"foreignKey:DefinitionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (reportRun) TableName() string { return "report_runs" }
+
+type exportTemplate struct {
+	ID            uint      `gorm:"column:id;primaryKey;autoIncrement"`
+	Name          string    `gorm:"column:name;type:varchar(255);not null"`
+	Description   string    `gorm:"column:description;type:text"`
+	DataType      string    `gorm:"column:data_type;type:varchar(50);not null;index"`
+	LatestVersion int       `gorm:"column:latest_version;not null"`
+	IsDefault     bool      `gorm:"column:is_default;not null;default:false"`
+	CreatedBy     uint      `gorm:"column:created_by"`
+	UpdatedBy     uint      `gorm:"column:updated_by"`
+	CreatedAt     time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (exportTemplate) TableName() string { return "export_templates" }
+
+type exportTemplateVersion struct {
+	ID         uint           `gorm:"column:id;primaryKey;autoIncrement"`
+	TemplateID uint           `gorm:"column:template_id;not null;uniqueIndex:idx_export_template_version"`
+	Version    int            `gorm:"column:version;not null;uniqueIndex:idx_export_template_version"`
+	Content    string         `gorm:"column:content;size:16777215;not null"`
+	Comment    string         `gorm:"column:comment;type:varchar(255)"`
+	CreatedBy  uint           `gorm:"column:created_by"`
+	CreatedAt  time.Time      `gorm:"column:created_at;autoCreateTime"`
+	Template   exportTemplate `gorm:"foreignKey:TemplateID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (exportTemplateVersion) TableName() string { return "export_template_versions" }
+
+type importJob struct {
+	ID           uint       `gorm:"column:id;primaryKey;autoIncrement"`
+	DataType     string     `gorm:"column:data_type;type:varchar(50);not null"`
+	Format       string     `gorm:"column:format;type:varchar(20);not null"`
+	FileName     string     `gorm:"column:file_name;type:varchar(255)"`
+	Mapping      string     `gorm:"column:mapping;type:text"`
+	Status       string     `gorm:"column:status;type:varchar(20);not null;index"`
+	Error        string     `gorm:"column:error;type:text"`
+	TotalRows    int        `gorm:"column:total_rows"`
+	InvalidRows  int        `gorm:"column:invalid_rows"`
+	ImportedRows int        `gorm:"column:imported_rows"`
+	Errors       string     `gorm:"column:errors;size:16777215"`
+	CreatedBy    uint       `gorm:"column:created_by"`
+	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime"`
+	FinishedAt   *time.Time `gorm:"column:finished_at"`
+	RolledBackBy *uint      `gorm:"column:rolled_back_by"`
+	RolledBackAt *time.Time `gorm:"column:rolled_back_at"`
+}
+
+func (importJob) TableName() string { return "import_jobs" }
+
+type connectedDevice struct {
+	ParentID    uint           `gorm:"column:parent_id;index;not null"`
+	ChildID     uint           `gorm:"column:child_id;index;not null"`
+	Deleted     gorm.DeletedAt `gorm:"index"`
+	ChildDevice device         `gorm:"foreignKey:ChildID;references:ID"`
+}
+
+func (connectedDevice) TableName() string { return "connected_devices" }
+
+type deviceState struct {
+	ID        uint      `gorm:"column:id;primaryKey"`
+	Name      string    `gorm:"column:name;unique;index;size:100"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (deviceState) TableName() string { return "device_states" }
+
+type deviceStateHistory struct {
+	ID           uint        `gorm:"column:id;primaryKey;autoIncrement"`
+	DeviceID     uint        `gorm:"column:device_id"`
+	CausedAction uint8       `gorm:"column:caused_action"`
+	StateID      uint        `gorm:"column:state_id"`
+	CreatedBy    uint        `gorm:"column:created_by"`
+	CreatedAt    time.Time   `gorm:"column:created_at;autoCreateTime"`
+	DeviceState  deviceState `gorm:"foreignKey:StateID;references:ID"`
+	Device       device      `gorm:"foreignKey:DeviceID;references:ID"`
+	User         user        `gorm:"foreignKey:CreatedBy;references:ID;constraint:-"`
+}
+
+func (deviceStateHistory) TableName() string { return "device_state_history" }
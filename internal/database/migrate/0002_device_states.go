@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/config"
+	"github.com/aruncs31s/skvms/internal/database/dialect"
+	"gorm.io/gorm"
+)
+
+// deviceStates are referenced by ID from code, e.g. new devices start
+// Active.
+var deviceStates = Migration{
+	Version: 2,
+	Name:    "device_states",
+	Up: func(tx *gorm.DB) error {
+		states := []struct {
+			ID   uint
+			Name string
+		}{
+			{1, "Active"},
+			{2, "Inactive"},
+			{3, "Maintenance"},
+			{4, "Decommissioned"},
+			{5, "Initialized"},
+		}
+		for _, state := range states {
+			var count int64
+			if err := tx.Table("device_states").Where("id = ?", state.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			if err := tx.Exec("INSERT INTO device_states (id, name, created_at) VALUES (?, ?, ?)",
+				state.ID, state.Name, time.Now()).Error; err != nil {
+				return err
+			}
+		}
+		// Explicit IDs do not advance a PostgreSQL sequence
+		if dialect.Of(tx).Name() == config.DriverPostgres {
+			return tx.Exec("SELECT setval(pg_get_serial_sequence('device_states', 'id'), (SELECT MAX(id) FROM device_states))").Error
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Exec("DELETE FROM device_states WHERE id BETWEEN 1 AND 5").Error
+	},
+}
@@ -0,0 +1,17 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// defaultDeviceState moves devices created without a state, which used to
+// be stored as 0, to Active.
+var defaultDeviceState = Migration{
+	Version: 3,
+	Name:    "default_device_state",
+	Up: func(tx *gorm.DB) error {
+		return tx.Exec("UPDATE devices SET current_state = 1 WHERE current_state = 0").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		// Which devices had no state is not recorded, they stay Active
+		return nil
+	},
+}
@@ -0,0 +1,300 @@
+// Package migrate versions the database schema. Each Migration moves the
+// schema, or its data, one version up and back down; the versions applied
+// to a database are recorded in its schema_migrations table.
+//
+// Migrations are never edited once released. The baseline migrates frozen
+// copies of the models of its time, later changes come as new migrations
+// appended to All.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/config"
+	"github.com/aruncs31s/skvms/internal/database/dialect"
+	"gorm.io/gorm"
+)
+
+// ErrIrreversible is returned when rolling back a migration without Down.
+var ErrIrreversible = errors.New("migration cannot be rolled back")
+
+// Migration is one version of the schema.
+type Migration struct {
+	// Version orders the migrations, starting at 1
+	Version uint
+	Name    string
+	// Up moves the schema from the previous version to this one
+	Up func(tx *gorm.DB) error
+	// Down undoes Up; nil when the migration is irreversible
+	Down func(tx *gorm.DB) error
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Record is the history row of an applied migration.
+type Record struct {
+	Version   uint      `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:255;not null"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+}
+
+func (Record) TableName() string {
+	return "schema_migrations"
+}
+
+// Status is a known migration and whether it is applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and rolls back migrations on a database. Every migration
+// runs in a transaction with its history row, so a failed migration leaves
+// no record; MySQL commits DDL statements implicitly, though, so a migration
+// failing halfway through there has to be repaired by hand.
+//
+// On MySQL and PostgreSQL the migrations run under a database lock, so
+// replicas starting together with database.auto_migrate apply them once.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New returns a Migrator of migrations, which must have distinct versions.
+func New(db *gorm.DB, migrations []Migration) (*Migrator, error) {
+	sorted := slices.Clone(migrations)
+	slices.SortFunc(sorted, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	for i, m := range sorted {
+		if m.Version == 0 || m.Up == nil {
+			return nil, fmt.Errorf("migration %s: version and Up are required", m)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("migrations %s and %s share a version", sorted[i-1], m)
+		}
+	}
+	return &Migrator{db: db, migrations: sorted}, nil
+}
+
+// Status lists the known migrations in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		record, ok := applied[migration.Version]
+		statuses[i] = Status{Migration: migration, Applied: ok, AppliedAt: record.AppliedAt}
+	}
+	return statuses, nil
+}
+
+// Version returns the highest applied version, 0 for an empty database.
+func (m *Migrator) Version(ctx context.Context) (uint, error) {
+	applied, err := m.applied(m.db.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	var version uint
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// Pending lists the migrations Up would apply.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration and returns the ones applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.latest())
+}
+
+// Down rolls back the last steps applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var ran []Migration
+	err := m.locked(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		var target uint
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; !ok {
+				continue
+			}
+			if steps == 0 {
+				target = m.migrations[i].Version
+				break
+			}
+			steps--
+		}
+		ran, err = m.to(db, target)
+		return err
+	})
+	return ran, err
+}
+
+// To applies or rolls back migrations until version is the last one
+// applied; 0 rolls back everything. It returns the migrations run, in the
+// order they ran, also when one of them fails.
+func (m *Migrator) To(ctx context.Context, version uint) ([]Migration, error) {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(mg Migration) bool { return mg.Version == version }) {
+		return nil, fmt.Errorf("unknown migration version %d", version)
+	}
+	var ran []Migration
+	err := m.locked(ctx, func(db *gorm.DB) error {
+		var err error
+		ran, err = m.to(db, version)
+		return err
+	})
+	return ran, err
+}
+
+// migrationLock names the database lock held while migrations run.
+const migrationLock = "skvms:migrate"
+
+// locked runs fn on a single connection holding the migration lock. The
+// history is read under the lock, so a replica that waited for another one
+// finds its migrations applied. SQLite databases are not shared between
+// servers and go without.
+func (m *Migrator) locked(ctx context.Context, fn func(db *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		db := conn.WithContext(ctx)
+		// The lock belongs to the session; it is released even when ctx is
+		// done, before the connection goes back to the pool
+		release := conn.WithContext(context.WithoutCancel(ctx))
+		switch dialect.Of(db).Name() {
+		case config.DriverPostgres:
+			if err := db.Exec("SELECT pg_advisory_lock(hashtext(?))", migrationLock).Error; err != nil {
+				return fmt.Errorf("take migration lock: %w", err)
+			}
+			defer release.Exec("SELECT pg_advisory_unlock(hashtext(?))", migrationLock)
+		case config.DriverMySQL:
+			var locked *int
+			if err := db.Raw("SELECT GET_LOCK(?, -1)", migrationLock).Scan(&locked).Error; err != nil {
+				return fmt.Errorf("take migration lock: %w", err)
+			}
+			if locked == nil || *locked != 1 {
+				return errors.New("take migration lock: GET_LOCK failed")
+			}
+			defer release.Exec("SELECT RELEASE_LOCK(?)", migrationLock)
+		}
+		return fn(db)
+	})
+}
+
+// to runs the migrations of To on db, which holds the migration lock.
+func (m *Migrator) to(db *gorm.DB, version uint) ([]Migration, error) {
+	applied, err := m.applied(db)
+	if err != nil {
+		return nil, err
+	}
+	// A newer build has migrated the database, an older one must not touch it
+	for v, record := range applied {
+		if !slices.ContainsFunc(m.migrations, func(mg Migration) bool { return mg.Version == v }) {
+			return nil, fmt.Errorf("database has migration %04d_%s that this build does not know", v, record.Name)
+		}
+	}
+
+	var ran []Migration
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+			continue
+		}
+		if err := m.down(db, migration); err != nil {
+			return ran, err
+		}
+		ran = append(ran, migration)
+	}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+		if err := m.up(db, migration); err != nil {
+			return ran, err
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+func (m *Migrator) up(db *gorm.DB, migration Migration) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&Record{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate up %s: %w", migration, err)
+	}
+	return nil
+}
+
+func (m *Migrator) down(db *gorm.DB, migration Migration) error {
+	if migration.Down == nil {
+		return fmt.Errorf("migrate down %s: %w", migration, ErrIrreversible)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&Record{Version: migration.Version}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate down %s: %w", migration, err)
+	}
+	return nil
+}
+
+// applied returns the history rows by version, creating the history table
+// on first use.
+func (m *Migrator) applied(db *gorm.DB) (map[uint]Record, error) {
+	if err := db.AutoMigrate(&Record{}); err != nil {
+		return nil, fmt.Errorf("create migration history: %w", err)
+	}
+	var records []Record
+	if err := db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("read migration history: %w", err)
+	}
+	applied := make(map[uint]Record, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func (m *Migrator) latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
@@ -0,0 +1,130 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aruncs31s/skvms/internal/config"
+	"github.com/aruncs31s/skvms/internal/database"
+	"github.com/aruncs31s/skvms/internal/database/migrate"
+	"github.com/aruncs31s/skvms/internal/model"
+	"gorm.io/gorm"
+)
+
+func open(t *testing.T) *gorm.DB {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Database.Driver = config.DriverSQLite
+	cfg.Database.Path = filepath.Join(t.TempDir(), "skvms.db")
+	db, err := database.Open(cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "CGO_ENABLED=0") {
+			t.Skip("SQLite needs cgo:", err)
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := open(t)
+	var ran []string
+	step := func(name string) func(*gorm.DB) error {
+		return func(*gorm.DB) error {
+			ran = append(ran, name)
+			return nil
+		}
+	}
+	m, err := migrate.New(db, []migrate.Migration{
+		{Version: 1, Name: "one", Up: step("up 1"), Down: step("down 1")},
+		{Version: 2, Name: "two", Up: step("up 2"), Down: step("down 2")},
+		{Version: 3, Name: "three", Up: step("up 3")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.To(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := m.Pending(ctx); len(pending) != 1 || pending[0].Version != 3 {
+		t.Errorf("pending after To(2) = %v, want 0003_three", pending)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Down(ctx, 1); !errors.Is(err, migrate.ErrIrreversible) {
+		t.Errorf("Down over an irreversible migration: err = %v", err)
+	}
+	if version, _ := m.Version(ctx); version != 3 {
+		t.Errorf("version = %d, want 3", version)
+	}
+
+	want := "up 1,up 2,up 3"
+	if got := strings.Join(ran, ","); got != want {
+		t.Errorf("ran %s, want %s", got, want)
+	}
+}
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	m, err := migrate.New(open(t), migrate.All)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.To(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up after rolling everything back: %v", err)
+	}
+}
+
+// TestMigrationsCoverModels fails when a model gains a column that no
+// migration creates.
+func TestMigrationsCoverModels(t *testing.T) {
+	db := open(t)
+	m, err := migrate.New(db, migrate.All)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	models := []any{
+		&model.User{}, &model.Location{}, &model.Device{}, &model.DeviceDetails{},
+		&model.DeviceAssignment{}, &model.Reading{}, &model.AuditLog{}, &model.AuditCheckpoint{},
+		&model.DeviceTypes{}, &model.BoardProfile{}, &model.WiFiProfile{}, &model.DeviceFirmware{},
+		&model.Version{}, &model.Feature{}, &model.VersionFeature{}, &model.FeatureOverride{},
+		&model.ReportDefinition{}, &model.ReportRun{}, &model.ExportTemplate{}, &model.ExportTemplateVersion{},
+		&model.ImportJob{}, &model.ConnectedDevice{}, &model.DeviceState{}, &model.DeviceStateHistory{},
+	}
+	for _, value := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(value); err != nil {
+			t.Fatal(err)
+		}
+		if !db.Migrator().HasTable(value) {
+			t.Errorf("table %s is not migrated", stmt.Schema.Table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(value, field.DBName) {
+				t.Errorf("column %s.%s is not migrated", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package migrate
+
+// All is every migration of the schema, in version order. New migrations
+// are appended with the next version.
+var All = []Migration{
+	baseline,
+	deviceStates,
+	defaultDeviceState,
+}
@@ -11,6 +11,8 @@ import (
 	"gorm.io/gorm"
 )
 
+// Seed loads the default device types and board profiles, the admin user
+// and demo devices. It only runs when asked for with the seed command.
 func Seed(db *gorm.DB) error {
 	// if err := seedLocations(db); err != nil {
 	// 	return err
@@ -18,7 +20,7 @@ func Seed(db *gorm.DB) error {
 	if err := seedDeviceTypes(db); err != nil {
 		return err
 	}
-	if err := seedDeviceStates(db); err != nil {
+	if err := seedBoardProfiles(db); err != nil {
 		return err
 	}
 	if err := seedAdminUser(db); err != nil {
@@ -115,42 +117,39 @@ func seedDeviceTypes(db *gorm.DB) error {
 	return nil
 }
 
-/* ---------------- Device States ---------------- */
+/* ---------------- Board Profiles ---------------- */
 
-func seedDeviceStates(db *gorm.DB) error {
-	deviceStates := []model.DeviceState{
-		{
-			ID:   1,
-			Name: "Active",
-		},
-		{
-			ID:   2,
-			Name: "Inactive",
-		},
-		{
-			ID:   3,
-			Name: "Maintenance",
-		},
-		{
-			ID:   4,
-			Name: "Decommissioned",
+func seedBoardProfiles(db *gorm.DB) error {
+	profiles := map[string]model.BoardProfile{
+		"ESP8266 (NODEMCU) ": {
+			FQBN:          "esp8266:esp8266:nodemcuv2",
+			PlatformIOEnv: "nodemcuv2",
+			Libraries:     "ArduinoJson",
 		},
-		{
-			ID:   5,
-			Name: "Initialized",
+		"ESP32 (NODEMCU-32)": {
+			FQBN:            "esp32:esp32:esp32",
+			PlatformIOEnv:   "esp32dev",
+			PartitionScheme: "default",
+			FlashSize:       "4M",
+			Libraries:       "ArduinoJson",
 		},
 	}
 
-	for _, ds := range deviceStates {
-		var existing model.DeviceState
-		err := db.Where("id = ?", ds.ID).First(&existing).Error
-		if err == gorm.ErrRecordNotFound {
-			// Create new record with explicit ID
-			if err := db.Exec("INSERT INTO device_states (id, name, created_at) VALUES (?, ?, ?)",
-				ds.ID, ds.Name, time.Now()).Error; err != nil {
-				return err
+	for name, profile := range profiles {
+		var deviceType model.DeviceTypes
+		if err := db.Where("name = ?", name).First(&deviceType).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				continue
 			}
-		} else if err != nil {
+			return err
+		}
+		profile.DeviceTypeID = deviceType.ID
+		profile.CreatedBy = 1
+		if err := db.FirstOrCreate(
+			&model.BoardProfile{},
+			model.BoardProfile{DeviceTypeID: deviceType.ID},
+			profile,
+		).Error; err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,124 @@
+package dto
+
+import "time"
+
+// AuditEntry is a structured audit record. Before and After are snapshots
+// of the resource; only the fields that differ are stored.
+type AuditEntry struct {
+	UserID       uint
+	Username     string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Details      string
+	Before       interface{}
+	After        interface{}
+	IPAddress    string
+	// RequestID defaults to the request ID carried by the context
+	RequestID string
+	// Status defaults to success
+	Status     string
+	StatusCode int
+	Method     string
+	Path       string
+	DeviceID   *uint
+}
+
+// AuditLogFilter selects audit entries. Zero fields do not filter.
+type AuditLogFilter struct {
+	UserID       uint      `form:"user_id"`
+	Username     string    `form:"username"`
+	Action       string    `form:"action"`
+	ResourceType string    `form:"resource_type"`
+	ResourceID   string    `form:"resource_id"`
+	IPAddress    string    `form:"ip"`
+	Status       string    `form:"status"`
+	RequestID    string    `form:"request_id"`
+	From         time.Time `form:"-"`
+	To           time.Time `form:"-"`
+	// Cursor returns the entries older than the entry with this ID
+	Cursor uint `form:"cursor"`
+	Limit  int  `form:"limit"`
+}
+
+// AuditChange is the before and after value of a changed field.
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type AuditLogResponse struct {
+	ID           uint                   `json:"id"`
+	UserID       uint                   `json:"user_id"`
+	Username     string                 `json:"username"`
+	Action       string                 `json:"action"`
+	ResourceType string                 `json:"resource_type,omitempty"`
+	ResourceID   string                 `json:"resource_id,omitempty"`
+	Details      string                 `json:"details,omitempty"`
+	Changes      map[string]AuditChange `json:"changes,omitempty"`
+	IPAddress    string                 `json:"ip_address"`
+	RequestID    string                 `json:"request_id,omitempty"`
+	Status       string                 `json:"status"`
+	StatusCode   int                    `json:"status_code,omitempty"`
+	Method       string                 `json:"method,omitempty"`
+	Path         string                 `json:"path,omitempty"`
+	DeviceID     *uint                  `json:"device_id,omitempty"`
+	Seq          *uint64                `json:"seq,omitempty"`
+	Hash         string                 `json:"hash,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// AuditLogPage is one page of audit entries, newest first. NextCursor is
+// passed as cursor to fetch the next page; it is 0 on the last page.
+type AuditLogPage struct {
+	Logs       []AuditLogResponse `json:"logs"`
+	NextCursor uint               `json:"next_cursor,omitempty"`
+}
+
+// Kinds of AuditChainProblem.
+const (
+	// AuditChainGap is a missing sequence number
+	AuditChainGap = "gap"
+	// AuditChainBrokenLink is an entry whose prev_hash is not the hash of
+	// the entry before it
+	AuditChainBrokenLink = "broken_link"
+	// AuditChainModified is an entry whose content no longer matches its hash
+	AuditChainModified = "modified"
+	// AuditChainBadSignature is a checkpoint whose signature does not verify
+	AuditChainBadSignature = "bad_signature"
+	// AuditChainCheckpointMismatch is a checkpoint that does not match the
+	// hash of the entry it covers
+	AuditChainCheckpointMismatch = "checkpoint_mismatch"
+	// AuditChainTruncated is a checkpoint beyond the end of the chain
+	AuditChainTruncated = "truncated"
+)
+
+// AuditChainProblem is one finding of a chain verification.
+type AuditChainProblem struct {
+	Kind    string `json:"kind"`
+	Seq     uint64 `json:"seq"`
+	ID      uint   `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+// AuditChainVerification is the result of verifying the audit hash chain.
+// Problems lists the first findings; Valid is false if there are any.
+type AuditChainVerification struct {
+	Valid              bool                `json:"valid"`
+	FromSeq            uint64              `json:"from_seq"`
+	ToSeq              uint64              `json:"to_seq"`
+	Checked            int64               `json:"checked"`
+	CheckpointsChecked int                 `json:"checkpoints_checked"`
+	Pending            int                 `json:"pending"`
+	Problems           []AuditChainProblem `json:"problems"`
+}
+
+// AuditCheckpointResponse is a signed checkpoint of the audit chain.
+type AuditCheckpointResponse struct {
+	ID        uint      `json:"id"`
+	Seq       uint64    `json:"seq"`
+	Hash      string    `json:"hash"`
+	KeyID     string    `json:"key_id"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
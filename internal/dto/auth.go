@@ -8,3 +8,8 @@ type LoginRequest struct {
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
+
+// DeviceTokenRequest asks for a token a device posts its readings with.
+type DeviceTokenRequest struct {
+	DeviceID uint `json:"device_id" binding:"required"`
+}
@@ -4,3 +4,10 @@ type ListResponse[T any] struct {
 	List       []T   `json:"list"`
 	TotalCount int64 `json:"total_count"`
 }
+
+// ErrorResponse is the body of a failed request. Fields lists the invalid
+// parameters of a request that failed validation.
+type ErrorResponse struct {
+	Error  string            `json:"error"`
+	Fields []ValidationError `json:"fields,omitempty"`
+}
@@ -25,9 +25,12 @@ func NewControlRequest(action uint, command string) *ControlRequest {
 	}
 }
 
+// ValidationError is an invalid parameter of a request. In is where the
+// parameter is: path, query or body.
 type ValidationError struct {
-	Field   string
-	Message string
+	In      string `json:"in,omitempty"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 func (e *ValidationError) Error() string {
@@ -36,6 +39,7 @@ func (e *ValidationError) Error() string {
 
 func NewValidationError(field, message string) *ValidationError {
 	return &ValidationError{
+		In:      "body",
 		Field:   field,
 		Message: message,
 	}
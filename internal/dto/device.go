@@ -20,8 +20,9 @@ type MicrocontrollerDeviceView struct {
 	IP               string             `json:"ip_address"`
 	MAC              string             `json:"mac_address"`
 	Status           string             `json:"status"`
-	UsedBy           *string            `json:"used_by"` // Or Connected To this perticullar Solar Device
-	FirmwareVersion  string             `json:"firmware_version"`
+	UsedBy           *string            `json:"used_by"`          // Or Connected To this perticullar Solar Device
+	FirmwareVersion  string             `json:"firmware_version"` // Assigned version
+	ReportedVersion  string             `json:"reported_version,omitempty"`
 	ConncetedSensors []SensorDeviceView `json:"connected_sensors"`
 }
 
@@ -4,3 +4,22 @@ type CreateDeviceTypeRequest struct {
 	Name         string `json:"name" binding:"required"`
 	HardwareType uint   `json:"hardware_type" binding:"required"`
 }
+
+// BoardProfileRequest sets the firmware build profile of a device type.
+type BoardProfileRequest struct {
+	FQBN            string   `json:"fqbn" binding:"required"`
+	PlatformIOEnv   string   `json:"platformio_env"`
+	PartitionScheme string   `json:"partition_scheme"`
+	FlashSize       string   `json:"flash_size"`
+	Libraries       []string `json:"libraries"`
+}
+
+type BoardProfileResponse struct {
+	DeviceTypeID    uint     `json:"device_type_id"`
+	DeviceType      string   `json:"device_type"`
+	FQBN            string   `json:"fqbn"`
+	PlatformIOEnv   string   `json:"platformio_env"`
+	PartitionScheme string   `json:"partition_scheme"`
+	FlashSize       string   `json:"flash_size"`
+	Libraries       []string `json:"libraries"`
+}
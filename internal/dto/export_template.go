@@ -0,0 +1,59 @@
+package dto
+
+import "time"
+
+// ExportTemplateRequest uploads a new template to the library. DataType is
+// readings, devices or summary; Content is the HTML template source.
+type ExportTemplateRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	DataType    string `json:"data_type" binding:"required"`
+	Content     string `json:"content" binding:"required"`
+	Comment     string `json:"comment"`
+}
+
+// UpdateExportTemplateRequest changes a template's details. Its source is
+// changed by uploading a new version.
+type UpdateExportTemplateRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// ExportTemplateVersionRequest uploads a new version of a template.
+type ExportTemplateVersionRequest struct {
+	Content string `json:"content" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+// TemplatePreviewRequest renders a template with sample data. Either
+// TemplateID (with an optional Version, default latest) or DataType and
+// Content must be given.
+type TemplatePreviewRequest struct {
+	TemplateID uint   `json:"template_id"`
+	Version    int    `json:"version"`
+	DataType   string `json:"data_type"`
+	Content    string `json:"content"`
+}
+
+type ExportTemplateResponse struct {
+	ID            uint      `json:"id"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	DataType      string    `json:"data_type"`
+	LatestVersion int       `json:"latest_version"`
+	IsDefault     bool      `json:"is_default"`
+	Content       string    `json:"content,omitempty"`
+	CreatedBy     uint      `json:"created_by"`
+	UpdatedBy     uint      `json:"updated_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type ExportTemplateVersionResponse struct {
+	TemplateID uint      `json:"template_id"`
+	Version    int       `json:"version"`
+	Comment    string    `json:"comment"`
+	Content    string    `json:"content,omitempty"`
+	CreatedBy  uint      `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
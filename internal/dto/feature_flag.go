@@ -0,0 +1,41 @@
+package dto
+
+// FeatureFlagView is the effective state of one feature for a device and
+// where that state comes from.
+type FeatureFlagView struct {
+	FeatureID uint   `json:"feature_id"`
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	// Source is "version", "location" or "device"
+	Source string `json:"source"`
+	// Version is the newest version in the device's chain with the feature
+	Version string `json:"version"`
+}
+
+type DeviceFeatureFlags struct {
+	DeviceID   uint              `json:"device_id"`
+	Version    string            `json:"version"`
+	LocationID *uint             `json:"location_id,omitempty"`
+	Flags      []FeatureFlagView `json:"flags"`
+}
+
+// DeviceFlagConfig is the compact flag document served to devices. Rev
+// changes whenever a flag changes, so devices can poll with If-None-Match.
+type DeviceFlagConfig struct {
+	Version string          `json:"version"`
+	Rev     string          `json:"rev"`
+	Flags   map[string]bool `json:"flags"`
+}
+
+type FeatureOverrideRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+type FeatureOverrideResponse struct {
+	FeatureID uint   `json:"feature_id"`
+	Name      string `json:"name"`
+	Scope     string `json:"scope"`
+	ScopeID   uint   `json:"scope_id"`
+	Enabled   bool   `json:"enabled"`
+	UpdatedBy uint   `json:"updated_by"`
+}
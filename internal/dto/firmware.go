@@ -0,0 +1,48 @@
+package dto
+
+import "time"
+
+// FirmwareReportRequest is sent by a device on boot and with its heartbeat.
+type FirmwareReportRequest struct {
+	Version  string `json:"version" binding:"required"`
+	BuildID  string `json:"build_id"`
+	Checksum string `json:"checksum"`
+	// Event is "boot" or "heartbeat", defaults to heartbeat
+	Event string `json:"event"`
+}
+
+type DeviceFirmwareResponse struct {
+	DeviceID   uint       `json:"device_id"`
+	Version    string     `json:"version"`
+	BuildID    string     `json:"build_id,omitempty"`
+	Checksum   string     `json:"checksum,omitempty"`
+	LastEvent  string     `json:"last_event"`
+	BootedAt   *time.Time `json:"booted_at,omitempty"`
+	ReportedAt time.Time  `json:"reported_at"`
+}
+
+// Drift statuses of a device in the firmware drift report.
+const (
+	DriftUnreported = "unreported" // the device never reported its firmware
+	DriftMismatch   = "mismatch"   // running firmware differs from the assigned version
+	DriftOutdated   = "outdated"   // running firmware lags the latest release
+)
+
+type FirmwareDriftEntry struct {
+	DeviceID        uint       `json:"device_id"`
+	Name            string     `json:"name"`
+	Type            string     `json:"type"`
+	AssignedVersion string     `json:"assigned_version,omitempty"`
+	ReportedVersion string     `json:"reported_version,omitempty"`
+	BuildID         string     `json:"build_id,omitempty"`
+	Checksum        string     `json:"checksum,omitempty"`
+	ReportedAt      *time.Time `json:"reported_at,omitempty"`
+	// Statuses lists every kind of drift that applies to the device
+	Statuses []string `json:"statuses"`
+}
+
+type FirmwareDriftReport struct {
+	LatestVersion string               `json:"latest_version"`
+	TotalDevices  int                  `json:"total_devices"`
+	Drifted       []FirmwareDriftEntry `json:"drifted"`
+}
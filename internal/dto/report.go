@@ -0,0 +1,51 @@
+package dto
+
+import "time"
+
+// ReportDefinitionRequest creates or replaces a report definition. Schedule is
+// a cron expression or one of @daily, @weekly, @monthly.
+type ReportDefinitionRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	DataType   string   `json:"data_type" binding:"required"`
+	DeviceID   *uint    `json:"device_id,omitempty"`
+	Period     string   `json:"period"`
+	Format     string   `json:"format" binding:"required"`
+	TemplateID *uint    `json:"template_id,omitempty"`
+	Schedule   string   `json:"schedule" binding:"required"`
+	Recipients []string `json:"recipients"`
+	Enabled    *bool    `json:"enabled,omitempty"`
+}
+
+type ReportDefinitionResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	DataType   string     `json:"data_type"`
+	DeviceID   *uint      `json:"device_id,omitempty"`
+	Period     string     `json:"period"`
+	Format     string     `json:"format"`
+	TemplateID *uint      `json:"template_id,omitempty"`
+	Schedule   string     `json:"schedule"`
+	Recipients []string   `json:"recipients"`
+	Enabled    bool       `json:"enabled"`
+	NextRunAt  *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// ReportRunResponse is one rendering of a report and its delivery history.
+type ReportRunResponse struct {
+	ID             uint       `json:"id"`
+	DefinitionID   uint       `json:"definition_id"`
+	Trigger        string     `json:"trigger"`
+	Status         string     `json:"status"`
+	Error          string     `json:"error,omitempty"`
+	FileSize       int64      `json:"file_size"`
+	PeriodStart    time.Time  `json:"period_start"`
+	PeriodEnd      time.Time  `json:"period_end"`
+	DeliveryStatus string     `json:"delivery_status"`
+	DeliveryError  string     `json:"delivery_error,omitempty"`
+	Recipients     []string   `json:"recipients"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,88 @@
+package dto
+
+import "time"
+
+// Summary report kinds.
+const (
+	SummaryKindDevice   = "device"
+	SummaryKindLocation = "location"
+)
+
+// AlertThresholds decide which readings raise alerts. A zero voltage or
+// current limit is not checked.
+type AlertThresholds struct {
+	MinVoltage float64 `json:"min_voltage"`
+	MaxVoltage float64 `json:"max_voltage"`
+	MaxCurrent float64 `json:"max_current"`
+	// OfflineAfterMinutes is the longest gap between readings before the
+	// device counts as offline. Energy is not integrated across such gaps.
+	OfflineAfterMinutes int `json:"offline_after_minutes"`
+}
+
+// AlertCounts counts alert events. Consecutive readings out of range count
+// as one event.
+type AlertCounts struct {
+	LowVoltage  int `json:"low_voltage"`
+	HighVoltage int `json:"high_voltage"`
+	OverCurrent int `json:"over_current"`
+	Offline     int `json:"offline"`
+}
+
+func (a AlertCounts) Total() int {
+	return a.LowVoltage + a.HighVoltage + a.OverCurrent + a.Offline
+}
+
+// SeriesPoint is the average of a value over one chart bucket.
+type SeriesPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+type DailySummary struct {
+	Day        time.Time `json:"day"`
+	Count      int64     `json:"count"`
+	MinVoltage float64   `json:"min_voltage"`
+	MaxVoltage float64   `json:"max_voltage"`
+	AvgVoltage float64   `json:"avg_voltage"`
+	MinCurrent float64   `json:"min_current"`
+	MaxCurrent float64   `json:"max_current"`
+	AvgCurrent float64   `json:"avg_current"`
+}
+
+// TimeWindow is a span during which a device belongs to a report, such as
+// the time it was assigned to the location.
+type TimeWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type DeviceSummary struct {
+	Device        DeviceView     `json:"device"`
+	Windows       []TimeWindow   `json:"windows"`
+	Readings      int64          `json:"readings"`
+	EnergyWh      float64        `json:"energy_wh"`
+	UptimePercent float64        `json:"uptime_percent"`
+	Alerts        AlertCounts    `json:"alerts"`
+	Daily         []DailySummary `json:"daily"`
+	Voltage       []SeriesPoint  `json:"voltage"`
+	Current       []SeriesPoint  `json:"current"`
+}
+
+// SummaryReport is a device or location report over a time range. Totals
+// add up the devices; UptimePercent is weighted by each device's time in
+// the report.
+type SummaryReport struct {
+	Kind          string          `json:"kind"`
+	Title         string          `json:"title"`
+	LocationID    uint            `json:"location_id,omitempty"`
+	LocationName  string          `json:"location_name,omitempty"`
+	Start         time.Time       `json:"start"`
+	End           time.Time       `json:"end"`
+	GeneratedAt   time.Time       `json:"generated_at"`
+	Thresholds    AlertThresholds `json:"thresholds"`
+	Devices       []DeviceSummary `json:"devices"`
+	Readings      int64           `json:"readings"`
+	EnergyWh      float64         `json:"energy_wh"`
+	UptimePercent float64         `json:"uptime_percent"`
+	Alerts        AlertCounts     `json:"alerts"`
+}
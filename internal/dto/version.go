@@ -0,0 +1,29 @@
+package dto
+
+type CreateVersionRequest struct {
+	PreviousVersion string `json:"previous_version,omitempty"`
+	Version         string `json:"version" binding:"required"`
+}
+
+// CreateDeviceVersionRequest releases a new version of a device with the
+// IDs of its features.
+type CreateDeviceVersionRequest struct {
+	PreviousVersion *uint  `json:"previous_version,omitempty"`
+	Version         string `json:"version" binding:"required"`
+	Features        []int  `json:"features,omitempty"`
+}
+
+type UpdateVersionRequest struct {
+	Version string `json:"version" binding:"required"`
+}
+
+type CreateFeatureRequest struct {
+	VersionID   uint   `json:"version_id" binding:"required"`
+	FeatureName string `json:"name" binding:"required"`
+	Enabled     bool   `json:"enabled"`
+}
+
+type UpdateFeatureRequest struct {
+	FeatureName string `json:"feature_name" binding:"required"`
+	Enabled     bool   `json:"enabled"`
+}
@@ -0,0 +1,59 @@
+package dto
+
+import "time"
+
+type VersionNode struct {
+	ID                uint              `json:"id"`
+	Version           string            `json:"version"`
+	DeviceID          uint              `json:"device_id"`
+	PreviousVersionID *uint             `json:"previous_version_id,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	Current           bool              `json:"current"`
+	Features          []FeatureResponse `json:"features"`
+}
+
+// VersionEdge links a version (To) to the version it was made from (From).
+type VersionEdge struct {
+	From uint `json:"from"`
+	To   uint `json:"to"`
+}
+
+type VersionLineage struct {
+	DeviceID         uint          `json:"device_id"`
+	CurrentVersionID *uint         `json:"current_version_id,omitempty"`
+	Nodes            []VersionNode `json:"nodes"`
+	Edges            []VersionEdge `json:"edges"`
+}
+
+type FeatureToggle struct {
+	ID          uint   `json:"id"`
+	FeatureName string `json:"feature_name"`
+	From        bool   `json:"from"`
+	To          bool   `json:"to"`
+}
+
+type VersionRef struct {
+	ID      uint   `json:"id"`
+	Version string `json:"version"`
+}
+
+// VersionDiff lists how the feature set changed from one version to another.
+type VersionDiff struct {
+	From    *VersionRef       `json:"from,omitempty"`
+	To      VersionRef        `json:"to"`
+	Added   []FeatureResponse `json:"added"`
+	Removed []FeatureResponse `json:"removed"`
+	Toggled []FeatureToggle   `json:"toggled"`
+}
+
+type ChangelogEntry struct {
+	VersionDiff
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// VersionChangelog lists the releases of a device's current version chain,
+// newest first, each compared with the release before it.
+type VersionChangelog struct {
+	DeviceID uint             `json:"device_id"`
+	Entries  []ChangelogEntry `json:"entries"`
+}
@@ -0,0 +1,25 @@
+package dto
+
+import "time"
+
+type CreateWiFiProfileRequest struct {
+	Name     string `json:"name" binding:"required"`
+	SSID     string `json:"ssid" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// UpdateWiFiProfileRequest keeps the stored password when Password is empty.
+type UpdateWiFiProfileRequest struct {
+	Name     string `json:"name" binding:"required"`
+	SSID     string `json:"ssid" binding:"required"`
+	Password string `json:"password"`
+}
+
+// WiFiProfileResponse never carries the password.
+type WiFiProfileResponse struct {
+	ID         uint      `json:"id"`
+	LocationID uint      `json:"location_id"`
+	Name       string    `json:"name"`
+	SSID       string    `json:"ssid"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,79 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+
+	appDto "github.com/aruncs31s/skvms/internal/dto"
+)
+
+const (
+	chartWidth    = 700
+	chartHeight   = 180
+	chartPadLeft  = 48
+	chartPadTop   = 16
+	chartPadBot   = 24
+	chartPadRight = 12
+)
+
+// lineChartSVG renders points as an inline SVG line chart spanning start to
+// end. Charts are drawn on the server so reports need no scripts.
+func lineChartSVG(title, unit, color string, points []appDto.SeriesPoint, start, end time.Time) template.HTML {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" class="chart">`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<text x="%d" y="11" font-size="11" fill="#1a3a5c">%s (%s)</text>`,
+		chartPadLeft, html.EscapeString(title), html.EscapeString(unit))
+
+	plotW := float64(chartWidth - chartPadLeft - chartPadRight)
+	plotH := float64(chartHeight - chartPadTop - chartPadBot)
+	bottom := float64(chartHeight - chartPadBot)
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.0f" height="%.0f" fill="none" stroke="#ccc"/>`,
+		chartPadLeft, chartPadTop, plotW, plotH)
+
+	if len(points) == 0 {
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" font-size="11" fill="#999" text-anchor="middle">No readings</text></svg>`,
+			float64(chartPadLeft)+plotW/2, float64(chartPadTop)+plotH/2)
+		return template.HTML(b.String())
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		lo = math.Min(lo, p.Value)
+		hi = math.Max(hi, p.Value)
+	}
+	if hi == lo {
+		hi, lo = hi+1, lo-1
+	}
+	span := end.Sub(start).Seconds()
+	if span <= 0 {
+		span = 1
+	}
+
+	b.WriteString(`<polyline fill="none" stroke-width="1.5" stroke="` + html.EscapeString(color) + `" points="`)
+	for i, p := range points {
+		x := float64(chartPadLeft) + p.Time.Sub(start).Seconds()/span*plotW
+		y := bottom - (p.Value-lo)/(hi-lo)*plotH
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%.1f,%.1f", x, y)
+	}
+	b.WriteString(`"/>`)
+
+	// Axis labels: value range on the left, time range below
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="10" fill="#666" text-anchor="end">%.2f</text>`,
+		chartPadLeft-4, chartPadTop+8, hi)
+	fmt.Fprintf(&b, `<text x="%d" y="%.0f" font-size="10" fill="#666" text-anchor="end">%.2f</text>`,
+		chartPadLeft-4, bottom, lo)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="10" fill="#666">%s</text>`,
+		chartPadLeft, chartHeight-8, start.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="10" fill="#666" text-anchor="end">%s</text>`,
+		chartWidth-chartPadRight, chartHeight-8, end.Format("2006-01-02 15:04"))
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
@@ -0,0 +1,427 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/tracing"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrPDFUnavailable is returned when no Chrome executable was found or
+	// the renderer has been closed.
+	ErrPDFUnavailable = errors.New("pdf rendering is unavailable")
+	// ErrRenderTimeout is returned when a page takes longer than the render
+	// timeout to print.
+	ErrRenderTimeout = errors.New("pdf rendering timed out")
+)
+
+// PDFRenderer converts HTML documents to PDF.
+type PDFRenderer interface {
+	// RenderPDF prints a HTML document to an A4 PDF.
+	RenderPDF(ctx context.Context, html string) ([]byte, error)
+	// Available reports whether PDFs can be rendered, and if not, why.
+	Available() (bool, string)
+	// Stats returns the renderer's health counters.
+	Stats() RendererStats
+}
+
+// RendererStats describes the health of a PDF renderer.
+type RendererStats struct {
+	Available bool   `json:"available"`
+	Error     string `json:"error,omitempty"`
+	ExecPath  string `json:"exec_path,omitempty"`
+
+	// Size is the number of browsers, and so of concurrent renders
+	Size int `json:"size"`
+	// Running is the number of started browsers
+	Running int `json:"running"`
+	Busy    int `json:"busy"`
+	// Waiting is the number of renders queued for a free browser
+	Waiting int `json:"waiting"`
+
+	Renders  uint64 `json:"renders"`
+	Failures uint64 `json:"failures"`
+	Timeouts uint64 `json:"timeouts"`
+	// Restarts counts browsers replaced after a crash, timeout or recycling
+	Restarts    uint64  `json:"restarts"`
+	AvgRenderMs float64 `json:"avg_render_ms"`
+}
+
+// ChromePoolConfig configures a ChromePool. Zero values select the defaults.
+type ChromePoolConfig struct {
+	// ExecPath is the Chrome executable; by default it is searched for on
+	// the PATH like chromedp does.
+	ExecPath string
+	// Size is the number of warm browsers (default 2).
+	Size int
+	// RenderTimeout bounds a single render, queueing excluded (default 60s).
+	RenderTimeout time.Duration
+	// MaxRenders recycles a browser after this many renders (default 200).
+	MaxRenders int
+}
+
+// chromeExecNames are the executables searched for without an ExecPath.
+var chromeExecNames = []string{
+	"headless_shell",
+	"headless-shell",
+	"chromium",
+	"chromium-browser",
+	"google-chrome",
+	"google-chrome-stable",
+	"/usr/bin/google-chrome",
+	"/usr/local/bin/chrome",
+	"/snap/bin/chromium",
+	"chrome",
+}
+
+// ChromePool renders PDFs with a fixed set of warm headless Chrome browsers.
+// Each render opens a tab in a free browser, so at most Size renders run at
+// once and the rest wait their turn. Browsers that crash or time out are
+// replaced on their next use.
+type ChromePool struct {
+	cfg      ChromePoolConfig
+	execPath string
+	lookErr  error
+
+	// slots holds one entry per browser; nil entries are not started yet
+	slots     chan *chromeBrowser
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	running  atomic.Int64
+	busy     atomic.Int64
+	waiting  atomic.Int64
+	renders  atomic.Uint64
+	failures atomic.Uint64
+	timeouts atomic.Uint64
+	restarts atomic.Uint64
+	renderNs atomic.Uint64
+}
+
+type chromeBrowser struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	renders int
+}
+
+// NewChromePool creates a pool and starts its browsers in the background.
+// Without a Chrome executable the pool reports itself unavailable.
+func NewChromePool(cfg ChromePoolConfig) *ChromePool {
+	if cfg.Size <= 0 {
+		cfg.Size = 2
+	}
+	if cfg.RenderTimeout <= 0 {
+		cfg.RenderTimeout = 60 * time.Second
+	}
+	if cfg.MaxRenders <= 0 {
+		cfg.MaxRenders = 200
+	}
+
+	p := &ChromePool{
+		cfg:    cfg,
+		slots:  make(chan *chromeBrowser, cfg.Size),
+		closed: make(chan struct{}),
+	}
+	p.execPath, p.lookErr = findChrome(cfg.ExecPath)
+	for i := 0; i < cfg.Size; i++ {
+		p.slots <- nil
+	}
+
+	if p.lookErr != nil {
+		logger.GetLogger().Warn("PDF export disabled", zap.Error(p.lookErr))
+		return p
+	}
+	go p.warm()
+	return p
+}
+
+func findChrome(execPath string) (string, error) {
+	if execPath != "" {
+		found, err := exec.LookPath(execPath)
+		if err != nil {
+			return "", fmt.Errorf("chrome not found at %s: %w", execPath, err)
+		}
+		return found, nil
+	}
+	for _, name := range chromeExecNames {
+		if found, err := exec.LookPath(name); err == nil {
+			return found, nil
+		}
+	}
+	return "", errors.New("chrome is not installed")
+}
+
+// warm starts every browser so the first renders do not wait for Chrome.
+func (p *ChromePool) warm() {
+	for i := 0; i < p.cfg.Size; i++ {
+		var b *chromeBrowser
+		select {
+		case b = <-p.slots:
+		case <-p.closed:
+			return
+		}
+		if b == nil {
+			started, err := p.startBrowser()
+			if err != nil {
+				logger.GetLogger().Error("Failed to start Chrome", zap.Error(err))
+			}
+			b = started
+		}
+		p.slots <- b
+	}
+}
+
+func (p *ChromePool) startBrowser() (*chromeBrowser, error) {
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(),
+		append(chromedp.DefaultExecAllocatorOptions[:],
+			chromedp.ExecPath(p.execPath),
+			chromedp.Flag("headless", true),
+			chromedp.Flag("disable-gpu", true),
+			chromedp.Flag("no-sandbox", true),
+			chromedp.Flag("disable-dev-shm-usage", true),
+		)...,
+	)
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	cancel := func() {
+		cancelBrowser()
+		cancelAlloc()
+	}
+
+	// Running no actions starts the browser process
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("start chrome: %w", err)
+	}
+	p.running.Add(1)
+	return &chromeBrowser{ctx: browserCtx, cancel: cancel}, nil
+}
+
+func (p *ChromePool) closeBrowser(b *chromeBrowser) {
+	b.cancel()
+	p.running.Add(-1)
+}
+
+// alive reports whether the browser process is still connected.
+func (b *chromeBrowser) alive() bool {
+	if b.ctx.Err() != nil {
+		return false
+	}
+	c := chromedp.FromContext(b.ctx)
+	if c == nil || c.Browser == nil {
+		return false
+	}
+	select {
+	case <-c.Browser.LostConnection:
+		return false
+	default:
+		return true
+	}
+}
+
+// acquire waits for a free browser, starting or restarting it as needed.
+// The caller must hand a browser it got back with release.
+func (p *ChromePool) acquire(ctx context.Context) (*chromeBrowser, error) {
+	p.waiting.Add(1)
+	var b *chromeBrowser
+	select {
+	case b = <-p.slots:
+		p.waiting.Add(-1)
+	case <-ctx.Done():
+		p.waiting.Add(-1)
+		return nil, ctx.Err()
+	case <-p.closed:
+		p.waiting.Add(-1)
+		return nil, fmt.Errorf("%w: renderer closed", ErrPDFUnavailable)
+	}
+
+	if b != nil && !b.alive() {
+		logger.FromContext(ctx).Warn("Chrome browser lost, restarting")
+		p.closeBrowser(b)
+		p.restarts.Add(1)
+		b = nil
+	}
+	if b == nil {
+		started, err := p.startBrowser()
+		if err != nil {
+			p.slots <- nil
+			return nil, err
+		}
+		b = started
+	}
+	p.busy.Add(1)
+	return b, nil
+}
+
+// release hands a browser back to the pool. Browsers that failed or reached
+// their render limit are closed and replaced on next use.
+func (p *ChromePool) release(b *chromeBrowser, healthy bool) {
+	p.busy.Add(-1)
+	b.renders++
+	if !healthy || b.renders >= p.cfg.MaxRenders {
+		p.closeBrowser(b)
+		p.restarts.Add(1)
+		b = nil
+	}
+	select {
+	case <-p.closed:
+		// Close drains the slots; it must not close the browser again
+		if b != nil {
+			p.closeBrowser(b)
+			b = nil
+		}
+	default:
+	}
+	p.slots <- b
+}
+
+// RenderPDF prints html in a new tab of a pooled browser. Templates may come
+// from users, so the page is loaded into about:blank, where local files
+// cannot be referenced, and every request it makes is blocked. Only inline
+// content such as styles, SVG and data URLs is rendered.
+func (p *ChromePool) RenderPDF(ctx context.Context, html string) (_ []byte, err error) {
+	ctx, span := tracing.Start(ctx, "pdf.render", attribute.Int("pdf.html_bytes", len(html)))
+	defer func() { tracing.End(span, err) }()
+
+	if p.lookErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPDFUnavailable, p.lookErr)
+	}
+
+	_, waitSpan := tracing.Start(ctx, "pdf.acquire_browser")
+	b, err := p.acquire(ctx)
+	tracing.End(waitSpan, err)
+	if err != nil {
+		p.failures.Add(1)
+		return nil, err
+	}
+
+	started := time.Now()
+	pdf, err := p.render(ctx, b, html)
+	elapsed := time.Since(started)
+	span.SetAttributes(attribute.Int("pdf.bytes", len(pdf)))
+
+	// A timed out page may still be busy, so its browser is replaced too
+	healthy := err == nil || (!errors.Is(err, ErrRenderTimeout) && b.alive())
+	p.release(b, healthy)
+
+	if err != nil {
+		p.failures.Add(1)
+		return nil, err
+	}
+	p.renders.Add(1)
+	p.renderNs.Add(uint64(elapsed))
+	return pdf, nil
+}
+
+func (p *ChromePool) render(ctx context.Context, b *chromeBrowser, html string) ([]byte, error) {
+	tabCtx, cancelTab := chromedp.NewContext(b.ctx)
+	defer cancelTab()
+	tabCtx, cancelTimeout := context.WithTimeout(tabCtx, p.cfg.RenderTimeout)
+	defer cancelTimeout()
+	// Abandon the render when the request goes away
+	stop := context.AfterFunc(ctx, cancelTab)
+	defer stop()
+
+	chromedp.ListenTarget(tabCtx, func(ev interface{}) {
+		if ev, ok := ev.(*fetch.EventRequestPaused); ok {
+			go func() {
+				_ = chromedp.Run(tabCtx, fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient))
+			}()
+		}
+	})
+
+	var pdfBuf []byte
+	err := chromedp.Run(tabCtx,
+		chromedp.Navigate("about:blank"),
+		fetch.Enable(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			tree, err := page.GetFrameTree().Do(ctx)
+			if err != nil {
+				return err
+			}
+			return page.SetDocumentContent(tree.Frame.ID, html).Do(ctx)
+		}),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			pdfBuf, _, err = page.PrintToPDF().
+				WithPrintBackground(true).
+				WithPaperWidth(a4PaperWidthInches).
+				WithPaperHeight(a4PaperHeightInches).
+				WithMarginTop(defaultMarginInches).
+				WithMarginBottom(defaultMarginInches).
+				WithMarginLeft(defaultMarginInches).
+				WithMarginRight(defaultMarginInches).
+				Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(tabCtx.Err(), context.DeadlineExceeded) {
+			p.timeouts.Add(1)
+			return nil, fmt.Errorf("%w after %s", ErrRenderTimeout, p.cfg.RenderTimeout)
+		}
+		return nil, fmt.Errorf("chromedp print to pdf: %w", err)
+	}
+	return pdfBuf, nil
+}
+
+func (p *ChromePool) Available() (bool, string) {
+	if p.lookErr != nil {
+		return false, p.lookErr.Error()
+	}
+	select {
+	case <-p.closed:
+		return false, "renderer closed"
+	default:
+		return true, ""
+	}
+}
+
+func (p *ChromePool) Stats() RendererStats {
+	available, reason := p.Available()
+	stats := RendererStats{
+		Available: available,
+		Error:     reason,
+		ExecPath:  p.execPath,
+		Size:      p.cfg.Size,
+		Running:   int(p.running.Load()),
+		Busy:      int(p.busy.Load()),
+		Waiting:   int(p.waiting.Load()),
+		Renders:   p.renders.Load(),
+		Failures:  p.failures.Load(),
+		Timeouts:  p.timeouts.Load(),
+		Restarts:  p.restarts.Load(),
+	}
+	if stats.Renders > 0 {
+		stats.AvgRenderMs = float64(p.renderNs.Load()) / float64(stats.Renders) / float64(time.Millisecond)
+	}
+	return stats
+}
+
+// Close stops the browsers. Renders in progress finish first; later ones
+// fail with ErrPDFUnavailable.
+func (p *ChromePool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		for i := 0; i < p.cfg.Size; i++ {
+			if b := <-p.slots; b != nil {
+				p.closeBrowser(b)
+			}
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package export
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReleaseAfterCloseClosesBrowserOnce(t *testing.T) {
+	p := &ChromePool{
+		cfg:    ChromePoolConfig{Size: 1, MaxRenders: 10},
+		slots:  make(chan *chromeBrowser, 1),
+		closed: make(chan struct{}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &chromeBrowser{ctx: ctx, cancel: cancel}
+	p.running.Add(1)
+	p.busy.Add(1)
+
+	// Close waits for the browser in use
+	done := make(chan struct{})
+	go func() {
+		p.Close()
+		close(done)
+	}()
+	<-p.closed
+	p.release(b, true)
+	<-done
+
+	if ctx.Err() == nil {
+		t.Error("browser released after Close is still running")
+	}
+	if running := p.running.Load(); running != 0 {
+		t.Errorf("running = %d, want 0", running)
+	}
+}
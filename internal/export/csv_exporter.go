@@ -20,23 +20,22 @@ func (e *csvExporter) Format() dto.ExportFormat {
 	return dto.FormatCSV
 }
 
-func (e *csvExporter) Export(_ context.Context, data *dto.ExportData, _ string, w io.Writer) error {
+func (e *csvExporter) Export(ctx context.Context, data *dto.ExportData, _ string, w io.Writer) error {
+	headers := data.Headers()
 	cw := csv.NewWriter(w)
 
-	if err := cw.Write(data.Headers); err != nil {
+	if err := cw.Write(headers); err != nil {
 		return fmt.Errorf("csv: write headers: %w", err)
 	}
 
-	for _, row := range data.Rows {
-		record := make([]string, len(data.Headers))
-		for i, h := range data.Headers {
-			if v, ok := row[h]; ok {
-				record[i] = fmt.Sprintf("%v", v)
-			}
-		}
-		if err := cw.Write(record); err != nil {
+	err := forEachRow(ctx, data.Rows, func(row dto.ExportRow) error {
+		if err := cw.Write(rowCells(row, headers)); err != nil {
 			return fmt.Errorf("csv: write row: %w", err)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	cw.Flush()
@@ -1,5 +1,10 @@
 package dto
 
+import (
+	"context"
+	"io"
+)
+
 // ExportFormat represents the output format for an export.
 type ExportFormat string
 
@@ -8,6 +13,11 @@ const (
 	FormatXLSX ExportFormat = "xlsx"
 	FormatXML  ExportFormat = "xml"
 	FormatPDF  ExportFormat = "pdf"
+
+	// Formats for analytics tools. They keep column types and units.
+	FormatParquet ExportFormat = "parquet"
+	FormatNDJSON  ExportFormat = "ndjson"
+	FormatInflux  ExportFormat = "influx"
 )
 
 // ExportRequest holds the parameters for an export operation.
@@ -21,21 +31,90 @@ type ExportRequest struct {
 	// DeviceID filters readings by device (optional for devices export).
 	DeviceID uint `json:"device_id"`
 
+	// DeviceIDs and LocationID export the readings of several devices, or of
+	// every device at a location, into one file. They add to DeviceID.
+	DeviceIDs  []uint `json:"device_ids"`
+	LocationID uint   `json:"location_id"`
+
 	// StartDate and EndDate filter readings by date range (RFC3339 or 2006-01-02).
 	StartDate string `json:"start_date"`
 	EndDate   string `json:"end_date"`
 
-	// TemplatePath is an optional custom template path for PDF exports.
-	// If empty, the default template for the DataType is used.
-	TemplatePath string `json:"template_path"`
+	// TemplateID selects a library template for PDF exports. If zero, the
+	// default template for the DataType is used.
+	TemplateID uint `json:"template_id"`
+
+	// Template is the source of the template selected by TemplateID,
+	// resolved from the template library before exporting.
+	Template string `json:"-"`
+}
+
+// ColumnType is the type of the values in an export column.
+type ColumnType string
+
+const (
+	ColumnString ColumnType = "string"
+	ColumnInt    ColumnType = "int"   // uint or int values
+	ColumnFloat  ColumnType = "float" // float64 values
+	ColumnTime   ColumnType = "time"  // time.Time values
+)
+
+// Column describes one exported column. Text formats only use Name; typed
+// formats also carry Type and Unit.
+type Column struct {
+	Name string
+	Type ColumnType
+	// Unit of the values, such as "V"; empty for unitless columns
+	Unit string
+	// Tag marks metadata identifying the series, such as the device, rather
+	// than a measured value
+	Tag bool
 }
 
 // ExportRow is a generic row of data to be exported, keyed by column name.
+// Values have the Go type matching their column's Type.
 type ExportRow map[string]interface{}
 
-// ExportData holds the headers and rows to be exported.
+// RowIterator yields export rows one at a time, so exporters can stream
+// results that do not fit in memory.
+type RowIterator interface {
+	// Next returns the next row, or io.EOF after the last one.
+	Next(ctx context.Context) (ExportRow, error)
+}
+
+// SliceRows iterates over rows that are already in memory.
+func SliceRows(rows []ExportRow) RowIterator {
+	return &sliceRows{rows: rows}
+}
+
+type sliceRows struct {
+	rows []ExportRow
+}
+
+func (s *sliceRows) Next(_ context.Context) (ExportRow, error) {
+	if len(s.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := s.rows[0]
+	s.rows = s.rows[1:]
+	return row, nil
+}
+
+// ExportData holds the columns and rows to be exported. Rows can only be
+// iterated once.
 type ExportData struct {
-	Title   string
-	Headers []string
-	Rows    []ExportRow
+	Title string
+	// Name is a short machine-readable name of the data, such as "readings"
+	Name    string
+	Columns []Column
+	Rows    RowIterator
+}
+
+// Headers returns the column names in order.
+func (d *ExportData) Headers() []string {
+	headers := make([]string, len(d.Columns))
+	for i, c := range d.Columns {
+		headers[i] = c.Name
+	}
+	return headers
 }
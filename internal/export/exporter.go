@@ -2,7 +2,11 @@ package export
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/aruncs31s/skvms/internal/export/dto"
 )
@@ -12,6 +16,124 @@ type Exporter interface {
 	// Format returns the export format this exporter handles.
 	Format() dto.ExportFormat
 
-	// Export writes the exported data to w.
-	Export(ctx context.Context, data *dto.ExportData, templatePath string, w io.Writer) error
+	// Export writes the exported data to w. Rows are consumed from
+	// data.Rows as they are written, so memory stays bounded where the
+	// format allows it. template is the source of a PDF template, or empty
+	// for the default; other formats ignore it.
+	Export(ctx context.Context, data *dto.ExportData, template string, w io.Writer) error
+}
+
+// forEachRow calls fn for every row of the iterator until it is exhausted,
+// fn fails or ctx is cancelled.
+func forEachRow(ctx context.Context, rows dto.RowIterator, fn func(dto.ExportRow) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row, err := rows.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// rowCells returns the row's values as strings ordered by headers, for
+// formats without column types.
+func rowCells(row dto.ExportRow, headers []string) []string {
+	cells := make([]string, len(headers))
+	for i, h := range headers {
+		switch v := row[h].(type) {
+		case nil:
+		case time.Time:
+			cells[i] = v.Format(time.RFC3339)
+		default:
+			cells[i] = fmt.Sprintf("%v", v)
+		}
+	}
+	return cells
+}
+
+// fieldKey returns the machine-readable key of a column for typed formats:
+// the name in snake case with the unit appended, e.g. "voltage_v".
+func fieldKey(c dto.Column) string {
+	name := c.Name
+	if c.Unit != "" {
+		name += " " + c.Unit
+	}
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if underscore && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			underscore = false
+			continue
+		}
+		underscore = true
+	}
+	if b.Len() == 0 {
+		return "field"
+	}
+	return b.String()
+}
+
+// MIMEType returns the MIME content-type and file extension for the given export format.
+func MIMEType(f dto.ExportFormat) (contentType, ext string) {
+	switch f {
+	case dto.FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"
+	case dto.FormatXML:
+		return "application/xml", "xml"
+	case dto.FormatPDF:
+		return "application/pdf", "pdf"
+	case dto.FormatParquet:
+		return "application/vnd.apache.parquet", "parquet"
+	case dto.FormatNDJSON:
+		return "application/x-ndjson", "ndjson"
+	case dto.FormatInflux:
+		return "text/plain; charset=utf-8", "lp"
+	default: // csv
+		return "text/csv", "csv"
+	}
+}
+
+// toInt converts an integer column value to int64.
+func toInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// toFloat converts a float column value to float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	}
+	if i, ok := toInt(v); ok {
+		return float64(i), true
+	}
+	return 0, false
 }
@@ -0,0 +1,139 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/export/dto"
+)
+
+// influxExporter exports data as InfluxDB line protocol. The data name is
+// the measurement, tag columns such as the device become tags, the first time
+// column is the timestamp in nanoseconds and every other column is a field.
+type influxExporter struct{}
+
+func newInfluxExporter() Exporter {
+	return &influxExporter{}
+}
+
+func (e *influxExporter) Format() dto.ExportFormat {
+	return dto.FormatInflux
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	fieldStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func (e *influxExporter) Export(ctx context.Context, data *dto.ExportData, _ string, w io.Writer) error {
+	measurement := data.Name
+	if measurement == "" {
+		measurement = "export"
+	}
+	measurement = measurementEscaper.Replace(measurement)
+
+	var tags, fields []dto.Column
+	var timeColumn *dto.Column
+	for i, c := range data.Columns {
+		switch {
+		case c.Tag:
+			tags = append(tags, c)
+		case c.Type == dto.ColumnTime && timeColumn == nil:
+			timeColumn = &data.Columns[i]
+		default:
+			fields = append(fields, c)
+		}
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("influx: %s has no field columns", measurement)
+	}
+
+	bw := bufio.NewWriter(w)
+	err := forEachRow(ctx, data.Rows, func(row dto.ExportRow) error {
+		bw.WriteString(measurement)
+		for _, c := range tags {
+			val := influxTagValue(row[c.Name])
+			// Line protocol does not allow empty tag values
+			if val == "" {
+				continue
+			}
+			bw.WriteByte(',')
+			bw.WriteString(tagEscaper.Replace(fieldKey(c)))
+			bw.WriteByte('=')
+			bw.WriteString(tagEscaper.Replace(val))
+		}
+
+		wrote := 0
+		for _, c := range fields {
+			val, ok := influxFieldValue(c, row[c.Name])
+			if !ok {
+				continue
+			}
+			if wrote == 0 {
+				bw.WriteByte(' ')
+			} else {
+				bw.WriteByte(',')
+			}
+			bw.WriteString(tagEscaper.Replace(fieldKey(c)))
+			bw.WriteByte('=')
+			bw.WriteString(val)
+			wrote++
+		}
+		if wrote == 0 {
+			return fmt.Errorf("influx: row has no field values")
+		}
+
+		if timeColumn != nil {
+			if t, ok := row[timeColumn.Name].(time.Time); ok {
+				bw.WriteByte(' ')
+				bw.WriteString(strconv.FormatInt(t.UnixNano(), 10))
+			}
+		}
+		_, err := bw.WriteString("\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func influxTagValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// influxFieldValue formats a field value by column type: floats as is,
+// integers with the "i" suffix and everything else as a quoted string.
+func influxFieldValue(c dto.Column, v interface{}) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+	switch c.Type {
+	case dto.ColumnFloat:
+		if f, ok := toFloat(v); ok {
+			return strconv.FormatFloat(f, 'g', -1, 64), true
+		}
+	case dto.ColumnInt:
+		if i, ok := toInt(v); ok {
+			return strconv.FormatInt(i, 10) + "i", true
+		}
+	case dto.ColumnTime:
+		if t, ok := v.(time.Time); ok {
+			return strconv.FormatInt(t.UnixNano(), 10) + "i", true
+		}
+	}
+	return `"` + fieldStringEscaper.Replace(fmt.Sprintf("%v", v)) + `"`, true
+}
@@ -0,0 +1,75 @@
+package export
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rendererCollector exposes the counters of a PDF renderer to Prometheus,
+// read from its Stats on every scrape.
+type rendererCollector struct {
+	renderer PDFRenderer
+
+	available *prometheus.Desc
+	size      *prometheus.Desc
+	running   *prometheus.Desc
+	busy      *prometheus.Desc
+	waiting   *prometheus.Desc
+	renders   *prometheus.Desc
+	seconds   *prometheus.Desc
+	failures  *prometheus.Desc
+	timeouts  *prometheus.Desc
+	restarts  *prometheus.Desc
+}
+
+// NewRendererCollector returns a collector of the PDF renderer of s.
+func NewRendererCollector(s *Service) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("skvms", "pdf", name), help, nil, nil)
+	}
+	return &rendererCollector{
+		renderer:  s.renderer,
+		available: desc("available", "Whether PDFs can be rendered (1) or not (0)."),
+		size:      desc("pool_size", "Browsers in the pool, and so concurrent renders."),
+		running:   desc("browsers_running", "Started browsers."),
+		busy:      desc("browsers_busy", "Browsers rendering a PDF."),
+		waiting:   desc("renders_waiting", "Renders queued for a free browser."),
+		renders:   desc("renders_total", "PDFs rendered."),
+		seconds:   desc("render_seconds_total", "Time spent rendering PDFs."),
+		failures:  desc("render_failures_total", "PDF renders that failed."),
+		timeouts:  desc("render_timeouts_total", "PDF renders that timed out."),
+		restarts:  desc("browser_restarts_total", "Browsers replaced after a crash, timeout or recycling."),
+	}
+}
+
+func (c *rendererCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.available, c.size, c.running, c.busy, c.waiting,
+		c.renders, c.seconds, c.failures, c.timeouts, c.restarts,
+	} {
+		ch <- d
+	}
+}
+
+func (c *rendererCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.renderer.Stats()
+	available := 0.0
+	if stats.Available {
+		available = 1
+	}
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge(c.available, available)
+	gauge(c.size, float64(stats.Size))
+	gauge(c.running, float64(stats.Running))
+	gauge(c.busy, float64(stats.Busy))
+	gauge(c.waiting, float64(stats.Waiting))
+	counter(c.renders, float64(stats.Renders))
+	counter(c.seconds, stats.AvgRenderMs*float64(stats.Renders)/1000)
+	counter(c.failures, float64(stats.Failures))
+	counter(c.timeouts, float64(stats.Timeouts))
+	counter(c.restarts, float64(stats.Restarts))
+}
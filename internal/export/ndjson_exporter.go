@@ -0,0 +1,66 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/export/dto"
+)
+
+// ndjsonExporter exports data as JSON Lines: one JSON object per row, keyed
+// by fieldKey so units are part of the key. Numbers stay numbers and times
+// are RFC 3339 strings with nanoseconds.
+type ndjsonExporter struct{}
+
+func newNDJSONExporter() Exporter {
+	return &ndjsonExporter{}
+}
+
+func (e *ndjsonExporter) Format() dto.ExportFormat {
+	return dto.FormatNDJSON
+}
+
+func (e *ndjsonExporter) Export(ctx context.Context, data *dto.ExportData, _ string, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	// Keys are encoded once; objects are written by hand to keep column order
+	keys := make([][]byte, len(data.Columns))
+	for i, c := range data.Columns {
+		key, err := json.Marshal(fieldKey(c))
+		if err != nil {
+			return fmt.Errorf("ndjson: encode key: %w", err)
+		}
+		keys[i] = key
+	}
+
+	err := forEachRow(ctx, data.Rows, func(row dto.ExportRow) error {
+		bw.WriteByte('{')
+		for i, c := range data.Columns {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			bw.Write(keys[i])
+			bw.WriteByte(':')
+
+			v := row[c.Name]
+			if t, ok := v.(time.Time); ok {
+				v = t.Format(time.RFC3339Nano)
+			}
+			val, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("ndjson: encode %s: %w", c.Name, err)
+			}
+			bw.Write(val)
+		}
+		_, err := bw.WriteString("}\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
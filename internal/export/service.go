@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
 	"time"
 
 	appDto "github.com/aruncs31s/skvms/internal/dto"
 	"github.com/aruncs31s/skvms/internal/export/dto"
+	"github.com/aruncs31s/skvms/internal/metrics"
 	"github.com/aruncs31s/skvms/internal/model"
 )
 
@@ -17,21 +19,27 @@ import (
 type Service struct {
 	exporters    map[dto.ExportFormat]Exporter
 	templatesDir string
+	renderer     PDFRenderer
 }
 
 // NewService creates a new export Service.
 // templatesDir should point to the folder that contains the default HTML templates.
-func NewService(templatesDir string) *Service {
+// renderer prints PDFs; while it is unavailable the pdf format is not offered.
+func NewService(templatesDir string, renderer PDFRenderer) *Service {
 	s := &Service{
 		exporters:    make(map[dto.ExportFormat]Exporter),
 		templatesDir: templatesDir,
+		renderer:     renderer,
 	}
 
 	// Register built-in exporters
 	s.register(newCSVExporter())
 	s.register(newXMLExporter())
 	s.register(newXLSXExporter())
-	s.register(newPDFExporter(templatesDir))
+	s.register(newPDFExporter(templatesDir, renderer))
+	s.register(newParquetExporter())
+	s.register(newNDJSONExporter())
+	s.register(newInfluxExporter())
 
 	return s
 }
@@ -45,14 +53,87 @@ func (s *Service) register(e Exporter) {
 func (s *Service) SupportedFormats() []string {
 	formats := make([]string, 0, len(s.exporters))
 	for f := range s.exporters {
-		formats = append(formats, string(f))
+		if s.Supports(f) {
+			formats = append(formats, string(f))
+		}
 	}
+	sort.Strings(formats)
 	return formats
 }
 
-// ExportReadings exports readings data for a device to w using the format in req.
-func (s *Service) ExportReadings(ctx context.Context, req dto.ExportRequest, readings []model.Reading, w io.Writer) error {
-	data := readingsToExportData(readings)
+// UnavailableFormats returns the registered formats that cannot be exported
+// right now, with the reason, such as pdf without Chrome.
+func (s *Service) UnavailableFormats() map[string]string {
+	unavailable := make(map[string]string)
+	if ok, reason := s.renderer.Available(); !ok {
+		unavailable[string(dto.FormatPDF)] = reason
+	}
+	return unavailable
+}
+
+// Supports reports whether the format can be exported.
+func (s *Service) Supports(format dto.ExportFormat) bool {
+	if _, ok := s.exporters[format]; !ok {
+		return false
+	}
+	if format == dto.FormatPDF {
+		ok, _ := s.renderer.Available()
+		return ok
+	}
+	return true
+}
+
+// RendererStats returns the health counters of the PDF renderer.
+func (s *Service) RendererStats() RendererStats {
+	return s.renderer.Stats()
+}
+
+// readingsPageSize is the number of readings fetched per query while streaming.
+const readingsPageSize = 5000
+
+// ReadingSource pages through readings for streaming exports.
+type ReadingSource interface {
+	// ListPageByDevicesAndDateRange returns up to limit readings of the
+	// devices between startTime and endTime, newest first, that come after
+	// the reading after. A nil after starts at the newest reading.
+	ListPageByDevicesAndDateRange(
+		ctx context.Context,
+		deviceIDs []uint,
+		startTime time.Time,
+		endTime time.Time,
+		after *model.Reading,
+		limit int,
+	) ([]model.Reading, error)
+}
+
+// ExportReadings streams the readings of one or more devices between start
+// and end to w using the format in req. Readings are fetched page by page, so
+// memory stays bounded however long the range is. Each row carries the name
+// and type of its device.
+func (s *Service) ExportReadings(
+	ctx context.Context,
+	req dto.ExportRequest,
+	source ReadingSource,
+	devices []appDto.DeviceView,
+	start, end time.Time,
+	w io.Writer,
+) error {
+	rows := &readingRows{
+		source:  source,
+		devices: make(map[uint]appDto.DeviceView, len(devices)),
+		start:   start,
+		end:     end,
+	}
+	for _, d := range devices {
+		rows.deviceIDs = append(rows.deviceIDs, d.ID)
+		rows.devices[d.ID] = d
+	}
+	data := &dto.ExportData{
+		Title:   "Readings Export",
+		Name:    "readings",
+		Columns: readingColumns,
+		Rows:    rows,
+	}
 	return s.export(ctx, req, data, w)
 }
 
@@ -68,32 +149,85 @@ func (s *Service) export(ctx context.Context, req dto.ExportRequest, data *dto.E
 	if !ok {
 		return fmt.Errorf("unsupported export format: %s", req.Format)
 	}
-	return exporter.Export(ctx, data, req.TemplatePath, w)
+	if req.Format == dto.FormatPDF {
+		if ok, reason := s.renderer.Available(); !ok {
+			return fmt.Errorf("%w: %s", ErrPDFUnavailable, reason)
+		}
+	}
+	start := time.Now()
+	err := exporter.Export(ctx, data, req.Template, w)
+	metrics.ObserveExport(string(req.Format), data.Name, err, time.Since(start))
+	return err
 }
 
-// readingsToExportData converts a slice of readings into a format-agnostic ExportData.
-func readingsToExportData(readings []model.Reading) *dto.ExportData {
-	headers := []string{"ID", "Device ID", "Voltage", "Current", "Created At"}
-	rows := make([]dto.ExportRow, len(readings))
-	for i, r := range readings {
-		rows[i] = dto.ExportRow{
-			"ID":         r.ID,
-			"Device ID":  r.DeviceID,
-			"Voltage":    r.Voltage,
-			"Current":    r.Current,
-			"Created At": r.CreatedAt.Format(time.RFC3339),
+var readingColumns = []dto.Column{
+	{Name: "ID", Type: dto.ColumnInt},
+	{Name: "Device ID", Type: dto.ColumnInt, Tag: true},
+	{Name: "Device Name", Type: dto.ColumnString, Tag: true},
+	{Name: "Device Type", Type: dto.ColumnString, Tag: true},
+	{Name: "Voltage", Type: dto.ColumnFloat, Unit: "V"},
+	{Name: "Current", Type: dto.ColumnFloat, Unit: "A"},
+	{Name: "Created At", Type: dto.ColumnTime},
+}
+
+// readingRows iterates over readings one page at a time.
+type readingRows struct {
+	source    ReadingSource
+	deviceIDs []uint
+	devices   map[uint]appDto.DeviceView
+	start     time.Time
+	end       time.Time
+
+	page []model.Reading
+	last *model.Reading
+	done bool
+}
+
+func (r *readingRows) Next(ctx context.Context) (dto.ExportRow, error) {
+	if len(r.page) == 0 {
+		if r.done {
+			return nil, io.EOF
 		}
+		page, err := r.source.ListPageByDevicesAndDateRange(ctx, r.deviceIDs, r.start, r.end, r.last, readingsPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("fetch readings: %w", err)
+		}
+		if len(page) < readingsPageSize {
+			r.done = true
+		}
+		if len(page) == 0 {
+			return nil, io.EOF
+		}
+		r.page = page
 	}
-	return &dto.ExportData{
-		Title:   "Readings Export",
-		Headers: headers,
-		Rows:    rows,
-	}
+
+	reading := r.page[0]
+	r.page = r.page[1:]
+	r.last = &reading
+	device := r.devices[reading.DeviceID]
+	return dto.ExportRow{
+		"ID":          reading.ID,
+		"Device ID":   reading.DeviceID,
+		"Device Name": device.Name,
+		"Device Type": device.Type,
+		"Voltage":     reading.Voltage,
+		"Current":     reading.Current,
+		"Created At":  reading.CreatedAt,
+	}, nil
+}
+
+var deviceColumns = []dto.Column{
+	{Name: "ID", Type: dto.ColumnInt, Tag: true},
+	{Name: "Name", Type: dto.ColumnString, Tag: true},
+	{Name: "Type", Type: dto.ColumnString, Tag: true},
+	{Name: "Status", Type: dto.ColumnString},
+	{Name: "IP Address", Type: dto.ColumnString},
+	{Name: "MAC Address", Type: dto.ColumnString},
+	{Name: "Firmware Version", Type: dto.ColumnString},
 }
 
 // devicesToExportData converts a slice of device views into a format-agnostic ExportData.
 func devicesToExportData(devices []appDto.DeviceView) *dto.ExportData {
-	headers := []string{"ID", "Name", "Type", "Status", "IP Address", "MAC Address", "Firmware Version"}
 	rows := make([]dto.ExportRow, len(devices))
 	for i, d := range devices {
 		rows[i] = dto.ExportRow{
@@ -108,7 +242,8 @@ func devicesToExportData(devices []appDto.DeviceView) *dto.ExportData {
 	}
 	return &dto.ExportData{
 		Title:   "Devices Export",
-		Headers: headers,
-		Rows:    rows,
+		Name:    "devices",
+		Columns: deviceColumns,
+		Rows:    dto.SliceRows(rows),
 	}
 }
@@ -0,0 +1,78 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	appDto "github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/export/dto"
+	"github.com/aruncs31s/skvms/internal/metrics"
+)
+
+// summaryTemplate is the default template of device and location reports.
+const summaryTemplate = "summary_report.html"
+
+// summaryTemplateData is passed to the summary report template.
+type summaryTemplateData struct {
+	Report      *appDto.SummaryReport
+	GeneratedAt string
+	Devices     []summaryDeviceData
+}
+
+type summaryDeviceData struct {
+	appDto.DeviceSummary
+	VoltageChart template.HTML
+	CurrentChart template.HTML
+}
+
+// RenderSummaryPDF renders a device or location report to PDF, with SVG
+// charts of voltage and current for each device. source may be the source of
+// a library template; by default summary_report.html in the templates
+// directory is used.
+func (s *Service) RenderSummaryPDF(ctx context.Context, report *appDto.SummaryReport, source string, w io.Writer) error {
+	htmlContent, err := s.renderSummaryHTML(ctx, report, source)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	pdfBytes, err := s.renderer.RenderPDF(ctx, htmlContent)
+	metrics.ObserveExport(string(dto.FormatPDF), "summary", err, time.Since(start))
+	if err != nil {
+		return fmt.Errorf("pdf: generate pdf: %w", err)
+	}
+	_, err = w.Write(pdfBytes)
+	return err
+}
+
+// renderSummaryHTML renders a summary report with the template source, or
+// the default summary template when source is empty.
+func (s *Service) renderSummaryHTML(ctx context.Context, report *appDto.SummaryReport, source string) (string, error) {
+	if source == "" {
+		var err error
+		if source, err = s.DefaultTemplate(TemplateSummary); err != nil {
+			return "", fmt.Errorf("pdf: %w", err)
+		}
+	}
+	tmpl, err := parseTemplate(TemplateSummary, source)
+	if err != nil {
+		return "", err
+	}
+
+	data := summaryTemplateData{
+		Report:      report,
+		GeneratedAt: report.GeneratedAt.Format("2006-01-02 15:04:05"),
+		Devices:     make([]summaryDeviceData, len(report.Devices)),
+	}
+	for i, d := range report.Devices {
+		data.Devices[i] = summaryDeviceData{
+			DeviceSummary: d,
+			VoltageChart:  lineChartSVG("Voltage", "V", "#1a73e8", d.Voltage, report.Start, report.End),
+			CurrentChart:  lineChartSVG("Current", "A", "#e8710a", d.Current, report.Start, report.End),
+		}
+	}
+	return executeTemplate(ctx, tmpl, data)
+}
@@ -0,0 +1,263 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template/parse"
+	"time"
+)
+
+// Template data types: the data a PDF template is rendered with.
+const (
+	// TemplateReadings and TemplateDevices render a table of the exported
+	// rows (Title, GeneratedAt, Headers, Rows).
+	TemplateReadings = "readings"
+	TemplateDevices  = "devices"
+	// TemplateSummary renders a device or location report (Report,
+	// GeneratedAt, Devices with VoltageChart and CurrentChart).
+	TemplateSummary = "summary"
+)
+
+// TemplateDataTypes lists the data types templates can be written for.
+var TemplateDataTypes = []string{TemplateReadings, TemplateDevices, TemplateSummary}
+
+const (
+	// maxTemplateSize caps the source of a template.
+	maxTemplateSize = 256 << 10
+	// maxTemplateOutput caps the HTML a template may render.
+	maxTemplateOutput = 32 << 20
+	// maxTemplateDuration caps the time a template may take to render, so a
+	// loop such as {{range 1000000000}}{{end}} cannot pin a CPU.
+	maxTemplateDuration = 30 * time.Second
+)
+
+// tickFunc is called at the start of every template and every range
+// iteration to stop a render past its deadline. It is bound per render by
+// executeTemplate.
+const tickFunc = "__tick"
+
+// TemplateError reports a template that could not be parsed or rendered.
+// Its message names the template line at fault where possible.
+type TemplateError struct {
+	// Stage is "parse" or "render"
+	Stage string
+	Err   error
+}
+
+func (e *TemplateError) Error() string {
+	return "template " + e.Stage + " error: " + e.Err.Error()
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// templateFuncs are the only functions available to templates besides the
+// safe builtins. Templates cannot reach files, the environment or the network.
+var templateFuncs = template.FuncMap{
+	"f2": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"kwh": func(wh float64) string {
+		return fmt.Sprintf("%.3f", wh/1000)
+	},
+	"date":     func(t time.Time) string { return t.Format("2006-01-02") },
+	"datetime": func(t time.Time) string { return t.Format("2006-01-02 15:04") },
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	tickFunc:   func() (string, error) { return "", nil },
+}
+
+// blockedBuiltins are builtins templates may not use. call would run any
+// function value reachable from the data.
+var blockedBuiltins = map[string]bool{
+	"call": true,
+}
+
+// parseTemplate parses template source with the restricted function set.
+func parseTemplate(name, source string) (*template.Template, error) {
+	if len(source) > maxTemplateSize {
+		return nil, &TemplateError{Stage: "parse", Err: fmt.Errorf("template is larger than %d KiB", maxTemplateSize>>10)}
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(source)
+	if err != nil {
+		return nil, &TemplateError{Stage: "parse", Err: err}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		if err := checkNode(t.Tree, t.Tree.Root); err != nil {
+			return nil, &TemplateError{Stage: "parse", Err: err}
+		}
+		insertTicks(t.Tree, t.Tree.Root)
+	}
+	return tmpl, nil
+}
+
+// insertTicks prepends {{$tick := __tick}} to list and to every list nested
+// in it, which includes the body of each range. Being a declaration, it
+// renders nothing.
+func insertTicks(tree *parse.Tree, list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.IfNode:
+			insertTicks(tree, n.List)
+			insertTicks(tree, n.ElseList)
+		case *parse.RangeNode:
+			insertTicks(tree, n.List)
+			insertTicks(tree, n.ElseList)
+		case *parse.WithNode:
+			insertTicks(tree, n.List)
+			insertTicks(tree, n.ElseList)
+		}
+	}
+
+	pos := list.Position()
+	tick := &parse.ActionNode{
+		NodeType: parse.NodeAction,
+		Pos:      pos,
+		Pipe: &parse.PipeNode{
+			NodeType: parse.NodePipe,
+			Pos:      pos,
+			Decl: []*parse.VariableNode{
+				{NodeType: parse.NodeVariable, Pos: pos, Ident: []string{"$tick"}},
+			},
+			Cmds: []*parse.CommandNode{{
+				NodeType: parse.NodeCommand,
+				Pos:      pos,
+				Args:     []parse.Node{parse.NewIdentifier(tickFunc).SetTree(tree).SetPos(pos)},
+			}},
+		},
+	}
+	list.Nodes = append([]parse.Node{tick}, list.Nodes...)
+}
+
+// checkNode rejects blocked builtins anywhere below node.
+func checkNode(tree *parse.Tree, node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkNode(tree, child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkNode(tree, n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkNode(tree, cmd); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkNode(tree, arg); err != nil {
+				return err
+			}
+		}
+	case *parse.ChainNode:
+		return checkNode(tree, n.Node)
+	case *parse.IdentifierNode:
+		if blockedBuiltins[n.Ident] {
+			location, _ := tree.ErrorContext(n)
+			return fmt.Errorf("%s: function %q is not allowed", location, n.Ident)
+		}
+	case *parse.IfNode:
+		return checkBranch(tree, &n.BranchNode)
+	case *parse.RangeNode:
+		return checkBranch(tree, &n.BranchNode)
+	case *parse.WithNode:
+		return checkBranch(tree, &n.BranchNode)
+	case *parse.TemplateNode:
+		return checkNode(tree, n.Pipe)
+	}
+	return nil
+}
+
+func checkBranch(tree *parse.Tree, n *parse.BranchNode) error {
+	if err := checkNode(tree, n.Pipe); err != nil {
+		return err
+	}
+	if err := checkNode(tree, n.List); err != nil {
+		return err
+	}
+	return checkNode(tree, n.ElseList)
+}
+
+var errTemplateOutput = fmt.Errorf("rendered HTML is larger than %d MiB", maxTemplateOutput>>20)
+
+var errTemplateTimeout = fmt.Errorf("rendering took longer than %s", maxTemplateDuration)
+
+// limitedBuffer fails writes past maxTemplateOutput, so a template cannot
+// exhaust memory, and writes after the render was cancelled.
+type limitedBuffer struct {
+	bytes.Buffer
+	stopped func() error
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if err := b.stopped(); err != nil {
+		return 0, err
+	}
+	if b.Len()+len(p) > maxTemplateOutput {
+		return 0, errTemplateOutput
+	}
+	return b.Buffer.Write(p)
+}
+
+// executeTemplate renders tmpl, as returned by parseTemplate, with data to
+// HTML. The render stops when ctx is done or after maxTemplateDuration.
+func executeTemplate(ctx context.Context, tmpl *template.Template, data interface{}) (string, error) {
+	renderCtx, cancel := context.WithTimeout(ctx, maxTemplateDuration)
+	defer cancel()
+	stopped := func() error {
+		if renderCtx.Err() == nil {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("rendering stopped: %w", err)
+		}
+		return errTemplateTimeout
+	}
+
+	tmpl.Funcs(template.FuncMap{tickFunc: func() (string, error) {
+		return "", stopped()
+	}})
+	buf := limitedBuffer{stopped: stopped}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", &TemplateError{Stage: "render", Err: err}
+	}
+	return buf.String(), nil
+}
+
+// DefaultTemplate returns the source of the built-in template of a data type.
+func (s *Service) DefaultTemplate(dataType string) (string, error) {
+	var name string
+	switch dataType {
+	case TemplateReadings, TemplateDevices:
+		name = dataType + ".html"
+	case TemplateSummary:
+		name = summaryTemplate
+	default:
+		return "", fmt.Errorf("unknown template data type: %s", dataType)
+	}
+
+	source, err := os.ReadFile(filepath.Join(s.templatesDir, name))
+	if err != nil {
+		return "", fmt.Errorf("default template not found: %w", err)
+	}
+	return string(source), nil
+}
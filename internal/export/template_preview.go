@@ -0,0 +1,148 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	appDto "github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/export/dto"
+)
+
+// ValidateTemplate parses template source and renders it with sample data
+// of the data type, so mistakes such as unknown fields are reported when the
+// template is saved rather than when it is first used. Failures are
+// *TemplateError.
+func (s *Service) ValidateTemplate(ctx context.Context, dataType, source string) error {
+	_, err := s.renderSample(ctx, dataType, source)
+	return err
+}
+
+// PreviewTemplate renders template source with sample data of the data type
+// and writes it to w as HTML, or as PDF when asPDF is set.
+func (s *Service) PreviewTemplate(ctx context.Context, dataType, source string, asPDF bool, w io.Writer) error {
+	htmlContent, err := s.renderSample(ctx, dataType, source)
+	if err != nil {
+		return err
+	}
+	if !asPDF {
+		_, err = io.WriteString(w, htmlContent)
+		return err
+	}
+
+	pdfBytes, err := s.renderer.RenderPDF(ctx, htmlContent)
+	if err != nil {
+		return fmt.Errorf("pdf: generate pdf: %w", err)
+	}
+	_, err = w.Write(pdfBytes)
+	return err
+}
+
+// renderSample renders source to HTML with sample data of the data type.
+func (s *Service) renderSample(ctx context.Context, dataType, source string) (string, error) {
+	var data *dto.ExportData
+	switch dataType {
+	case TemplateReadings:
+		data = sampleReadings()
+	case TemplateDevices:
+		data = devicesToExportData(sampleDevices)
+	case TemplateSummary:
+		return s.renderSummaryHTML(ctx, sampleSummary(), source)
+	default:
+		return "", fmt.Errorf("unknown template data type: %s", dataType)
+	}
+
+	tmpl, err := parseTemplate(dataType, source)
+	if err != nil {
+		return "", err
+	}
+	td, err := tableTemplateData(ctx, data)
+	if err != nil {
+		return "", err
+	}
+	return executeTemplate(ctx, tmpl, td)
+}
+
+var sampleDevices = []appDto.DeviceView{
+	{ID: 1, Name: "Roof Panel A", Type: "Solar Panel", Status: "active",
+		IPAddress: "192.168.1.21", MACAddress: "AA:BB:CC:00:00:01", FirmwareVersion: "1.4.0"},
+	{ID: 2, Name: "Battery Bank", Type: "Battery", Status: "inactive",
+		IPAddress: "192.168.1.22", MACAddress: "AA:BB:CC:00:00:02", FirmwareVersion: "1.3.2"},
+}
+
+// sampleReadings returns a day of hourly readings of the first sample device.
+func sampleReadings() *dto.ExportData {
+	device := sampleDevices[0]
+	end := time.Now().Truncate(time.Hour)
+	rows := make([]dto.ExportRow, 24)
+	for i := range rows {
+		v, a := sampleValues(i)
+		rows[i] = dto.ExportRow{
+			"ID":          uint(1000 + i),
+			"Device ID":   device.ID,
+			"Device Name": device.Name,
+			"Device Type": device.Type,
+			"Voltage":     v,
+			"Current":     a,
+			"Created At":  end.Add(-time.Duration(i) * time.Hour),
+		}
+	}
+	return &dto.ExportData{
+		Title:   "Readings Export",
+		Name:    "readings",
+		Columns: readingColumns,
+		Rows:    dto.SliceRows(rows),
+	}
+}
+
+// sampleSummary returns a location report of the sample devices over a day.
+func sampleSummary() *appDto.SummaryReport {
+	end := time.Now().Truncate(time.Hour)
+	start := end.Add(-24 * time.Hour)
+	report := &appDto.SummaryReport{
+		Kind:          appDto.SummaryKindLocation,
+		Title:         "Sample Location Report",
+		LocationID:    1,
+		LocationName:  "Sample Site",
+		Start:         start,
+		End:           end,
+		GeneratedAt:   time.Now(),
+		Thresholds:    appDto.AlertThresholds{MinVoltage: 11, MaxVoltage: 14, MaxCurrent: 8, OfflineAfterMinutes: 15},
+		UptimePercent: 97.5,
+	}
+	for _, device := range sampleDevices {
+		summary := appDto.DeviceSummary{
+			Device:        device,
+			Windows:       []appDto.TimeWindow{{Start: start, End: end}},
+			Readings:      24,
+			EnergyWh:      1250,
+			UptimePercent: 97.5,
+			Alerts:        appDto.AlertCounts{LowVoltage: 1, Offline: 1},
+			Daily: []appDto.DailySummary{{
+				Day: start.Truncate(24 * time.Hour), Count: 24,
+				MinVoltage: 11.2, MaxVoltage: 13.4, AvgVoltage: 12.3,
+				MinCurrent: 0.4, MaxCurrent: 6.1, AvgCurrent: 3.2,
+			}},
+		}
+		for i := 0; i < 24; i++ {
+			v, a := sampleValues(i)
+			t := start.Add(time.Duration(i) * time.Hour)
+			summary.Voltage = append(summary.Voltage, appDto.SeriesPoint{Time: t, Value: v})
+			summary.Current = append(summary.Current, appDto.SeriesPoint{Time: t, Value: a})
+		}
+		report.Devices = append(report.Devices, summary)
+		report.Readings += summary.Readings
+		report.EnergyWh += summary.EnergyWh
+		report.Alerts.LowVoltage += summary.Alerts.LowVoltage
+		report.Alerts.Offline += summary.Alerts.Offline
+	}
+	return report
+}
+
+// sampleValues returns a plausible voltage and current for hour i of a day.
+func sampleValues(i int) (float64, float64) {
+	sun := math.Max(0, math.Sin(float64(i-6)/12*math.Pi))
+	return math.Round((11.8+1.6*sun)*100) / 100, math.Round(6*sun*100) / 100
+}
@@ -0,0 +1,47 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteTemplate(t *testing.T) {
+	tmpl, err := parseTemplate("t", `<p>{{range .}}{{.}},{{end}}</p><script>var n = {{len .}};</script>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := executeTemplate(context.Background(), tmpl, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<p>a,b,</p><script>var n =  2 ;</script>`; html != want {
+		t.Errorf("rendered %q, want %q", html, want)
+	}
+}
+
+func TestExecuteTemplateStopsAtDeadline(t *testing.T) {
+	for _, source := range []string{
+		`{{range 1000000000}}{{end}}`,
+		`{{define "loop"}}{{range 1000}}{{end}}{{template "loop"}}{{end}}{{template "loop"}}`,
+	} {
+		tmpl, err := parseTemplate("t", source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		_, err = executeTemplate(ctx, tmpl, nil)
+		cancel()
+
+		var templateErr *TemplateError
+		if !errors.As(err, &templateErr) || !strings.Contains(err.Error(), "deadline") {
+			t.Errorf("%s: error = %v, want a render error past the deadline", source, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s: render took %s after the deadline", source, elapsed)
+		}
+	}
+}
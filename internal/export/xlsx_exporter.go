@@ -23,7 +23,10 @@ func (e *xlsxExporter) Format() dto.ExportFormat {
 	return dto.FormatXLSX
 }
 
-func (e *xlsxExporter) Export(_ context.Context, data *dto.ExportData, _ string, w io.Writer) error {
+// Export writes the workbook through excelize's StreamWriter, which spills
+// rows to a temporary file instead of keeping the whole sheet in memory.
+func (e *xlsxExporter) Export(ctx context.Context, data *dto.ExportData, _ string, w io.Writer) error {
+	headers := data.Headers()
 	f := excelize.NewFile()
 	defer f.Close()
 
@@ -50,39 +53,70 @@ func (e *xlsxExporter) Export(_ context.Context, data *dto.ExportData, _ string,
 		return fmt.Errorf("xlsx: create header style: %w", err)
 	}
 
-	for col, h := range data.Headers {
-		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
-		if err := f.SetCellValue(sheetName, cell, h); err != nil {
-			return fmt.Errorf("xlsx: set header cell: %w", err)
+	header := make([]interface{}, len(headers))
+	for col, h := range headers {
+		header[col] = excelize.Cell{StyleID: headerStyle, Value: h}
+	}
+
+	// startSheet opens a stream on the named sheet and writes the header row
+	startSheet := func(name string) (*excelize.StreamWriter, error) {
+		sw, err := f.NewStreamWriter(name)
+		if err != nil {
+			return nil, fmt.Errorf("xlsx: create stream writer: %w", err)
+		}
+		// Column widths must be set before the first row is streamed
+		for col, h := range headers {
+			width := float64(len(h) + columnPadding)
+			if err := sw.SetColWidth(col+1, col+1, width); err != nil {
+				return nil, fmt.Errorf("xlsx: set column width: %w", err)
+			}
 		}
-		if err := f.SetCellStyle(sheetName, cell, cell, headerStyle); err != nil {
-			return fmt.Errorf("xlsx: set header style: %w", err)
+		if err := sw.SetRow("A1", header); err != nil {
+			return nil, fmt.Errorf("xlsx: set header row: %w", err)
 		}
+		return sw, nil
+	}
+
+	sw, err := startSheet(sheetName)
+	if err != nil {
+		return err
 	}
 
-	// Write data rows
-	for rowIdx, row := range data.Rows {
-		for colIdx, h := range data.Headers {
-			cell, _ := excelize.CoordinatesToCellName(colIdx+1, rowIdx+2)
-			val := ""
-			if v, ok := row[h]; ok {
-				val = fmt.Sprintf("%v", v)
+	// Write data rows, continuing on a new sheet when one is full
+	rowIdx, sheets := 2, 1
+	values := make([]interface{}, len(headers))
+	err = forEachRow(ctx, data.Rows, func(row dto.ExportRow) error {
+		if rowIdx > excelize.TotalRows {
+			if err := sw.Flush(); err != nil {
+				return fmt.Errorf("xlsx: flush stream: %w", err)
 			}
-			if err := f.SetCellValue(sheetName, cell, val); err != nil {
-				return fmt.Errorf("xlsx: set data cell: %w", err)
+			sheets++
+			name := fmt.Sprintf("%s (%d)", sheetName, sheets)
+			if _, err := f.NewSheet(name); err != nil {
+				return fmt.Errorf("xlsx: add sheet: %w", err)
 			}
+			if sw, err = startSheet(name); err != nil {
+				return err
+			}
+			rowIdx = 2
 		}
-	}
-
-	// Auto-fit columns
-	for col, h := range data.Headers {
-		colName, _ := excelize.ColumnNumberToName(col + 1)
-		width := float64(len(h) + columnPadding)
-		if err := f.SetColWidth(sheetName, colName, colName, width); err != nil {
-			return fmt.Errorf("xlsx: set column width: %w", err)
+		for i, val := range rowCells(row, headers) {
+			values[i] = val
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, rowIdx)
+		if err := sw.SetRow(cell, values); err != nil {
+			return fmt.Errorf("xlsx: set data row: %w", err)
 		}
+		rowIdx++
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	if err := sw.Flush(); err != nil {
+		return fmt.Errorf("xlsx: flush stream: %w", err)
+	}
 	if _, err := f.WriteTo(w); err != nil {
 		return fmt.Errorf("xlsx: write to writer: %w", err)
 	}
@@ -32,41 +32,47 @@ type xmlRow struct {
 	Fields  []xmlField `xml:",any"`
 }
 
-// xmlDocument is the root XML element.
-type xmlDocument struct {
-	XMLName xml.Name `xml:"export"`
-	Title   string   `xml:"title,attr"`
-	Rows    []xmlRow `xml:"row"`
-}
+func (e *xmlExporter) Export(ctx context.Context, data *dto.ExportData, _ string, w io.Writer) error {
+	headers := data.Headers()
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return fmt.Errorf("xml: write header: %w", err)
+	}
 
-func (e *xmlExporter) Export(_ context.Context, data *dto.ExportData, _ string, w io.Writer) error {
-	doc := xmlDocument{
-		Title: data.Title,
-		Rows:  make([]xmlRow, 0, len(data.Rows)),
+	// Names are sanitized once; rows are encoded one at a time under the
+	// root element so the document is never held in memory
+	names := make([]xml.Name, len(headers))
+	for i, h := range headers {
+		names[i] = xml.Name{Local: sanitizeXMLName(h)}
 	}
 
-	for _, r := range data.Rows {
-		row := xmlRow{XMLName: xml.Name{Local: "row"}}
-		for _, h := range data.Headers {
-			val := ""
-			if v, ok := r[h]; ok {
-				val = fmt.Sprintf("%v", v)
-			}
-			row.Fields = append(row.Fields, xmlField{
-				XMLName: xml.Name{Local: sanitizeXMLName(h)},
-				Value:   val,
-			})
-		}
-		doc.Rows = append(doc.Rows, row)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	root := xml.StartElement{
+		Name: xml.Name{Local: "export"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "title"}, Value: data.Title}},
+	}
+	if err := enc.EncodeToken(root); err != nil {
+		return fmt.Errorf("xml: encode: %w", err)
 	}
 
-	if _, err := w.Write([]byte(xml.Header)); err != nil {
-		return fmt.Errorf("xml: write header: %w", err)
+	err := forEachRow(ctx, data.Rows, func(r dto.ExportRow) error {
+		row := xmlRow{
+			XMLName: xml.Name{Local: "row"},
+			Fields:  make([]xmlField, len(names)),
+		}
+		for i, val := range rowCells(r, headers) {
+			row.Fields[i] = xmlField{XMLName: names[i], Value: val}
+		}
+		if err := enc.Encode(row); err != nil {
+			return fmt.Errorf("xml: encode: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	enc := xml.NewEncoder(w)
-	enc.Indent("", "  ")
-	if err := enc.Encode(doc); err != nil {
+	if err := enc.EncodeToken(root.End()); err != nil {
 		return fmt.Errorf("xml: encode: %w", err)
 	}
 	return enc.Flush()
@@ -1,35 +1,224 @@
 package http
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/export"
+	exportdto "github.com/aruncs31s/skvms/internal/export/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
+	"github.com/aruncs31s/skvms/internal/logger"
 	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type AuditHandler struct {
-	auditService service.AuditService
+	auditService  service.AuditService
+	exportService *export.Service
 }
 
-func NewAuditHandler(auditService service.AuditService) *AuditHandler {
-	return &AuditHandler{auditService: auditService}
+func NewAuditHandler(auditService service.AuditService, exportService *export.Service) *AuditHandler {
+	return &AuditHandler{
+		auditService:  auditService,
+		exportService: exportService,
+	}
 }
 
+// ListAuditLogs handles GET /api/audit
+// Query parameters (all optional):
+//
+//	user_id, username, action, resource_type, resource_id, ip,
+//	status (success, failure), request_id
+//	from, to - RFC 3339 times or 2006-01-02 dates; to is inclusive
+//	cursor   - next_cursor of the previous page
+//	limit    - entries per page (default 100, max 1000)
 func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
-	action := c.Query("action")
-	limit := 100
-	if c.Query("limit") != "" {
-		if v, err := strconv.Atoi(c.Query("limit")); err == nil {
-			limit = v
-		}
+	filter, err := auditFilter(c)
+	if err != nil {
+		utils.BindError(c, err)
+		return
 	}
 
-	logs, err := h.auditService.List(c.Request.Context(), action, limit)
+	page, err := h.auditService.Search(c.Request.Context(), filter)
 	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to search audit logs", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load audit logs"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"logs": logs})
+	c.JSON(http.StatusOK, page)
+}
+
+// GetAuditLog handles GET /api/audit/:id
+func (h *AuditHandler) GetAuditLog(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid audit log id"})
+		return
+	}
+
+	log, err := h.auditService.Get(c.Request.Context(), uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "audit log not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, log)
+}
+
+// ExportAuditLogs handles GET /api/audit/export
+// Streams every entry matching the filters of ListAuditLogs, newest first.
+// Query parameters:
+//
+//	format - csv (default), xlsx, xml, parquet, ndjson or influx
+func (h *AuditHandler) ExportAuditLogs(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	filter.Cursor = 0
+
+	req := exportdto.ExportRequest{
+		Format:   exportdto.ExportFormat(c.DefaultQuery("format", string(exportdto.FormatCSV))),
+		DataType: "audit",
+	}
+	if req.Format == exportdto.FormatPDF || !h.exportService.Supports(req.Format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported export format for audit logs: %s", req.Format)})
+		return
+	}
+
+	writeExport(c, req, func(w io.Writer) error {
+		return h.exportService.ExportAuditLogs(c.Request.Context(), req, h.auditService, filter, w)
+	})
+}
+
+// VerifyAuditChain handles GET /api/audit/verify
+// Recomputes the hash chain and checks it against the signed checkpoints.
+// Query parameters (optional):
+//
+//	from_seq - first entry to verify (default 1)
+//	to_seq   - last entry to verify (default the end of the chain)
+func (h *AuditHandler) VerifyAuditChain(c *gin.Context) {
+	var fromSeq, toSeq uint64
+	var err error
+	if v := c.Query("from_seq"); v != "" {
+		if fromSeq, err = strconv.ParseUint(v, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from_seq"})
+			return
+		}
+	}
+	if v := c.Query("to_seq"); v != "" {
+		if toSeq, err = strconv.ParseUint(v, 10, 64); err != nil || toSeq < fromSeq {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to_seq"})
+			return
+		}
+	}
+
+	result, err := h.auditService.Verify(c.Request.Context(), fromSeq, toSeq)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to verify audit chain", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify audit chain"})
+		return
+	}
+	if !result.Valid {
+		logger.FromContext(c.Request.Context()).Warn("Audit chain verification failed",
+			zap.Int("problems", len(result.Problems)),
+			zap.Uint64("from_seq", result.FromSeq),
+			zap.Uint64("to_seq", result.ToSeq),
+		)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ListCheckpoints handles GET /api/audit/checkpoints
+// Query parameters:
+//
+//	limit - number of checkpoints, newest first (optional, default 100)
+func (h *AuditHandler) ListCheckpoints(c *gin.Context) {
+	limit := 100
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = v
+	}
+
+	checkpoints, err := h.auditService.ListCheckpoints(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load audit checkpoints"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"checkpoints": checkpoints})
+}
+
+// CreateCheckpoint handles POST /api/audit/checkpoints
+// Appends queued entries to the chain and signs a checkpoint of its end
+// without waiting for the next scheduled one.
+func (h *AuditHandler) CreateCheckpoint(c *gin.Context) {
+	if _, err := h.auditService.Flush(c.Request.Context()); err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to append audit entries", zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "failed to append queued audit entries"})
+		return
+	}
+
+	checkpoint, err := h.auditService.Checkpoint(c.Request.Context())
+	if errors.Is(err, service.ErrAuditChainEmpty) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to checkpoint audit chain", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to checkpoint audit chain"})
+		return
+	}
+
+	entry := middleware.AuditEntry(c, "audit_checkpoint", "audit_checkpoint", strconv.FormatUint(uint64(checkpoint.Seq), 10))
+	entry.After = checkpoint
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
+	c.JSON(http.StatusCreated, checkpoint)
+}
+
+// auditFilter reads the audit log filters from the query string.
+func auditFilter(c *gin.Context) (dto.AuditLogFilter, error) {
+	var filter dto.AuditLogFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		return filter, err
+	}
+	var err error
+	if filter.From, err = parseAuditTime(c.Query("from"), false); err != nil {
+		return filter, &dto.ValidationError{In: "query", Field: "from", Message: err.Error()}
+	}
+	if filter.To, err = parseAuditTime(c.Query("to"), true); err != nil {
+		return filter, &dto.ValidationError{In: "query", Field: "to", Message: err.Error()}
+	}
+	return filter, nil
+}
+
+// parseAuditTime parses an RFC 3339 time or a 2006-01-02 date in server
+// time. A date used as the end of a range covers the whole day.
+func parseAuditTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("must be an RFC 3339 time or a 2006-01-02 date")
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
 }
@@ -2,11 +2,14 @@ package http
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
 	"github.com/aruncs31s/skvms/internal/logger"
 	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -24,14 +27,14 @@ func NewAuthHandler(authService service.AuthService, auditService service.AuditS
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req dto.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BindError(c, err)
 		return
 	}
 
 	user, err := h.authService.Register(c.Request.Context(), &req)
 	if err != nil {
 		if strings.Contains(err.Error(), "Duplicate") {
-			logger.GetLogger().Error("User registration failed - duplicate username or email",
+			logger.FromContext(c.Request.Context()).Error("User registration failed - duplicate username or email",
 				zap.String("username", req.Username),
 				zap.String("ip", c.ClientIP()),
 				zap.Error(err),
@@ -43,7 +46,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 			})
 			return
 		} else {
-			logger.GetLogger().Error("User registration failed",
+			logger.FromContext(c.Request.Context()).Error("User registration failed",
 				zap.String("username", req.Username),
 				zap.String("ip", c.ClientIP()),
 				zap.Error(err),
@@ -53,14 +56,14 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	logger.GetLogger().Info("User registered successfully",
+	logger.FromContext(c.Request.Context()).Info("User registered successfully",
 		zap.String("username", req.Username),
 		zap.String("ip", c.ClientIP()),
 	)
 
 	accessToken, refreshToken, user, err := h.authService.Login(c.Request.Context(), user.Username, req.Password)
 	if err != nil {
-		logger.GetLogger().Error("Login failed",
+		logger.FromContext(c.Request.Context()).Error("Login failed",
 			zap.String("username", user.Username),
 			zap.String("ip", c.ClientIP()),
 			zap.Error(err),
@@ -76,9 +79,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	// Log successful login
 	ipAddress := c.ClientIP()
-	_ = h.auditService.Log(c.Request.Context(), user.ID, user.Username, "login", "User logged in successfully", ipAddress)
+	entry := middleware.AuditEntry(c, "login", "user", strconv.FormatUint(uint64(user.ID), 10))
+	entry.UserID, entry.Username = user.ID, user.Username
+	_ = h.auditService.Record(c.Request.Context(), entry)
 
-	logger.GetLogger().Info("User logged in successfully",
+	logger.FromContext(c.Request.Context()).Info("User logged in successfully",
 		zap.String("username", user.Username),
 		zap.Uint("user_id", user.ID),
 		zap.String("ip", ipAddress),
@@ -99,11 +104,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req dto.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(
-			http.StatusBadRequest,
-			gin.H{
-				"error": "invalid request",
-			})
+		utils.BindError(c, err)
 		return
 	}
 
@@ -120,9 +121,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Log successful login
 	ipAddress := c.ClientIP()
-	_ = h.auditService.Log(c.Request.Context(), user.ID, user.Username, "login", "User logged in successfully", ipAddress)
+	entry := middleware.AuditEntry(c, "login", "user", strconv.FormatUint(uint64(user.ID), 10))
+	entry.UserID, entry.Username = user.ID, user.Username
+	_ = h.auditService.Record(c.Request.Context(), entry)
 
-	logger.GetLogger().Info("User logged in successfully",
+	logger.FromContext(c.Request.Context()).Info("User logged in successfully",
 		zap.String("username", user.Username),
 		zap.Uint("user_id", user.ID),
 		zap.String("ip", ipAddress),
@@ -144,7 +147,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 func (h *AuthHandler) Refresh(c *gin.Context) {
 	var req dto.RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BindError(c, err)
 		return
 	}
 
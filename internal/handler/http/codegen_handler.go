@@ -1,15 +1,20 @@
 package http
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
+	"strconv"
 
 	"github.com/aruncs31s/skvms/internal/codegen"
 	"github.com/aruncs31s/skvms/internal/codegen/dto"
 	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // CodeGenHandler handles HTTP requests for ESP32 firmware code generation.
@@ -29,19 +34,13 @@ func NewCodeGenHandler(codegenService *codegen.Service) *CodeGenHandler {
 func (h *CodeGenHandler) Generate(c *gin.Context) {
 	var req dto.CodeGenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid request body",
-			"details": err.Error(),
-		})
+		utils.BindError(c, err)
 		return
 	}
 
-	// Set default port if not specified
-	if req.Port == 0 {
-		req.Port = 8080
-	}
+	req.UserID = codegenUserID(c)
 
-	logger.GetLogger().Info("Codegen request received",
+	logger.FromContext(c.Request.Context()).Info("Codegen request received",
 		zap.String("device_ip", req.IP),
 		zap.String("host_ip", req.HostIP),
 		zap.String("wifi_ssid", req.HOSTSSID),
@@ -50,22 +49,22 @@ func (h *CodeGenHandler) Generate(c *gin.Context) {
 
 	result, err := h.codegenService.Generate(c.Request.Context(), req)
 	if err != nil {
-		logger.GetLogger().Error("Codegen failed",
+		logger.FromContext(c.Request.Context()).Error("Codegen failed",
 			zap.Error(err),
 			zap.String("device_ip", req.IP),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "firmware generation failed",
-			"details": err.Error(),
-		})
+		respondCodegenError(c, "firmware generation failed", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, dto.CodeGenResponse{
 		Message:    "Firmware built successfully",
 		BuildTool:  result.BuildTool,
+		Board:      result.Board,
+		BoardFQBN:  result.BoardFQBN,
 		BinarySize: result.BinarySize,
 		BuildID:    result.BuildID,
+		Cached:     result.Cached,
 	})
 }
 
@@ -74,47 +73,113 @@ func (h *CodeGenHandler) Generate(c *gin.Context) {
 func (h *CodeGenHandler) Build(c *gin.Context) {
 	var req dto.CodeGenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid request body",
-			"details": err.Error(),
-		})
+		utils.BindError(c, err)
 		return
 	}
 
-	if req.Port == 0 {
-		req.Port = 8080
-	}
+	req.UserID = codegenUserID(c)
 
 	result, err := h.codegenService.Generate(c.Request.Context(), req)
 	if err != nil {
-		logger.GetLogger().Error("Codegen failed",
+		logger.FromContext(c.Request.Context()).Error("Codegen failed",
 			zap.Error(err),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "firmware generation failed",
-			"details": err.Error(),
-		})
+		respondCodegenError(c, "firmware generation failed", err)
 		return
 	}
 
-	downloadURL := fmt.Sprintf("/api/codegen/download/%s", result.BuildID)
-	if c.Request != nil && c.Request.Host != "" {
-		scheme := "http"
-		if c.Request.TLS != nil {
-			scheme = "https"
-		}
-		downloadURL = fmt.Sprintf("%s://%s%s", scheme, c.Request.Host, downloadURL)
-	}
-
 	c.JSON(http.StatusOK, dto.CodeGenResponse{
 		Message:     "Firmware built successfully",
 		BuildTool:   result.BuildTool,
+		Board:       result.Board,
+		BoardFQBN:   result.BoardFQBN,
 		BinarySize:  result.BinarySize,
 		BuildID:     result.BuildID,
-		DownloadURL: downloadURL,
+		DownloadURL: downloadURL(c, result.BuildID),
+		Cached:      result.Cached,
 	})
 }
 
+// BuildMulti handles POST /api/codegen/build-multi
+// Builds the same configuration for several boards, one per device type in
+// device_type_ids, and returns a download URL for each binary.
+func (h *CodeGenHandler) BuildMulti(c *gin.Context) {
+	var req dto.CodeGenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	if len(req.DeviceTypeIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_type_ids is required"})
+		return
+	}
+	if req.BoardFQBN != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": codegen.ErrFQBNWithTargets.Error()})
+		return
+	}
+
+	req.UserID = codegenUserID(c)
+
+	results, failed, err := h.codegenService.GenerateMulti(c.Request.Context(), req)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Multi-target codegen failed",
+			zap.Error(err),
+		)
+		respondCodegenError(c, "firmware generation failed", err)
+		return
+	}
+
+	resp := dto.MultiBuildResponse{
+		Message: fmt.Sprintf("Built firmware for %d of %d boards", len(results), len(req.DeviceTypeIDs)),
+		Builds:  []dto.CodeGenResponse{},
+	}
+	for _, result := range results {
+		resp.Builds = append(resp.Builds, dto.CodeGenResponse{
+			Message:     "Firmware built successfully",
+			BuildTool:   result.BuildTool,
+			Board:       result.Board,
+			BoardFQBN:   result.BoardFQBN,
+			BinarySize:  result.BinarySize,
+			BuildID:     result.BuildID,
+			DownloadURL: downloadURL(c, result.BuildID),
+			Cached:      result.Cached,
+		})
+	}
+	for _, f := range failed {
+		resp.Failed = append(resp.Failed, dto.TargetBuildError{
+			DeviceTypeID: f.DeviceTypeID,
+			Board:        f.Board,
+			Error:        f.Err.Error(),
+		})
+	}
+
+	status := http.StatusOK
+	if len(results) == 0 {
+		status = http.StatusInternalServerError
+	}
+	c.JSON(status, resp)
+}
+
+// codegenUserID returns the authenticated user, who device tokens are issued for.
+func codegenUserID(c *gin.Context) uint {
+	userID, _ := c.Get("user_id")
+	id, _ := userID.(uint)
+	return id
+}
+
+// downloadURL builds an absolute download URL for a build when the request host is known.
+func downloadURL(c *gin.Context, buildID string) string {
+	url := fmt.Sprintf("/api/codegen/download/%s", buildID)
+	if c.Request != nil && c.Request.Host != "" {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		url = fmt.Sprintf("%s://%s%s", scheme, c.Request.Host, url)
+	}
+	return url
+}
+
 // Download handles GET /api/codegen/download/:build_id
 // Serves the compiled firmware binary for download.
 func (h *CodeGenHandler) Download(c *gin.Context) {
@@ -126,7 +191,7 @@ func (h *CodeGenHandler) Download(c *gin.Context) {
 
 	binaryPath, err := h.codegenService.GetBinaryPath(buildID)
 	if err != nil {
-		logger.GetLogger().Error("Binary not found",
+		logger.FromContext(c.Request.Context()).Error("Binary not found",
 			zap.String("build_id", buildID),
 			zap.Error(err),
 		)
@@ -149,26 +214,18 @@ func (h *CodeGenHandler) Download(c *gin.Context) {
 func (h *CodeGenHandler) GenerateAndDownload(c *gin.Context) {
 	var req dto.CodeGenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid request body",
-			"details": err.Error(),
-		})
+		utils.BindError(c, err)
 		return
 	}
 
-	if req.Port == 0 {
-		req.Port = 8080
-	}
+	req.UserID = codegenUserID(c)
 
 	result, err := h.codegenService.Generate(c.Request.Context(), req)
 	if err != nil {
-		logger.GetLogger().Error("Codegen failed",
+		logger.FromContext(c.Request.Context()).Error("Codegen failed",
 			zap.Error(err),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "firmware generation failed",
-			"details": err.Error(),
-		})
+		respondCodegenError(c, "firmware generation failed", err)
 		return
 	}
 
@@ -179,6 +236,7 @@ func (h *CodeGenHandler) GenerateAndDownload(c *gin.Context) {
 	c.Header("Content-Type", "application/octet-stream")
 	c.Header("X-Build-ID", result.BuildID)
 	c.Header("X-Build-Tool", result.BuildTool)
+	c.Header("X-Build-Cached", strconv.FormatBool(result.Cached))
 	c.File(result.BinaryPath)
 }
 
@@ -191,31 +249,23 @@ func (h *CodeGenHandler) Upload(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid request body",
-			"details": err.Error(),
-		})
+		utils.BindError(c, err)
 		return
 	}
 
-	if req.Port == 0 {
-		req.Port = 8080
-	}
+	req.UserID = codegenUserID(c)
 
-	logger.GetLogger().Info("OTA upload request received",
+	logger.FromContext(c.Request.Context()).Info("OTA upload request received",
 		zap.String("device_ip", req.DeviceIP),
 		zap.String("host_ip", req.HostIP),
 	)
 
 	if err := h.codegenService.Upload(c.Request.Context(), req.CodeGenRequest, req.DeviceIP); err != nil {
-		logger.GetLogger().Error("OTA upload failed",
+		logger.FromContext(c.Request.Context()).Error("OTA upload failed",
 			zap.Error(err),
 			zap.String("device_ip", req.DeviceIP),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "OTA upload failed",
-			"details": err.Error(),
-		})
+		respondCodegenError(c, "OTA upload failed", err)
 		return
 	}
 
@@ -225,6 +275,54 @@ func (h *CodeGenHandler) Upload(c *gin.Context) {
 	})
 }
 
+// Schema handles GET /api/codegen/schema
+// Returns the config schema of the firmware source so clients know which
+// keys a codegen request must provide. Secret defaults are not included.
+func (h *CodeGenHandler) Schema(c *gin.Context) {
+	schema, err := h.codegenService.Schema()
+	if err != nil {
+		respondCodegenError(c, "failed to load config schema", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"legacy": schema.IsLegacy(),
+		"schema": schema,
+	})
+}
+
+// respondCodegenError maps config validation failures to 400 with every
+// problem listed, a missing WiFi profile or device to 404, and anything
+// else to 500.
+func respondCodegenError(c *gin.Context, message string, err error) {
+	if errors.Is(err, service.ErrLocationAccess) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+		return
+	}
+	var cfgErr *codegen.ConfigError
+	if errors.As(err, &cfgErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    message,
+			"details":  err.Error(),
+			"problems": cfgErr.Problems,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
+
 // ListTools handles GET /api/codegen/tools
 // Returns the list of available build tools.
 func (h *CodeGenHandler) ListTools(c *gin.Context) {
@@ -246,3 +344,30 @@ func (h *CodeGenHandler) Cleanup(c *gin.Context) {
 	h.codegenService.CleanupBuild(buildID)
 	c.JSON(http.StatusOK, gin.H{"message": "build cleaned up", "build_id": buildID})
 }
+
+// CacheStats handles GET /api/codegen/cache
+// Reports how many object dirs and images the build cache holds.
+func (h *CodeGenHandler) CacheStats(c *gin.Context) {
+	stats, err := h.codegenService.CacheStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "failed to read build cache",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// PurgeCache handles DELETE /api/codegen/cache
+// Removes all cached objects and images; the next builds compile from scratch.
+func (h *CodeGenHandler) PurgeCache(c *gin.Context) {
+	if err := h.codegenService.PurgeCache(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "failed to purge build cache",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "build cache purged"})
+}
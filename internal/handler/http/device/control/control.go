@@ -1,12 +1,15 @@
 package control
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
 	"github.com/aruncs31s/skvms/internal/logger"
 	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -26,7 +29,7 @@ func NewDeviceControlHandler(
 func (h *DeviceControlHandler) ControlDevice(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		logger.GetLogger().Error("Invalid device ID",
+		logger.FromContext(c.Request.Context()).Error("Invalid device ID",
 			zap.Error(err),
 			zap.String("ip", c.ClientIP()),
 		)
@@ -35,7 +38,22 @@ func (h *DeviceControlHandler) ControlDevice(c *gin.Context) {
 	}
 
 	var req dto.ControlRequest
-	_ = c.ShouldBindJSON(&req)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		var verr *dto.ValidationError
+		if errors.As(err, &verr) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error:  "invalid request",
+				Fields: []dto.ValidationError{*verr},
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
 
 	userID, _ := c.Get("user_id")
 	message, err := h.s.ControlDevice(
@@ -53,17 +71,11 @@ func (h *DeviceControlHandler) ControlDevice(c *gin.Context) {
 		return
 	}
 
-	// Audit log
-	username, _ := c.Get("username")
-	_ = h.as.LogDeviceAction(
-		c.Request.Context(),
-		userID.(uint),
-		username.(string),
-		"device_control",
-		message.State,
-		c.ClientIP(),
-		uint(id),
-	)
+	deviceID := uint(id)
+	entry := middleware.AuditEntry(c, "device_control", "device", c.Param("id"))
+	entry.DeviceID = &deviceID
+	entry.After = gin.H{"action": req.Action, "state": message.State}
+	_ = h.as.Record(c.Request.Context(), entry)
 
 	c.JSON(http.StatusOK, gin.H{"message": message})
 }
@@ -15,7 +15,7 @@ func (h *DeviceReader) ListDevices(c *gin.Context) {
 	)
 	devices, count, err := h.deviceService.ListDevices(c.Request.Context(), limit, offset)
 	if err != nil {
-		logger.GetLogger().Error("Failed to list devices",
+		logger.FromContext(c.Request.Context()).Error("Failed to list devices",
 			zap.Error(err),
 			zap.String("ip", c.ClientIP()),
 		)
@@ -28,7 +28,7 @@ func (h *DeviceReader) ListDevices(c *gin.Context) {
 		return
 	}
 
-	logger.GetLogger().Debug("Devices listed successfully",
+	logger.FromContext(c.Request.Context()).Debug("Devices listed successfully",
 		zap.Int("count", len(devices)),
 	)
 	c.JSON(http.StatusOK, gin.H{
@@ -44,7 +44,7 @@ func (h *DeviceReader) ListRecentDevices(c *gin.Context) {
 	)
 	devices, count, err := h.deviceService.ListRecentDevices(c.Request.Context(), limit, offset)
 	if err != nil {
-		logger.GetLogger().Error("Failed to list recent devices",
+		logger.FromContext(c.Request.Context()).Error("Failed to list recent devices",
 			zap.Error(err),
 			zap.String("ip", c.ClientIP()),
 		)
@@ -57,7 +57,7 @@ func (h *DeviceReader) ListRecentDevices(c *gin.Context) {
 		return
 	}
 
-	logger.GetLogger().Debug("Devices listed successfully",
+	logger.FromContext(c.Request.Context()).Debug("Devices listed successfully",
 		zap.Int("count", len(devices)),
 	)
 	c.JSON(http.StatusOK, gin.H{
@@ -27,7 +27,7 @@ func (h *DeviceReader) GetMyDevices(c *gin.Context) {
 
 	devices, count, err := h.deviceService.ListDevicesByUser(c.Request.Context(), userID.(uint))
 	if err != nil {
-		logger.GetLogger().Error("Failed to list user's devices",
+		logger.FromContext(c.Request.Context()).Error("Failed to list user's devices",
 			zap.Error(err),
 			zap.String("ip", c.ClientIP()),
 			zap.Uint("user_id", userID.(uint)),
@@ -41,7 +41,7 @@ func (h *DeviceReader) GetMyDevices(c *gin.Context) {
 		return
 	}
 
-	logger.GetLogger().Debug("User's devices listed successfully",
+	logger.FromContext(c.Request.Context()).Debug("User's devices listed successfully",
 		zap.Int("count", len(devices)),
 		zap.Uint("user_id", userID.(uint)),
 	)
@@ -12,7 +12,7 @@ import (
 func (h *DeviceReader) ListAllSensors(c *gin.Context) {
 	sensors, err := h.deviceService.ListAllSensors(c.Request.Context())
 	if err != nil {
-		logger.GetLogger().Error("Failed to list sensors",
+		logger.FromContext(c.Request.Context()).Error("Failed to list sensors",
 			zap.Error(err),
 			zap.String("ip", c.ClientIP()),
 		)
@@ -38,7 +38,7 @@ func (h *DeviceReader) GetSensorDevice(c *gin.Context) {
 
 	sensor, err := h.deviceService.GetSensorDevice(c.Request.Context(), uint(id))
 	if err != nil {
-		logger.GetLogger().Error("Failed to get sensor device",
+		logger.FromContext(c.Request.Context()).Error("Failed to get sensor device",
 			zap.Error(err),
 			zap.String("ip", c.ClientIP()),
 			zap.Uint("device_id", uint(id)),
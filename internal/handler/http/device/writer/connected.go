@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -17,7 +18,7 @@ func (h *DeviceWriter) AddConnectedDevice(c *gin.Context) {
 
 	var req dto.AddConnectedDeviceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BindError(c, err)
 		return
 	}
 
@@ -26,16 +27,7 @@ func (h *DeviceWriter) AddConnectedDevice(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(
-		c.Request.Context(),
-		userID.(uint),
-		username.(string),
-		"add_connected_device",
-		"Added connected device ID: "+strconv.FormatUint(
-			uint64(req.ChildID),
-			10)+" to parent ID: "+strconv.FormatUint(parentID, 10), c.ClientIP())
+	h.record(c, "add_connected_device", uint(parentID), nil, gin.H{"child_id": req.ChildID})
 
 	c.JSON(http.StatusOK, gin.H{"message": "connected device added successfully"})
 }
@@ -58,16 +50,7 @@ func (h *DeviceWriter) RemoveConnectedDevice(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(
-		c.Request.Context(),
-		userID.(uint),
-		username.(string),
-		"remove_connected_device",
-		"Removed connected device ID: "+strconv.FormatUint(
-			uint64(childID),
-			10)+" from parent ID: "+strconv.FormatUint(parentID, 10), c.ClientIP())
+	h.record(c, "remove_connected_device", uint(parentID), gin.H{"child_id": uint(childID)}, nil)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "connected device removed successfully",
@@ -83,7 +66,7 @@ func (h *DeviceWriter) CreateConnectedDeviceWithDetails(c *gin.Context) {
 
 	var req dto.CreateConnectedDeviceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BindError(c, err)
 		return
 	}
 
@@ -5,18 +5,19 @@ import (
 	"strconv"
 
 	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 )
 
 func (h *DeviceWriter) CreateDevice(c *gin.Context) {
 	var req dto.CreateDeviceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BindError(c, err)
 		return
 	}
 
 	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
 
 	if userID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
@@ -39,8 +40,7 @@ func (h *DeviceWriter) CreateDevice(c *gin.Context) {
 		return
 	}
 
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "device_create",
-		"Created device: "+req.Name, c.ClientIP())
+	h.record(c, "device_create", device.ID, nil, device)
 
 	c.JSON(http.StatusCreated, gin.H{"device": device})
 }
@@ -54,19 +54,18 @@ func (h *DeviceWriter) UpdateDevice(c *gin.Context) {
 
 	var req dto.UpdateDeviceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(err)
+		utils.BindError(c, err)
 		return
 	}
 
+	before, _ := h.deviceService.GetDevice(c.Request.Context(), uint(id))
 	if err := h.deviceService.UpdateDevice(c.Request.Context(), uint(id), &req); err != nil {
+		_ = c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update device"})
 		return
 	}
-
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "device_update",
-		"Updated device ID: "+strconv.FormatUint(id, 10), c.ClientIP())
+	h.setAuditChange(c, uint(id), before)
 
 	c.JSON(http.StatusOK, gin.H{"message": "device updated successfully"})
 }
@@ -80,7 +79,8 @@ func (h *DeviceWriter) FullUpdateDevice(c *gin.Context) {
 
 	var req dto.FullUpdateDeviceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(err)
+		utils.BindError(c, err)
 		return
 	}
 
@@ -90,14 +90,13 @@ func (h *DeviceWriter) FullUpdateDevice(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deviceService.GetDevice(c.Request.Context(), uint(id))
 	if err := h.deviceService.FullUpdateDevice(c.Request.Context(), uint(id), &req, userID.(uint)); err != nil {
+		_ = c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update device"})
 		return
 	}
-
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "device_full_update",
-		"Fully updated device ID: "+strconv.FormatUint(id, 10), c.ClientIP())
+	h.setAuditChange(c, uint(id), before)
 
 	c.JSON(http.StatusOK, gin.H{"message": "device fully updated successfully"})
 }
@@ -109,15 +108,32 @@ func (h *DeviceWriter) DeleteDevice(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deviceService.GetDevice(c.Request.Context(), uint(id))
 	if err := h.deviceService.DeleteDevice(c.Request.Context(), uint(id)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete device"})
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "device_delete",
-		"Deleted device ID: "+strconv.FormatUint(id, 10), c.ClientIP())
+	h.record(c, "device_delete", uint(id), before, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "device deleted successfully"})
 }
+
+// setAuditChange hands the device before and after an update to the audit
+// middleware, which records the changed fields.
+func (h *DeviceWriter) setAuditChange(c *gin.Context, id uint, before *dto.DeviceView) {
+	after, _ := h.deviceService.GetDevice(c.Request.Context(), id)
+	if before == nil || after == nil {
+		return
+	}
+	middleware.SetAuditChange(c, before, after)
+}
+
+// record audits an action on the device id, recording the fields that
+// differ between before and after.
+func (h *DeviceWriter) record(c *gin.Context, action string, id uint, before, after interface{}) {
+	entry := middleware.AuditEntry(c, action, "device", strconv.FormatUint(uint64(id), 10))
+	entry.DeviceID = &id
+	entry.Before, entry.After = before, after
+	_ = h.auditService.Record(c.Request.Context(), entry)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/aruncs31s/skvms/internal/dto"
 	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -18,13 +19,13 @@ func (h *DeviceWriter) CreateSensorDevice(c *gin.Context) {
 
 	var req dto.CreateDeviceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BindError(c, err)
 		return
 	}
 
 	sensor, err := h.deviceService.CreateSensorDevice(c.Request.Context(), userID.(uint), &req)
 	if err != nil {
-		logger.GetLogger().Error("Failed to create sensor device",
+		logger.FromContext(c.Request.Context()).Error("Failed to create sensor device",
 			zap.Error(err),
 			zap.String("ip", c.ClientIP()),
 			zap.Uint("user_id", userID.(uint)),
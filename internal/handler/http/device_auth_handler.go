@@ -4,8 +4,11 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
 	"github.com/aruncs31s/skvms/internal/logger"
 	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -22,10 +25,6 @@ func NewDeviceAuthHandler(deviceAuthService service.DeviceAuthService, auditServ
 	}
 }
 
-type deviceAuthRequest struct {
-	DeviceID uint `json:"device_id" binding:"required"`
-}
-
 // GenerateDeviceToken generates a JWT token for a device that contains UserID and DeviceID
 func (h *DeviceAuthHandler) GenerateDeviceToken(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -34,19 +33,19 @@ func (h *DeviceAuthHandler) GenerateDeviceToken(c *gin.Context) {
 		return
 	}
 
-	var req deviceAuthRequest
+	var req dto.DeviceTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.GetLogger().Warn("Invalid device auth request",
+		logger.FromContext(c.Request.Context()).Warn("Invalid device auth request",
 			zap.String("ip", c.ClientIP()),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BindError(c, err)
 		return
 	}
 
 	token, err := h.deviceAuthService.GenerateDeviceToken(c.Request.Context(), userID.(uint), req.DeviceID)
 	if err != nil {
-		logger.GetLogger().Error("Device token generation failed",
+		logger.FromContext(c.Request.Context()).Error("Device token generation failed",
 			zap.Uint("user_id", userID.(uint)),
 			zap.Uint("device_id", req.DeviceID),
 			zap.String("ip", c.ClientIP()),
@@ -58,10 +57,9 @@ func (h *DeviceAuthHandler) GenerateDeviceToken(c *gin.Context) {
 
 	// Log successful token generation
 	ipAddress := c.ClientIP()
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "device_token_generated", "Device token generated successfully", ipAddress)
+	h.recordTokenIssued(c, req.DeviceID)
 
-	logger.GetLogger().Info("Device token generated successfully",
+	logger.FromContext(c.Request.Context()).Info("Device token generated successfully",
 		zap.Uint("user_id", userID.(uint)),
 		zap.Uint("device_id", req.DeviceID),
 		zap.String("ip", ipAddress),
@@ -84,7 +82,7 @@ func (h *DeviceAuthHandler) GenerateDeviceTokenByParam(c *gin.Context) {
 
 	deviceID, err := strconv.ParseUint(c.Param("device_id"), 10, 64)
 	if err != nil {
-		logger.GetLogger().Warn("Invalid device ID parameter",
+		logger.FromContext(c.Request.Context()).Warn("Invalid device ID parameter",
 			zap.String("ip", c.ClientIP()),
 			zap.Error(err),
 		)
@@ -94,7 +92,7 @@ func (h *DeviceAuthHandler) GenerateDeviceTokenByParam(c *gin.Context) {
 
 	token, err := h.deviceAuthService.GenerateDeviceToken(c.Request.Context(), userID.(uint), uint(deviceID))
 	if err != nil {
-		logger.GetLogger().Error("Device token generation failed",
+		logger.FromContext(c.Request.Context()).Error("Device token generation failed",
 			zap.Uint("user_id", userID.(uint)),
 			zap.Uint("device_id", uint(deviceID)),
 			zap.String("ip", c.ClientIP()),
@@ -106,10 +104,9 @@ func (h *DeviceAuthHandler) GenerateDeviceTokenByParam(c *gin.Context) {
 
 	// Log successful token generation
 	ipAddress := c.ClientIP()
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "device_token_generated", "Device token generated successfully", ipAddress)
+	h.recordTokenIssued(c, uint(deviceID))
 
-	logger.GetLogger().Info("Device token generated successfully",
+	logger.FromContext(c.Request.Context()).Info("Device token generated successfully",
 		zap.Uint("user_id", userID.(uint)),
 		zap.Uint("device_id", uint(deviceID)),
 		zap.String("ip", ipAddress),
@@ -121,3 +118,10 @@ func (h *DeviceAuthHandler) GenerateDeviceTokenByParam(c *gin.Context) {
 		"device_id": uint(deviceID),
 	})
 }
+
+// recordTokenIssued audits a device token issued to the user for deviceID.
+func (h *DeviceAuthHandler) recordTokenIssued(c *gin.Context, deviceID uint) {
+	entry := middleware.AuditEntry(c, "device_token_generated", "device", strconv.FormatUint(uint64(deviceID), 10))
+	entry.DeviceID = &deviceID
+	_ = h.auditService.Record(c.Request.Context(), entry)
+}
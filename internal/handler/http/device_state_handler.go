@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
 	"github.com/aruncs31s/skvms/internal/logger"
 	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -33,7 +35,7 @@ func NewDeviceStateHandler(
 func (h *DeviceStateHandler) ListDeviceStates(c *gin.Context) {
 	deviceStates, err := h.deviceStateService.ListDeviceStates(c.Request.Context())
 	if err != nil {
-		logger.GetLogger().Error("Failed to list device states",
+		logger.FromContext(c.Request.Context()).Error("Failed to list device states",
 			zap.Error(err),
 			zap.String("ip", c.ClientIP()),
 		)
@@ -46,7 +48,7 @@ func (h *DeviceStateHandler) ListDeviceStates(c *gin.Context) {
 		return
 	}
 
-	logger.GetLogger().Debug("Device states listed successfully",
+	logger.FromContext(c.Request.Context()).Debug("Device states listed successfully",
 		zap.Int("count", len(deviceStates)),
 	)
 	c.JSON(http.StatusOK, gin.H{"device_states": deviceStates})
@@ -75,7 +77,7 @@ func (h *DeviceStateHandler) GetDeviceState(c *gin.Context) {
 func (h *DeviceStateHandler) CreateDeviceState(c *gin.Context) {
 	var req dto.CreateDeviceStateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BindError(c, err)
 		return
 	}
 
@@ -89,11 +91,9 @@ func (h *DeviceStateHandler) CreateDeviceState(c *gin.Context) {
 		return
 	}
 
-	// Audit log
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "device_state_create",
-		"Created device state: "+req.Name, c.ClientIP())
+	entry := middleware.AuditEntry(c, "device_state_create", "device_state", "")
+	entry.After = req
+	_ = h.auditService.Record(c.Request.Context(), entry)
 
 	c.JSON(http.StatusCreated, gin.H{"message": "device state created successfully"})
 }
@@ -107,20 +107,21 @@ func (h *DeviceStateHandler) UpdateDeviceState(c *gin.Context) {
 
 	var req dto.UpdateDeviceStateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BindError(c, err)
 		return
 	}
 
+	before, _ := h.deviceStateService.GetByID(c.Request.Context(), uint(id))
 	if err := h.deviceStateService.Update(c.Request.Context(), uint(id), &req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update device state"})
 		return
 	}
 
-	// Audit log
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "device_state_update",
-		"Updated device state ID: "+strconv.Itoa(id), c.ClientIP())
+	entry := middleware.AuditEntry(c, "device_state_update", "device_state", strconv.Itoa(id))
+	if after, _ := h.deviceStateService.GetByID(c.Request.Context(), uint(id)); before != nil && after != nil {
+		entry.Before, entry.After = before, after
+	}
+	_ = h.auditService.Record(c.Request.Context(), entry)
 
 	c.JSON(http.StatusOK, gin.H{"message": "device state updated successfully"})
 }
@@ -132,16 +133,17 @@ func (h *DeviceStateHandler) DeleteDeviceState(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deviceStateService.GetByID(c.Request.Context(), uint(id))
 	if err := h.deviceStateService.Delete(c.Request.Context(), uint(id)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete device state"})
 		return
 	}
 
-	// Audit log
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "device_state_delete",
-		"Deleted device state ID: "+strconv.Itoa(id), c.ClientIP())
+	entry := middleware.AuditEntry(c, "device_state_delete", "device_state", strconv.Itoa(id))
+	if before != nil {
+		entry.Before = before
+	}
+	_ = h.auditService.Record(c.Request.Context(), entry)
 
 	c.JSON(http.StatusOK, gin.H{"message": "device state deleted successfully"})
 }
@@ -178,7 +180,7 @@ func (h *DeviceStateHandler) GetDeviceStateHistory(c *gin.Context) {
 
 	history, err := h.deviceStateHistoryService.GetDeviceStateHistory(c.Request.Context(), req)
 	if err != nil {
-		logger.GetLogger().Error("Failed to get device state history",
+		logger.FromContext(c.Request.Context()).Error("Failed to get device state history",
 			zap.Error(err),
 			zap.Uint("device_id", uint(deviceID)),
 			zap.String("ip", c.ClientIP()),
@@ -187,7 +189,7 @@ func (h *DeviceStateHandler) GetDeviceStateHistory(c *gin.Context) {
 		return
 	}
 
-	logger.GetLogger().Debug("Device state history retrieved successfully",
+	logger.FromContext(c.Request.Context()).Debug("Device state history retrieved successfully",
 		zap.Uint("device_id", uint(deviceID)),
 		zap.Int("count", len(history.History)),
 	)
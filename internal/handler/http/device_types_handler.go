@@ -1,11 +1,14 @@
 package http
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/aruncs31s/skvms/internal/dto"
 	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type DeviceTypesHandler interface {
@@ -14,6 +17,9 @@ type DeviceTypesHandler interface {
 	CreateDeviceType(c *gin.Context)
 	GetSensorType(c *gin.Context)
 	GetDeviceTypeByDeviceID(c *gin.Context)
+	ListBoardProfiles(c *gin.Context)
+	GetBoardProfile(c *gin.Context)
+	SetBoardProfile(c *gin.Context)
 }
 
 type deviceTypesHandler struct {
@@ -82,7 +88,7 @@ func (h *deviceTypesHandler) CreateDeviceType(c *gin.Context) {
 	var req dto.CreateDeviceTypeRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "invalid request payload"})
+		utils.BindError(c, err)
 		return
 	}
 
@@ -124,3 +130,70 @@ func (h *deviceTypesHandler) GetDeviceTypeByDeviceID(c *gin.Context) {
 	}
 	c.JSON(200, deviceType)
 }
+
+func (h *deviceTypesHandler) ListBoardProfiles(c *gin.Context) {
+	profiles, err := h.deviceTypesService.ListBoardProfiles(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load board profiles"})
+		return
+	}
+	c.JSON(200, gin.H{"board_profiles": profiles})
+}
+
+func (h *deviceTypesHandler) GetBoardProfile(c *gin.Context) {
+	deviceTypeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid device type id"})
+		return
+	}
+
+	profile, err := h.deviceTypesService.GetBoardProfile(
+		c.Request.Context(),
+		uint(deviceTypeID),
+	)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "board profile not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to load board profile"})
+		return
+	}
+	c.JSON(200, gin.H{"board_profile": profile})
+}
+
+func (h *deviceTypesHandler) SetBoardProfile(c *gin.Context) {
+	deviceTypeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid device type id"})
+		return
+	}
+
+	var req dto.BoardProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	profile, err := h.deviceTypesService.SetBoardProfile(
+		c.Request.Context(),
+		uint(deviceTypeID),
+		req,
+		userID.(uint),
+	)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "device type not found"})
+		return
+	}
+	if errors.Is(err, service.ErrNotMicrocontroller) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to save board profile"})
+		return
+	}
+	c.JSON(200, gin.H{"board_profile": profile})
+}
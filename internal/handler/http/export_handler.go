@@ -1,22 +1,33 @@
 package http
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/aruncs31s/skvms/internal/dto"
 	"github.com/aruncs31s/skvms/internal/export"
 	exportdto "github.com/aruncs31s/skvms/internal/export/dto"
+	"github.com/aruncs31s/skvms/internal/logger"
 	"github.com/aruncs31s/skvms/internal/service"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
-// ExportHandler handles HTTP requests for data exports in PDF, XLSX, CSV, and XML formats.
+// ExportHandler handles HTTP requests for data exports in PDF, XLSX, CSV, XML,
+// Parquet, NDJSON and InfluxDB line protocol formats.
 type ExportHandler struct {
-	exportService  *export.Service
-	readingService service.ReadingService
-	deviceService  service.DeviceService
+	exportService   *export.Service
+	readingService  service.ReadingService
+	deviceService   service.DeviceService
+	locationService service.LocationService
+	summaryService  service.SummaryService
+	templateService service.ExportTemplateService
 }
 
 // NewExportHandler creates a new ExportHandler.
@@ -24,31 +35,45 @@ func NewExportHandler(
 	exportService *export.Service,
 	readingService service.ReadingService,
 	deviceService service.DeviceService,
+	locationService service.LocationService,
+	summaryService service.SummaryService,
+	templateService service.ExportTemplateService,
 ) *ExportHandler {
 	return &ExportHandler{
-		exportService:  exportService,
-		readingService: readingService,
-		deviceService:  deviceService,
+		exportService:   exportService,
+		readingService:  readingService,
+		deviceService:   deviceService,
+		locationService: locationService,
+		summaryService:  summaryService,
+		templateService: templateService,
 	}
 }
 
 // ExportReadings handles GET /api/export/readings
+// Readings are streamed to the client as they are read from the database.
 // Query parameters:
 //
-//	format     - output format: csv, xlsx, xml, pdf (required)
-//	device_id  - filter by device ID (required)
-//	start_date - start of date range (2006-01-02, optional)
-//	end_date   - end of date range (2006-01-02, optional)
-//	template   - custom template path for PDF (optional)
+//	format      - output format: csv, xlsx, xml, pdf, parquet, ndjson, influx (required)
+//	device_id   - export readings of a device
+//	device_ids  - export readings of several devices, comma separated
+//	location_id - export readings of every device at a location
+//	start_date  - start of date range (2006-01-02, optional)
+//	end_date    - end of date range (2006-01-02, optional)
+//	template    - library template ID for PDF (optional, default for readings)
+//
+// At least one of device_id, device_ids and location_id is required.
 func (h *ExportHandler) ExportReadings(c *gin.Context) {
 	req, err := h.parseExportQuery(c, "readings")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !h.loadTemplate(c, &req) {
+		return
+	}
 
-	if req.DeviceID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id is required"})
+	if req.DeviceID == 0 && len(req.DeviceIDs) == 0 && req.LocationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id, device_ids or location_id is required"})
 		return
 	}
 
@@ -58,33 +83,74 @@ func (h *ExportHandler) ExportReadings(c *gin.Context) {
 		return
 	}
 
-	readings, err := h.readingService.ListByDeviceAndDateRange(
-		c.Request.Context(),
-		req.DeviceID,
-		startTime,
-		endTime,
-	)
+	devices, err := h.exportDevices(c, req)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch readings"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch devices"})
+		return
+	}
+	if len(devices) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no devices found at location"})
 		return
 	}
 
-	h.writeExport(c, req, func(w gin.ResponseWriter) error {
-		return h.exportService.ExportReadings(c.Request.Context(), req, readings, w)
+	writeExport(c, req, func(w io.Writer) error {
+		return h.exportService.ExportReadings(c.Request.Context(), req, h.readingService, devices, startTime, endTime, w)
 	})
 }
 
+// exportDevices resolves the devices selected by req, without duplicates.
+func (h *ExportHandler) exportDevices(c *gin.Context, req exportdto.ExportRequest) ([]dto.DeviceView, error) {
+	seen := make(map[uint]bool)
+	var devices []dto.DeviceView
+
+	ids := append([]uint{req.DeviceID}, req.DeviceIDs...)
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		device, err := h.deviceService.GetDevice(c.Request.Context(), id)
+		if err != nil {
+			return nil, err
+		}
+		if device == nil {
+			return nil, gorm.ErrRecordNotFound
+		}
+		seen[id] = true
+		devices = append(devices, *device)
+	}
+	if req.LocationID != 0 {
+		located, err := h.locationService.ListDevicesInLocation(c.Request.Context(), req.LocationID)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range located {
+			if !seen[d.ID] {
+				seen[d.ID] = true
+				devices = append(devices, d)
+			}
+		}
+	}
+	return devices, nil
+}
+
 // ExportDevices handles GET /api/export/devices
 // Query parameters:
 //
-//	format   - output format: csv, xlsx, xml, pdf (required)
-//	template - custom template path for PDF (optional)
+//	format   - output format: csv, xlsx, xml, pdf, parquet, ndjson, influx (required)
+//	template - library template ID for PDF (optional, default for devices)
 func (h *ExportHandler) ExportDevices(c *gin.Context) {
 	req, err := h.parseExportQuery(c, "devices")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !h.loadTemplate(c, &req) {
+		return
+	}
 
 	devices, _, err := h.deviceService.ListDevices(c.Request.Context(), 0, 0) // Fetch all devices without pagination
 	if err != nil {
@@ -92,40 +158,243 @@ func (h *ExportHandler) ExportDevices(c *gin.Context) {
 		return
 	}
 
-	h.writeExport(c, req, func(w gin.ResponseWriter) error {
+	writeExport(c, req, func(w io.Writer) error {
 		return h.exportService.ExportDevices(c.Request.Context(), req, devices, w)
 	})
 }
 
+// DeviceReport handles GET /api/export/reports/device/:id
+// Renders a device report with voltage and current charts, daily summaries,
+// energy, uptime and alert counts. See summaryQuery for the parameters.
+func (h *ExportHandler) DeviceReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device id"})
+		return
+	}
+	start, end, thresholds, err := summaryQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.summaryService.DeviceReport(c.Request.Context(), uint(id), start, end, thresholds)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+	h.writeSummary(c, report, err)
+}
+
+// LocationReport handles GET /api/export/reports/location/:id
+// Rolls up every device assigned to the location during the period, each
+// for the time it was assigned. See summaryQuery for the parameters.
+func (h *ExportHandler) LocationReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid location id"})
+		return
+	}
+	start, end, thresholds, err := summaryQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.summaryService.LocationReport(c.Request.Context(), uint(id), start, end, thresholds)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "location not found"})
+		return
+	}
+	h.writeSummary(c, report, err)
+}
+
+// writeSummary sends a summary report as PDF, or as JSON with format=json.
+func (h *ExportHandler) writeSummary(c *gin.Context, report *dto.SummaryReport, err error) {
+	if errors.Is(err, service.ErrInvalidSummaryRange) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+		return
+	}
+
+	switch c.DefaultQuery("format", "pdf") {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"report": report})
+	case "pdf":
+		req := exportdto.ExportRequest{Format: exportdto.FormatPDF, DataType: export.TemplateSummary}
+		if raw := c.Query("template"); raw != "" {
+			id, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "template must be a template id"})
+				return
+			}
+			req.TemplateID = uint(id)
+		}
+		if !h.loadTemplate(c, &req) {
+			return
+		}
+		req.DataType = report.Kind + "_report"
+		writeExport(c, req, func(w io.Writer) error {
+			return h.exportService.RenderSummaryPDF(c.Request.Context(), report, req.Template, w)
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be pdf or json"})
+	}
+}
+
+// summaryQuery parses the parameters of device and location reports:
+//
+//	start_date    - start of the period (2006-01-02, default today)
+//	end_date      - last day of the period (2006-01-02, default today)
+//	format        - pdf (default) or json
+//	template      - library template ID for PDF (optional, default for summary)
+//	min_voltage   - alert below this voltage (optional)
+//	max_voltage   - alert above this voltage (optional)
+//	max_current   - alert above this current (optional)
+//	offline_after - minutes without readings that count as offline (default 15)
+func summaryQuery(c *gin.Context) (time.Time, time.Time, dto.AlertThresholds, error) {
+	var thresholds dto.AlertThresholds
+	start, end, err := parseDateRange(c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		return start, end, thresholds, err
+	}
+
+	limits := []struct {
+		name string
+		dst  *float64
+	}{
+		{"min_voltage", &thresholds.MinVoltage},
+		{"max_voltage", &thresholds.MaxVoltage},
+		{"max_current", &thresholds.MaxCurrent},
+	}
+	for _, l := range limits {
+		if raw := c.Query(l.name); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return start, end, thresholds, fmt.Errorf("invalid %s", l.name)
+			}
+			*l.dst = v
+		}
+	}
+	if raw := c.Query("offline_after"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return start, end, thresholds, fmt.Errorf("invalid offline_after")
+		}
+		thresholds.OfflineAfterMinutes = v
+	}
+	return start, end, thresholds, nil
+}
+
 // ListFormats handles GET /api/export/formats
-// Returns the list of supported export formats.
+// Returns the list of supported export formats, and the formats that are
+// unavailable right now with the reason, such as pdf without Chrome.
 func (h *ExportHandler) ListFormats(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"formats": h.exportService.SupportedFormats(),
+		"formats":     h.exportService.SupportedFormats(),
+		"unavailable": h.exportService.UnavailableFormats(),
 	})
 }
 
-// writeExport sets the appropriate Content-Type and Content-Disposition headers, then
-// invokes the write function to stream the export data to the client.
-func (h *ExportHandler) writeExport(c *gin.Context, req exportdto.ExportRequest, writeFn func(gin.ResponseWriter) error) {
-	contentType, ext := formatMIME(req.Format)
-	filename := fmt.Sprintf("export_%s_%s.%s", req.DataType, time.Now().Format("20060102_150405"), ext)
+// RendererStats handles GET /api/export/renderer
+// Returns the health of the PDF renderer pool.
+func (h *ExportHandler) RendererStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.exportService.RendererStats())
+}
 
-	c.Header("Content-Type", contentType)
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Status(http.StatusOK)
+// loadTemplate resolves the library template of a PDF export into
+// req.Template: the one named by req.TemplateID, or the default of the data
+// type. It writes an error response and returns false if that fails.
+func (h *ExportHandler) loadTemplate(c *gin.Context, req *exportdto.ExportRequest) bool {
+	if req.Format != exportdto.FormatPDF {
+		return true
+	}
+	source, err := h.templateService.Resolve(c.Request.Context(), req.DataType, req.TemplateID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return false
+	}
+	if errors.Is(err, service.ErrInvalidTemplate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load template"})
+		return false
+	}
+	req.Template = source
+	return true
+}
+
+// writeExport streams the export to the client. The Content-Type and
+// Content-Disposition headers are sent with the first byte, so an export
+// that fails before writing anything, such as a PDF whose template does not
+// render, is still answered with an error status.
+func writeExport(c *gin.Context, req exportdto.ExportRequest, writeFn func(io.Writer) error) {
+	contentType, ext := export.MIMEType(req.Format)
+	w := &exportWriter{
+		c:           c,
+		contentType: contentType,
+		filename:    fmt.Sprintf("export_%s_%s.%s", req.DataType, time.Now().Format("20060102_150405"), ext),
+	}
 
-	if err := writeFn(c.Writer); err != nil {
+	err := writeFn(w)
+	if err != nil && !w.started {
+		var templateErr *export.TemplateError
+		if errors.As(err, &templateErr) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": templateErr.Error()})
+			return
+		}
+		if errors.Is(err, export.ErrPDFUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, export.ErrRenderTimeout) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+			return
+		}
+		logger.FromContext(c.Request.Context()).Error("Export failed", zap.Error(err), zap.String("format", string(req.Format)))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "export failed"})
+		return
+	}
+	if err != nil {
 		// Headers already sent; log the error but cannot change status code
 		_ = c.Error(err)
 	}
+	w.start()
+}
+
+// exportWriter sends the export headers on the first write.
+type exportWriter struct {
+	c           *gin.Context
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (w *exportWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	w.c.Header("Content-Type", w.contentType)
+	w.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", w.filename))
+	w.c.Status(http.StatusOK)
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	w.start()
+	return w.c.Writer.Write(p)
 }
 
 // parseExportQuery extracts common export parameters from the request query string.
 func (h *ExportHandler) parseExportQuery(c *gin.Context, defaultDataType string) (exportdto.ExportRequest, error) {
 	format := exportdto.ExportFormat(c.Query("format"))
 	if format == "" {
-		return exportdto.ExportRequest{}, fmt.Errorf("format query parameter is required (csv, xlsx, xml, pdf)")
+		return exportdto.ExportRequest{}, fmt.Errorf("format query parameter is required (csv, xlsx, xml, pdf, parquet, ndjson, influx)")
 	}
 
 	dataType := c.Query("data_type")
@@ -142,13 +411,44 @@ func (h *ExportHandler) parseExportQuery(c *gin.Context, defaultDataType string)
 		deviceID = uint(id)
 	}
 
+	var deviceIDs []uint
+	if raw := c.Query("device_ids"); raw != "" {
+		for _, part := range strings.Split(raw, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+			if err != nil {
+				return exportdto.ExportRequest{}, fmt.Errorf("invalid device_ids")
+			}
+			deviceIDs = append(deviceIDs, uint(id))
+		}
+	}
+
+	var locationID uint
+	if raw := c.Query("location_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return exportdto.ExportRequest{}, fmt.Errorf("invalid location_id")
+		}
+		locationID = uint(id)
+	}
+
+	var templateID uint
+	if raw := c.Query("template"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return exportdto.ExportRequest{}, fmt.Errorf("template must be a template id")
+		}
+		templateID = uint(id)
+	}
+
 	return exportdto.ExportRequest{
-		Format:       format,
-		DataType:     dataType,
-		DeviceID:     deviceID,
-		StartDate:    c.Query("start_date"),
-		EndDate:      c.Query("end_date"),
-		TemplatePath: c.Query("template"),
+		Format:     format,
+		DataType:   dataType,
+		DeviceID:   deviceID,
+		DeviceIDs:  deviceIDs,
+		LocationID: locationID,
+		StartDate:  c.Query("start_date"),
+		EndDate:    c.Query("end_date"),
+		TemplateID: templateID,
 	}, nil
 }
 
@@ -174,24 +474,11 @@ func parseDateRange(startStr, endStr string) (time.Time, time.Time, error) {
 		if err != nil {
 			return time.Time{}, time.Time{}, fmt.Errorf("invalid end_date: use format 2006-01-02")
 		}
-		end = t
+		// The end date is inclusive, like the default of today
+		end = t.Add(24*time.Hour - time.Second)
 	} else {
 		end = time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, now.Location())
 	}
 
 	return start, end, nil
 }
-
-// formatMIME returns the MIME content-type and file extension for the given export format.
-func formatMIME(f exportdto.ExportFormat) (contentType, ext string) {
-	switch f {
-	case exportdto.FormatXLSX:
-		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"
-	case exportdto.FormatXML:
-		return "application/xml", "xml"
-	case exportdto.FormatPDF:
-		return "application/pdf", "pdf"
-	default: // csv
-		return "text/csv", "csv"
-	}
-}
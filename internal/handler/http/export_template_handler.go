@@ -0,0 +1,369 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/export"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ExportTemplateHandler manages the library of PDF export templates:
+// upload, versions, previews and the default template of each data type.
+type ExportTemplateHandler struct {
+	templateService service.ExportTemplateService
+	auditService    service.AuditService
+}
+
+func NewExportTemplateHandler(
+	templateService service.ExportTemplateService,
+	auditService service.AuditService,
+) *ExportTemplateHandler {
+	return &ExportTemplateHandler{
+		templateService: templateService,
+		auditService:    auditService,
+	}
+}
+
+// ListTemplates handles GET /api/export/templates
+// Query parameters:
+//
+//	data_type - readings, devices or summary (optional)
+func (h *ExportTemplateHandler) ListTemplates(c *gin.Context) {
+	templates, err := h.templateService.List(c.Request.Context(), c.Query("data_type"))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to list export templates", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load templates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// GetTemplate handles GET /api/export/templates/:id
+// The response carries the source of the latest version.
+func (h *ExportTemplateHandler) GetTemplate(c *gin.Context) {
+	id, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.templateService.Get(c.Request.Context(), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load template"})
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// CreateTemplate handles POST /api/export/templates
+// The template is rendered with sample data before it is saved; parse and
+// render errors are returned with the line at fault.
+func (h *ExportTemplateHandler) CreateTemplate(c *gin.Context) {
+	var req dto.ExportTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	template, err := h.templateService.Create(c.Request.Context(), req, userID.(uint))
+	if errors.Is(err, service.ErrInvalidTemplate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to create export template", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create template"})
+		return
+	}
+
+	entry := middleware.AuditEntry(c, "export_template_create", "export_template", strconv.FormatUint(uint64(template.ID), 10))
+	entry.After = template
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateTemplate handles PUT /api/export/templates/:id
+// Only the name and description change; new source is uploaded as a version.
+func (h *ExportTemplateHandler) UpdateTemplate(c *gin.Context) {
+	id, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req dto.UpdateExportTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	before, _ := h.templateService.Get(c.Request.Context(), id)
+	template, err := h.templateService.Update(c.Request.Context(), id, req, userID.(uint))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidTemplate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update template"})
+		return
+	}
+
+	h.recordTemplateChange(c, "export_template_update", id, before)
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate handles DELETE /api/export/templates/:id
+// All versions are removed with the template.
+func (h *ExportTemplateHandler) DeleteTemplate(c *gin.Context) {
+	id, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+
+	before, _ := h.templateService.Get(c.Request.Context(), id)
+	err := h.templateService.Delete(c.Request.Context(), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete template"})
+		return
+	}
+
+	entry := middleware.AuditEntry(c, "export_template_delete", "export_template", strconv.FormatUint(uint64(id), 10))
+	entry.Before = before
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
+	c.JSON(http.StatusOK, gin.H{"message": "template deleted"})
+}
+
+// AddTemplateVersion handles POST /api/export/templates/:id/versions
+// The new version is validated like a new template and used by exports from
+// then on.
+func (h *ExportTemplateHandler) AddTemplateVersion(c *gin.Context) {
+	id, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req dto.ExportTemplateVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	before, _ := h.templateService.Get(c.Request.Context(), id)
+	version, err := h.templateService.AddVersion(c.Request.Context(), id, req, userID.(uint))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidTemplate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to add export template version", zap.Error(err), zap.Uint("template_id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add template version"})
+		return
+	}
+
+	h.recordTemplateChange(c, "export_template_version", id, before)
+
+	c.JSON(http.StatusCreated, version)
+}
+
+// ListTemplateVersions handles GET /api/export/templates/:id/versions
+func (h *ExportTemplateHandler) ListTemplateVersions(c *gin.Context) {
+	id, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.templateService.ListVersions(c.Request.Context(), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load template versions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// GetTemplateVersion handles GET /api/export/templates/:id/versions/:version
+func (h *ExportTemplateHandler) GetTemplateVersion(c *gin.Context) {
+	id, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return
+	}
+
+	version, err := h.templateService.GetVersion(c.Request.Context(), id, number)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template version not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load template version"})
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
+
+// SetDefaultTemplate handles PUT /api/export/templates/:id/default
+// The template replaces the previous default of its data type. Admins only,
+// as the default is used by every user's exports.
+func (h *ExportTemplateHandler) SetDefaultTemplate(c *gin.Context) {
+	id, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+
+	before, _ := h.templateService.Get(c.Request.Context(), id)
+	err := h.templateService.SetDefault(c.Request.Context(), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set default template"})
+		return
+	}
+
+	h.recordTemplateChange(c, "export_template_default", id, before)
+
+	c.JSON(http.StatusOK, gin.H{"message": "default template set"})
+}
+
+// ClearDefaultTemplate handles DELETE /api/export/templates/:id/default
+// Exports of the data type fall back to the built-in template.
+func (h *ExportTemplateHandler) ClearDefaultTemplate(c *gin.Context) {
+	id, ok := templateIDParam(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	before, _ := h.templateService.Get(c.Request.Context(), id)
+	err := h.templateService.ClearDefault(c.Request.Context(), id, userID.(uint))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear default template"})
+		return
+	}
+
+	h.recordTemplateChange(c, "export_template_default", id, before)
+
+	c.JSON(http.StatusOK, gin.H{"message": "default template cleared"})
+}
+
+// PreviewTemplate handles POST /api/export/templates/preview
+// Renders a saved template (template_id, optional version) or unsaved source
+// (data_type, content) with sample data.
+// Query parameters:
+//
+//	format - html (default) or pdf
+func (h *ExportTemplateHandler) PreviewTemplate(c *gin.Context) {
+	var req dto.TemplatePreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	var asPDF bool
+	contentType := "text/html; charset=utf-8"
+	switch c.DefaultQuery("format", "html") {
+	case "html":
+	case "pdf":
+		asPDF = true
+		contentType = "application/pdf"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html or pdf"})
+		return
+	}
+
+	// Rendered into memory first, so errors can still be reported
+	var buf bytes.Buffer
+	err := h.templateService.Preview(c.Request.Context(), req, asPDF, &buf)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidTemplate) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, export.ErrPDFUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, export.ErrRenderTimeout) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to preview export template", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render preview"})
+		return
+	}
+
+	// Previews may contain user HTML; keep the browser from running it
+	// against this origin
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src data:; sandbox")
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// templateIDParam parses the :id path parameter, answering 400 if invalid.
+func templateIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// recordTemplateChange audits a change to a template, recording the fields
+// that differ from before.
+func (h *ExportTemplateHandler) recordTemplateChange(c *gin.Context, action string, id uint, before *dto.ExportTemplateResponse) {
+	entry := middleware.AuditEntry(c, action, "export_template", strconv.FormatUint(uint64(id), 10))
+	if after, err := h.templateService.Get(c.Request.Context(), id); err == nil && before != nil {
+		entry.Before, entry.After = before, after
+	}
+	_ = h.auditService.Record(c.Request.Context(), entry)
+}
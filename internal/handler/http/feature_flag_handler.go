@@ -0,0 +1,267 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type FeatureFlagHandler struct {
+	featureFlagService service.FeatureFlagService
+	auditService       service.AuditService
+}
+
+func NewFeatureFlagHandler(
+	featureFlagService service.FeatureFlagService,
+	auditService service.AuditService,
+) *FeatureFlagHandler {
+	return &FeatureFlagHandler{
+		featureFlagService: featureFlagService,
+		auditService:       auditService,
+	}
+}
+
+// GetDeviceFlags handles GET /api/device/:id/features
+// Returns the effective flags of a device and where each comes from.
+func (h *FeatureFlagHandler) GetDeviceFlags(c *gin.Context) {
+	deviceID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device id"})
+		return
+	}
+
+	flags, err := h.featureFlagService.EffectiveFlags(c.Request.Context(), uint(deviceID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to resolve feature flags",
+			zap.Uint("device_id", uint(deviceID)),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get features for device"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"features": flags})
+}
+
+// GetFlagConfig handles GET /api/devices/flags
+// Called by a device (device token) to fetch its flags. The rev is sent as
+// ETag, a matching If-None-Match gets 304.
+func (h *FeatureFlagHandler) GetFlagConfig(c *gin.Context) {
+	deviceID, exists := c.Get("device_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	config, err := h.featureFlagService.DeviceConfig(c.Request.Context(), deviceID.(uint))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to build device flag config",
+			zap.Uint("device_id", deviceID.(uint)),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load flags"})
+		return
+	}
+
+	etag := `"` + config.Rev + `"`
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.JSON(http.StatusOK, config)
+}
+
+// ListDeviceOverrides handles GET /api/devices/:id/feature-overrides
+func (h *FeatureFlagHandler) ListDeviceOverrides(c *gin.Context) {
+	h.listOverrides(c, model.OverrideScopeDevice)
+}
+
+// ListLocationOverrides handles GET /api/locations/:id/feature-overrides
+func (h *FeatureFlagHandler) ListLocationOverrides(c *gin.Context) {
+	h.listOverrides(c, model.OverrideScopeLocation)
+}
+
+// SetDeviceOverride handles PUT /api/devices/:id/feature-overrides/:fid
+func (h *FeatureFlagHandler) SetDeviceOverride(c *gin.Context) {
+	h.setOverride(c, model.OverrideScopeDevice)
+}
+
+// SetLocationOverride handles PUT /api/locations/:id/feature-overrides/:fid
+func (h *FeatureFlagHandler) SetLocationOverride(c *gin.Context) {
+	h.setOverride(c, model.OverrideScopeLocation)
+}
+
+// ClearDeviceOverride handles DELETE /api/devices/:id/feature-overrides/:fid
+func (h *FeatureFlagHandler) ClearDeviceOverride(c *gin.Context) {
+	h.clearOverride(c, model.OverrideScopeDevice)
+}
+
+// ClearLocationOverride handles DELETE /api/locations/:id/feature-overrides/:fid
+func (h *FeatureFlagHandler) ClearLocationOverride(c *gin.Context) {
+	h.clearOverride(c, model.OverrideScopeLocation)
+}
+
+func (h *FeatureFlagHandler) listOverrides(c *gin.Context, scope string) {
+	scopeID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + scope + " id"})
+		return
+	}
+
+	overrides, err := h.featureFlagService.ListOverrides(c.Request.Context(), scope, uint(scopeID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load feature overrides"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"overrides": overrides})
+}
+
+func (h *FeatureFlagHandler) setOverride(c *gin.Context, scope string) {
+	scopeID, featureID, ok := overrideParams(c, scope)
+	if !ok {
+		return
+	}
+
+	var req dto.FeatureOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	previous, _ := h.featureFlagService.GetOverride(c.Request.Context(), scope, scopeID, featureID)
+	override, err := h.featureFlagService.SetOverride(
+		c.Request.Context(),
+		scope,
+		scopeID,
+		featureID,
+		*req.Enabled,
+		userID.(uint),
+	)
+	if errors.Is(err, service.ErrOverrideScopeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": scope + " not found"})
+		return
+	}
+	if errors.Is(err, service.ErrOverrideAccess) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no access to this " + scope})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature not found"})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to set feature override",
+			zap.String("scope", scope),
+			zap.Uint("scope_id", scopeID),
+			zap.Uint("feature_id", featureID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set feature override"})
+		return
+	}
+
+	var before interface{}
+	if previous != nil {
+		before = gin.H{"enabled": previous.Enabled}
+	}
+	h.recordOverride(c, "feature_override_set", scope, scopeID, before, gin.H{"enabled": override.Enabled},
+		fmt.Sprintf("Set feature %s (ID %d) enabled=%t for %s %d",
+			override.Name, featureID, override.Enabled, scope, scopeID))
+
+	c.JSON(http.StatusOK, gin.H{"override": override})
+}
+
+func (h *FeatureFlagHandler) clearOverride(c *gin.Context, scope string) {
+	scopeID, featureID, ok := overrideParams(c, scope)
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	previous, _ := h.featureFlagService.GetOverride(c.Request.Context(), scope, scopeID, featureID)
+	err := h.featureFlagService.ClearOverride(c.Request.Context(), scope, scopeID, featureID, userID.(uint))
+	if errors.Is(err, service.ErrOverrideAccess) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no access to this " + scope})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feature override not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear feature override"})
+		return
+	}
+
+	var before interface{}
+	if previous != nil {
+		before = gin.H{"enabled": previous.Enabled}
+	}
+	h.recordOverride(c, "feature_override_clear", scope, scopeID, before, nil,
+		fmt.Sprintf("Cleared override of feature ID %d for %s %d", featureID, scope, scopeID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "feature override cleared"})
+}
+
+// recordOverride writes the audit entry of a change to an override of the
+// device or location scopeID.
+func (h *FeatureFlagHandler) recordOverride(
+	c *gin.Context,
+	action string,
+	scope string,
+	scopeID uint,
+	before interface{},
+	after interface{},
+	details string,
+) {
+	entry := middleware.AuditEntry(c, action, scope, strconv.FormatUint(uint64(scopeID), 10))
+	entry.Details = details
+	entry.Before, entry.After = before, after
+	if scope == model.OverrideScopeDevice {
+		entry.DeviceID = &scopeID
+	}
+	if err := h.auditService.Record(c.Request.Context(), entry); err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to write audit entry",
+			zap.Error(err),
+			zap.String("action", action),
+		)
+	}
+}
+
+// overrideParams parses the scope ID and feature ID of an override route and
+// responds with 400 if either is invalid.
+func overrideParams(c *gin.Context, scope string) (uint, uint, bool) {
+	scopeID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + scope + " id"})
+		return 0, 0, false
+	}
+	featureID, err := strconv.ParseUint(c.Param("fid"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feature id"})
+		return 0, 0, false
+	}
+	return uint(scopeID), uint(featureID), true
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type FirmwareHandler struct {
+	firmwareService service.FirmwareService
+}
+
+func NewFirmwareHandler(firmwareService service.FirmwareService) *FirmwareHandler {
+	return &FirmwareHandler{firmwareService: firmwareService}
+}
+
+// ReportFirmware handles POST /api/devices/firmware
+// Called by a device (device token) on boot and with its heartbeat.
+func (h *FirmwareHandler) ReportFirmware(c *gin.Context) {
+	deviceID, exists := c.Get("device_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req dto.FirmwareReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	firmware, err := h.firmwareService.Report(c.Request.Context(), deviceID.(uint), req)
+	if errors.Is(err, service.ErrInvalidFirmwareEvent) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to record firmware report",
+			zap.Uint("device_id", deviceID.(uint)),
+			zap.String("version", req.Version),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record firmware report"})
+		return
+	}
+
+	logger.FromContext(c.Request.Context()).Debug("Firmware report received",
+		zap.Uint("device_id", deviceID.(uint)),
+		zap.String("version", firmware.Version),
+		zap.String("event", firmware.LastEvent),
+	)
+	c.JSON(http.StatusOK, gin.H{"firmware": firmware})
+}
+
+// GetDeviceFirmware handles GET /api/devices/:id/firmware
+func (h *FirmwareHandler) GetDeviceFirmware(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device id"})
+		return
+	}
+
+	firmware, err := h.firmwareService.GetByDevice(c.Request.Context(), uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device has not reported its firmware"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load firmware"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"firmware": firmware})
+}
+
+// DriftReport handles GET /api/devices/firmware/drift
+func (h *FirmwareHandler) DriftReport(c *gin.Context) {
+	report, err := h.firmwareService.DriftReport(c.Request.Context())
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to build firmware drift report",
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build drift report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/aruncs31s/skvms/internal/health"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler serves the liveness and readiness probes.
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Live handles GET /healthz
+// Answers 503 only when a background worker is stuck and a restart may help.
+func (h *HealthHandler) Live(c *gin.Context) {
+	respondHealth(c, h.checker.Live(c.Request.Context()))
+}
+
+// Ready handles GET /readyz
+// Checks the database, the codegen disk space, Chrome and the background
+// workers. Answers 503 while a critical check fails or the server shuts
+// down; a degraded server answers 200.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	respondHealth(c, h.checker.Ready(c.Request.Context()))
+}
+
+func respondHealth(c *gin.Context, report health.Report) {
+	code := http.StatusOK
+	if !report.Healthy() {
+		code = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(code, report)
+}
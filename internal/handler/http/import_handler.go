@@ -0,0 +1,255 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
+	"github.com/aruncs31s/skvms/internal/importer"
+	"github.com/aruncs31s/skvms/internal/importer/dto"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxImportSize is the largest accepted upload, in bytes.
+const maxImportSize = 32 << 20
+
+// ImportHandler imports historical readings and device inventories from
+// CSV, XLSX and JSON files, and rolls imports back.
+type ImportHandler struct {
+	importService *importer.Service
+	auditService  service.AuditService
+}
+
+func NewImportHandler(
+	importService *importer.Service,
+	auditService service.AuditService,
+) *ImportHandler {
+	return &ImportHandler{
+		importService: importService,
+		auditService:  auditService,
+	}
+}
+
+// ListFields handles GET /api/import/fields
+// Query parameters:
+//
+//	data_type - readings or devices (required)
+func (h *ImportHandler) ListFields(c *gin.Context) {
+	fields, err := h.importService.Fields(c.Query("data_type"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"fields":  fields,
+		"formats": h.importService.SupportedFormats(),
+	})
+}
+
+// Import handles POST /api/import
+// Multipart form fields:
+//
+//	file         - the file to import (required)
+//	data_type    - readings or devices (required)
+//	format       - csv, xlsx or json; inferred from the file name when omitted
+//	mapping      - JSON object of field name to column name (optional)
+//	sheet        - XLSX worksheet (optional, default first sheet)
+//	dry_run      - true to validate and preview without saving
+//	skip_invalid - true to import the valid rows of a file with invalid rows
+//
+// A dry run answers 200 with the row errors and a preview. A commit answers
+// 201 with the import job, or 422 with the row errors when rows are invalid.
+func (h *ImportHandler) Import(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file is larger than %d MiB", maxImportSize>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	req := dto.ImportRequest{
+		Format:      dto.ImportFormat(c.PostForm("format")),
+		DataType:    c.PostForm("data_type"),
+		FileName:    header.Filename,
+		Sheet:       c.PostForm("sheet"),
+		DryRun:      formBool(c, "dry_run"),
+		SkipInvalid: formBool(c, "skip_invalid"),
+	}
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &req.Mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mapping must be a JSON object of field to column"})
+			return
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	userID, _ := c.Get("user_id")
+
+	result, err := h.importService.Import(c.Request.Context(), req, file, userID.(uint))
+	switch {
+	case errors.Is(err, importer.ErrUnsupportedFormat),
+		errors.Is(err, importer.ErrInvalidFile),
+		errors.Is(err, importer.ErrInvalidMapping):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, importer.ErrInvalidRows):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "result": result})
+		return
+	case errors.Is(err, importer.ErrImportFailed):
+		logger.FromContext(c.Request.Context()).Error("Import failed", zap.Uint("job_id", result.JobID), zap.Error(err))
+		entry := middleware.AuditEntry(c, "import_failed", "import_job", strconv.FormatUint(uint64(result.JobID), 10))
+		entry.Status = model.AuditFailure
+		entry.After = importSnapshot(result)
+		entry.Details = err.Error()
+		_ = h.auditService.Record(c.Request.Context(), entry)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "import failed; rows imported so far can be rolled back", "result": result})
+		return
+	case err != nil:
+		logger.FromContext(c.Request.Context()).Error("Failed to import file", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to import file"})
+		return
+	}
+
+	if req.DryRun {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	entry := middleware.AuditEntry(c, "import", "import_job", strconv.FormatUint(uint64(result.JobID), 10))
+	entry.After = importSnapshot(result)
+	entry.Details = "Imported from " + req.FileName
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// ListJobs handles GET /api/import/jobs
+// Query parameters:
+//
+//	limit  - number of jobs (optional, default 50)
+//	offset - jobs to skip (optional)
+func (h *ImportHandler) ListJobs(c *gin.Context) {
+	limit := 50
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	jobs, total, err := h.importService.ListJobs(c.Request.Context(), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load import jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs, "total": total})
+}
+
+// GetJob handles GET /api/import/jobs/:id
+func (h *ImportHandler) GetJob(c *gin.Context) {
+	id, ok := importJobIDParam(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.importService.GetJob(c.Request.Context(), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "import job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load import job"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// RollbackJob handles POST /api/import/jobs/:id/rollback
+// Deletes every reading or device the job created. Only admins and the user
+// who ran the import may roll it back. Devices that have since received
+// readings from other sources are not rolled back.
+func (h *ImportHandler) RollbackJob(c *gin.Context) {
+	id, ok := importJobIDParam(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	before, _ := h.importService.GetJob(c.Request.Context(), id)
+	job, err := h.importService.Rollback(c.Request.Context(), id, userID.(uint))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "import job not found"})
+		return
+	}
+	if errors.Is(err, importer.ErrJobAccess) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, importer.ErrRollback) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to roll back import", zap.Uint("job_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to roll back import"})
+		return
+	}
+
+	entry := middleware.AuditEntry(c, "import_rollback", "import_job", strconv.FormatUint(uint64(id), 10))
+	if before != nil {
+		entry.Before = gin.H{"status": before.Status}
+	}
+	entry.After = gin.H{"status": job.Status}
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
+	c.JSON(http.StatusOK, job)
+}
+
+// importSnapshot is the audited outcome of an import, without its row
+// errors.
+func importSnapshot(result *dto.ImportResult) gin.H {
+	return gin.H{
+		"data_type":     result.DataType,
+		"status":        result.Status,
+		"total_rows":    result.TotalRows,
+		"invalid_rows":  result.InvalidRows,
+		"imported_rows": result.ImportedRows,
+	}
+}
+
+// formBool reads a boolean form field; anything unparsable is false.
+func formBool(c *gin.Context, name string) bool {
+	v, _ := strconv.ParseBool(c.PostForm(name))
+	return v
+}
+
+// importJobIDParam parses the :id path parameter, answering 400 if invalid.
+func importJobIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid import job id"})
+		return 0, false
+	}
+	return uint(id), true
+}
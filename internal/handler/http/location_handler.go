@@ -5,8 +5,10 @@ import (
 	"strconv"
 
 	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
 	"github.com/aruncs31s/skvms/internal/logger"
 	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -57,7 +59,7 @@ func (h *LocationHandler) GetLocation(c *gin.Context) {
 
 	location, err := h.locationService.GetByID(c.Request.Context(), uint(id))
 	if err != nil {
-		logger.GetLogger().Error("Failed to get location",
+		logger.FromContext(c.Request.Context()).Error("Failed to get location",
 			zap.Error(err),
 			zap.Uint("location_id", uint(id)),
 			zap.String("ip", c.ClientIP()),
@@ -85,7 +87,7 @@ func (h *LocationHandler) SearchLocations(c *gin.Context) {
 
 	locations, err := h.locationService.Search(c.Request.Context(), query)
 	if err != nil {
-		logger.GetLogger().Error("Failed to search locations",
+		logger.FromContext(c.Request.Context()).Error("Failed to search locations",
 			zap.Error(err),
 			zap.String("query", query),
 			zap.String("ip", c.ClientIP()),
@@ -99,7 +101,7 @@ func (h *LocationHandler) SearchLocations(c *gin.Context) {
 		return
 	}
 
-	logger.GetLogger().Debug("Locations searched successfully",
+	logger.FromContext(c.Request.Context()).Debug("Locations searched successfully",
 		zap.String("query", query),
 		zap.Int("count", len(locations)),
 	)
@@ -109,12 +111,15 @@ func (h *LocationHandler) SearchLocations(c *gin.Context) {
 func (h *LocationHandler) CreateLocation(c *gin.Context) {
 	var req dto.CreateLocationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(err)
+		utils.BindError(c, err)
 		return
 	}
 
-	if err := h.locationService.Create(c.Request.Context(), req); err != nil {
-		logger.GetLogger().Error("Failed to create location",
+	id, err := h.locationService.Create(c.Request.Context(), req)
+	if err != nil {
+		_ = c.Error(err)
+		logger.FromContext(c.Request.Context()).Error("Failed to create location",
 			zap.Error(err),
 			zap.String("code", req.Code),
 			zap.String("name", req.Name),
@@ -124,17 +129,16 @@ func (h *LocationHandler) CreateLocation(c *gin.Context) {
 		return
 	}
 
-	// Audit log
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "location_create",
-		"Created location: "+req.Name, c.ClientIP())
+	// The audit middleware records the creation
+	middleware.SetAuditResourceID(c, strconv.FormatUint(uint64(id), 10))
+	middleware.SetAuditChange(c, nil, req)
 
-	logger.GetLogger().Info("Location created successfully",
+	logger.FromContext(c.Request.Context()).Info("Location created successfully",
+		zap.Uint("location_id", id),
 		zap.String("code", req.Code),
 		zap.String("name", req.Name),
 	)
-	c.JSON(http.StatusCreated, gin.H{"message": "location created successfully"})
+	c.JSON(http.StatusCreated, gin.H{"message": "location created successfully", "id": id})
 }
 
 func (h *LocationHandler) UpdateLocation(c *gin.Context) {
@@ -146,16 +150,19 @@ func (h *LocationHandler) UpdateLocation(c *gin.Context) {
 
 	var req dto.UpdateLocationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(err)
+		utils.BindError(c, err)
 		return
 	}
 
+	before, _ := h.locationService.GetByID(c.Request.Context(), uint(id))
 	if err := h.locationService.Update(
 		c.Request.Context(),
 		uint(id),
 		req,
 	); err != nil {
-		logger.GetLogger().Error("Failed to update location",
+		_ = c.Error(err)
+		logger.FromContext(c.Request.Context()).Error("Failed to update location",
 			zap.Error(err),
 			zap.Uint("location_id", uint(id)),
 			zap.String("ip", c.ClientIP()),
@@ -164,13 +171,12 @@ func (h *LocationHandler) UpdateLocation(c *gin.Context) {
 		return
 	}
 
-	// Audit log
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "location_update",
-		"Updated location ID: "+strconv.FormatUint(id, 10), c.ClientIP())
+	// The audit middleware records the changed fields
+	if after, err := h.locationService.GetByID(c.Request.Context(), uint(id)); err == nil && before != nil {
+		middleware.SetAuditChange(c, before, after)
+	}
 
-	logger.GetLogger().Info("Location updated successfully",
+	logger.FromContext(c.Request.Context()).Info("Location updated successfully",
 		zap.Uint("location_id", uint(id)),
 	)
 	c.JSON(http.StatusOK, gin.H{"message": "location updated successfully"})
@@ -183,8 +189,10 @@ func (h *LocationHandler) DeleteLocation(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.locationService.GetByID(c.Request.Context(), uint(id))
 	if err := h.locationService.Delete(c.Request.Context(), uint(id)); err != nil {
-		logger.GetLogger().Error("Failed to delete location",
+		_ = c.Error(err)
+		logger.FromContext(c.Request.Context()).Error("Failed to delete location",
 			zap.Error(err),
 			zap.Uint("location_id", uint(id)),
 			zap.String("ip", c.ClientIP()),
@@ -193,13 +201,12 @@ func (h *LocationHandler) DeleteLocation(c *gin.Context) {
 		return
 	}
 
-	// Audit log
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "location_delete",
-		"Deleted location ID: "+strconv.FormatUint(id, 10), c.ClientIP())
+	// The audit middleware records the deletion
+	if before != nil {
+		middleware.SetAuditChange(c, before, nil)
+	}
 
-	logger.GetLogger().Info("Location deleted successfully",
+	logger.FromContext(c.Request.Context()).Info("Location deleted successfully",
 		zap.Uint("location_id", uint(id)),
 	)
 	c.JSON(http.StatusOK, gin.H{"message": "location deleted successfully"})
@@ -214,7 +221,7 @@ func (h *LocationHandler) ListDevicesInLocation(c *gin.Context) {
 
 	devices, err := h.locationService.ListDevicesInLocation(c.Request.Context(), uint(id))
 	if err != nil {
-		logger.GetLogger().Error("Failed to list devices in location",
+		logger.FromContext(c.Request.Context()).Error("Failed to list devices in location",
 			zap.Error(err),
 			zap.Uint("location_id", uint(id)),
 			zap.String("ip", c.ClientIP()),
@@ -223,7 +230,7 @@ func (h *LocationHandler) ListDevicesInLocation(c *gin.Context) {
 		return
 	}
 
-	logger.GetLogger().Debug("Devices in location listed successfully",
+	logger.FromContext(c.Request.Context()).Debug("Devices in location listed successfully",
 		zap.Uint("location_id", uint(id)),
 		zap.Int("device_count", len(devices)),
 	)
@@ -238,7 +245,7 @@ func (h *LocationHandler) GetSevenDaysReadings(c *gin.Context) {
 	}
 	responses, err := h.locationService.SevenDaysReadings(c.Request.Context(), uint(locationID))
 	if err != nil {
-		logger.GetLogger().Error("Failed to get seven days readings for location",
+		logger.FromContext(c.Request.Context()).Error("Failed to get seven days readings for location",
 			zap.Error(err),
 			zap.Uint("location_id", uint(locationID)),
 			zap.String("ip", c.ClientIP()),
@@ -247,7 +254,7 @@ func (h *LocationHandler) GetSevenDaysReadings(c *gin.Context) {
 		return
 	}
 
-	logger.GetLogger().Debug("Seven days readings for location retrieved successfully",
+	logger.FromContext(c.Request.Context()).Debug("Seven days readings for location retrieved successfully",
 		zap.Uint("location_id", uint(locationID)),
 		zap.Int("device_count", len(responses)),
 	)
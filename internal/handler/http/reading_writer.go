@@ -2,6 +2,7 @@ package http
 
 import (
 	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -26,7 +27,7 @@ func (h *ReadingHandler) CreateReading(c *gin.Context) {
 	}
 	var req dto.EssentialReadingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		utils.BindError(c, err)
 		return
 	}
 
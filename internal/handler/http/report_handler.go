@@ -0,0 +1,223 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ReportHandler manages saved report definitions, which are rendered on a
+// schedule and emailed, and serves their rendered files.
+type ReportHandler struct {
+	reportService service.ReportService
+	auditService  service.AuditService
+}
+
+func NewReportHandler(
+	reportService service.ReportService,
+	auditService service.AuditService,
+) *ReportHandler {
+	return &ReportHandler{
+		reportService: reportService,
+		auditService:  auditService,
+	}
+}
+
+// ListReports handles GET /api/reports
+func (h *ReportHandler) ListReports(c *gin.Context) {
+	reports, err := h.reportService.List(c.Request.Context())
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to list reports", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load reports"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+// GetReport handles GET /api/reports/:id
+func (h *ReportHandler) GetReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid report id"})
+		return
+	}
+
+	report, err := h.reportService.Get(c.Request.Context(), uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "report not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// CreateReport handles POST /api/reports
+func (h *ReportHandler) CreateReport(c *gin.Context) {
+	var req dto.ReportDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	report, err := h.reportService.Create(c.Request.Context(), req, userID.(uint))
+	if errors.Is(err, service.ErrInvalidReport) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to create report", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create report"})
+		return
+	}
+
+	entry := middleware.AuditEntry(c, "report_create", "report", strconv.FormatUint(uint64(report.ID), 10))
+	entry.After = report
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// UpdateReport handles PUT /api/reports/:id
+func (h *ReportHandler) UpdateReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid report id"})
+		return
+	}
+
+	var req dto.ReportDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	before, _ := h.reportService.Get(c.Request.Context(), uint(id))
+	report, err := h.reportService.Update(c.Request.Context(), uint(id), req, userID.(uint))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "report not found"})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidReport) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to update report", zap.Error(err), zap.Uint64("report_id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update report"})
+		return
+	}
+
+	entry := middleware.AuditEntry(c, "report_update", "report", strconv.FormatUint(id, 10))
+	entry.Before, entry.After = before, report
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
+	c.JSON(http.StatusOK, report)
+}
+
+// DeleteReport handles DELETE /api/reports/:id
+// The report's run history and stored files are removed with it.
+func (h *ReportHandler) DeleteReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid report id"})
+		return
+	}
+
+	before, _ := h.reportService.Get(c.Request.Context(), uint(id))
+	err = h.reportService.Delete(c.Request.Context(), uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "report not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete report"})
+		return
+	}
+
+	entry := middleware.AuditEntry(c, "report_delete", "report", strconv.FormatUint(id, 10))
+	entry.Before = before
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
+	c.JSON(http.StatusOK, gin.H{"message": "report deleted"})
+}
+
+// RunReport handles POST /api/reports/:id/run
+// Renders and delivers the report now. Render and delivery failures are
+// reported on the returned run rather than as an error status.
+func (h *ReportHandler) RunReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid report id"})
+		return
+	}
+
+	run, err := h.reportService.RunNow(c.Request.Context(), uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "report not found"})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to run report", zap.Error(err), zap.Uint64("report_id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to run report"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
+
+// ListReportRuns handles GET /api/reports/:id/runs
+func (h *ReportHandler) ListReportRuns(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid report id"})
+		return
+	}
+
+	runs, err := h.reportService.ListRuns(c.Request.Context(), uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "report not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load report runs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// DownloadReportRun handles GET /api/reports/runs/:run_id/download
+func (h *ReportHandler) DownloadReportRun(c *gin.Context) {
+	runID, err := strconv.ParseUint(c.Param("run_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+
+	path, filename, contentType, err := h.reportService.RunFile(c.Request.Context(), uint(runID))
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, service.ErrReportFileMissing) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "report file not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load report file"})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.FileAttachment(path, filename)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/aruncs31s/skvms/internal/dto"
 	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -71,12 +72,7 @@ func (h *SolarHandler) CreateASolarDevice(
 	var req dto.CreateSolarDeviceDTO
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(
-			400,
-			gin.H{
-				"error": err.Error(),
-			},
-		)
+		utils.BindError(c, err)
 		return
 	}
 
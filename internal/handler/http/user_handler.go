@@ -6,7 +6,9 @@ import (
 	"strconv"
 
 	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
 	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -51,27 +53,23 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var req dto.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BindError(c, err)
 		return
 	}
 	log.Printf("Creating user - name: %s, username: %s, email: %s, role: %s", req.Name, req.Username, req.Email, req.Role)
-	if err := h.userService.Create(
+	user, err := h.userService.Create(
 		c.Request.Context(),
 		&req,
-	); err != nil {
+	)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
 		return
 	}
 
 	// Audit log
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(
-		c.Request.Context(),
-		userID.(uint),
-		username.(string),
-		"user_create",
-		"Created user: "+req.Username, c.ClientIP())
+	entry := middleware.AuditEntry(c, "user_create", "user", strconv.FormatUint(uint64(user.ID), 10))
+	entry.After = user
+	_ = h.auditService.Record(c.Request.Context(), entry)
 
 	c.JSON(http.StatusCreated, gin.H{"message": "user created successfully"})
 }
@@ -85,20 +83,25 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 
 	var req dto.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BindError(c, err)
 		return
 	}
 
+	before, _ := h.userService.GetByID(c.Request.Context(), uint(id))
 	if err := h.userService.Update(c.Request.Context(), uint(id), &req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
 		return
 	}
 
 	// Audit log
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(c.Request.Context(), userID.(uint), username.(string), "user_update",
-		"Updated user ID: "+strconv.FormatUint(id, 10), c.ClientIP())
+	entry := middleware.AuditEntry(c, "user_update", "user", strconv.FormatUint(id, 10))
+	if after, err := h.userService.GetByID(c.Request.Context(), uint(id)); err == nil && before != nil {
+		entry.Before, entry.After = before, after
+	}
+	if req.Password != "" {
+		entry.Details = "Password changed"
+	}
+	_ = h.auditService.Record(c.Request.Context(), entry)
 
 	c.JSON(http.StatusOK, gin.H{"message": "user updated successfully"})
 }
@@ -110,20 +113,16 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.userService.GetByID(c.Request.Context(), uint(id))
 	if err := h.userService.Delete(c.Request.Context(), uint(id)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user"})
 		return
 	}
 
 	// Audit log
-	userID, _ := c.Get("user_id")
-	username, _ := c.Get("username")
-	_ = h.auditService.Log(
-		c.Request.Context(),
-		userID.(uint),
-		username.(string),
-		"user_delete",
-		"Deleted user ID: "+strconv.FormatUint(id, 10), c.ClientIP())
+	entry := middleware.AuditEntry(c, "user_delete", "user", strconv.FormatUint(id, 10))
+	entry.Before = before
+	_ = h.auditService.Record(c.Request.Context(), entry)
 
 	c.JSON(http.StatusOK, gin.H{"message": "user deleted successfully"})
 }
@@ -1,50 +1,36 @@
 package http
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
+	"github.com/aruncs31s/skvms/internal/model"
 	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type VersionHandler struct {
 	versionService service.VersionService
+	auditService   service.AuditService
 }
 
-func NewVersionHandler(versionService service.VersionService) *VersionHandler {
-	return &VersionHandler{versionService: versionService}
-}
-
-type createVersionRequest struct {
-	PreviousVersion string `json:"previous_version,omitempty"`
-	Version         string `json:"version" binding:"required"`
-}
-type createVersionWithFeatures struct {
-	PreviousVersion *uint  `json:"previous_version,omitempty"`
-	Version         string `json:"version" binding:"required"`
-	Features        []int  `json:"features,omitempty"`
-}
-
-type updateVersionRequest struct {
-	Version string `json:"version" binding:"required"`
-}
-
-type createFeatureRequest struct {
-	VersionID   uint   `json:"version_id" binding:"required"`
-	FeatureName string `json:"name" binding:"required"`
-	Enabled     bool   `json:"enabled"`
-}
-
-type updateFeatureRequest struct {
-	FeatureName string `json:"feature_name" binding:"required"`
-	Enabled     bool   `json:"enabled"`
+func NewVersionHandler(versionService service.VersionService, auditService service.AuditService) *VersionHandler {
+	return &VersionHandler{
+		versionService: versionService,
+		auditService:   auditService,
+	}
 }
 
 func (h *VersionHandler) CreateVersion(c *gin.Context) {
-	var req createVersionRequest
+	var req dto.CreateVersionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BindError(c, err)
 		return
 	}
 
@@ -89,9 +75,9 @@ func (h *VersionHandler) UpdateVersion(c *gin.Context) {
 		return
 	}
 
-	var req updateVersionRequest
+	var req dto.UpdateVersionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BindError(c, err)
 		return
 	}
 
@@ -121,9 +107,9 @@ func (h *VersionHandler) DeleteVersion(c *gin.Context) {
 }
 
 func (h *VersionHandler) CreateFeature(c *gin.Context) {
-	var req createFeatureRequest
+	var req dto.CreateFeatureRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BindError(c, err)
 		return
 	}
 
@@ -133,6 +119,12 @@ func (h *VersionHandler) CreateFeature(c *gin.Context) {
 		return
 	}
 
+	// Audit log: the feature's enabled flag is the default of a feature flag
+	entry := middleware.AuditEntry(c, "feature_create", "feature", strconv.FormatUint(uint64(feature.ID), 10))
+	entry.After = featureSnapshot(feature)
+	entry.Details = fmt.Sprintf("Created for version ID %d", req.VersionID)
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
 	c.JSON(http.StatusCreated, feature)
 }
 
@@ -159,18 +151,24 @@ func (h *VersionHandler) UpdateFeature(c *gin.Context) {
 		return
 	}
 
-	var req updateFeatureRequest
+	var req dto.UpdateFeatureRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BindError(c, err)
 		return
 	}
 
+	before, _ := h.versionService.GetFeature(c.Request.Context(), uint(id))
 	feature, err := h.versionService.UpdateFeature(c.Request.Context(), uint(id), req.FeatureName, req.Enabled)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Audit log
+	entry := middleware.AuditEntry(c, "feature_update", "feature", strconv.FormatUint(id, 10))
+	entry.Before, entry.After = featureSnapshot(before), featureSnapshot(feature)
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
 	c.JSON(http.StatusOK, feature)
 }
 
@@ -181,31 +179,21 @@ func (h *VersionHandler) DeleteFeature(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.versionService.GetFeature(c.Request.Context(), uint(id))
 	err = h.versionService.DeleteFeature(c.Request.Context(), uint(id))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Audit log
+	entry := middleware.AuditEntry(c, "feature_delete", "feature", strconv.FormatUint(id, 10))
+	entry.Before = featureSnapshot(before)
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
 	c.JSON(http.StatusOK, gin.H{"message": "feature deleted"})
 }
 
-func (h *VersionHandler) GetAllFeaturesByDevice(c *gin.Context) {
-	_, err := strconv.ParseUint(c.Param("id"), 10, 64)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device id"})
-		return
-	}
-
-	// features, err := h.versionService.GetAllFeaturesByDevice(c.Request.Context(), uint(deviceID))
-	// if err != nil {
-	// 	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get features for device"})
-	// 	return
-	// }
-
-	c.JSON(http.StatusOK, gin.H{"features": nil})
-}
 func (h *VersionHandler) GetVersionsByDevice(c *gin.Context) {
 	deviceID, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
@@ -228,13 +216,21 @@ func (h *VersionHandler) CreateNewDeviceVersion(c *gin.Context) {
 		return
 	}
 
-	var req createVersionWithFeatures
+	var req dto.CreateDeviceVersionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BindError(c, err)
 		return
 	}
 
 	version, err := h.versionService.CreateNewDeviceVersion(c.Request.Context(), uint(deviceID), req.PreviousVersion, req.Version, req.Features)
+	if errors.Is(err, service.ErrVersionCycle) || errors.Is(err, service.ErrOrphanVersion) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -242,3 +238,76 @@ func (h *VersionHandler) CreateNewDeviceVersion(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, version)
 }
+
+// GetDeviceLineage handles GET /api/devices/:id/versions/lineage
+func (h *VersionHandler) GetDeviceLineage(c *gin.Context) {
+	deviceID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device id"})
+		return
+	}
+
+	lineage, err := h.versionService.GetDeviceLineage(c.Request.Context(), uint(deviceID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get version lineage"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lineage": lineage})
+}
+
+// GetDeviceChangelog handles GET /api/devices/:id/versions/changelog
+func (h *VersionHandler) GetDeviceChangelog(c *gin.Context) {
+	deviceID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device id"})
+		return
+	}
+
+	changelog, err := h.versionService.GetDeviceChangelog(c.Request.Context(), uint(deviceID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get changelog"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"changelog": changelog})
+}
+
+// DiffVersions handles GET /api/versions/diff?from=<id>&to=<id>
+func (h *VersionHandler) DiffVersions(c *gin.Context) {
+	fromID, err := strconv.ParseUint(c.Query("from"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from version id"})
+		return
+	}
+	toID, err := strconv.ParseUint(c.Query("to"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to version id"})
+		return
+	}
+
+	diff, err := h.versionService.DiffVersions(c.Request.Context(), uint(fromID), uint(toID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to diff versions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"diff": diff})
+}
+
+// featureSnapshot is the audited state of a feature, without its versions.
+func featureSnapshot(feature *model.Feature) interface{} {
+	if feature == nil {
+		return nil
+	}
+	return gin.H{"feature_name": feature.FeatureName, "enabled": feature.Enabled}
+}
@@ -0,0 +1,190 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/handler/middleware"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// WiFiProfileHandler manages the WiFi credentials stored per location for
+// firmware builds. Passwords are write-only: they are never returned.
+type WiFiProfileHandler struct {
+	wifiProfileService service.WiFiProfileService
+	auditService       service.AuditService
+}
+
+func NewWiFiProfileHandler(
+	wifiProfileService service.WiFiProfileService,
+	auditService service.AuditService,
+) *WiFiProfileHandler {
+	return &WiFiProfileHandler{
+		wifiProfileService: wifiProfileService,
+		auditService:       auditService,
+	}
+}
+
+func (h *WiFiProfileHandler) ListWiFiProfiles(c *gin.Context) {
+	locationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid location id"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	profiles, err := h.wifiProfileService.ListByLocation(c.Request.Context(), uint(locationID), userID.(uint))
+	if errors.Is(err, service.ErrLocationAccess) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to list WiFi profiles",
+			zap.Error(err),
+			zap.Uint("location_id", uint(locationID)),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load WiFi profiles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"wifi_profiles": profiles})
+}
+
+func (h *WiFiProfileHandler) CreateWiFiProfile(c *gin.Context) {
+	locationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid location id"})
+		return
+	}
+
+	var req dto.CreateWiFiProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	profile, err := h.wifiProfileService.Create(
+		c.Request.Context(),
+		uint(locationID),
+		req,
+		userID.(uint),
+	)
+	if errors.Is(err, service.ErrLocationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "location not found"})
+		return
+	}
+	if errors.Is(err, service.ErrLocationAccess) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to create WiFi profile",
+			zap.Error(err),
+			zap.Uint("location_id", uint(locationID)),
+			zap.String("ip", c.ClientIP()),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create WiFi profile"})
+		return
+	}
+
+	entry := middleware.AuditEntry(c, "wifi_profile_create", "wifi_profile", strconv.FormatUint(uint64(profile.ID), 10))
+	entry.After = profile
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
+	c.JSON(http.StatusCreated, gin.H{"wifi_profile": profile})
+}
+
+func (h *WiFiProfileHandler) UpdateWiFiProfile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid WiFi profile id"})
+		return
+	}
+
+	var req dto.UpdateWiFiProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	before, _ := h.wifiProfileService.Get(c.Request.Context(), uint(id), userID.(uint))
+	profile, err := h.wifiProfileService.Update(
+		c.Request.Context(),
+		uint(id),
+		req,
+		userID.(uint),
+	)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "WiFi profile not found"})
+		return
+	}
+	if errors.Is(err, service.ErrLocationAccess) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to update WiFi profile",
+			zap.Error(err),
+			zap.Uint("wifi_profile_id", uint(id)),
+			zap.String("ip", c.ClientIP()),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update WiFi profile"})
+		return
+	}
+
+	entry := middleware.AuditEntry(c, "wifi_profile_update", "wifi_profile", strconv.FormatUint(id, 10))
+	entry.Before, entry.After = before, profile
+	if req.Password != "" {
+		entry.Details = "Password changed"
+	}
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
+	c.JSON(http.StatusOK, gin.H{"wifi_profile": profile})
+}
+
+func (h *WiFiProfileHandler) DeleteWiFiProfile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid WiFi profile id"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	before, _ := h.wifiProfileService.Get(c.Request.Context(), uint(id), userID.(uint))
+	err = h.wifiProfileService.Delete(c.Request.Context(), uint(id), userID.(uint))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "WiFi profile not found"})
+		return
+	}
+	if errors.Is(err, service.ErrLocationAccess) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to delete WiFi profile",
+			zap.Error(err),
+			zap.Uint("wifi_profile_id", uint(id)),
+			zap.String("ip", c.ClientIP()),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete WiFi profile"})
+		return
+	}
+
+	entry := middleware.AuditEntry(c, "wifi_profile_delete", "wifi_profile", strconv.FormatUint(id, 10))
+	entry.Before = before
+	_ = h.auditService.Record(c.Request.Context(), entry)
+
+	c.JSON(http.StatusOK, gin.H{"message": "WiFi profile deleted successfully"})
+}
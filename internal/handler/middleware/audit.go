@@ -2,97 +2,114 @@ package middleware
 
 import (
 	"context"
+	"net/http"
 	"strings"
-	"time"
 
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/model"
 	"github.com/aruncs31s/skvms/internal/service"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
 )
 
+// Context keys handlers set to enrich the entry written by Audit.
+const (
+	auditBeforeKey     = "audit_before"
+	auditAfterKey      = "audit_after"
+	auditResourceIDKey = "audit_resource_id"
+)
+
+// SetAuditChange records snapshots of the resource before and after the
+// request, so the audit entry lists the changed fields. Either may be nil.
+func SetAuditChange(c *gin.Context, before, after interface{}) {
+	c.Set(auditBeforeKey, before)
+	c.Set(auditAfterKey, after)
+}
+
+// SetAuditResourceID names the resource of the audit entry when it is not
+// the :id path parameter, e.g. the ID of a newly created resource.
+func SetAuditResourceID(c *gin.Context, id string) {
+	c.Set(auditResourceIDKey, id)
+}
+
+// AuditEntry starts the entry of an action a handler records itself with
+// AuditService.Record, filled with the acting user, the client and the
+// request. Handlers add the changes and details.
+func AuditEntry(c *gin.Context, action, resourceType, resourceID string) dto.AuditEntry {
+	userID, _ := c.Get("user_id")
+	id, _ := userID.(uint)
+	return dto.AuditEntry{
+		UserID:       id,
+		Username:     c.GetString("username"),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		IPAddress:    c.ClientIP(),
+		RequestID:    c.GetString("request_id"),
+		Method:       c.Request.Method,
+		Path:         c.FullPath(),
+	}
+}
+
 type AuditMiddleware struct {
 	auditService service.AuditService
-	jwtSecret    string
 }
 
-func NewAuditMiddleware(auditService service.AuditService, jwtSecret string) *AuditMiddleware {
+func NewAuditMiddleware(auditService service.AuditService) *AuditMiddleware {
 	return &AuditMiddleware{
 		auditService: auditService,
-		jwtSecret:    jwtSecret,
 	}
 }
 
-// Audit logs actions asynchronously using goroutines
-func (m *AuditMiddleware) Audit(action string) gin.HandlerFunc {
+// Audit records the request as an action on a resource of resourceType once
+// the handler has run, with the outcome of the request. Handlers attach the
+// cause of a failure with c.Error; it becomes the entry's details. It must
+// follow JWTAuth, which identifies the actor. Recording only queues the entry in
+// the local outbox, so it is durable without waiting for the database.
+func (m *AuditMiddleware) Audit(action, resourceType string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Extract user info from JWT token
-		userID, username, err := m.extractUserFromToken(c)
-		if err != nil {
-			// If we can't extract user info, continue without auditing
-			c.Next()
-			return
-		}
-
-		// Get client IP address
-		ipAddress := m.getClientIP(c)
-
-		// Get request details
-		method := c.Request.Method
-		path := c.Request.URL.Path
-		details := m.buildDetails(c, action, method, path)
-
-		// Log asynchronously using goroutine
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-
-			err := m.auditService.Log(ctx, userID, username, action, details, ipAddress)
-			if err != nil {
-				// Log the error but don't fail the request
-				// In a production system, you might want to use a proper logger here
-				// For now, we'll just ignore audit failures to not block the main flow
-			}
-		}()
-
 		c.Next()
-	}
-}
 
-// extractUserFromToken extracts user information from the JWT token
-func (m *AuditMiddleware) extractUserFromToken(c *gin.Context) (uint, string, error) {
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		return 0, "", jwt.ErrTokenMalformed
-	}
-
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
+		userID, ok := c.Get("user_id")
+		if !ok {
+			return
+		}
+		username, _ := c.Get("username")
+		name, _ := username.(string)
+
+		entry := dto.AuditEntry{
+			UserID:       userID.(uint),
+			Username:     name,
+			Action:       action,
+			ResourceType: resourceType,
+			ResourceID:   c.Param("id"),
+			IPAddress:    m.getClientIP(c),
+			RequestID:    c.GetString("request_id"),
+			Status:       model.AuditSuccess,
+			StatusCode:   c.Writer.Status(),
+			Method:       c.Request.Method,
+			Path:         c.FullPath(),
+		}
+		if id := c.GetString(auditResourceIDKey); id != "" {
+			entry.ResourceID = id
+		}
+		if entry.StatusCode >= http.StatusBadRequest {
+			entry.Status = model.AuditFailure
+			entry.Details = strings.Join(c.Errors.Errors(), "; ")
+		} else {
+			entry.Before, _ = c.Get(auditBeforeKey)
+			entry.After, _ = c.Get(auditAfterKey)
 		}
-		return []byte(m.jwtSecret), nil
-	})
-
-	if err != nil || !token.Valid {
-		return 0, "", err
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return 0, "", jwt.ErrTokenMalformed
-	}
-
-	userIDFloat, ok := claims["user_id"].(float64)
-	if !ok {
-		return 0, "", jwt.ErrTokenMalformed
-	}
 
-	username, ok := claims["username"].(string)
-	if !ok {
-		return 0, "", jwt.ErrTokenMalformed
+		if err := m.auditService.Record(context.WithoutCancel(c.Request.Context()), entry); err != nil {
+			logger.FromContext(c.Request.Context()).Error("Failed to write audit entry",
+				zap.Error(err),
+				zap.String("action", action),
+				zap.String("request_id", entry.RequestID),
+			)
+		}
 	}
-
-	return uint(userIDFloat), username, nil
 }
 
 // getClientIP extracts the real client IP address
@@ -114,36 +131,3 @@ func (m *AuditMiddleware) getClientIP(c *gin.Context) string {
 	// Fall back to RemoteAddr
 	return c.ClientIP()
 }
-
-// buildDetails creates a detailed description of the action
-func (m *AuditMiddleware) buildDetails(c *gin.Context, action, method, path string) string {
-	details := ""
-
-	switch action {
-	case "device_update":
-		deviceID := c.Param("id")
-		details = "Updated device ID: " + deviceID
-	case "device_create":
-		details = "Created new device"
-	case "device_delete":
-		deviceID := c.Param("id")
-		details = "Deleted device ID: " + deviceID
-	case "user_update":
-		userID := c.Param("id")
-		details = "Updated user ID: " + userID
-	case "user_create":
-		details = "Created new user"
-	case "user_delete":
-		userID := c.Param("id")
-		details = "Deleted user ID: " + userID
-	case "version_create":
-		details = "Created new version"
-	case "feature_create":
-		versionID := c.Param("verid")
-		details = "Created feature for version ID: " + versionID
-	default:
-		details = method + " " + path
-	}
-
-	return details
-}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// CORS answers the browser cross-origin checks for a list of allowed
+// origins that can be replaced at runtime.
+type CORS struct {
+	handler atomic.Pointer[gin.HandlerFunc]
+}
+
+func NewCORS(origins []string) *CORS {
+	c := &CORS{}
+	c.SetOrigins(origins)
+	return c
+}
+
+// SetOrigins replaces the allowed origins. "*" allows any origin.
+func (c *CORS) SetOrigins(origins []string) {
+	config := cors.Config{
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "X-Request-ID"},
+		AllowCredentials: true,
+	}
+	for _, origin := range origins {
+		if origin == "*" {
+			// Credentials cannot be shared with any origin, echo the
+			// caller's origin instead of "*"
+			config.AllowOriginFunc = func(string) bool { return true }
+			break
+		}
+		config.AllowOrigins = append(config.AllowOrigins, origin)
+	}
+	if config.AllowOriginFunc == nil && len(config.AllowOrigins) == 0 {
+		// No browser origin may call the API
+		config.AllowOriginFunc = func(string) bool { return false }
+	}
+	handler := cors.New(config)
+	c.handler.Store(&handler)
+}
+
+// Handler returns the middleware.
+func (c *CORS) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		(*c.handler.Load())(ctx)
+	}
+}
@@ -52,7 +52,22 @@ func JWTAuth(secret string) gin.HandlerFunc {
 		if username, ok := claims["username"].(string); ok {
 			c.Set("username", username)
 		}
+		if role, ok := claims["role"].(string); ok {
+			c.Set("role", role)
+		}
+
+		c.Next()
+	}
+}
 
+// RequireAdmin rejects users whose access token is not an admin's. It must
+// run after JWTAuth.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != "admin" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+			return
+		}
 		c.Next()
 	}
 }
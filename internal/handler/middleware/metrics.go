@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that matched no route, so scans of random
+// paths do not create a series per path.
+const unmatchedRoute = "unmatched"
+
+// Metrics records the count, latency and status of every request by its
+// route pattern, e.g. /api/devices/:id.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		done := metrics.HTTPRequestStarted()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		done(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
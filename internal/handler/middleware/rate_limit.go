@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitIdle is how long a client's bucket is kept after its last
+// request.
+const rateLimitIdle = 10 * time.Minute
+
+// RateLimiter limits the requests of each client IP with a token bucket:
+// a client may send burst requests at once and rate requests per second
+// after that. The limits can be changed at runtime; a zero rate disables
+// limiting.
+type RateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	l := &RateLimiter{}
+	l.SetLimit(rate, burst)
+	return l
+}
+
+// SetLimit replaces the limits. Clients start again with a full bucket.
+func (l *RateLimiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = float64(max(burst, 1))
+	l.buckets = map[string]*tokenBucket{}
+	l.lastSweep = time.Now()
+}
+
+// Handler returns the middleware. Limited requests get 429 with a
+// Retry-After header.
+func (l *RateLimiter) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if wait, ok := l.allow(c.ClientIP(), time.Now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// allow takes a token from the client's bucket, or returns how long until
+// one is available.
+func (l *RateLimiter) allow(client string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return 0, true
+	}
+	if now.Sub(l.lastSweep) > rateLimitIdle {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second)), false
+	}
+	bucket.tokens--
+	return 0, true
+}
+
+// sweep forgets the clients idle for rateLimitIdle; they start again with a
+// full bucket.
+func (l *RateLimiter) sweep(now time.Time) {
+	for client, bucket := range l.buckets {
+		if now.Sub(bucket.last) > rateLimitIdle {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
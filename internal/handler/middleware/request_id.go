@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/aruncs31s/skvms/internal/requestid"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestID tags every request with an ID, taken from the X-Request-ID
+// header when the client sent a usable one. The ID is echoed in the
+// response, stored as "request_id", carried in the request context and
+// recorded on the request's span.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		c.Set("request_id", id)
+		c.Header(requestid.Header, id)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request.id", id))
+		c.Next()
+	}
+}
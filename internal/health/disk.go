@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// errDiskUnsupported is returned by freeSpace where it cannot be measured.
+var errDiskUnsupported = errors.New("disk space cannot be measured on this platform")
+
+// DiskSpace checks that the file system holding dir has at least minFree
+// bytes available. A dir that does not exist yet is measured through its
+// nearest existing parent.
+func DiskSpace(dir string, minFree uint64) CheckFunc {
+	return func(ctx context.Context) error {
+		path, err := existingParent(dir)
+		if err != nil {
+			return err
+		}
+		free, err := freeSpace(path)
+		if errors.Is(err, errDiskUnsupported) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", path, err)
+		}
+		if free < minFree {
+			return fmt.Errorf("%d MB free in %s, want at least %d MB", free>>20, path, minFree>>20)
+		}
+		return nil
+	}
+}
+
+func existingParent(dir string) (string, error) {
+	path, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", fmt.Errorf("no existing directory above %s", dir)
+		}
+		path = parent
+	}
+}
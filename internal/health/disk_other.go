@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package health
+
+func freeSpace(path string) (uint64, error) {
+	return 0, errDiskUnsupported
+}
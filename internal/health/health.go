@@ -0,0 +1,170 @@
+// Package health answers the liveness and readiness probes. Readiness runs
+// the registered checks, e.g. the database and disk space, and looks at the
+// heartbeats of the background workers; liveness only fails when a worker is
+// stuck, so that a restart can help.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a report and of its checks.
+const (
+	StatusOK = "ok"
+	// StatusDegraded means a non-critical check failed: the server can take
+	// traffic but some features, e.g. PDF export, do not work.
+	StatusDegraded = "degraded"
+	StatusFailing  = "failing"
+)
+
+// checkTimeout bounds a single check.
+const checkTimeout = 3 * time.Second
+
+// CheckFunc checks a dependency, returning why it is unhealthy.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// CheckResult is the outcome of one check.
+type CheckResult struct {
+	Status     string  `json:"status"`
+	Critical   bool    `json:"critical"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Report is the answer to a probe.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Healthy reports whether the probe passes. A degraded server still does.
+func (r Report) Healthy() bool {
+	return r.Status != StatusFailing
+}
+
+// Checker holds the checks and workers behind the probes.
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []check
+	workers []*Worker
+
+	shuttingDown atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Add registers a readiness check. The server is not ready while a critical
+// check fails, and degraded while any other does.
+func (c *Checker) Add(name string, critical bool, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// AddWorker registers a background worker. Its failures are critical.
+func (c *Checker) AddWorker(w *Worker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.workers = append(c.workers, w)
+}
+
+// Workers returns the registered workers.
+func (c *Checker) Workers() []*Worker {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]*Worker(nil), c.workers...)
+}
+
+// SetShuttingDown makes readiness fail so that load balancers stop sending
+// requests while the server drains.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Live reports whether the process is working: it fails only when a
+// background worker stopped making progress.
+func (c *Checker) Live(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: map[string]CheckResult{}}
+	for _, w := range c.Workers() {
+		result := CheckResult{Status: StatusOK, Critical: true}
+		if err := w.stalled(); err != nil {
+			result.Status = StatusFailing
+			result.Error = err.Error()
+			report.Status = StatusFailing
+		}
+		report.Checks["worker:"+w.Name()] = result
+	}
+	return report
+}
+
+// Ready runs every check concurrently and reports whether the server can
+// take traffic.
+func (c *Checker) Ready(ctx context.Context) Report {
+	if c.shuttingDown.Load() {
+		return Report{Status: StatusFailing, Checks: map[string]CheckResult{
+			"shutdown": {Status: StatusFailing, Critical: true, Error: "server is shutting down"},
+		}}
+	}
+
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	for _, w := range c.workers {
+		checks = append(checks, check{name: "worker:" + w.Name(), critical: true, fn: w.Check})
+	}
+	c.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, chk)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	for i, chk := range checks {
+		result := results[i]
+		report.Checks[chk.name] = result
+		if result.Status == StatusOK {
+			continue
+		}
+		if chk.critical {
+			report.Status = StatusFailing
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, chk check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := chk.fn(ctx)
+	result := CheckResult{
+		Status:     StatusOK,
+		Critical:   chk.critical,
+		DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
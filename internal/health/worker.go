@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// minStallTimeout is the least time a worker may go without a heartbeat
+// before it counts as stalled, whatever its interval.
+const minStallTimeout = 5 * time.Minute
+
+// Worker tracks a background loop: it beats after every cycle and stops
+// when the loop returns. A worker is unhealthy when its last cycle failed,
+// when it stopped, or when it has not beaten for three intervals.
+type Worker struct {
+	name         string
+	stallTimeout time.Duration
+	done         chan struct{}
+	stopOnce     sync.Once
+
+	mu       sync.Mutex
+	lastBeat time.Time
+	lastErr  error
+}
+
+// NewWorker creates the tracker of a loop that runs every interval.
+func NewWorker(name string, interval time.Duration) *Worker {
+	return &Worker{
+		name:         name,
+		stallTimeout: max(3*interval, minStallTimeout),
+		done:         make(chan struct{}),
+		lastBeat:     time.Now(),
+	}
+}
+
+func (w *Worker) Name() string {
+	return w.name
+}
+
+// Beat records the end of a cycle and its error, if any.
+func (w *Worker) Beat(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastBeat = time.Now()
+	w.lastErr = err
+}
+
+// Stop marks the loop as returned.
+func (w *Worker) Stop() {
+	w.stopOnce.Do(func() { close(w.done) })
+}
+
+// Done is closed once the loop returned.
+func (w *Worker) Done() <-chan struct{} {
+	return w.done
+}
+
+// Wait waits for the loop to return, up to ctx's deadline.
+func (w *Worker) Wait(ctx context.Context) error {
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("worker %s did not stop: %w", w.name, ctx.Err())
+	}
+}
+
+// Check reports why the worker is unhealthy, if it is.
+func (w *Worker) Check(ctx context.Context) error {
+	select {
+	case <-w.done:
+		return errors.New("worker stopped")
+	default:
+	}
+	if err := w.stalled(); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.lastErr != nil {
+		return fmt.Errorf("last run failed: %w", w.lastErr)
+	}
+	return nil
+}
+
+// stalled reports a running worker that has not beaten for too long.
+func (w *Worker) stalled() error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if since := time.Since(w.lastBeat); since > w.stallTimeout {
+		return fmt.Errorf("no progress for %s", since.Truncate(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aruncs31s/skvms/internal/importer/dto"
+)
+
+// csvParser reads comma-separated values files with a header row.
+type csvParser struct{}
+
+func newCSVParser() Parser {
+	return &csvParser{}
+}
+
+func (p *csvParser) Format() dto.ImportFormat {
+	return dto.FormatCSV
+}
+
+func (p *csvParser) Parse(ctx context.Context, r io.Reader, _ ParseOptions) (*Table, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	table := &Table{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: csv: %w", ErrInvalidFile, err)
+		}
+		line, _ := cr.FieldPos(0)
+		if table.Headers == nil {
+			// Spreadsheet programs often save CSV with a byte order mark
+			record[0] = strings.TrimPrefix(record[0], "\ufeff")
+			table.Headers = record
+			continue
+		}
+		if blank(record) {
+			continue
+		}
+		if len(table.Rows) == maxRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidFile, maxRows)
+		}
+		table.Rows = append(table.Rows, Row{Number: line, Cells: record})
+	}
+	if table.Headers == nil {
+		return nil, fmt.Errorf("%w: csv: the file is empty", ErrInvalidFile)
+	}
+	return table, nil
+}
@@ -0,0 +1,104 @@
+package dto
+
+import "time"
+
+// ImportFormat represents the format of an uploaded file.
+type ImportFormat string
+
+const (
+	FormatCSV  ImportFormat = "csv"
+	FormatXLSX ImportFormat = "xlsx"
+	// FormatJSON is an array of objects, one per row.
+	FormatJSON ImportFormat = "json"
+)
+
+// Data types that can be imported.
+const (
+	DataTypeReadings = "readings"
+	DataTypeDevices  = "devices"
+)
+
+// ImportRequest holds the parameters of an import.
+type ImportRequest struct {
+	// Format of the file; inferred from FileName when empty.
+	Format ImportFormat `json:"format"`
+
+	// DataType is what the rows hold: "readings" or "devices".
+	DataType string `json:"data_type" binding:"required"`
+
+	// FileName is the name of the uploaded file, kept on the job.
+	FileName string `json:"file_name"`
+
+	// Mapping maps field names to source column names. Fields left out are
+	// matched to columns with the same name, ignoring case, spaces and units,
+	// so files exported by SKVMS import without a mapping.
+	Mapping map[string]string `json:"mapping"`
+
+	// Sheet selects the XLSX worksheet; the first sheet by default.
+	Sheet string `json:"sheet"`
+
+	// DryRun validates the file and returns a preview without saving.
+	DryRun bool `json:"dry_run"`
+
+	// SkipInvalid imports the valid rows of a file with invalid rows. By
+	// default any invalid row rejects the whole import.
+	SkipInvalid bool `json:"skip_invalid"`
+}
+
+// Field describes a field rows are mapped onto.
+type Field struct {
+	Name        string `json:"name"`
+	Required    bool   `json:"required"`
+	Description string `json:"description"`
+}
+
+// RowError reports why a row cannot be imported.
+type RowError struct {
+	// Row is the row number in the file (the header row of a spreadsheet is 1)
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult summarizes a dry run or a committed import.
+type ImportResult struct {
+	JobID    uint   `json:"job_id,omitempty"`
+	DataType string `json:"data_type"`
+	Format   string `json:"format"`
+	DryRun   bool   `json:"dry_run"`
+	Status   string `json:"status"`
+
+	// Mapping is the effective field to column mapping
+	Mapping map[string]string `json:"mapping"`
+
+	TotalRows    int `json:"total_rows"`
+	ValidRows    int `json:"valid_rows"`
+	InvalidRows  int `json:"invalid_rows"`
+	ImportedRows int `json:"imported_rows"`
+
+	// Errors lists the first row errors
+	Errors []RowError `json:"errors"`
+	// Preview holds the first valid rows as they would be imported
+	Preview []map[string]interface{} `json:"preview,omitempty"`
+}
+
+// ImportJobResponse is a committed import and its outcome.
+type ImportJobResponse struct {
+	ID           uint              `json:"id"`
+	DataType     string            `json:"data_type"`
+	Format       string            `json:"format"`
+	FileName     string            `json:"file_name"`
+	Mapping      map[string]string `json:"mapping"`
+	Status       string            `json:"status"`
+	Error        string            `json:"error,omitempty"`
+	TotalRows    int               `json:"total_rows"`
+	InvalidRows  int               `json:"invalid_rows"`
+	ImportedRows int               `json:"imported_rows"`
+	Errors       []RowError        `json:"errors,omitempty"`
+	CreatedBy    uint              `json:"created_by"`
+	CreatedAt    time.Time         `json:"created_at"`
+	FinishedAt   *time.Time        `json:"finished_at,omitempty"`
+	RolledBackBy *uint             `json:"rolled_back_by,omitempty"`
+	RolledBackAt *time.Time        `json:"rolled_back_at,omitempty"`
+}
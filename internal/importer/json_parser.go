@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/aruncs31s/skvms/internal/importer/dto"
+)
+
+// jsonParser reads a JSON array of flat objects, one per row. The headers
+// are the keys of all objects in the order they first appear.
+type jsonParser struct{}
+
+func newJSONParser() Parser {
+	return &jsonParser{}
+}
+
+func (p *jsonParser) Format() dto.ImportFormat {
+	return dto.FormatJSON
+}
+
+func (p *jsonParser) Parse(ctx context.Context, r io.Reader, _ ParseOptions) (*Table, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("%w: json: %w", ErrInvalidFile, err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("%w: json: expected an array of objects", ErrInvalidFile)
+	}
+
+	table := &Table{Headers: []string{}}
+	columns := make(map[string]int)
+	var objects []map[string]string
+	for dec.More() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(objects) == maxRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidFile, maxRows)
+		}
+		number := len(objects) + 1
+		object, keys, err := decodeObject(dec)
+		if err != nil {
+			return nil, fmt.Errorf("%w: json: object %d: %w", ErrInvalidFile, number, err)
+		}
+		for _, k := range keys {
+			if _, ok := columns[k]; !ok {
+				columns[k] = len(table.Headers)
+				table.Headers = append(table.Headers, k)
+			}
+		}
+		objects = append(objects, object)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("%w: json: %w", ErrInvalidFile, err)
+	}
+
+	for i, object := range objects {
+		cells := make([]string, len(table.Headers))
+		for k, v := range object {
+			cells[columns[k]] = v
+		}
+		if blank(cells) {
+			continue
+		}
+		table.Rows = append(table.Rows, Row{Number: i + 1, Cells: cells})
+	}
+	return table, nil
+}
+
+// decodeObject reads one flat object, returning its values as strings and
+// its keys in order. Nested objects and arrays are rejected.
+func decodeObject(dec *json.Decoder) (map[string]string, []string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, nil, errors.New("not an object")
+	}
+
+	object := make(map[string]string)
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := tok.(string)
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		if _, seen := object[key]; !seen {
+			keys = append(keys, key)
+		}
+		switch v := value.(type) {
+		case nil:
+			object[key] = ""
+		case string:
+			object[key] = v
+		case json.Number:
+			object[key] = v.String()
+		case bool:
+			object[key] = strconv.FormatBool(v)
+		default:
+			return nil, nil, fmt.Errorf("field %q: nested values are not supported", key)
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	return object, keys, nil
+}
@@ -0,0 +1,227 @@
+package importer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/importer/dto"
+	"github.com/xuri/excelize/v2"
+)
+
+// Fields that rows are mapped onto.
+const (
+	fieldDeviceID   = "device_id"
+	fieldDeviceName = "device_name"
+	fieldVoltage    = "voltage"
+	fieldCurrent    = "current"
+	fieldCreatedAt  = "created_at"
+
+	fieldName       = "name"
+	fieldType       = "type"
+	fieldIPAddress  = "ip_address"
+	fieldMACAddress = "mac_address"
+	fieldLocation   = "location"
+)
+
+var readingFields = []dto.Field{
+	{Name: fieldDeviceID, Description: "ID of the device; device_id or device_name is required"},
+	{Name: fieldDeviceName, Description: "Name of the device, when it is unique"},
+	{Name: fieldVoltage, Required: true, Description: "Voltage in V"},
+	{Name: fieldCurrent, Required: true, Description: "Current in A"},
+	{Name: fieldCreatedAt, Required: true, Description: "Time of the reading: RFC 3339, \"2006-01-02 15:04:05\" in server time, or a Unix timestamp"},
+}
+
+var deviceFields = []dto.Field{
+	{Name: fieldName, Required: true, Description: "Name of the device; must not already exist"},
+	{Name: fieldType, Required: true, Description: "Name or ID of the device type"},
+	{Name: fieldIPAddress, Description: "IP address"},
+	{Name: fieldMACAddress, Description: "MAC address"},
+	{Name: fieldLocation, Description: "Code, name or ID of the location the device is assigned to"},
+}
+
+// headerAliases maps normalized headers that differ from the field name,
+// such as the typed column keys written by the exporters, onto fields.
+var headerAliases = map[string]string{
+	"voltage_v":   fieldVoltage,
+	"current_a":   fieldCurrent,
+	"timestamp":   fieldCreatedAt,
+	"time":        fieldCreatedAt,
+	"device":      fieldDeviceName,
+	"device_type": fieldType,
+	"ip":          fieldIPAddress,
+	"mac":         fieldMACAddress,
+	"location_id": fieldLocation,
+}
+
+// fieldsFor returns the fields of a data type.
+func fieldsFor(dataType string) ([]dto.Field, bool) {
+	switch dataType {
+	case dto.DataTypeReadings:
+		return readingFields, true
+	case dto.DataTypeDevices:
+		return deviceFields, true
+	}
+	return nil, false
+}
+
+// normalizeHeader converts a header to snake case, e.g. "Voltage (V)"
+// becomes "voltage_v".
+func normalizeHeader(h string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(h)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if underscore && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			underscore = false
+			continue
+		}
+		underscore = true
+	}
+	return b.String()
+}
+
+// columnMap holds the column index of every mapped field.
+type columnMap map[string]int
+
+// resolveMapping matches fields to columns of headers. Explicit entries of
+// mapping name a column exactly or by its normalized form; other fields
+// are matched by normalized header. It returns the column indexes and the
+// effective mapping of field to header.
+func resolveMapping(fields []dto.Field, headers []string, mapping map[string]string) (columnMap, map[string]string, error) {
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.Name] = true
+	}
+
+	byHeader := make(map[string]int, len(headers))
+	byNormalized := make(map[string]int, len(headers))
+	for i, h := range headers {
+		if _, ok := byHeader[h]; !ok {
+			byHeader[h] = i
+		}
+		n := normalizeHeader(h)
+		if _, ok := byNormalized[n]; !ok && n != "" {
+			byNormalized[n] = i
+		}
+	}
+
+	columns := make(columnMap)
+	for field, header := range mapping {
+		if !known[field] {
+			return nil, nil, fmt.Errorf("%w: unknown field %q in mapping", ErrInvalidMapping, field)
+		}
+		if header == "" {
+			continue
+		}
+		i, ok := byHeader[header]
+		if !ok {
+			i, ok = byNormalized[normalizeHeader(header)]
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: column %q of field %q not found", ErrInvalidMapping, header, field)
+		}
+		columns[field] = i
+	}
+
+	// Columns named after a field win over aliases, which are taken in
+	// header order
+	for _, f := range fields {
+		if _, explicit := mapping[f.Name]; explicit {
+			continue
+		}
+		if i, ok := byNormalized[f.Name]; ok {
+			columns[f.Name] = i
+		}
+	}
+	for i, h := range headers {
+		field, ok := headerAliases[normalizeHeader(h)]
+		if !ok || !known[field] {
+			continue
+		}
+		if _, explicit := mapping[field]; explicit {
+			continue
+		}
+		if _, mapped := columns[field]; !mapped {
+			columns[field] = i
+		}
+	}
+
+	var missing []string
+	for _, f := range fields {
+		if _, ok := columns[f.Name]; f.Required && !ok {
+			missing = append(missing, f.Name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, nil, fmt.Errorf("%w: no column for required field(s) %s", ErrInvalidMapping, strings.Join(missing, ", "))
+	}
+
+	effective := make(map[string]string, len(columns))
+	for field, i := range columns {
+		effective[field] = headers[i]
+	}
+	return columns, effective, nil
+}
+
+// value returns the cell of a field in row, or "" when the field is not mapped.
+func (m columnMap) value(row Row, field string) string {
+	i, ok := m[field]
+	if !ok {
+		return ""
+	}
+	return row.Cell(i)
+}
+
+// parseNumber parses a finite decimal number.
+func parseNumber(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return f, nil
+}
+
+// timeLayouts are tried in order; layouts without a zone are read in the
+// server's local time, like the rest of SKVMS.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTime parses a timestamp in one of timeLayouts, a Unix timestamp in
+// seconds or milliseconds, or an Excel serial date as read from
+// unformatted spreadsheet cells.
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && f > 0 {
+		switch {
+		case f < 1e6:
+			t, err := excelize.ExcelDateToTime(f, false)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("%q is not a valid time", s)
+			}
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local), nil
+		case f < 1e11:
+			return time.Unix(int64(f), 0), nil
+		default:
+			return time.UnixMilli(int64(f)), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a valid time", s)
+}
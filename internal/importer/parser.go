@@ -0,0 +1,71 @@
+package importer
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/aruncs31s/skvms/internal/importer/dto"
+)
+
+// Parser defines the strategy interface for reading an uploaded file in a
+// specific format into a table of strings.
+type Parser interface {
+	// Format returns the import format this parser handles.
+	Format() dto.ImportFormat
+
+	// Parse reads the whole file. Blank rows are skipped; every other row is
+	// returned with its number in the file, so errors can point at it.
+	Parse(ctx context.Context, r io.Reader, opts ParseOptions) (*Table, error)
+}
+
+// ParseOptions holds format specific options.
+type ParseOptions struct {
+	// Sheet is the XLSX worksheet to read; the first sheet when empty.
+	Sheet string
+}
+
+// Table is a parsed file: the header row and the data rows below it.
+type Table struct {
+	Headers []string
+	Rows    []Row
+}
+
+// Row is one data row of a file.
+type Row struct {
+	// Number is the line or spreadsheet row number, starting at 1
+	Number int
+	Cells  []string
+}
+
+// Cell returns the value of column i, or "" when the row is short.
+func (r Row) Cell(i int) string {
+	if i < 0 || i >= len(r.Cells) {
+		return ""
+	}
+	return strings.TrimSpace(r.Cells[i])
+}
+
+// blank reports whether every cell of a row is empty.
+func blank(cells []string) bool {
+	for _, c := range cells {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatFromFileName infers the import format from a file extension.
+func FormatFromFileName(name string) dto.ImportFormat {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".xlsx"):
+		return dto.FormatXLSX
+	case strings.HasSuffix(name, ".json"):
+		return dto.FormatJSON
+	case strings.HasSuffix(name, ".csv"):
+		return dto.FormatCSV
+	}
+	return ""
+}
@@ -0,0 +1,357 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/importer/dto"
+	"github.com/aruncs31s/skvms/internal/metrics"
+	"github.com/aruncs31s/skvms/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnsupportedFormat reports a file format without a parser.
+	ErrUnsupportedFormat = errors.New("unsupported import format")
+	// ErrInvalidFile reports a file that cannot be parsed.
+	ErrInvalidFile = errors.New("invalid import file")
+	// ErrInvalidMapping reports an unknown data type or a column mapping
+	// that does not fit the file.
+	ErrInvalidMapping = errors.New("invalid column mapping")
+	// ErrInvalidRows rejects a commit of a file with invalid rows when
+	// SkipInvalid is not set.
+	ErrInvalidRows = errors.New("file has invalid rows")
+	// ErrImportFailed reports a commit that stopped part way; the rows of
+	// the batches before the failure are kept and can be rolled back.
+	ErrImportFailed = errors.New("import failed")
+	// ErrRollback reports a job that cannot be rolled back.
+	ErrRollback = errors.New("import cannot be rolled back")
+	// ErrJobAccess reports a rollback by a user who is neither an admin nor
+	// the creator of the job.
+	ErrJobAccess = errors.New("no access to this import job")
+)
+
+const (
+	// maxRows is the largest number of data rows accepted in one file.
+	maxRows = 500000
+	// batchSize is the number of rows inserted per transaction.
+	batchSize = 1000
+	// maxRowErrors is the number of row errors reported and kept on a job.
+	maxRowErrors = 100
+	// previewRows is the number of rows shown by a dry run.
+	previewRows = 20
+)
+
+// Store is the persistence the import service needs. It is implemented by
+// repository.ImportRepository.
+type Store interface {
+	// IsAdmin reports whether the user has the admin role.
+	IsAdmin(ctx context.Context, userID uint) (bool, error)
+	// ListDeviceRefs returns the ID, name, type and owner of every device.
+	ListDeviceRefs(ctx context.Context) ([]model.Device, error)
+	ListDeviceTypes(ctx context.Context) ([]model.DeviceTypes, error)
+	ListLocations(ctx context.Context) ([]model.Location, error)
+
+	CreateJob(ctx context.Context, job *model.ImportJob) error
+	UpdateJob(ctx context.Context, job *model.ImportJob) error
+	GetJob(ctx context.Context, id uint) (*model.ImportJob, error)
+	ListJobs(ctx context.Context, limit, offset int) ([]model.ImportJob, int64, error)
+
+	// InsertReadings inserts one batch of readings in a transaction.
+	InsertReadings(ctx context.Context, readings []model.Reading) error
+	// InsertDevices inserts one batch of devices with their details and,
+	// when Assignment.LocationID is set, their assignment, in a transaction.
+	InsertDevices(ctx context.Context, devices []model.Device) error
+
+	// CountForeignReadings counts readings of the devices of a job that
+	// were not loaded by the job.
+	CountForeignReadings(ctx context.Context, jobID uint) (int64, error)
+	// DeleteJobRows marks the job rolled back and deletes every row it
+	// created, in a transaction. It returns the number of rows deleted, or
+	// gorm.ErrRecordNotFound when the job is running or already rolled back.
+	DeleteJobRows(ctx context.Context, job *model.ImportJob) (int64, error)
+}
+
+// Service imports readings and devices from uploaded files, mirroring
+// export.Service: parsers for each format turn a file into a table, which
+// is mapped onto the fields of a data type, validated against the devices,
+// device types and locations in the database, and then previewed or
+// committed in batches under an import job.
+type Service struct {
+	parsers map[dto.ImportFormat]Parser
+	store   Store
+}
+
+// NewService creates a new import Service.
+func NewService(store Store) *Service {
+	s := &Service{
+		parsers: make(map[dto.ImportFormat]Parser),
+		store:   store,
+	}
+
+	// Register built-in parsers
+	s.register(newCSVParser())
+	s.register(newXLSXParser())
+	s.register(newJSONParser())
+
+	return s
+}
+
+// register adds a parser to the service.
+func (s *Service) register(p Parser) {
+	s.parsers[p.Format()] = p
+}
+
+// SupportedFormats returns the list of import formats.
+func (s *Service) SupportedFormats() []string {
+	formats := make([]string, 0, len(s.parsers))
+	for f := range s.parsers {
+		formats = append(formats, string(f))
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Fields returns the fields rows of a data type are mapped onto.
+func (s *Service) Fields(dataType string) ([]dto.Field, error) {
+	fields, ok := fieldsFor(dataType)
+	if !ok {
+		return nil, fmt.Errorf("%w: data_type must be %s or %s", ErrInvalidMapping, dto.DataTypeReadings, dto.DataTypeDevices)
+	}
+	return fields, nil
+}
+
+// Import parses r, validates every row and either returns a preview (dry
+// run) or commits the valid rows under a new import job created by userID.
+// Readings are only imported into devices the user owns, unless the user is
+// an admin.
+// A commit that fails part way returns the result of the job together with
+// an error wrapping ErrImportFailed.
+func (s *Service) Import(ctx context.Context, req dto.ImportRequest, r io.Reader, userID uint) (*dto.ImportResult, error) {
+	if req.Format == "" {
+		req.Format = FormatFromFileName(req.FileName)
+	}
+	parser, ok := s.parsers[req.Format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q, expected one of %s",
+			ErrUnsupportedFormat, req.Format, strings.Join(s.SupportedFormats(), ", "))
+	}
+	fields, err := s.Fields(req.DataType)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := parser.Parse(ctx, r, ParseOptions{Sheet: req.Sheet})
+	if err != nil {
+		return nil, err
+	}
+	columns, mapping, err := resolveMapping(fields, table.Headers, req.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := s.store.IsAdmin(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.newValidator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !admin {
+		v.owner = userID
+	}
+	var batch rowBatch
+	switch req.DataType {
+	case dto.DataTypeReadings:
+		if _, ok := columns[fieldDeviceID]; !ok {
+			if _, ok := columns[fieldDeviceName]; !ok {
+				return nil, fmt.Errorf("%w: no column for device_id or device_name", ErrInvalidMapping)
+			}
+		}
+		batch = v.readings(table.Rows, columns)
+	case dto.DataTypeDevices:
+		batch = v.devices(table.Rows, columns, userID)
+	}
+
+	result := &dto.ImportResult{
+		DataType:    req.DataType,
+		Format:      string(req.Format),
+		DryRun:      req.DryRun,
+		Mapping:     mapping,
+		TotalRows:   len(table.Rows),
+		ValidRows:   batch.len(),
+		InvalidRows: v.invalid,
+		Errors:      v.errors,
+	}
+	if result.Errors == nil {
+		result.Errors = []dto.RowError{}
+	}
+
+	if req.DryRun {
+		result.Status = "preview"
+		result.Preview = batch.preview(previewRows)
+		return result, nil
+	}
+	if v.invalid > 0 && !req.SkipInvalid {
+		return result, fmt.Errorf("%w: %d of %d rows are invalid", ErrInvalidRows, v.invalid, len(table.Rows))
+	}
+	return result, s.commit(ctx, req, result, batch, userID)
+}
+
+// commit records an import job and inserts the rows in batches, keeping
+// the job's counts up to date as it goes.
+func (s *Service) commit(ctx context.Context, req dto.ImportRequest, result *dto.ImportResult, batch rowBatch, userID uint) error {
+	mapping, _ := json.Marshal(result.Mapping)
+	rowErrors, _ := json.Marshal(result.Errors)
+	job := &model.ImportJob{
+		DataType:    req.DataType,
+		Format:      string(req.Format),
+		FileName:    req.FileName,
+		Mapping:     string(mapping),
+		Status:      model.ImportJobRunning,
+		TotalRows:   result.TotalRows,
+		InvalidRows: result.InvalidRows,
+		Errors:      string(rowErrors),
+		CreatedBy:   userID,
+	}
+	if err := s.store.CreateJob(ctx, job); err != nil {
+		return err
+	}
+	result.JobID = job.ID
+
+	started := time.Now()
+	var insertErr error
+	for start := 0; start < batch.len(); start += batchSize {
+		end := min(start+batchSize, batch.len())
+		if insertErr = batch.insert(ctx, s.store, job.ID, start, end); insertErr != nil {
+			break
+		}
+		job.ImportedRows = end
+		if insertErr = s.store.UpdateJob(ctx, job); insertErr != nil {
+			break
+		}
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = model.ImportJobCompleted
+	if insertErr != nil {
+		job.Status = model.ImportJobFailed
+		job.Error = insertErr.Error()
+	}
+	// Record the outcome even when the request was cancelled part way
+	if err := s.store.UpdateJob(context.WithoutCancel(ctx), job); err != nil && insertErr == nil {
+		insertErr = err
+	}
+
+	metrics.ObserveJob("import", insertErr, time.Since(started))
+
+	result.Status = job.Status
+	result.ImportedRows = job.ImportedRows
+	if insertErr != nil {
+		return fmt.Errorf("%w: job %d: %w", ErrImportFailed, job.ID, insertErr)
+	}
+	return nil
+}
+
+// Rollback deletes every row created by an import job. Only admins and the
+// creator of the job may roll it back. Devices are only deleted while they
+// have no readings from other sources.
+func (s *Service) Rollback(ctx context.Context, jobID, userID uint) (*dto.ImportJobResponse, error) {
+	job, err := s.store.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.CreatedBy != userID {
+		admin, err := s.store.IsAdmin(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !admin {
+			return nil, ErrJobAccess
+		}
+	}
+	switch job.Status {
+	case model.ImportJobRolledBack:
+		return nil, fmt.Errorf("%w: job %d is already rolled back", ErrRollback, job.ID)
+	case model.ImportJobRunning:
+		return nil, fmt.Errorf("%w: job %d is still running", ErrRollback, job.ID)
+	}
+	if job.DataType == dto.DataTypeDevices {
+		n, err := s.store.CountForeignReadings(ctx, job.ID)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			return nil, fmt.Errorf("%w: the imported devices have %d readings from other sources", ErrRollback, n)
+		}
+	}
+
+	now := time.Now()
+	job.Status = model.ImportJobRolledBack
+	job.RolledBackBy = &userID
+	job.RolledBackAt = &now
+	_, err = s.store.DeleteJobRows(ctx, job)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Another rollback got there first
+		return nil, fmt.Errorf("%w: job %d is already rolled back", ErrRollback, job.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp := toJobResponse(job)
+	return &resp, nil
+}
+
+// GetJob returns an import job.
+func (s *Service) GetJob(ctx context.Context, id uint) (*dto.ImportJobResponse, error) {
+	job, err := s.store.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	resp := toJobResponse(job)
+	return &resp, nil
+}
+
+// ListJobs returns import jobs, newest first, without their row errors.
+func (s *Service) ListJobs(ctx context.Context, limit, offset int) ([]dto.ImportJobResponse, int64, error) {
+	jobs, total, err := s.store.ListJobs(ctx, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	responses := make([]dto.ImportJobResponse, len(jobs))
+	for i := range jobs {
+		responses[i] = toJobResponse(&jobs[i])
+		responses[i].Errors = nil
+	}
+	return responses, total, nil
+}
+
+func toJobResponse(job *model.ImportJob) dto.ImportJobResponse {
+	resp := dto.ImportJobResponse{
+		ID:           job.ID,
+		DataType:     job.DataType,
+		Format:       job.Format,
+		FileName:     job.FileName,
+		Status:       job.Status,
+		Error:        job.Error,
+		TotalRows:    job.TotalRows,
+		InvalidRows:  job.InvalidRows,
+		ImportedRows: job.ImportedRows,
+		CreatedBy:    job.CreatedBy,
+		CreatedAt:    job.CreatedAt,
+		FinishedAt:   job.FinishedAt,
+		RolledBackBy: job.RolledBackBy,
+		RolledBackAt: job.RolledBackAt,
+	}
+	_ = json.Unmarshal([]byte(job.Mapping), &resp.Mapping)
+	_ = json.Unmarshal([]byte(job.Errors), &resp.Errors)
+	return resp
+}
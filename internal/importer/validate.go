@@ -0,0 +1,368 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/importer/dto"
+	"github.com/aruncs31s/skvms/internal/metrics"
+	"github.com/aruncs31s/skvms/internal/model"
+)
+
+// validator checks rows against the devices, device types and locations in
+// the database, which it loads once per import.
+type validator struct {
+	deviceIDs     map[uint]bool
+	deviceOwners  map[uint]uint
+	deviceNames   map[string][]uint
+	deviceTypes   map[uint]string
+	types         map[uint]bool
+	typeNames     map[string]uint
+	locations     map[uint]bool
+	locationCodes map[string]uint
+	locationNames map[string][]uint
+	// owner restricts readings to the devices of this user; 0 allows every
+	// device
+	owner uint
+
+	invalid int
+	errors  []dto.RowError
+}
+
+func (s *Service) newValidator(ctx context.Context) (*validator, error) {
+	devices, err := s.store.ListDeviceRefs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load devices: %w", err)
+	}
+	types, err := s.store.ListDeviceTypes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load device types: %w", err)
+	}
+	locations, err := s.store.ListLocations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load locations: %w", err)
+	}
+
+	v := &validator{
+		deviceIDs:     make(map[uint]bool, len(devices)),
+		deviceOwners:  make(map[uint]uint, len(devices)),
+		deviceNames:   make(map[string][]uint, len(devices)),
+		deviceTypes:   make(map[uint]string, len(devices)),
+		types:         make(map[uint]bool, len(types)),
+		typeNames:     make(map[string]uint, len(types)),
+		locations:     make(map[uint]bool, len(locations)),
+		locationCodes: make(map[string]uint, len(locations)),
+		locationNames: make(map[string][]uint, len(locations)),
+	}
+	typeName := make(map[uint]string, len(types))
+	for _, t := range types {
+		v.types[t.ID] = true
+		v.typeNames[strings.ToLower(t.Name)] = t.ID
+		typeName[t.ID] = t.Name
+	}
+	for _, d := range devices {
+		v.deviceIDs[d.ID] = true
+		v.deviceOwners[d.ID] = d.CreatedBy
+		name := strings.ToLower(d.Name)
+		v.deviceNames[name] = append(v.deviceNames[name], d.ID)
+		v.deviceTypes[d.ID] = typeName[d.DeviceTypeID]
+	}
+	for _, l := range locations {
+		v.locations[l.ID] = true
+		v.locationCodes[l.Code] = l.ID
+		name := strings.ToLower(l.Name)
+		v.locationNames[name] = append(v.locationNames[name], l.ID)
+	}
+	return v, nil
+}
+
+// fail records the first error of an invalid row.
+func (v *validator) fail(row Row, field, value, format string, args ...interface{}) {
+	v.invalid++
+	if len(v.errors) < maxRowErrors {
+		v.errors = append(v.errors, dto.RowError{
+			Row:     row.Number,
+			Field:   field,
+			Value:   value,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+}
+
+// readings validates reading rows.
+func (v *validator) readings(rows []Row, columns columnMap) *readingBatch {
+	batch := &readingBatch{deviceTypes: v.deviceTypes}
+	now := time.Now()
+	for _, row := range rows {
+		var deviceID uint
+		if raw := columns.value(row, fieldDeviceID); raw != "" {
+			id, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil || !v.deviceIDs[uint(id)] {
+				v.fail(row, fieldDeviceID, raw, "device not found")
+				continue
+			}
+			if !v.owns(uint(id)) {
+				v.fail(row, fieldDeviceID, raw, "no access to this device")
+				continue
+			}
+			deviceID = uint(id)
+		} else if name := columns.value(row, fieldDeviceName); name != "" {
+			ids := v.deviceNames[strings.ToLower(name)]
+			if len(ids) == 0 {
+				v.fail(row, fieldDeviceName, name, "device not found")
+				continue
+			}
+			if len(ids) > 1 {
+				v.fail(row, fieldDeviceName, name, "%d devices have this name; use device_id", len(ids))
+				continue
+			}
+			if !v.owns(ids[0]) {
+				v.fail(row, fieldDeviceName, name, "no access to this device")
+				continue
+			}
+			deviceID = ids[0]
+		} else {
+			v.fail(row, fieldDeviceID, "", "device_id or device_name is required")
+			continue
+		}
+
+		voltage, ok := v.number(row, columns, fieldVoltage)
+		if !ok {
+			continue
+		}
+		current, ok := v.number(row, columns, fieldCurrent)
+		if !ok {
+			continue
+		}
+
+		raw := columns.value(row, fieldCreatedAt)
+		if raw == "" {
+			v.fail(row, fieldCreatedAt, "", "created_at is required")
+			continue
+		}
+		createdAt, err := parseTime(raw)
+		if err != nil {
+			v.fail(row, fieldCreatedAt, raw, "%s", err)
+			continue
+		}
+		if createdAt.After(now) {
+			v.fail(row, fieldCreatedAt, raw, "time is in the future")
+			continue
+		}
+
+		batch.rows = append(batch.rows, model.Reading{
+			DeviceID:  deviceID,
+			Voltage:   voltage,
+			Current:   current,
+			CreatedAt: createdAt,
+		})
+	}
+	return batch
+}
+
+// owns reports whether readings may be imported into a device.
+func (v *validator) owns(deviceID uint) bool {
+	return v.owner == 0 || v.deviceOwners[deviceID] == v.owner
+}
+
+// number parses a required numeric field of a row.
+func (v *validator) number(row Row, columns columnMap, field string) (float64, bool) {
+	raw := columns.value(row, field)
+	if raw == "" {
+		v.fail(row, field, "", "%s is required", field)
+		return 0, false
+	}
+	f, err := parseNumber(raw)
+	if err != nil {
+		v.fail(row, field, raw, "%s", err)
+		return 0, false
+	}
+	return f, true
+}
+
+// devices validates device rows. Names must be new, and unique in the file.
+func (v *validator) devices(rows []Row, columns columnMap, userID uint) *deviceBatch {
+	batch := &deviceBatch{}
+	seen := make(map[string]int)
+	for _, row := range rows {
+		name := columns.value(row, fieldName)
+		if name == "" {
+			v.fail(row, fieldName, "", "name is required")
+			continue
+		}
+		key := strings.ToLower(name)
+		if len(v.deviceNames[key]) > 0 {
+			v.fail(row, fieldName, name, "device already exists")
+			continue
+		}
+		if first, ok := seen[key]; ok {
+			v.fail(row, fieldName, name, "duplicate of row %d", first)
+			continue
+		}
+
+		raw := columns.value(row, fieldType)
+		typeID, ok := v.deviceType(raw)
+		if !ok {
+			v.fail(row, fieldType, raw, "device type not found")
+			continue
+		}
+
+		ip := columns.value(row, fieldIPAddress)
+		if ip != "" && net.ParseIP(ip) == nil {
+			v.fail(row, fieldIPAddress, ip, "invalid IP address")
+			continue
+		}
+		mac := columns.value(row, fieldMACAddress)
+		if mac != "" {
+			if _, err := net.ParseMAC(mac); err != nil {
+				v.fail(row, fieldMACAddress, mac, "invalid MAC address")
+				continue
+			}
+		}
+
+		var locationID uint
+		if raw := columns.value(row, fieldLocation); raw != "" {
+			id, msg := v.location(raw)
+			if msg != "" {
+				v.fail(row, fieldLocation, raw, "%s", msg)
+				continue
+			}
+			locationID = id
+		}
+
+		seen[key] = row.Number
+		batch.rows = append(batch.rows, model.Device{
+			Name:         name,
+			DeviceTypeID: typeID,
+			CurrentState: 1, // Active, as for devices created through the API
+			CreatedBy:    userID,
+			UpdatedBy:    userID,
+			Details: model.DeviceDetails{
+				IPAddress:  ip,
+				MACAddress: mac,
+			},
+			Assignment: model.DeviceAssignment{
+				LocationID: locationID,
+			},
+		})
+	}
+	return batch
+}
+
+// deviceType resolves a device type by ID or name.
+func (v *validator) deviceType(raw string) (uint, bool) {
+	if raw == "" {
+		return 0, false
+	}
+	if id, err := strconv.ParseUint(raw, 10, 64); err == nil {
+		return uint(id), v.types[uint(id)]
+	}
+	id, ok := v.typeNames[strings.ToLower(raw)]
+	return id, ok
+}
+
+// location resolves a location by ID, code or name, returning a message
+// when it cannot.
+func (v *validator) location(raw string) (uint, string) {
+	if id, err := strconv.ParseUint(raw, 10, 64); err == nil && v.locations[uint(id)] {
+		return uint(id), ""
+	}
+	if id, ok := v.locationCodes[raw]; ok {
+		return id, ""
+	}
+	ids := v.locationNames[strings.ToLower(raw)]
+	switch len(ids) {
+	case 0:
+		return 0, "location not found"
+	case 1:
+		return ids[0], ""
+	}
+	return 0, fmt.Sprintf("%d locations have this name; use the code", len(ids))
+}
+
+// rowBatch holds the validated rows of an import.
+type rowBatch interface {
+	len() int
+	// preview returns up to n rows as they would be imported.
+	preview(n int) []map[string]interface{}
+	// insert inserts rows [start, end) under the job.
+	insert(ctx context.Context, store Store, jobID uint, start, end int) error
+}
+
+type readingBatch struct {
+	rows []model.Reading
+	// deviceTypes maps device IDs to type names for metrics
+	deviceTypes map[uint]string
+}
+
+func (b *readingBatch) len() int {
+	return len(b.rows)
+}
+
+func (b *readingBatch) preview(n int) []map[string]interface{} {
+	preview := make([]map[string]interface{}, 0, min(n, len(b.rows)))
+	for _, r := range b.rows[:min(n, len(b.rows))] {
+		preview = append(preview, map[string]interface{}{
+			fieldDeviceID:  r.DeviceID,
+			fieldVoltage:   r.Voltage,
+			fieldCurrent:   r.Current,
+			fieldCreatedAt: r.CreatedAt,
+		})
+	}
+	return preview
+}
+
+func (b *readingBatch) insert(ctx context.Context, store Store, jobID uint, start, end int) error {
+	rows := b.rows[start:end]
+	for i := range rows {
+		rows[i].ImportJobID = &jobID
+	}
+	if err := store.InsertReadings(ctx, rows); err != nil {
+		return err
+	}
+	counts := make(map[string]int)
+	for _, r := range rows {
+		counts[b.deviceTypes[r.DeviceID]]++
+	}
+	for deviceType, n := range counts {
+		metrics.AddReadings(deviceType, "import", n)
+	}
+	return nil
+}
+
+type deviceBatch struct {
+	rows []model.Device
+}
+
+func (b *deviceBatch) len() int {
+	return len(b.rows)
+}
+
+func (b *deviceBatch) preview(n int) []map[string]interface{} {
+	preview := make([]map[string]interface{}, 0, min(n, len(b.rows)))
+	for _, d := range b.rows[:min(n, len(b.rows))] {
+		row := map[string]interface{}{
+			fieldName:       d.Name,
+			"type_id":       d.DeviceTypeID,
+			fieldIPAddress:  d.Details.IPAddress,
+			fieldMACAddress: d.Details.MACAddress,
+		}
+		if d.Assignment.LocationID != 0 {
+			row["location_id"] = d.Assignment.LocationID
+		}
+		preview = append(preview, row)
+	}
+	return preview
+}
+
+func (b *deviceBatch) insert(ctx context.Context, store Store, jobID uint, start, end int) error {
+	rows := b.rows[start:end]
+	for i := range rows {
+		rows[i].ImportJobID = &jobID
+	}
+	return store.InsertDevices(ctx, rows)
+}
@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aruncs31s/skvms/internal/importer/dto"
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxParser reads one worksheet of an Excel workbook. The first non-blank
+// row holds the headers.
+type xlsxParser struct{}
+
+func newXLSXParser() Parser {
+	return &xlsxParser{}
+}
+
+func (p *xlsxParser) Format() dto.ImportFormat {
+	return dto.FormatXLSX
+}
+
+func (p *xlsxParser) Parse(ctx context.Context, r io.Reader, opts ParseOptions) (*Table, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: xlsx: %w", ErrInvalidFile, err)
+	}
+	defer f.Close()
+
+	sheet := opts.Sheet
+	if sheet == "" {
+		sheet = f.GetSheetName(0)
+	}
+	if idx, err := f.GetSheetIndex(sheet); err != nil || idx < 0 {
+		return nil, fmt.Errorf("%w: xlsx: sheet %q not found", ErrInvalidFile, sheet)
+	}
+
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("%w: xlsx: %w", ErrInvalidFile, err)
+	}
+	defer rows.Close()
+
+	table := &Table{}
+	number := 0
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		number++
+		cells, err := rows.Columns()
+		if err != nil {
+			return nil, fmt.Errorf("%w: xlsx: row %d: %w", ErrInvalidFile, number, err)
+		}
+		if blank(cells) {
+			continue
+		}
+		if table.Headers == nil {
+			table.Headers = cells
+			continue
+		}
+		if len(table.Rows) == maxRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidFile, maxRows)
+		}
+		table.Rows = append(table.Rows, Row{Number: number, Cells: cells})
+	}
+	if err := rows.Error(); err != nil {
+		return nil, fmt.Errorf("%w: xlsx: %w", ErrInvalidFile, err)
+	}
+	if table.Headers == nil {
+		return nil, fmt.Errorf("%w: xlsx: sheet %q is empty", ErrInvalidFile, sheet)
+	}
+	return table, nil
+}
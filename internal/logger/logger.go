@@ -1,9 +1,11 @@
 package logger
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -11,6 +13,9 @@ import (
 
 var Log *zap.Logger
 
+// level is shared by the cores of Log so it can be changed at runtime.
+var level = zap.NewAtomicLevel()
+
 // Init initializes the global logger with both console and file output
 func Init(logDir string, logLevel string) error {
 	// Create logs directory if it doesn't exist
@@ -19,15 +24,7 @@ func Init(logDir string, logLevel string) error {
 	}
 
 	// Configure log level
-	level := zapcore.InfoLevel
-	switch logLevel {
-	case "debug":
-		level = zapcore.DebugLevel
-	case "warn":
-		level = zapcore.WarnLevel
-	case "error":
-		level = zapcore.ErrorLevel
-	}
+	level.SetLevel(parseLevel(logLevel))
 
 	// Configure encoder
 	encoderConfig := zap.NewProductionEncoderConfig()
@@ -67,6 +64,23 @@ func Init(logDir string, logLevel string) error {
 	return nil
 }
 
+// SetLevel changes the level of Log, e.g. on a configuration reload.
+func SetLevel(logLevel string) {
+	level.SetLevel(parseLevel(logLevel))
+}
+
+func parseLevel(logLevel string) zapcore.Level {
+	switch logLevel {
+	case "debug":
+		return zapcore.DebugLevel
+	case "warn":
+		return zapcore.WarnLevel
+	case "error":
+		return zapcore.ErrorLevel
+	}
+	return zapcore.InfoLevel
+}
+
 // Sync flushes any buffered log entries
 func Sync() {
 	if Log != nil {
@@ -102,3 +116,16 @@ func Fatal(msg string, fields ...zap.Field) {
 func GetLogger() *zap.Logger {
 	return Log
 }
+
+// FromContext returns the logger with the trace and span IDs of the span in
+// ctx, so log lines can be matched to traces.
+func FromContext(ctx context.Context) *zap.Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return Log
+	}
+	return Log.With(
+		zap.String("trace_id", sc.TraceID().String()),
+		zap.String("span_id", sc.SpanID().String()),
+	)
+}
@@ -0,0 +1,163 @@
+// Package mailer sends email over SMTP. Any SMTP server works; for local
+// testing a catcher such as MailHog or Mailpit on localhost:1025 is enough.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ErrNotConfigured is returned by a Mailer without an SMTP host.
+var ErrNotConfigured = errors.New("smtp is not configured")
+
+// Attachment is a file attached to a Message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is a plain-text email with optional attachments.
+type Message struct {
+	To          []string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config holds the SMTP settings. Username and Password are optional; without
+// them no authentication is attempted, as local catchers expect.
+type Config struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPMailer sends messages through an SMTP server.
+type SMTPMailer struct {
+	cfg Config
+}
+
+// NewSMTPMailer creates a mailer for the given SMTP settings.
+func NewSMTPMailer(cfg Config) *SMTPMailer {
+	if cfg.Port == "" {
+		cfg.Port = "25"
+	}
+	return &SMTPMailer{cfg: cfg}
+}
+
+// Configured reports whether an SMTP host is set.
+func (m *SMTPMailer) Configured() bool {
+	return m.cfg.Host != ""
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if !m.Configured() {
+		return ErrNotConfigured
+	}
+	if len(msg.To) == 0 {
+		return errors.New("message has no recipients")
+	}
+
+	body, err := m.build(msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, m.cfg.Port)
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	// net/smtp has no context support, run it aside and give up on cancel
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, m.cfg.From, msg.To, body)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("smtp delivery to %s failed: %w", addr, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// build renders the message as a MIME multipart/mixed email.
+func (m *SMTPMailer) build(msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	headers := []string{
+		"From: " + m.cfg.From,
+		"To: " + strings.Join(msg.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=" + writer.Boundary(),
+	}
+	header := strings.Join(headers, "\r\n") + "\r\n\r\n"
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write([]byte(msg.Body)); err != nil {
+		return nil, err
+	}
+
+	for _, a := range msg.Attachments {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64Lines(part, a.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return append([]byte(header), buf.Bytes()...), nil
+}
+
+// writeBase64Lines writes data base64 encoded in 76 character lines.
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := w.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := w.Write([]byte(encoded + "\r\n"))
+	return err
+}
@@ -0,0 +1,203 @@
+// Package metrics holds the Prometheus metrics of SKVMS and the registry
+// they are served from at /metrics.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "skvms"
+
+// Results used as metric labels.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	// ResultCached is a codegen build served from a cached firmware image
+	ResultCached = "cached"
+)
+
+// Registry holds every SKVMS metric along with the Go runtime and process
+// collectors.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	httpRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	httpInFlight = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "HTTP requests being served.",
+	})
+
+	readingsIngested = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ingest",
+		Name:      "readings_total",
+		Help:      "Readings stored by device type and source (api or import).",
+	}, []string{"device_type", "source"})
+
+	codegenBuildDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "codegen",
+		Name:      "build_duration_seconds",
+		Help:      "Firmware build time by board and result (success, failure or cached).",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200},
+	}, []string{"board", "result"})
+
+	exportDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "export",
+		Name:      "render_duration_seconds",
+		Help:      "Export render time by format, data and result.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"format", "data", "result"})
+
+	jobRuns = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "runs_total",
+		Help:      "Background job runs by job and result.",
+	}, []string{"job", "result"})
+
+	jobDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "run_duration_seconds",
+		Help:      "Background job run time by job.",
+		Buckets:   []float64{0.01, 0.1, 0.5, 1, 5, 15, 60, 300, 900},
+	}, []string{"job"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics of Registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// HTTPRequestStarted counts a request in flight; call the returned function
+// with the outcome once it has been served.
+func HTTPRequestStarted() func(method, route string, status int, elapsed time.Duration) {
+	httpInFlight.Inc()
+	return func(method, route string, status int, elapsed time.Duration) {
+		httpInFlight.Dec()
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+	}
+}
+
+// AddReadings counts n stored readings of a device type.
+func AddReadings(deviceType, source string, n int) {
+	readingsIngested.WithLabelValues(deviceType, source).Add(float64(n))
+}
+
+// ObserveCodegenBuild records the duration of a firmware build.
+func ObserveCodegenBuild(board, result string, elapsed time.Duration) {
+	codegenBuildDuration.WithLabelValues(board, result).Observe(elapsed.Seconds())
+}
+
+// ObserveExport records the duration of an export.
+func ObserveExport(format, data string, err error, elapsed time.Duration) {
+	exportDuration.WithLabelValues(format, data, Result(err)).Observe(elapsed.Seconds())
+}
+
+// ObserveJob records a run of a background job.
+func ObserveJob(job string, err error, elapsed time.Duration) {
+	jobRuns.WithLabelValues(job, Result(err)).Inc()
+	jobDuration.WithLabelValues(job).Observe(elapsed.Seconds())
+}
+
+// Result returns the result label of an operation that returned err.
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// RegisterDB exposes the connection pool statistics of db.
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterGauge exposes the value returned by fn as a gauge, read on every
+// scrape.
+func RegisterGauge(subsystem, name, help string, fn func() float64) {
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      name,
+		Help:      help,
+	}, fn)
+}
+
+// deviceStatusTimeout bounds the query run on each scrape.
+const deviceStatusTimeout = 5 * time.Second
+
+// DeviceStatusFunc returns the number of online and offline devices.
+type DeviceStatusFunc func(ctx context.Context) (online, offline int64, err error)
+
+// RegisterDeviceStatus exposes the online and offline device counts
+// returned by fn, queried on every scrape.
+func RegisterDeviceStatus(fn DeviceStatusFunc) {
+	Registry.MustRegister(&deviceStatusCollector{
+		count: fn,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "devices", "status"),
+			"Devices by connection status (online or offline).",
+			[]string{"status"}, nil,
+		),
+	})
+}
+
+type deviceStatusCollector struct {
+	count DeviceStatusFunc
+	desc  *prometheus.Desc
+}
+
+func (c *deviceStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *deviceStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), deviceStatusTimeout)
+	defer cancel()
+
+	online, offline, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(online), "online")
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(offline), "offline")
+}
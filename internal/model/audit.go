@@ -1,18 +1,54 @@
 package model
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
 
 type DeviceAction uint8
 
+// Audit entry outcomes.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
 type AuditLog struct {
 	ID        uint         `gorm:"column:id;primaryKey;autoIncrement"`
 	UserID    uint         `gorm:"column:user_id;index" `
 	Username  string       `gorm:"column:username"`
 	Action    string       `gorm:"column:action;index"`
 	Details   string       `gorm:"column:details"`
-	IPAddress string       `gorm:"column:ip_address"`
+	IPAddress string       `gorm:"column:ip_address;index"`
 	DeviceID  *uint        `gorm:"column:device_id;index"`
-	CreatedAt time.Time    `gorm:"column:created_at;autoCreateTime"`
+
+	// ResourceType and ResourceID name what the action was applied to,
+	// e.g. "device" and "5"
+	ResourceType string `gorm:"column:resource_type;type:varchar(50);index:idx_audit_resource"`
+	ResourceID   string `gorm:"column:resource_id;type:varchar(64);index:idx_audit_resource"`
+	// Changes is the JSON object of changed fields, each {"before", "after"}
+	Changes   string `gorm:"column:changes;type:text"`
+	RequestID string `gorm:"column:request_id;type:varchar(64);index"`
+	// Status is AuditSuccess or AuditFailure; StatusCode is the HTTP status
+	// of the request when the entry was written by the audit middleware
+	Status     string `gorm:"column:status;type:varchar(20);index;default:success"`
+	StatusCode int    `gorm:"column:status_code"`
+	Method     string `gorm:"column:method;type:varchar(10)"`
+	Path       string `gorm:"column:path;type:varchar(255)"`
+
+	// EventID identifies the entry from the moment it is recorded, so an
+	// entry delivered twice from the outbox is stored once
+	EventID string `gorm:"column:event_id;type:varchar(32);index"`
+	// Seq numbers entries of the hash chain without gaps, from 1. Hash is
+	// the SHA-256 of the entry including PrevHash, the hash of entry Seq-1.
+	// Entries written before the chain existed have no Seq.
+	Seq      *uint64 `gorm:"column:seq;uniqueIndex"`
+	PrevHash string  `gorm:"column:prev_hash;type:varchar(64)"`
+	Hash     string  `gorm:"column:hash;type:varchar(64)"`
+
+	CreatedAt time.Time    `gorm:"column:created_at;autoCreateTime;index"`
 	Device    *Device      `gorm:"foreignKey:DeviceID"`
 	User      *User        `gorm:"foreignKey:UserID"`
 }
@@ -20,3 +56,45 @@ type AuditLog struct {
 func (AuditLog) TableName() string {
 	return "audit_logs"
 }
+
+// ChainHash computes the hash of the entry: SHA-256 over a fixed JSON
+// encoding of Seq, PrevHash and every recorded field. CreatedAt is hashed
+// in whole seconds, the precision it is stored with.
+func (l *AuditLog) ChainHash() string {
+	var seq uint64
+	if l.Seq != nil {
+		seq = *l.Seq
+	}
+	var deviceID uint
+	if l.DeviceID != nil {
+		deviceID = *l.DeviceID
+	}
+	data, _ := json.Marshal([]interface{}{
+		seq, l.PrevHash, l.EventID,
+		l.UserID, l.Username, l.Action,
+		l.ResourceType, l.ResourceID, l.Details, l.Changes,
+		l.IPAddress, deviceID, l.RequestID,
+		l.Status, l.StatusCode, l.Method, l.Path,
+		l.CreatedAt.Unix(),
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditCheckpoint is a signed statement of the hash of the audit chain at
+// Seq. Checkpoints are also appended to a file outside the database, so
+// rewriting the chain and its checkpoints together is detected.
+type AuditCheckpoint struct {
+	ID   uint   `gorm:"column:id;primaryKey;autoIncrement"`
+	Seq  uint64 `gorm:"column:seq;index"`
+	Hash string `gorm:"column:hash;type:varchar(64)"`
+	// KeyID is the hex of the first 8 bytes of the SHA-256 of the public key
+	KeyID string `gorm:"column:key_id;type:varchar(16)"`
+	// Signature is the base64 ed25519 signature of the checkpoint message
+	Signature string    `gorm:"column:signature;type:varchar(128)"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (AuditCheckpoint) TableName() string {
+	return "audit_checkpoints"
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// BoardProfile describes how firmware is built for a device type.
+// Each microcontroller device type can have one profile which the
+// codegen pipeline uses to pick the board, partition layout and
+// the libraries that must be installed before compiling.
+type BoardProfile struct {
+	ID           uint `gorm:"column:id;primaryKey;autoIncrement"`
+	DeviceTypeID uint `gorm:"column:device_type_id;uniqueIndex;not null"`
+
+	// Fully Qualified Board Name used by arduino-cli (e.g. "esp32:esp32:esp32")
+	FQBN string `gorm:"column:fqbn;type:varchar(255);not null"`
+	// PlatformIO environment from platformio.ini (e.g. "esp32dev")
+	PlatformIOEnv   string `gorm:"column:platformio_env;type:varchar(100)"`
+	PartitionScheme string `gorm:"column:partition_scheme;type:varchar(100)"`
+	FlashSize       string `gorm:"column:flash_size;type:varchar(20)"`
+
+	// Comma separated list of library names, e.g. "ArduinoJson,PubSubClient"
+	Libraries string `gorm:"column:libraries;type:text"`
+
+	CreatedBy uint `gorm:"column:created_by"`
+	UpdatedBy uint `gorm:"column:updated_by"`
+
+	CreatedAt  time.Time   `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt  time.Time   `gorm:"column:updated_at;autoUpdateTime"`
+	DeviceType DeviceTypes `gorm:"foreignKey:DeviceTypeID;references:ID"`
+}
+
+func (BoardProfile) TableName() string {
+	return "board_profiles"
+}
+
+// LibraryList returns the libraries as a slice, skipping empty entries.
+func (b BoardProfile) LibraryList() []string {
+	var libs []string
+	for _, lib := range strings.Split(b.Libraries, ",") {
+		if lib = strings.TrimSpace(lib); lib != "" {
+			libs = append(libs, lib)
+		}
+	}
+	return libs
+}
@@ -81,6 +81,9 @@ type Device struct {
 	CreatedBy        uint              `gorm:"column:created_by"`
 	UpdatedBy        uint              `gorm:"column:updated_by"`
 
+	// ImportJobID is set on devices created by an import job
+	ImportJobID *uint `gorm:"column:import_job_id;index"`
+
 	CreatedAt time.Time      `gorm:"column:created_at;autoCreateTime"`
 	UpdatedAt time.Time      `gorm:"column:updated_at;autoUpdateTime"`
 	User      User           `gorm:"foreignKey:CreatedBy;references:ID;constraint:-"`
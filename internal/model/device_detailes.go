@@ -19,8 +19,9 @@ type MicrocontrollerDeviceView struct {
 	Type            string `gorm:"column:type"`
 	IPAddress       string `gorm:"column:ip_address"`
 	MACAddress      string `gorm:"column:mac_address"`
-	FirmwareVersion string `gorm:"column:firmware_version"`
-	DeviceState     string `gorm:"column:current_state"` // Status
+	FirmwareVersion string `gorm:"column:firmware_version"` // Assigned version
+	ReportedVersion string `gorm:"column:reported_version"` // Version the device last reported running
+	DeviceState     string `gorm:"column:current_state"`    // Status
 	UsedBy          string `gorm:"column:used_by"`
 }
 type MicrocontrollerStatsView struct {
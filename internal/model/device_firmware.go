@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+const (
+	FirmwareEventBoot      = "boot"
+	FirmwareEventHeartbeat = "heartbeat"
+)
+
+// DeviceFirmware is the firmware a device last reported running. Devices
+// report on boot and with their heartbeat; one row per device is kept.
+// It can differ from Device.VersionID, which is the version an operator
+// assigned to the device.
+type DeviceFirmware struct {
+	ID       uint `gorm:"column:id;primaryKey;autoIncrement"`
+	DeviceID uint `gorm:"column:device_id;uniqueIndex;not null"`
+
+	// Version name the firmware was built as (e.g. "1.4.2")
+	Version string `gorm:"column:version;type:varchar(100);not null"`
+	// Codegen build ID of the image, empty for firmware built elsewhere
+	BuildID string `gorm:"column:build_id;type:varchar(100)"`
+	// SHA-256 of the running application image, hex encoded
+	Checksum string `gorm:"column:checksum;type:varchar(64)"`
+
+	// LastEvent is FirmwareEventBoot or FirmwareEventHeartbeat
+	LastEvent  string     `gorm:"column:last_event;type:varchar(20)"`
+	BootedAt   *time.Time `gorm:"column:booted_at"`
+	ReportedAt time.Time  `gorm:"column:reported_at;index"`
+
+	Device Device `gorm:"foreignKey:DeviceID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (DeviceFirmware) TableName() string {
+	return "device_firmware"
+}
+
+// DeviceFirmwareView joins a microcontroller with its assigned and reported
+// firmware for drift detection.
+type DeviceFirmwareView struct {
+	DeviceID        uint       `gorm:"column:device_id"`
+	Name            string     `gorm:"column:name"`
+	Type            string     `gorm:"column:type"`
+	AssignedVersion string     `gorm:"column:assigned_version"`
+	ReportedVersion string     `gorm:"column:reported_version"`
+	BuildID         string     `gorm:"column:build_id"`
+	Checksum        string     `gorm:"column:checksum"`
+	ReportedAt      *time.Time `gorm:"column:reported_at"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// ExportTemplate is a PDF template in the template library. Its source is
+// kept in versions; exports render the latest one.
+type ExportTemplate struct {
+	ID          uint   `gorm:"column:id;primaryKey;autoIncrement"`
+	Name        string `gorm:"column:name;type:varchar(255);not null"`
+	Description string `gorm:"column:description;type:text"`
+
+	// DataType is the data the template renders: readings, devices or summary
+	DataType string `gorm:"column:data_type;type:varchar(50);not null;index"`
+	// LatestVersion is the number of the newest version
+	LatestVersion int `gorm:"column:latest_version;not null"`
+	// IsDefault marks the template used for its data type when an export
+	// does not name one. At most one template per data type is the default.
+	IsDefault bool `gorm:"column:is_default;not null;default:false"`
+
+	CreatedBy uint      `gorm:"column:created_by"`
+	UpdatedBy uint      `gorm:"column:updated_by"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (ExportTemplate) TableName() string {
+	return "export_templates"
+}
+
+// ExportTemplateVersion is one uploaded revision of a template's source.
+type ExportTemplateVersion struct {
+	ID         uint   `gorm:"column:id;primaryKey;autoIncrement"`
+	TemplateID uint   `gorm:"column:template_id;not null;uniqueIndex:idx_export_template_version"`
+	Version    int    `gorm:"column:version;not null;uniqueIndex:idx_export_template_version"`
+	Content    string `gorm:"column:content;size:16777215;not null"`
+	Comment    string `gorm:"column:comment;type:varchar(255)"`
+
+	CreatedBy uint      `gorm:"column:created_by"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+
+	Template ExportTemplate `gorm:"foreignKey:TemplateID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (ExportTemplateVersion) TableName() string {
+	return "export_template_versions"
+}
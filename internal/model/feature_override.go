@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// Scopes a feature flag can be overridden for. A device override wins over
+// the override of the device's location, which wins over the flag's default
+// from the device's version chain.
+const (
+	OverrideScopeDevice   = "device"
+	OverrideScopeLocation = "location"
+)
+
+// FeatureOverride forces a feature on or off for one device or location.
+type FeatureOverride struct {
+	ID        uint   `gorm:"column:id;primaryKey;autoIncrement"`
+	FeatureID uint   `gorm:"column:feature_id;not null;uniqueIndex:idx_feature_override_scope"`
+	Scope     string `gorm:"column:scope;type:varchar(20);not null;uniqueIndex:idx_feature_override_scope"`
+	// ScopeID is the device or location ID, depending on Scope
+	ScopeID uint `gorm:"column:scope_id;not null;uniqueIndex:idx_feature_override_scope"`
+	Enabled bool `gorm:"column:enabled;not null"`
+
+	CreatedBy uint      `gorm:"column:created_by"`
+	UpdatedBy uint      `gorm:"column:updated_by"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+
+	Feature Feature `gorm:"foreignKey:FeatureID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (FeatureOverride) TableName() string {
+	return "feature_overrides"
+}
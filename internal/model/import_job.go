@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Import job statuses.
+const (
+	ImportJobRunning    = "running"
+	ImportJobCompleted  = "completed"
+	ImportJobFailed     = "failed"
+	ImportJobRolledBack = "rolled_back"
+)
+
+// ImportJob records one committed import of readings or devices. Rows it
+// created carry its ID, so the import can be rolled back.
+type ImportJob struct {
+	ID       uint   `gorm:"column:id;primaryKey;autoIncrement"`
+	DataType string `gorm:"column:data_type;type:varchar(50);not null"`
+	Format   string `gorm:"column:format;type:varchar(20);not null"`
+	FileName string `gorm:"column:file_name;type:varchar(255)"`
+	// Mapping is the JSON object of field to source column used
+	Mapping string `gorm:"column:mapping;type:text"`
+	Status  string `gorm:"column:status;type:varchar(20);not null;index"`
+	Error   string `gorm:"column:error;type:text"`
+
+	TotalRows    int `gorm:"column:total_rows"`
+	InvalidRows  int `gorm:"column:invalid_rows"`
+	ImportedRows int `gorm:"column:imported_rows"`
+	// Errors is the JSON list of the first row errors of skipped rows
+	Errors string `gorm:"column:errors;size:16777215"`
+
+	CreatedBy    uint       `gorm:"column:created_by"`
+	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime"`
+	FinishedAt   *time.Time `gorm:"column:finished_at"`
+	RolledBackBy *uint      `gorm:"column:rolled_back_by"`
+	RolledBackAt *time.Time `gorm:"column:rolled_back_at"`
+}
+
+func (ImportJob) TableName() string {
+	return "import_jobs"
+}
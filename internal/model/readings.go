@@ -12,12 +12,15 @@ type Reading struct {
 	// Switch to CreatedAt to use time.Time for better handling
 	CreatedAt time.Time `gorm:"column:created_at;index;autoCreateTime" json:"created_at"`
 	Device    Device    `gorm:"foreignKey:DeviceID;references:ID"`
+
+	// ImportJobID is set on readings loaded by an import job
+	ImportJobID *uint `gorm:"column:import_job_id;index" json:"-"`
 }
 
 type SevenDaysReadings struct {
 	Voltage float64   `gorm:"column:voltage"`
 	Current float64   `gorm:"column:current"`
-	Bucket  time.Time `gorm:"column:bucket"`
+	Bucket  time.Time `gorm:"column:bucket;serializer:dbtime"`
 }
 
 func (Reading) TableName() string {
@@ -31,3 +34,15 @@ type AvgCurentVoltageReading struct {
 	AvgCurrent float64   `gorm:"column:avg_current" json:"avg_current"`
 	CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
 }
+
+// DailyReadingStats summarizes a device's readings for one calendar day.
+type DailyReadingStats struct {
+	Day        time.Time `gorm:"column:day;serializer:dbtime"`
+	Count      int64     `gorm:"column:count"`
+	MinVoltage float64   `gorm:"column:min_voltage"`
+	MaxVoltage float64   `gorm:"column:max_voltage"`
+	AvgVoltage float64   `gorm:"column:avg_voltage"`
+	MinCurrent float64   `gorm:"column:min_current"`
+	MaxCurrent float64   `gorm:"column:max_current"`
+	AvgCurrent float64   `gorm:"column:avg_current"`
+}
@@ -0,0 +1,96 @@
+package model
+
+import "time"
+
+// Report periods: the span of readings a scheduled report covers, ending
+// when the report runs.
+const (
+	ReportPeriodDay   = "day"
+	ReportPeriodWeek  = "week"
+	ReportPeriodMonth = "month"
+)
+
+// Report run statuses.
+const (
+	ReportRunRunning = "running"
+	ReportRunSuccess = "success"
+	ReportRunFailed  = "failed"
+)
+
+// Report run triggers.
+const (
+	ReportTriggerSchedule = "schedule"
+	ReportTriggerManual   = "manual"
+)
+
+// Report delivery statuses of a run.
+const (
+	ReportDeliverySent    = "sent"
+	ReportDeliveryFailed  = "failed"
+	ReportDeliverySkipped = "skipped" // no recipients or SMTP is not configured
+)
+
+// ReportDefinition is a saved export that is rendered on a schedule and
+// emailed to its recipients.
+type ReportDefinition struct {
+	ID   uint   `gorm:"column:id;primaryKey;autoIncrement"`
+	Name string `gorm:"column:name;type:varchar(255);not null"`
+
+	// DataType is "readings" or "devices", as for exports
+	DataType string `gorm:"column:data_type;type:varchar(50);not null"`
+	// DeviceID filters readings; required for readings reports
+	DeviceID *uint `gorm:"column:device_id;index"`
+	// Period is the span of readings covered (ReportPeriodDay, ...)
+	Period string `gorm:"column:period;type:varchar(20)"`
+	Format string `gorm:"column:format;type:varchar(20);not null"`
+	// TemplateID selects a library template for PDF reports; without one
+	// the default template of the data type is used
+	TemplateID *uint `gorm:"column:template_id"`
+
+	// Schedule is a cron expression or one of @daily, @weekly, @monthly
+	Schedule string `gorm:"column:schedule;type:varchar(100);not null"`
+	// Recipients is a comma separated list of email addresses
+	Recipients string `gorm:"column:recipients;type:text"`
+	Enabled    bool   `gorm:"column:enabled;not null;default:true"`
+
+	NextRunAt *time.Time `gorm:"column:next_run_at;index"`
+	LastRunAt *time.Time `gorm:"column:last_run_at"`
+
+	CreatedBy uint      `gorm:"column:created_by"`
+	UpdatedBy uint      `gorm:"column:updated_by"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (ReportDefinition) TableName() string {
+	return "report_definitions"
+}
+
+// ReportRun is one rendering of a report definition and its delivery.
+type ReportRun struct {
+	ID           uint   `gorm:"column:id;primaryKey;autoIncrement"`
+	DefinitionID uint   `gorm:"column:definition_id;index;not null"`
+	Trigger      string `gorm:"column:trigger_type;type:varchar(20)"`
+	Status       string `gorm:"column:status;type:varchar(20);not null"`
+	Error        string `gorm:"column:error;type:text"`
+
+	// FilePath is where the rendered report is stored for download
+	FilePath string `gorm:"column:file_path;type:varchar(512)"`
+	FileSize int64  `gorm:"column:file_size"`
+
+	PeriodStart time.Time `gorm:"column:period_start"`
+	PeriodEnd   time.Time `gorm:"column:period_end"`
+
+	DeliveryStatus string `gorm:"column:delivery_status;type:varchar(20)"`
+	DeliveryError  string `gorm:"column:delivery_error;type:text"`
+	Recipients     string `gorm:"column:recipients;type:text"`
+
+	StartedAt  time.Time  `gorm:"column:started_at;index"`
+	FinishedAt *time.Time `gorm:"column:finished_at"`
+
+	Definition ReportDefinition `gorm:"foreignKey:DefinitionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (ReportRun) TableName() string {
+	return "report_runs"
+}
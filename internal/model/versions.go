@@ -37,6 +37,18 @@ func (Feature) TableName() string {
 type VersionFeature struct {
 	VersionID uint `json:"VersionID" gorm:"column:version_id;primaryKey;not null"`
 	FeatureID uint `json:"FeatureID" gorm:"column:feature_id;primaryKey;not null"`
+	// Enabled is the feature's state when it was added to the version, so
+	// changelogs can show toggles between releases. Null for links made
+	// before it was recorded; Feature.Enabled applies then.
+	Enabled *bool `json:"Enabled,omitempty" gorm:"column:enabled;default:null"`
+}
+
+// VersionFeatureState is a feature as shipped with one version.
+type VersionFeatureState struct {
+	VersionID   uint   `gorm:"column:version_id"`
+	FeatureID   uint   `gorm:"column:feature_id"`
+	FeatureName string `gorm:"column:feature_name"`
+	Enabled     bool   `gorm:"column:enabled"`
 }
 
 func (VersionFeature) TableName() string {
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// WiFiProfile is a stored WiFi network of a location. Firmware builds refer to
+// it by ID instead of carrying the credentials in the request.
+// The password is only ever stored encrypted (see the secrets package).
+type WiFiProfile struct {
+	ID         uint   `gorm:"column:id;primaryKey;autoIncrement"`
+	LocationID uint   `gorm:"column:location_id;index;not null"`
+	Name       string `gorm:"column:name;type:varchar(100);not null"`
+	SSID       string `gorm:"column:ssid;type:varchar(64);not null"`
+
+	// AES-GCM ciphertext of the WiFi password
+	PasswordCiphertext string `gorm:"column:password_ciphertext;type:text;not null"`
+
+	CreatedBy uint `gorm:"column:created_by"`
+	UpdatedBy uint `gorm:"column:updated_by"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+	Location  Location  `gorm:"foreignKey:LocationID;references:ID"`
+}
+
+func (WiFiProfile) TableName() string {
+	return "wifi_profiles"
+}
@@ -0,0 +1,51 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed docs.html
+var docsPage string
+
+// Handler serves the document and its docs page.
+type Handler struct {
+	spec     *Spec
+	specPath string
+
+	once sync.Once
+	json []byte
+	err  error
+}
+
+// NewHandler serves spec, whose JSON document is at specPath for the docs
+// page.
+func NewHandler(spec *Spec, specPath string) *Handler {
+	return &Handler{spec: spec, specPath: specPath}
+}
+
+// Spec handles GET /api/openapi.json
+func (h *Handler) Spec(c *gin.Context) {
+	// Routes are all added before the server starts, the document does not
+	// change afterwards
+	h.once.Do(func() {
+		h.json, h.err = json.Marshal(h.spec.Document())
+	})
+	if h.err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode the API document"})
+		return
+	}
+	c.Data(http.StatusOK, "application/json", h.json)
+}
+
+// Docs handles GET /api/docs, a page browsing the document and trying its
+// routes.
+func (h *Handler) Docs(c *gin.Context) {
+	page := strings.ReplaceAll(docsPage, "{{SPEC_URL}}", h.specPath)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
@@ -0,0 +1,319 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+)
+
+// Auth is the credential a route requires.
+type Auth int
+
+const (
+	Public Auth = iota
+	// UserAuth is the access token of a logged in user
+	UserAuth
+	// DeviceAuth is a device token issued by /api/device-auth/token
+	DeviceAuth
+	// AdminAuth is the access token of a logged in admin
+	AdminAuth
+)
+
+// Names of the security schemes of the document.
+const (
+	userScheme   = "user"
+	deviceScheme = "device"
+)
+
+// Route documents a route of the router.
+type Route struct {
+	Method string
+	// Path is the Gin path, with :name parameters
+	Path        string
+	Tag         string
+	Summary     string
+	Description string
+	Auth        Auth
+	// Params are the query parameters, and the path parameters that are
+	// not numeric IDs
+	Params []Param
+	// Body is a value of the type the handler binds the JSON body to
+	Body any
+	// Form are the fields of a multipart form body
+	Form []Param
+	// Status is the status of a successful response, 200 when zero
+	Status int
+	// Response is a value of the type of the JSON response, if known
+	Response any
+	// Produces lists the content types of a response that is not JSON,
+	// such as a file download
+	Produces []string
+}
+
+// Param is a path, query or form parameter.
+type Param struct {
+	Name        string
+	In          string
+	Type        string
+	Format      string
+	Description string
+	Required    bool
+	Enum        []string
+}
+
+// Query returns an optional query parameter of type integer, number,
+// boolean or string.
+func Query(name string, typ string, description string) Param {
+	return Param{Name: name, In: "query", Type: typ, Description: description}
+}
+
+// PathParam returns a path parameter of type typ.
+func PathParam(name string, typ string, description string) Param {
+	return Param{Name: name, In: "path", Type: typ, Description: description, Required: true}
+}
+
+// FormField returns a field of a multipart form body.
+func FormField(name string, typ string, description string) Param {
+	return Param{Name: name, In: "formData", Type: typ, Description: description}
+}
+
+// Require returns p as a required parameter.
+func (p Param) Require() Param {
+	p.Required = true
+	return p
+}
+
+// OneOf returns p restricted to values.
+func (p Param) OneOf(values ...string) Param {
+	p.Enum = values
+	return p
+}
+
+// As returns p with a string format such as date or date-time, which is
+// documented but left to the handler to parse.
+func (p Param) As(format string) Param {
+	p.Format = format
+	return p
+}
+
+func (p Param) schema() *Schema {
+	s := &Schema{Type: p.Type, Format: p.Format}
+	if p.Type == "file" {
+		s = &Schema{Type: "string", Format: "binary"}
+	}
+	for _, value := range p.Enum {
+		s.Enum = append(s.Enum, value)
+	}
+	return s
+}
+
+// Spec is the OpenAPI document of the routes added to it, and validates
+// the requests to them.
+type Spec struct {
+	doc        *Document
+	schemas    *schemaGenerator
+	operations map[string]*operation
+}
+
+// operation is a documented route with what validation needs.
+type operation struct {
+	params []*Parameter
+	// body is the schema of the JSON body, nil without one
+	body         *Schema
+	bodyRequired bool
+}
+
+// New returns an empty spec.
+func New(info Info, tags ...Tag) *Spec {
+	s := &Spec{
+		doc: &Document{
+			OpenAPI: Version,
+			Info:    info,
+			Tags:    tags,
+			Paths:   make(map[string]*PathItem),
+		},
+		schemas:    newSchemaGenerator(),
+		operations: make(map[string]*operation),
+	}
+	s.doc.Components.Schemas = s.schemas.components
+	s.doc.Components.SecuritySchemes = map[string]*SecurityScheme{
+		userScheme: {
+			Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+			Description: "Access token returned by POST /api/login",
+		},
+		deviceScheme: {
+			Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+			Description: "Device token returned by POST /api/device-auth/token",
+		},
+	}
+	return s
+}
+
+// Add documents routes.
+func (s *Spec) Add(routes ...Route) {
+	for _, route := range routes {
+		s.add(route)
+	}
+}
+
+func (s *Spec) add(route Route) {
+	op := &operation{}
+	doc := &Operation{
+		Summary:     route.Summary,
+		Description: route.Description,
+		OperationID: operationID(route.Method, route.Path),
+		Responses:   make(map[string]*Response),
+	}
+	if route.Tag != "" {
+		doc.Tags = []string{route.Tag}
+	}
+
+	// Path parameters are numeric IDs unless documented otherwise
+	segments := strings.Split(route.Path, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+		name := segment[1:]
+		segments[i] = "{" + name + "}"
+		param := PathParam(name, "integer", "")
+		for _, p := range route.Params {
+			if p.In == "path" && p.Name == name {
+				param = p
+			}
+		}
+		op.params = append(op.params, s.parameter(param))
+	}
+	for _, p := range route.Params {
+		if p.In == "query" {
+			op.params = append(op.params, s.parameter(p))
+		}
+	}
+	doc.Parameters = op.params
+
+	if route.Body != nil {
+		op.body = s.schemas.of(reflect.TypeOf(route.Body))
+		op.bodyRequired = len(s.resolve(op.body).Required) > 0
+		doc.RequestBody = &RequestBody{
+			Required: op.bodyRequired,
+			Content:  map[string]*MediaType{"application/json": {Schema: op.body}},
+		}
+	}
+	if len(route.Form) > 0 {
+		form := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for _, field := range route.Form {
+			form.Properties[field.Name] = field.schema()
+			form.Properties[field.Name].Description = field.Description
+			if field.Required {
+				form.Required = append(form.Required, field.Name)
+			}
+		}
+		doc.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"multipart/form-data": {Schema: form}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	if route.Response != nil {
+		success.Content = map[string]*MediaType{
+			"application/json": {Schema: s.schemas.of(reflect.TypeOf(route.Response))},
+		}
+	}
+	for _, contentType := range route.Produces {
+		if success.Content == nil {
+			success.Content = make(map[string]*MediaType)
+		}
+		success.Content[contentType] = &MediaType{}
+	}
+	doc.Responses[strconv.Itoa(status)] = success
+
+	errorBody := map[string]*MediaType{
+		"application/json": {Schema: s.schemas.of(reflect.TypeOf(dto.ErrorResponse{}))},
+	}
+	if len(op.params) > 0 || doc.RequestBody != nil {
+		doc.Responses["400"] = &Response{Description: "Invalid request", Content: errorBody}
+	}
+	switch route.Auth {
+	case UserAuth:
+		doc.Security = []map[string][]string{{userScheme: {}}}
+		doc.Responses["401"] = &Response{Description: "Missing or invalid access token", Content: errorBody}
+	case AdminAuth:
+		doc.Security = []map[string][]string{{userScheme: {}}}
+		doc.Responses["401"] = &Response{Description: "Missing or invalid access token", Content: errorBody}
+		doc.Responses["403"] = &Response{Description: "Admin role required", Content: errorBody}
+	case DeviceAuth:
+		doc.Security = []map[string][]string{{deviceScheme: {}}}
+		doc.Responses["401"] = &Response{Description: "Missing or invalid device token", Content: errorBody}
+	}
+
+	path := strings.Join(segments, "/")
+	item := s.doc.Paths[path]
+	if item == nil {
+		item = &PathItem{}
+		s.doc.Paths[path] = item
+	}
+	(*item)[strings.ToLower(route.Method)] = doc
+	s.operations[route.Method+" "+route.Path] = op
+}
+
+func (s *Spec) parameter(p Param) *Parameter {
+	return &Parameter{
+		Name:        p.Name,
+		In:          p.In,
+		Description: p.Description,
+		Required:    p.Required,
+		Schema:      p.schema(),
+	}
+}
+
+// resolve follows the reference of a component schema.
+func (s *Spec) resolve(schema *Schema) *Schema {
+	if name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/"); ok {
+		return s.schemas.components[name]
+	}
+	return schema
+}
+
+// Document returns the OpenAPI document.
+func (s *Spec) Document() *Document {
+	return s.doc
+}
+
+// Documented reports whether the route with method and Gin path was added.
+func (s *Spec) Documented(method string, path string) bool {
+	_, ok := s.operations[method+" "+path]
+	return ok
+}
+
+// Routes returns the documented routes as "METHOD path", sorted.
+func (s *Spec) Routes() []string {
+	routes := make([]string, 0, len(s.operations))
+	for route := range s.operations {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// operationID turns GET /api/devices/:id/readings into
+// getDevicesIdReadings.
+func operationID(method string, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/api"), "/") {
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool {
+			return r == ':' || r == '-' || r == '_'
+		}) {
+			id += exported(word)
+		}
+	}
+	return id
+}
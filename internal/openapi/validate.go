@@ -0,0 +1,243 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// Validate returns middleware rejecting requests to documented routes whose
+// path, query or JSON body do not match the spec, with a 400 and the
+// invalid fields. Requests to routes not in the spec pass unchecked.
+//
+// Unknown query parameters and body fields are allowed, as Gin's binding
+// ignores them too.
+func (s *Spec) Validate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op, ok := s.operations[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		var invalid []dto.ValidationError
+		for _, p := range op.params {
+			var values []string
+			if p.In == "path" {
+				values = []string{c.Param(p.Name)}
+			} else {
+				values = c.QueryArray(p.Name)
+			}
+			invalid = append(invalid, checkParam(p, values)...)
+		}
+		if op.body != nil {
+			bodyErrors, err := s.checkBody(c.Request, op)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, dto.ErrorResponse{Error: "failed to read request body"})
+				return
+			}
+			invalid = append(invalid, bodyErrors...)
+		}
+
+		if len(invalid) > 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error:  "invalid request",
+				Fields: invalid,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func checkParam(p *Parameter, values []string) []dto.ValidationError {
+	invalid := func(message string) []dto.ValidationError {
+		return []dto.ValidationError{{In: p.In, Field: p.Name, Message: message}}
+	}
+	if len(values) == 0 || values[0] == "" {
+		if p.Required {
+			return invalid("is required")
+		}
+		return nil
+	}
+
+	for _, value := range values {
+		var err error
+		switch p.Schema.Type {
+		case "integer":
+			_, err = strconv.ParseInt(value, 10, 64)
+		case "number":
+			_, err = strconv.ParseFloat(value, 64)
+		case "boolean":
+			_, err = strconv.ParseBool(value)
+		}
+		if err != nil {
+			return invalid("must be " + article(p.Schema.Type))
+		}
+		if len(p.Schema.Enum) > 0 && !slices.Contains(p.Schema.Enum, any(value)) {
+			return invalid("must be one of " + enumList(p.Schema.Enum))
+		}
+	}
+	return nil
+}
+
+// checkBody validates the JSON body of req and puts it back for the
+// handler.
+func (s *Spec) checkBody(req *http.Request, op *operation) ([]dto.ValidationError, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.bodyRequired {
+			return []dto.ValidationError{{In: "body", Message: "is required"}}, nil
+		}
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return []dto.ValidationError{{In: "body", Message: "is not valid JSON"}}, nil
+	}
+	return s.checkValue(op.body, value, ""), nil
+}
+
+// checkValue validates value against schema. field is the path of value
+// in the body, e.g. device_type_ids[2].
+func (s *Spec) checkValue(schema *Schema, value any, field string) []dto.ValidationError {
+	schema = s.resolve(schema)
+	invalid := func(message string) []dto.ValidationError {
+		return []dto.ValidationError{{In: "body", Field: field, Message: message}}
+	}
+	// Gin leaves the zero value for a null, so only required fields
+	// reject it, as missing
+	if value == nil {
+		return nil
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return invalid("must be an object")
+		}
+		var errs []dto.ValidationError
+		for _, name := range schema.Required {
+			if object[name] == nil {
+				errs = append(errs, dto.ValidationError{In: "body", Field: join(field, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				errs = append(errs, s.checkValue(property, object[name], join(field, name))...)
+			} else if schema.AdditionalProperties != nil {
+				errs = append(errs, s.checkValue(schema.AdditionalProperties, object[name], join(field, name))...)
+			}
+		}
+		return errs
+
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return invalid("must be an array")
+		}
+		var errs []dto.ValidationError
+		for i, item := range array {
+			errs = append(errs, s.checkValue(schema.Items, item, fmt.Sprintf("%s[%d]", field, i))...)
+		}
+		return errs
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return invalid("must be a string")
+		}
+		if schema.MinLength != nil && len(str) < *schema.MinLength {
+			return invalid("must not be empty")
+		}
+		switch schema.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return invalid("must be an RFC 3339 date and time")
+			}
+		case "byte":
+			if _, err := base64.StdEncoding.DecodeString(str); err != nil {
+				return invalid("must be base64")
+			}
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, any(str)) {
+			return invalid("must be one of " + enumList(schema.Enum))
+		}
+
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return invalid("must be " + article(schema.Type))
+		}
+		f, err := number.Float64()
+		if schema.Type == "integer" && !isInteger(number) || err != nil {
+			return invalid("must be " + article(schema.Type))
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return invalid(fmt.Sprintf("must be at least %g", *schema.Minimum))
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return invalid("must be a boolean")
+		}
+	}
+	return nil
+}
+
+func isInteger(number json.Number) bool {
+	if _, err := strconv.ParseInt(number.String(), 10, 64); err == nil {
+		return true
+	}
+	_, err := strconv.ParseUint(number.String(), 10, 64)
+	return err == nil
+}
+
+func join(field string, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func article(typ string) string {
+	if typ == "integer" {
+		return "an integer"
+	}
+	return "a " + typ
+}
+
+func enumList(values []any) string {
+	list := make([]string, len(values))
+	for i, v := range values {
+		list[i] = fmt.Sprint(v)
+	}
+	return strings.Join(list, ", ")
+}
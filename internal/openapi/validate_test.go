@@ -0,0 +1,86 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/gin-gonic/gin"
+)
+
+type point struct {
+	X int `json:"x" binding:"required"`
+}
+
+type shape struct {
+	Name   string  `json:"name" binding:"required"`
+	Points []point `json:"points"`
+	Scale  *uint   `json:"scale"`
+}
+
+func TestValidate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spec := New(Info{Title: "test", Version: "1"})
+	spec.Add(Route{
+		Method: http.MethodPost,
+		Path:   "/shapes/:id",
+		Params: []Param{Query("mode", "string", "").OneOf("fast", "slow")},
+		Body:   shape{},
+	})
+
+	engine := gin.New()
+	engine.POST("/shapes/:id", spec.Validate(), func(c *gin.Context) {
+		var s shape
+		if err := c.ShouldBindJSON(&s); err != nil {
+			t.Errorf("handler could not bind the validated body: %v", err)
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		fields []string
+	}{
+		{"valid", "/shapes/1?mode=fast", `{"name":"a","points":[{"x":1}],"scale":2}`, nil},
+		{"null optional", "/shapes/1", `{"name":"a","scale":null}`, nil},
+		{"path", "/shapes/x", `{"name":"a"}`, []string{"id"}},
+		{"enum", "/shapes/1?mode=medium", `{"name":"a"}`, []string{"mode"}},
+		{"empty required string", "/shapes/1", `{"name":""}`, []string{"name"}},
+		{"nested", "/shapes/1", `{"name":"a","points":[{"x":1},{"x":1.5},{}]}`, []string{"points[1].x", "points[2].x"}},
+		{"minimum", "/shapes/1", `{"name":"a","scale":-1}`, []string{"scale"}},
+		{"not json", "/shapes/1", `{`, []string{""}},
+		{"missing body", "/shapes/1", ``, []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+
+			if tt.fields == nil {
+				if rec.Code != http.StatusNoContent {
+					t.Fatalf("status %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body)
+				}
+				return
+			}
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			var resp dto.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var fields []string
+			for _, f := range resp.Fields {
+				fields = append(fields, f.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
@@ -2,17 +2,61 @@ package repository
 
 import (
 	"context"
+	"errors"
 
+	"github.com/aruncs31s/skvms/internal/dto"
 	"github.com/aruncs31s/skvms/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuditRepository interface {
-	Create(
+	// Append adds an entry to the end of the hash chain, setting its Seq,
+	// PrevHash and Hash. An entry whose EventID is already stored is skipped,
+	// so delivering an entry twice is harmless.
+	Append(
 		ctx context.Context,
 		log *model.AuditLog,
 	) error
-	List(ctx context.Context, action string, limit int) ([]model.AuditLog, error)
+	// ListChain returns up to limit chained entries after afterSeq, in
+	// chain order.
+	ListChain(
+		ctx context.Context,
+		afterSeq uint64,
+		limit int,
+	) ([]model.AuditLog, error)
+	// LastChained returns the entry at the end of the chain, or nil when
+	// the chain is empty.
+	LastChained(
+		ctx context.Context,
+	) (*model.AuditLog, error)
+	GetBySeq(
+		ctx context.Context,
+		seq uint64,
+	) (*model.AuditLog, error)
+	CreateCheckpoint(
+		ctx context.Context,
+		checkpoint *model.AuditCheckpoint,
+	) error
+	// LastCheckpoint returns the newest checkpoint, or nil when there is none.
+	LastCheckpoint(
+		ctx context.Context,
+	) (*model.AuditCheckpoint, error)
+	// ListCheckpoints returns checkpoints newest first.
+	ListCheckpoints(
+		ctx context.Context,
+		limit int,
+	) ([]model.AuditCheckpoint, error)
+	// Search returns up to filter.Limit entries matching filter, newest
+	// first, older than filter.Cursor when it is set.
+	Search(
+		ctx context.Context,
+		filter dto.AuditLogFilter,
+	) ([]model.AuditLog, error)
+	GetByID(
+		ctx context.Context,
+		id uint,
+	) (*model.AuditLog, error)
 	ListByUser(ctx context.Context, userID uint, limit int) ([]model.AuditLog, error)
 	Count(ctx context.Context) (int64, error)
 }
@@ -25,21 +69,153 @@ func NewAuditRepository(db *gorm.DB) AuditRepository {
 	return &auditRepository{db: db}
 }
 
-func (r *auditRepository) Create(ctx context.Context, log *model.AuditLog) error {
-	return r.db.WithContext(ctx).Create(log).Error
+func (r *auditRepository) Append(
+	ctx context.Context,
+	log *model.AuditLog,
+) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if log.EventID != "" {
+			var count int64
+			if err := tx.Model(&model.AuditLog{}).Where("event_id = ?", log.EventID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+		}
+
+		// Lock the end of the chain so concurrent writers append in turn
+		var last model.AuditLog
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("seq IS NOT NULL").
+			Order("seq DESC").
+			Take(&last).Error
+		var seq uint64 = 1
+		switch {
+		case err == nil:
+			seq = *last.Seq + 1
+			log.PrevHash = last.Hash
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			log.PrevHash = ""
+		default:
+			return err
+		}
+		log.Seq = &seq
+		log.Hash = log.ChainHash()
+		return tx.Create(log).Error
+	})
 }
 
-func (r *auditRepository) List(ctx context.Context, action string, limit int) ([]model.AuditLog, error) {
-	if limit <= 0 {
-		limit = 100
+func (r *auditRepository) ListChain(
+	ctx context.Context,
+	afterSeq uint64,
+	limit int,
+) ([]model.AuditLog, error) {
+	var logs []model.AuditLog
+	err := r.db.WithContext(ctx).
+		Where("seq > ?", afterSeq).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&logs).Error
+	return logs, err
+}
+
+func (r *auditRepository) LastChained(
+	ctx context.Context,
+) (*model.AuditLog, error) {
+	var log model.AuditLog
+	err := r.db.WithContext(ctx).Where("seq IS NOT NULL").Order("seq DESC").Take(&log).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
 	}
-	if limit > 1000 {
-		limit = 1000
+	if err != nil {
+		return nil, err
 	}
+	return &log, nil
+}
 
-	query := r.db.WithContext(ctx).Order("created_at DESC").Limit(limit)
-	if action != "" {
-		query = query.Where("action = ?", action)
+func (r *auditRepository) GetBySeq(
+	ctx context.Context,
+	seq uint64,
+) (*model.AuditLog, error) {
+	var log model.AuditLog
+	if err := r.db.WithContext(ctx).Where("seq = ?", seq).Take(&log).Error; err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+func (r *auditRepository) CreateCheckpoint(
+	ctx context.Context,
+	checkpoint *model.AuditCheckpoint,
+) error {
+	return r.db.WithContext(ctx).Create(checkpoint).Error
+}
+
+func (r *auditRepository) LastCheckpoint(
+	ctx context.Context,
+) (*model.AuditCheckpoint, error) {
+	var checkpoint model.AuditCheckpoint
+	err := r.db.WithContext(ctx).Order("id DESC").Take(&checkpoint).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+func (r *auditRepository) ListCheckpoints(
+	ctx context.Context,
+	limit int,
+) ([]model.AuditCheckpoint, error) {
+	var checkpoints []model.AuditCheckpoint
+	query := r.db.WithContext(ctx).Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&checkpoints).Error
+	return checkpoints, err
+}
+
+func (r *auditRepository) Search(
+	ctx context.Context,
+	filter dto.AuditLogFilter,
+) ([]model.AuditLog, error) {
+	query := r.db.WithContext(ctx).Order("id DESC").Limit(filter.Limit)
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.IPAddress != "" {
+		query = query.Where("ip_address = ?", filter.IPAddress)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at <= ?", filter.To)
+	}
+	if filter.Cursor != 0 {
+		query = query.Where("id < ?", filter.Cursor)
 	}
 
 	var logs []model.AuditLog
@@ -47,6 +223,17 @@ func (r *auditRepository) List(ctx context.Context, action string, limit int) ([
 	return logs, err
 }
 
+func (r *auditRepository) GetByID(
+	ctx context.Context,
+	id uint,
+) (*model.AuditLog, error) {
+	var log model.AuditLog
+	if err := r.db.WithContext(ctx).First(&log, id).Error; err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
 func (r *auditRepository) ListByUser(ctx context.Context, userID uint, limit int) ([]model.AuditLog, error) {
 	if limit <= 0 {
 		limit = 100
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aruncs31s/skvms/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BoardProfileRepository interface {
+	List(
+		ctx context.Context,
+	) ([]model.BoardProfile, error)
+	GetByDeviceTypeID(
+		ctx context.Context,
+		deviceTypeID uint,
+	) (*model.BoardProfile, error)
+	// GetByDeviceID resolves the profile through the device's type.
+	GetByDeviceID(
+		ctx context.Context,
+		deviceID uint,
+	) (*model.BoardProfile, error)
+	Upsert(
+		ctx context.Context,
+		profile *model.BoardProfile,
+	) error
+}
+
+type boardProfileRepository struct {
+	db *gorm.DB
+}
+
+func NewBoardProfileRepository(db *gorm.DB) BoardProfileRepository {
+	return &boardProfileRepository{db: db}
+}
+
+func (r *boardProfileRepository) List(
+	ctx context.Context,
+) ([]model.BoardProfile, error) {
+	var profiles []model.BoardProfile
+	err := r.db.WithContext(ctx).
+		Preload("DeviceType").
+		Order("device_type_id").
+		Find(&profiles).Error
+	if err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func (r *boardProfileRepository) GetByDeviceTypeID(
+	ctx context.Context,
+	deviceTypeID uint,
+) (*model.BoardProfile, error) {
+	var profile model.BoardProfile
+	err := r.db.WithContext(ctx).
+		Preload("DeviceType").
+		Where("device_type_id = ?", deviceTypeID).
+		First(&profile).Error
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (r *boardProfileRepository) GetByDeviceID(
+	ctx context.Context,
+	deviceID uint,
+) (*model.BoardProfile, error) {
+	var profile model.BoardProfile
+	err := r.db.WithContext(ctx).
+		Preload("DeviceType").
+		Joins("JOIN devices ON devices.device_type = board_profiles.device_type_id").
+		Where("devices.id = ?", deviceID).
+		First(&profile).Error
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (r *boardProfileRepository) Upsert(
+	ctx context.Context,
+	profile *model.BoardProfile,
+) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "device_type_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"fqbn",
+				"platformio_env",
+				"partition_scheme",
+				"flash_size",
+				"libraries",
+				"updated_by",
+				"updated_at",
+			}),
+		}).
+		Create(profile).Error
+}
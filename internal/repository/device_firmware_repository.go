@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aruncs31s/skvms/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DeviceFirmwareRepository interface {
+	GetByDeviceID(
+		ctx context.Context,
+		deviceID uint,
+	) (*model.DeviceFirmware, error)
+	// Upsert replaces the device's last report. BootedAt is only overwritten
+	// when the report sets it.
+	Upsert(
+		ctx context.Context,
+		firmware *model.DeviceFirmware,
+	) error
+	// ListMicrocontrollerFirmware lists every microcontroller with its
+	// assigned version and last reported firmware, if any.
+	ListMicrocontrollerFirmware(
+		ctx context.Context,
+	) ([]model.DeviceFirmwareView, error)
+	// ListVersionNames returns the names of all known versions.
+	ListVersionNames(
+		ctx context.Context,
+	) ([]string, error)
+}
+
+type deviceFirmwareRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceFirmwareRepository(db *gorm.DB) DeviceFirmwareRepository {
+	return &deviceFirmwareRepository{db: db}
+}
+
+func (r *deviceFirmwareRepository) GetByDeviceID(
+	ctx context.Context,
+	deviceID uint,
+) (*model.DeviceFirmware, error) {
+	var firmware model.DeviceFirmware
+	err := r.db.WithContext(ctx).
+		Where("device_id = ?", deviceID).
+		First(&firmware).Error
+	if err != nil {
+		return nil, err
+	}
+	return &firmware, nil
+}
+
+func (r *deviceFirmwareRepository) Upsert(
+	ctx context.Context,
+	firmware *model.DeviceFirmware,
+) error {
+	columns := []string{
+		"version",
+		"build_id",
+		"checksum",
+		"last_event",
+		"reported_at",
+	}
+	if firmware.BootedAt != nil {
+		columns = append(columns, "booted_at")
+	}
+	return r.db.WithContext(ctx).
+		Omit("Device").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "device_id"}},
+			DoUpdates: clause.AssignmentColumns(columns),
+		}).
+		Create(firmware).Error
+}
+
+func (r *deviceFirmwareRepository) ListMicrocontrollerFirmware(
+	ctx context.Context,
+) ([]model.DeviceFirmwareView, error) {
+	var rows []model.DeviceFirmwareView
+	sql := `SELECT
+		d.id AS device_id,
+		d.name,
+		dt.name AS type,
+		v.name AS assigned_version,
+		df.version AS reported_version,
+		df.build_id,
+		df.checksum,
+		df.reported_at
+	FROM devices d
+	JOIN device_types dt
+		ON dt.id = d.device_type
+	LEFT JOIN versions v
+		ON v.id = d.version_id
+	LEFT JOIN device_firmware df
+		ON df.device_id = d.id
+	WHERE dt.hardware_type = ?
+	  AND d.deleted_at IS NULL
+	ORDER BY d.id`
+	err := r.db.WithContext(ctx).Raw(sql, model.HardwareTypeMicroController).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *deviceFirmwareRepository) ListVersionNames(
+	ctx context.Context,
+) ([]string, error) {
+	var names []string
+	err := r.db.WithContext(ctx).
+		Model(&model.Version{}).
+		Pluck("name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
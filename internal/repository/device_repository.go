@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 
+	"github.com/aruncs31s/skvms/internal/database/dialect"
 	"github.com/aruncs31s/skvms/internal/dto"
 	"github.com/aruncs31s/skvms/internal/model"
 	"github.com/aruncs31s/skvms/utils"
@@ -240,7 +241,6 @@ func (r *deviceRepository) ListDevicesByUser(ctx context.Context, userID uint) (
 		Preload("DeviceType").
 		Preload("Version").
 		Preload("Details").
-		Preload("Address").
 		Preload("DeviceState").
 		Find(&devices).Error
 	if err != nil {
@@ -265,7 +265,6 @@ func (r *deviceRepository) GetDeviceForUpdate(
 		WithContext(ctx).
 		Preload("DeviceState").
 		Preload("Details").
-		Preload("Address").
 		Preload("DeviceType").
 		Preload("Version").
 		First(&device, id).Error
@@ -541,7 +540,7 @@ func (r *deviceRepository) SearchDevices(
 	var results []dto.GenericDropdown
 	err := r.db.WithContext(ctx).Model(&model.Device{}).
 		Select("id, name").
-		Where("name LIKE ?", "%"+query+"%").
+		Where(dialect.Of(r.db).ContainsFold("name"), "%"+query+"%").
 		Scan(&results).Error
 	return results, err
 }
@@ -557,7 +556,8 @@ func (r *deviceRepository) SearchDevicesByHardwareType(
 		Table(model.Device{}.TableName()+" d").
 		Select("d.id, d.name").
 		Joins("JOIN device_types dt ON dt.id = d.device_type").
-		Where("d.name LIKE ? AND dt.hardware_type = ?", "%"+query+"%", hardwareType).
+		Where(dialect.Of(r.db).ContainsFold("d.name"), "%"+query+"%").
+		Where("dt.hardware_type = ?", hardwareType).
 		Scan(&results).Error
 	return results, err
 }
@@ -572,7 +572,8 @@ func (r *deviceRepository) SearchDevicesByHardwareTypes(
 		Table(model.Device{}.TableName()+" d").
 		Select("d.id, d.name").
 		Joins("JOIN device_types dt ON dt.id = d.device_type").
-		Where("d.name LIKE ? AND dt.hardware_type IN ?", "%"+query+"%", hardwareType).
+		Where(dialect.Of(r.db).ContainsFold("d.name"), "%"+query+"%").
+		Where("dt.hardware_type IN ?", hardwareType).
 		Scan(&results).Error
 	return results, err
 }
@@ -774,6 +775,7 @@ func (r *deviceRepository) GetConnectedDevicesByIDs(
 
 	today := utils.GetBeginningOfDay()
 	night := utils.GetEndOfDay()
+	d := dialect.Of(r.db)
 	query := `
 		SELECT
 		cd.parent_id,
@@ -781,18 +783,9 @@ func (r *deviceRepository) GetConnectedDevicesByIDs(
 			r.created_at,
 			r.voltage,
 			r.current,
-			AVG(r.current) OVER (
-				ORDER BY r.created_at
-				ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
-			) AS avg_current,
-			AVG(r.voltage) OVER (
-					ORDER BY r.created_at
-					ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
-			) AS avg_voltage,
-			80 / AVG(r.current) OVER (
-				ORDER BY r.created_at
-				ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
-			) AS estimated_remaining_hours
+			` + d.RunningAvg("r.current", "r.created_at") + ` AS avg_current,
+			` + d.RunningAvg("r.voltage", "r.created_at") + ` AS avg_voltage,
+			80 / ` + d.RunningAvg("r.current", "r.created_at") + ` AS estimated_remaining_hours
 		FROM readings r
 		JOIN connected_devices cd ON r.device_id = cd.child_id
 		WHERE r.created_at BETWEEN ? AND ? AND cd.parent_id IN ?
@@ -834,7 +827,7 @@ func (r *deviceRepository) GetDevicesByLocationID(
 		Joins("JOIN device_types dt ON dt.id = d.device_type").
 		Joins("JOIN device_states ds ON d.current_state  = ds.id").
 		Joins("JOIN versions v ON v.id = d	.version_id").
-		Joins("JOIN device_assignment da ON da.device_id = d.id").
+		Joins("JOIN device_assignments da ON da.device_id = d.id").
 		Where("da.location_id = ? AND dt.hardware_type = ?", locationID, int(model.HardwareTypeSolar))
 
 	err := query.Scan(&devices).Error
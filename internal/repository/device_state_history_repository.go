@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/aruncs31s/skvms/internal/database/dialect"
 	"github.com/aruncs31s/skvms/internal/model"
 	"gorm.io/gorm"
 )
@@ -58,16 +59,16 @@ func (r *deviceStateHistoryRepository) GetDeviceStateHistory(
 	query := r.db.WithContext(ctx).Table("device_state_history dsh")
 
 	if deviceID != 0 {
-		query = query.Where("device_id = ?", deviceID)
+		query = query.Where("dsh.device_id = ?", deviceID)
 	}
 	if len(states) > 0 {
-		query = query.Where("state_id IN ?", states)
+		query = query.Where("dsh.state_id IN ?", states)
 	}
 	if !from.IsZero() {
-		query = query.Where("created_at >= ?", from)
+		query = query.Where("dsh.created_at >= ?", from)
 	}
 	if !to.IsZero() {
-		query = query.Where("created_at <= ?", to)
+		query = query.Where("dsh.created_at <= ?", to)
 	}
 	query = query.
 		Joins(
@@ -79,7 +80,7 @@ func (r *deviceStateHistoryRepository) GetDeviceStateHistory(
 		[]string{
 			"dsh.caused_action AS action",
 			"ds.name AS state",
-			"DATE_FORMAT(dsh.created_at, '%Y-%m-%d %H:%i:%s') AS changed_at",
+			dialect.Of(r.db).FormatDateTime("dsh.created_at") + " AS changed_at",
 			"u.username AS changed_by",
 		},
 	)
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aruncs31s/skvms/internal/model"
+	"gorm.io/gorm"
+)
+
+type ExportTemplateRepository interface {
+	// List returns the templates of a data type, or all templates when
+	// dataType is empty.
+	List(
+		ctx context.Context,
+		dataType string,
+	) ([]model.ExportTemplate, error)
+	GetByID(
+		ctx context.Context,
+		id uint,
+	) (*model.ExportTemplate, error)
+	// GetDefault returns the default template of a data type, or
+	// gorm.ErrRecordNotFound if none is set.
+	GetDefault(
+		ctx context.Context,
+		dataType string,
+	) (*model.ExportTemplate, error)
+	// Create stores a new template with its first version.
+	Create(
+		ctx context.Context,
+		template *model.ExportTemplate,
+		version *model.ExportTemplateVersion,
+	) error
+	Update(
+		ctx context.Context,
+		template *model.ExportTemplate,
+	) error
+	Delete(
+		ctx context.Context,
+		id uint,
+	) error
+	// AddVersion stores the next version of a template and makes it the
+	// latest. version.Version is set to the new number.
+	AddVersion(
+		ctx context.Context,
+		version *model.ExportTemplateVersion,
+	) error
+	GetVersion(
+		ctx context.Context,
+		templateID uint,
+		version int,
+	) (*model.ExportTemplateVersion, error)
+	ListVersions(
+		ctx context.Context,
+		templateID uint,
+	) ([]model.ExportTemplateVersion, error)
+	// SetDefault makes a template the default of its data type, replacing
+	// the previous default.
+	SetDefault(
+		ctx context.Context,
+		id uint,
+	) error
+}
+
+type exportTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewExportTemplateRepository(db *gorm.DB) ExportTemplateRepository {
+	return &exportTemplateRepository{db: db}
+}
+
+func (r *exportTemplateRepository) List(
+	ctx context.Context,
+	dataType string,
+) ([]model.ExportTemplate, error) {
+	query := r.db.WithContext(ctx).Order("data_type, name")
+	if dataType != "" {
+		query = query.Where("data_type = ?", dataType)
+	}
+
+	var templates []model.ExportTemplate
+	if err := query.Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (r *exportTemplateRepository) GetByID(
+	ctx context.Context,
+	id uint,
+) (*model.ExportTemplate, error) {
+	var template model.ExportTemplate
+	if err := r.db.WithContext(ctx).First(&template, id).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *exportTemplateRepository) GetDefault(
+	ctx context.Context,
+	dataType string,
+) (*model.ExportTemplate, error) {
+	var template model.ExportTemplate
+	err := r.db.WithContext(ctx).
+		Where("data_type = ? AND is_default = ?", dataType, true).
+		First(&template).Error
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *exportTemplateRepository) Create(
+	ctx context.Context,
+	template *model.ExportTemplate,
+	version *model.ExportTemplateVersion,
+) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		template.LatestVersion = 1
+		if err := tx.Create(template).Error; err != nil {
+			return err
+		}
+		version.TemplateID = template.ID
+		version.Version = 1
+		return tx.Omit("Template").Create(version).Error
+	})
+}
+
+func (r *exportTemplateRepository) Update(
+	ctx context.Context,
+	template *model.ExportTemplate,
+) error {
+	return r.db.WithContext(ctx).Save(template).Error
+}
+
+func (r *exportTemplateRepository) Delete(
+	ctx context.Context,
+	id uint,
+) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", id).Delete(&model.ExportTemplateVersion{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&model.ExportTemplate{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *exportTemplateRepository) AddVersion(
+	ctx context.Context,
+	version *model.ExportTemplateVersion,
+) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Bump the latest version first; the row lock orders concurrent uploads
+		result := tx.Model(&model.ExportTemplate{}).
+			Where("id = ?", version.TemplateID).
+			Updates(map[string]interface{}{
+				"latest_version": gorm.Expr("latest_version + 1"),
+				"updated_by":     version.CreatedBy,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var template model.ExportTemplate
+		if err := tx.Select("latest_version").First(&template, version.TemplateID).Error; err != nil {
+			return err
+		}
+		version.Version = template.LatestVersion
+		return tx.Omit("Template").Create(version).Error
+	})
+}
+
+func (r *exportTemplateRepository) GetVersion(
+	ctx context.Context,
+	templateID uint,
+	version int,
+) (*model.ExportTemplateVersion, error) {
+	var v model.ExportTemplateVersion
+	err := r.db.WithContext(ctx).
+		Where("template_id = ? AND version = ?", templateID, version).
+		First(&v).Error
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *exportTemplateRepository) ListVersions(
+	ctx context.Context,
+	templateID uint,
+) ([]model.ExportTemplateVersion, error) {
+	var versions []model.ExportTemplateVersion
+	err := r.db.WithContext(ctx).
+		Omit("content").
+		Where("template_id = ?", templateID).
+		Order("version DESC").
+		Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *exportTemplateRepository) SetDefault(
+	ctx context.Context,
+	id uint,
+) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var template model.ExportTemplate
+		if err := tx.First(&template, id).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&model.ExportTemplate{}).
+			Where("data_type = ? AND id <> ?", template.DataType, id).
+			Update("is_default", false).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&template).Update("is_default", true).Error
+	})
+}
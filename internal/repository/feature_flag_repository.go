@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aruncs31s/skvms/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FeatureFlagRepository interface {
+	// DeviceLocationID returns the location the device is currently
+	// assigned to, or nil if it has none.
+	DeviceLocationID(
+		ctx context.Context,
+		deviceID uint,
+	) (*uint, error)
+	// DeviceOwnerID returns the ID of the user who created the device.
+	DeviceOwnerID(
+		ctx context.Context,
+		deviceID uint,
+	) (uint, error)
+	// ListOverridesForDevice returns the device's own overrides and those of
+	// its location.
+	ListOverridesForDevice(
+		ctx context.Context,
+		deviceID uint,
+		locationID *uint,
+	) ([]model.FeatureOverride, error)
+	ListOverrides(
+		ctx context.Context,
+		scope string,
+		scopeID uint,
+	) ([]model.FeatureOverride, error)
+	GetFeature(
+		ctx context.Context,
+		featureID uint,
+	) (*model.Feature, error)
+	GetOverride(
+		ctx context.Context,
+		featureID uint,
+		scope string,
+		scopeID uint,
+	) (*model.FeatureOverride, error)
+	// ScopeExists reports whether the device or location an override is for
+	// exists.
+	ScopeExists(
+		ctx context.Context,
+		scope string,
+		scopeID uint,
+	) (bool, error)
+	UpsertOverride(
+		ctx context.Context,
+		override *model.FeatureOverride,
+	) error
+	DeleteOverride(
+		ctx context.Context,
+		featureID uint,
+		scope string,
+		scopeID uint,
+	) error
+}
+
+type featureFlagRepository struct {
+	db *gorm.DB
+}
+
+func NewFeatureFlagRepository(db *gorm.DB) FeatureFlagRepository {
+	return &featureFlagRepository{db: db}
+}
+
+func (r *featureFlagRepository) DeviceLocationID(
+	ctx context.Context,
+	deviceID uint,
+) (*uint, error) {
+	var assignment model.DeviceAssignment
+	err := r.db.WithContext(ctx).
+		Where("device_id = ? AND unassigned_at IS NULL", deviceID).
+		Order("assigned_at DESC").
+		First(&assignment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &assignment.LocationID, nil
+}
+
+func (r *featureFlagRepository) DeviceOwnerID(
+	ctx context.Context,
+	deviceID uint,
+) (uint, error) {
+	var device model.Device
+	if err := r.db.WithContext(ctx).Select("id", "created_by").First(&device, deviceID).Error; err != nil {
+		return 0, err
+	}
+	return device.CreatedBy, nil
+}
+
+func (r *featureFlagRepository) ListOverridesForDevice(
+	ctx context.Context,
+	deviceID uint,
+	locationID *uint,
+) ([]model.FeatureOverride, error) {
+	var overrides []model.FeatureOverride
+	query := r.db.WithContext(ctx).
+		Preload("Feature").
+		Where("scope = ? AND scope_id = ?", model.OverrideScopeDevice, deviceID)
+	if locationID != nil {
+		query = query.Or("scope = ? AND scope_id = ?", model.OverrideScopeLocation, *locationID)
+	}
+	if err := query.Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+func (r *featureFlagRepository) ListOverrides(
+	ctx context.Context,
+	scope string,
+	scopeID uint,
+) ([]model.FeatureOverride, error) {
+	var overrides []model.FeatureOverride
+	err := r.db.WithContext(ctx).
+		Preload("Feature").
+		Where("scope = ? AND scope_id = ?", scope, scopeID).
+		Order("feature_id").
+		Find(&overrides).Error
+	if err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+func (r *featureFlagRepository) GetFeature(
+	ctx context.Context,
+	featureID uint,
+) (*model.Feature, error) {
+	var feature model.Feature
+	if err := r.db.WithContext(ctx).First(&feature, featureID).Error; err != nil {
+		return nil, err
+	}
+	return &feature, nil
+}
+
+func (r *featureFlagRepository) GetOverride(
+	ctx context.Context,
+	featureID uint,
+	scope string,
+	scopeID uint,
+) (*model.FeatureOverride, error) {
+	var override model.FeatureOverride
+	err := r.db.WithContext(ctx).
+		Preload("Feature").
+		Where("feature_id = ? AND scope = ? AND scope_id = ?", featureID, scope, scopeID).
+		First(&override).Error
+	if err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+func (r *featureFlagRepository) ScopeExists(
+	ctx context.Context,
+	scope string,
+	scopeID uint,
+) (bool, error) {
+	var value any = &model.Device{}
+	if scope == model.OverrideScopeLocation {
+		value = &model.Location{}
+	}
+	var count int64
+	if err := r.db.WithContext(ctx).Model(value).Where("id = ?", scopeID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *featureFlagRepository) UpsertOverride(
+	ctx context.Context,
+	override *model.FeatureOverride,
+) error {
+	return r.db.WithContext(ctx).
+		Omit("Feature").
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{
+				{Name: "feature_id"},
+				{Name: "scope"},
+				{Name: "scope_id"},
+			},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"enabled",
+				"updated_by",
+				"updated_at",
+			}),
+		}).
+		Create(override).Error
+}
+
+func (r *featureFlagRepository) DeleteOverride(
+	ctx context.Context,
+	featureID uint,
+	scope string,
+	scopeID uint,
+) error {
+	result := r.db.WithContext(ctx).
+		Where("feature_id = ? AND scope = ? AND scope_id = ?", featureID, scope, scopeID).
+		Delete(&model.FeatureOverride{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
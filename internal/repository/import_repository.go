@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aruncs31s/skvms/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImportRepository stores import jobs and the rows they create. It
+// implements importer.Store.
+type ImportRepository interface {
+	IsAdmin(
+		ctx context.Context,
+		userID uint,
+	) (bool, error)
+	// ListDeviceRefs returns the ID, name, type and owner of every device.
+	ListDeviceRefs(
+		ctx context.Context,
+	) ([]model.Device, error)
+	ListDeviceTypes(
+		ctx context.Context,
+	) ([]model.DeviceTypes, error)
+	ListLocations(
+		ctx context.Context,
+	) ([]model.Location, error)
+	CreateJob(
+		ctx context.Context,
+		job *model.ImportJob,
+	) error
+	UpdateJob(
+		ctx context.Context,
+		job *model.ImportJob,
+	) error
+	GetJob(
+		ctx context.Context,
+		id uint,
+	) (*model.ImportJob, error)
+	// ListJobs returns jobs newest first with the total count.
+	ListJobs(
+		ctx context.Context,
+		limit int,
+		offset int,
+	) ([]model.ImportJob, int64, error)
+	InsertReadings(
+		ctx context.Context,
+		readings []model.Reading,
+	) error
+	// InsertDevices inserts devices with their details and, when a location
+	// is set, their assignment.
+	InsertDevices(
+		ctx context.Context,
+		devices []model.Device,
+	) error
+	// CountForeignReadings counts readings of the devices created by a job
+	// that were not loaded by the job.
+	CountForeignReadings(
+		ctx context.Context,
+		jobID uint,
+	) (int64, error)
+	// DeleteJobRows marks a job rolled back and deletes the readings or
+	// devices it created, in one transaction. It returns
+	// gorm.ErrRecordNotFound when the job is running or already rolled back.
+	DeleteJobRows(
+		ctx context.Context,
+		job *model.ImportJob,
+	) (int64, error)
+}
+
+type importRepository struct {
+	db *gorm.DB
+}
+
+func NewImportRepository(db *gorm.DB) ImportRepository {
+	return &importRepository{db: db}
+}
+
+func (r *importRepository) IsAdmin(
+	ctx context.Context,
+	userID uint,
+) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.User{}).
+		Where("id = ? AND role = ?", userID, "admin").
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *importRepository) ListDeviceRefs(
+	ctx context.Context,
+) ([]model.Device, error) {
+	var devices []model.Device
+	err := r.db.WithContext(ctx).
+		Select("id", "name", "device_type", "created_by").
+		Find(&devices).Error
+	return devices, err
+}
+
+func (r *importRepository) ListDeviceTypes(
+	ctx context.Context,
+) ([]model.DeviceTypes, error) {
+	var types []model.DeviceTypes
+	err := r.db.WithContext(ctx).Find(&types).Error
+	return types, err
+}
+
+func (r *importRepository) ListLocations(
+	ctx context.Context,
+) ([]model.Location, error) {
+	var locations []model.Location
+	err := r.db.WithContext(ctx).
+		Select("id", "code", "name").
+		Find(&locations).Error
+	return locations, err
+}
+
+func (r *importRepository) CreateJob(
+	ctx context.Context,
+	job *model.ImportJob,
+) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+func (r *importRepository) UpdateJob(
+	ctx context.Context,
+	job *model.ImportJob,
+) error {
+	return r.db.WithContext(ctx).Save(job).Error
+}
+
+func (r *importRepository) GetJob(
+	ctx context.Context,
+	id uint,
+) (*model.ImportJob, error) {
+	var job model.ImportJob
+	if err := r.db.WithContext(ctx).First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *importRepository) ListJobs(
+	ctx context.Context,
+	limit int,
+	offset int,
+) ([]model.ImportJob, int64, error) {
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&model.ImportJob{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var jobs []model.ImportJob
+	query := r.db.WithContext(ctx).Omit("errors").Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit).Offset(offset)
+	}
+	if err := query.Find(&jobs).Error; err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+func (r *importRepository) InsertReadings(
+	ctx context.Context,
+	readings []model.Reading,
+) error {
+	if len(readings) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Omit(clause.Associations).Create(&readings).Error
+}
+
+func (r *importRepository) InsertDevices(
+	ctx context.Context,
+	devices []model.Device,
+) error {
+	if len(devices) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&devices).Error; err != nil {
+			return err
+		}
+
+		details := make([]model.DeviceDetails, len(devices))
+		var assignments []model.DeviceAssignment
+		for i, d := range devices {
+			details[i] = model.DeviceDetails{
+				DeviceID:   d.ID,
+				IPAddress:  d.Details.IPAddress,
+				MACAddress: d.Details.MACAddress,
+			}
+			if d.Assignment.LocationID != 0 {
+				assignments = append(assignments, model.DeviceAssignment{
+					DeviceID:   d.ID,
+					LocationID: d.Assignment.LocationID,
+					AssignedAt: d.CreatedAt,
+				})
+			}
+		}
+		if err := tx.Create(&details).Error; err != nil {
+			return err
+		}
+		if len(assignments) > 0 {
+			return tx.Create(&assignments).Error
+		}
+		return nil
+	})
+}
+
+// jobDevices selects the IDs of the devices created by a job, including
+// devices deleted since.
+func jobDevices(tx *gorm.DB, jobID uint) *gorm.DB {
+	return tx.Unscoped().Model(&model.Device{}).Select("id").Where("import_job_id = ?", jobID)
+}
+
+func (r *importRepository) CountForeignReadings(
+	ctx context.Context,
+	jobID uint,
+) (int64, error) {
+	db := r.db.WithContext(ctx)
+	var count int64
+	err := db.Model(&model.Reading{}).
+		Where("device_id IN (?)", jobDevices(db, jobID)).
+		Where("import_job_id IS NULL OR import_job_id <> ?", jobID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *importRepository) DeleteJobRows(
+	ctx context.Context,
+	job *model.ImportJob,
+) (int64, error) {
+	var deleted int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Claim the job first, so of two concurrent rollbacks only one
+		// deletes its rows
+		result := tx.Model(&model.ImportJob{}).
+			Where("id = ? AND status NOT IN ?", job.ID, []string{model.ImportJobRunning, model.ImportJobRolledBack}).
+			Updates(map[string]interface{}{
+				"status":         model.ImportJobRolledBack,
+				"rolled_back_by": job.RolledBackBy,
+				"rolled_back_at": job.RolledBackAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		result = tx.Where("import_job_id = ?", job.ID).Delete(&model.Reading{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+
+		devices := jobDevices(tx.Session(&gorm.Session{NewDB: true}), job.ID)
+		for _, dependent := range []interface{}{
+			&model.DeviceDetails{},
+			&model.DeviceAssignment{},
+			&model.DeviceStateHistory{},
+		} {
+			if err := tx.Where("device_id IN (?)", devices).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("parent_id IN (?) OR child_id IN (?)", devices, devices).
+			Delete(&model.ConnectedDevice{}).Error; err != nil {
+			return err
+		}
+		// The empty model skips the BeforeDelete hook's owner check
+		result = tx.Unscoped().Where("import_job_id = ?", job.ID).Delete(&model.Device{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted += result.RowsAffected
+		return nil
+	})
+	return deleted, err
+}
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"context"
+	"time"
 
+	"github.com/aruncs31s/skvms/internal/database/dialect"
 	"github.com/aruncs31s/skvms/internal/model"
 	"gorm.io/gorm"
 )
@@ -51,6 +53,14 @@ type LocationReader interface {
 		ctx context.Context,
 		locationID uint,
 	) (int, error)
+	// ListAssignments returns the device assignments of a location that
+	// overlap the time range, oldest first.
+	ListAssignments(
+		ctx context.Context,
+		locationID uint,
+		startTime time.Time,
+		endTime time.Time,
+	) ([]model.DeviceAssignment, error)
 }
 
 type locationRepository struct {
@@ -110,9 +120,10 @@ func (r *locationRepository) Search(
 	query string,
 ) ([]model.Location, error) {
 	var locations []model.Location
+	d := dialect.Of(r.db)
 	if err := r.db.WithContext(ctx).
 		Where(
-			"name LIKE ? OR code LIKE ?",
+			d.ContainsFold("name")+" OR "+d.ContainsFold("code"),
 			"%"+query+"%", "%"+query+"%",
 		).
 		Find(&locations).Error; err != nil {
@@ -145,3 +156,21 @@ func (r *locationRepository) GetUserCount(ctx context.Context, locationID uint)
 		Count(&count).Error
 	return int(count), err
 }
+
+func (r *locationRepository) ListAssignments(
+	ctx context.Context,
+	locationID uint,
+	startTime time.Time,
+	endTime time.Time,
+) ([]model.DeviceAssignment, error) {
+	var assignments []model.DeviceAssignment
+	err := r.db.WithContext(ctx).
+		Where("location_id = ? AND assigned_at <= ?", locationID, endTime).
+		Where("unassigned_at IS NULL OR unassigned_at >= ?", startTime).
+		Order("assigned_at").
+		Find(&assignments).Error
+	if err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
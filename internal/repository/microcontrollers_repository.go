@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/utils"
 	"gorm.io/gorm"
 )
 
@@ -46,19 +47,17 @@ func (r *microcontrollersRepository) ListMicrocontrollerDevices(
 		dd.ip_address,
 		dd.mac_address,
 		v.name as firmware_version,
+		df.version as reported_version,
 		ds.name as current_state,
 		cdevice.name as used_by
 	FROM devices d
 	JOIN device_states ds  ON d.current_state  = ds.id
 	JOIN device_types dt 
 		ON dt.id = d.device_type
+	LEFT JOIN device_firmware df
+		ON df.device_id = d.id
 	LEFT JOIN versions v
-	  ON v.device_id = d.id
-	 AND v.name = (
-		 SELECT MAX(v2.name) as version_name
-		 FROM versions v2
-		 WHERE v2.device_id = d.id
-	 )
+		ON v.id = d.version_id
 	LEFT JOIN device_details dd 
 		ON dd.device_id = d.id
 	LEFT JOIN connected_devices cd 
@@ -111,20 +110,18 @@ func (r *microcontrollersRepository) GetMicrocontrollerStats(
 	}
 	return stats, nil
 }
+
+// LatestVersion returns the highest version name. Names are compared
+// semantically, so "1.10.0" is newer than "1.9.0".
 func (r *microcontrollersRepository) LatestVersion(
 	ctx context.Context,
 ) (string, error) {
-	var version string
-	sql := `
-	SELECT  
-	v.name as version_name
-	FROM versions v
-	ORDER BY v.name DESC
-	LIMIT 1
-	`
-	err := r.db.WithContext(ctx).Raw(sql).Scan(&version).Error
+	var names []string
+	err := r.db.WithContext(ctx).
+		Model(&model.Version{}).
+		Pluck("name", &names).Error
 	if err != nil {
 		return "", err
 	}
-	return version, nil
+	return utils.LatestVersion(names), nil
 }
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aruncs31s/skvms/internal/database/dialect"
 	"github.com/aruncs31s/skvms/internal/logger"
 	"github.com/aruncs31s/skvms/internal/model"
 	"github.com/aruncs31s/skvms/utils"
@@ -20,6 +21,17 @@ type ReadingRepository interface {
 		startTime time.Time,
 		endTime time.Time,
 	) ([]model.Reading, error)
+	// ListPageByDevicesAndDateRange pages through the readings of several
+	// devices, newest first. Pages are keyed on the last reading returned
+	// rather than an offset, so deep pages stay cheap.
+	ListPageByDevicesAndDateRange(
+		ctx context.Context,
+		deviceIDs []uint,
+		startTime time.Time,
+		endTime time.Time,
+		after *model.Reading,
+		limit int,
+	) ([]model.Reading, error)
 	ListByDeviceWithInterval(
 		ctx context.Context,
 		deviceID uint,
@@ -34,6 +46,13 @@ type ReadingRepository interface {
 		startTime time.Time,
 		endTime time.Time,
 	) (map[string]interface{}, error)
+	// DailyStats returns min, max and average voltage and current per day.
+	DailyStats(
+		ctx context.Context,
+		deviceID uint,
+		startTime time.Time,
+		endTime time.Time,
+	) ([]model.DailyReadingStats, error)
 	Count(ctx context.Context) (int64, error)
 	ReadingWriter
 	GetLastReading(
@@ -81,7 +100,7 @@ func (r *readingRepository) ListByDeviceWithInterval(
 	interval time.Duration,
 	count int,
 ) ([]model.Reading, error) {
-	logger.GetLogger().Info(
+	logger.FromContext(ctx).Info(
 		"[ListByDeviceWithInterval]",
 		zap.String("device_id", strconv.FormatUint(uint64(deviceID), 10)),
 		zap.String("start_time", startTime.Format(time.RFC3339)),
@@ -175,6 +194,36 @@ func (r *readingRepository) ListByDeviceAndDateRange(
 	return readings, nil
 }
 
+func (r *readingRepository) ListPageByDevicesAndDateRange(
+	ctx context.Context,
+	deviceIDs []uint,
+	startTime time.Time,
+	endTime time.Time,
+	after *model.Reading,
+	limit int,
+) ([]model.Reading, error) {
+	var readings []model.Reading
+	if len(deviceIDs) == 0 {
+		return readings, nil
+	}
+	query := r.db.WithContext(ctx).
+		Select("id", "device_id", "voltage", "current", "created_at").
+		Where("device_id IN ? AND created_at >= ? AND created_at <= ?", deviceIDs, startTime, endTime)
+	if after != nil {
+		query = query.Where("(created_at < ? OR (created_at = ? AND id < ?))",
+			after.CreatedAt, after.CreatedAt, after.ID)
+	}
+	err := query.
+		Order("created_at DESC").
+		Order("id DESC").
+		Limit(limit).
+		Find(&readings).Error
+	if err != nil {
+		return nil, err
+	}
+	return readings, nil
+}
+
 func (r *readingRepository) GetStats(ctx context.Context, deviceID uint, startTime, endTime time.Time) (map[string]interface{}, error) {
 	var stats struct {
 		MaxVoltage     float64
@@ -232,6 +281,34 @@ func (r *readingRepository) GetStats(ctx context.Context, deviceID uint, startTi
 		"min_voltage_time": stats.MinVoltageTime,
 	}, nil
 }
+func (r *readingRepository) DailyStats(
+	ctx context.Context,
+	deviceID uint,
+	startTime time.Time,
+	endTime time.Time,
+) ([]model.DailyReadingStats, error) {
+	var stats []model.DailyReadingStats
+	day := dialect.Of(r.db).Date("created_at")
+	err := r.db.WithContext(ctx).
+		Model(&model.Reading{}).
+		Select(day+` AS day,
+			COUNT(*) AS count,
+			MIN(voltage) AS min_voltage,
+			MAX(voltage) AS max_voltage,
+			AVG(voltage) AS avg_voltage,
+			MIN(current) AS min_current,
+			MAX(current) AS max_current,
+			AVG(current) AS avg_current`).
+		Where("device_id = ? AND created_at >= ? AND created_at <= ?", deviceID, startTime, endTime).
+		Group(day).
+		Order("day").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
 func (r *readingRepository) Create(ctx context.Context, reading *model.Reading) (*model.Reading, error) {
 	err := r.db.WithContext(ctx).Create(reading).Error
 	if err != nil {
@@ -289,20 +366,15 @@ func (r *readingRepository) ListByDeviceProgressive(ctx context.Context, device
 
 	today := utils.GetBeginningOfDay()
 	night := utils.GetEndOfDay()
+	d := dialect.Of(r.db)
 	query := `
 		SELECT
 		d.id,
 		r.created_at,
 		r.voltage,
 		r.current,
-		AVG(r.voltage) OVER (
-			ORDER BY r.created_at
-			ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
-		) AS avg_voltage,
-		AVG(r.current) OVER (
-			ORDER BY r.created_at
-			ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
-		) AS avg_current
+		` + d.RunningAvg("r.voltage", "r.created_at") + ` AS avg_voltage,
+		` + d.RunningAvg("r.current", "r.created_at") + ` AS avg_current
 	FROM readings r
 	JOIN devices d ON r.device_id = d.id
 	WHERE r.created_at >= ? AND r.created_at <= ? AND d.id = ?
@@ -322,11 +394,10 @@ func (r *readingRepository) SevenDaysReadingsByLocation(
 ) ([]model.SevenDaysReadings, error) {
 	var readings []model.SevenDaysReadings
 	q := r.db.WithContext(ctx)
+	now := time.Now()
 	query := `
 		SELECT
-			FROM_UNIXTIME(
-				FLOOR(UNIX_TIMESTAMP(r.created_at)/3600) * 3600
-			) AS bucket,
+			` + dialect.Of(r.db).TimeBucket("r.created_at", time.Hour) + ` AS bucket,
 			AVG(r.voltage) as voltage,
 			AVG(r.current) as current
 		FROM readings r
@@ -334,12 +405,12 @@ func (r *readingRepository) SevenDaysReadingsByLocation(
 		ON da.device_id = r.device_id
 		AND da.location_id = ?
 		AND r.created_at BETWEEN da.assigned_at
-			AND COALESCE(da.unassigned_at, NOW())
-		WHERE r.created_at >= NOW() - INTERVAL 7 DAY AND da.device_id = ?
+			AND COALESCE(da.unassigned_at, ?)
+		WHERE r.created_at >= ? AND da.device_id = ?
 		GROUP BY r.device_id, bucket
 		ORDER BY bucket DESC
 	`
-	err := q.Raw(query, locationID, deviceID).Scan(&readings).Error
+	err := q.Raw(query, locationID, now, now.AddDate(0, 0, -7), deviceID).Scan(&readings).Error
 	if err != nil {
 		return []model.SevenDaysReadings{}, err
 	}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/model"
+	"gorm.io/gorm"
+)
+
+type ReportRepository interface {
+	List(
+		ctx context.Context,
+	) ([]model.ReportDefinition, error)
+	GetByID(
+		ctx context.Context,
+		id uint,
+	) (*model.ReportDefinition, error)
+	Create(
+		ctx context.Context,
+		definition *model.ReportDefinition,
+	) error
+	Update(
+		ctx context.Context,
+		definition *model.ReportDefinition,
+	) error
+	Delete(
+		ctx context.Context,
+		id uint,
+	) error
+	// ListDue returns the enabled definitions whose next run is at or before now.
+	ListDue(
+		ctx context.Context,
+		now time.Time,
+	) ([]model.ReportDefinition, error)
+	// ClaimRun moves a due definition's next run forward. It returns false if
+	// another scheduler already claimed this run.
+	ClaimRun(
+		ctx context.Context,
+		id uint,
+		due time.Time,
+		next time.Time,
+	) (bool, error)
+	CreateRun(
+		ctx context.Context,
+		run *model.ReportRun,
+	) error
+	UpdateRun(
+		ctx context.Context,
+		run *model.ReportRun,
+	) error
+	GetRun(
+		ctx context.Context,
+		id uint,
+	) (*model.ReportRun, error)
+	ListRuns(
+		ctx context.Context,
+		definitionID uint,
+		limit int,
+	) ([]model.ReportRun, error)
+}
+
+type reportRepository struct {
+	db *gorm.DB
+}
+
+func NewReportRepository(db *gorm.DB) ReportRepository {
+	return &reportRepository{db: db}
+}
+
+func (r *reportRepository) List(
+	ctx context.Context,
+) ([]model.ReportDefinition, error) {
+	var definitions []model.ReportDefinition
+	err := r.db.WithContext(ctx).
+		Order("id").
+		Find(&definitions).Error
+	if err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+func (r *reportRepository) GetByID(
+	ctx context.Context,
+	id uint,
+) (*model.ReportDefinition, error) {
+	var definition model.ReportDefinition
+	if err := r.db.WithContext(ctx).First(&definition, id).Error; err != nil {
+		return nil, err
+	}
+	return &definition, nil
+}
+
+func (r *reportRepository) Create(
+	ctx context.Context,
+	definition *model.ReportDefinition,
+) error {
+	return r.db.WithContext(ctx).Create(definition).Error
+}
+
+func (r *reportRepository) Update(
+	ctx context.Context,
+	definition *model.ReportDefinition,
+) error {
+	return r.db.WithContext(ctx).Save(definition).Error
+}
+
+func (r *reportRepository) Delete(
+	ctx context.Context,
+	id uint,
+) error {
+	result := r.db.WithContext(ctx).Delete(&model.ReportDefinition{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *reportRepository) ListDue(
+	ctx context.Context,
+	now time.Time,
+) ([]model.ReportDefinition, error) {
+	var definitions []model.ReportDefinition
+	err := r.db.WithContext(ctx).
+		Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Order("next_run_at").
+		Find(&definitions).Error
+	if err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+func (r *reportRepository) ClaimRun(
+	ctx context.Context,
+	id uint,
+	due time.Time,
+	next time.Time,
+) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.ReportDefinition{}).
+		Where("id = ? AND next_run_at = ?", id, due).
+		Updates(map[string]interface{}{
+			"next_run_at": next,
+			"last_run_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *reportRepository) CreateRun(
+	ctx context.Context,
+	run *model.ReportRun,
+) error {
+	return r.db.WithContext(ctx).Omit("Definition").Create(run).Error
+}
+
+func (r *reportRepository) UpdateRun(
+	ctx context.Context,
+	run *model.ReportRun,
+) error {
+	return r.db.WithContext(ctx).Omit("Definition").Save(run).Error
+}
+
+func (r *reportRepository) GetRun(
+	ctx context.Context,
+	id uint,
+) (*model.ReportRun, error) {
+	var run model.ReportRun
+	if err := r.db.WithContext(ctx).First(&run, id).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *reportRepository) ListRuns(
+	ctx context.Context,
+	definitionID uint,
+	limit int,
+) ([]model.ReportRun, error) {
+	var runs []model.ReportRun
+	query := r.db.WithContext(ctx).
+		Where("definition_id = ?", definitionID).
+		Order("started_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
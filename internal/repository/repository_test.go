@@ -0,0 +1,76 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/repository"
+	"github.com/aruncs31s/skvms/internal/testutil"
+)
+
+func TestReadingDailyStats(t *testing.T) {
+	h := testutil.New(t)
+	device := h.Device().Create()
+	y, m, d := time.Now().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	h.Readings(device).Ending(today.Add(-14 * time.Hour)).Count(2).
+		Voltage(func(i int) float64 { return 11 + float64(i) }).Create()
+	h.Readings(device).Ending(today.Add(18 * time.Hour)).Count(4).
+		Current(func(i int) float64 { return float64(i) }).Create()
+
+	stats, err := repository.NewReadingRepository(h.DB).DailyStats(
+		context.Background(), device.ID, today.AddDate(0, 0, -1), today.AddDate(0, 0, 1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("got %d days, want 2: %+v", len(stats), stats)
+	}
+	yesterday := stats[0]
+	if !yesterday.Day.Equal(today.AddDate(0, 0, -1)) || yesterday.Count != 2 ||
+		yesterday.MinVoltage != 11 || yesterday.MaxVoltage != 12 || yesterday.AvgVoltage != 11.5 {
+		t.Errorf("yesterday = %+v", yesterday)
+	}
+	if s := stats[1]; !s.Day.Equal(today) || s.Count != 4 || s.MaxCurrent != 3 || s.AvgCurrent != 1.5 {
+		t.Errorf("today = %+v", s)
+	}
+}
+
+func TestLocationSearchIgnoresCase(t *testing.T) {
+	h := testutil.New(t)
+	h.Location().Name("North Campus").Code("NC1").Create()
+	h.Location().Name("Workshop").Code("WS1").Create()
+
+	repo := repository.NewLocationRepository(h.DB)
+	for query, want := range map[string]string{"north": "North Campus", "ws": "Workshop"} {
+		locations, err := repo.Search(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(locations) != 1 || locations[0].Name != want {
+			t.Errorf("Search(%q) = %+v, want %s", query, locations, want)
+		}
+	}
+}
+
+func TestMicrocontrollerListShowsAssignedAndReportedVersions(t *testing.T) {
+	h := testutil.New(t)
+	device := h.Device().Create()
+	// A newer release the device has not been assigned yet
+	h.DB.Create(&model.Version{Name: "2.0.0", DeviceID: device.ID, PreviousVersionID: device.VersionID})
+	h.DB.Create(&model.DeviceFirmware{DeviceID: device.ID, Version: "0.9.0", ReportedAt: time.Now()})
+
+	devices, err := repository.NewMicrocontrollersRepository(h.DB).ListMicrocontrollerDevices(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("got %d devices, want 1: %+v", len(devices), devices)
+	}
+	if d := devices[0]; d.FirmwareVersion != "1.0.0" || d.ReportedVersion != "0.9.0" {
+		t.Errorf("firmware version = %q, reported = %q, want 1.0.0 and 0.9.0", d.FirmwareVersion, d.ReportedVersion)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/aruncs31s/skvms/internal/dto"
 	"github.com/aruncs31s/skvms/internal/logger"
 	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -44,7 +46,10 @@ func NewSolarRepository(
 }
 func (r *solarRepository) GetAllSolarDevices(
 	ctx context.Context,
-) (*[]dto.SolarDeviceView, error) {
+) (_ *[]dto.SolarDeviceView, err error) {
+	ctx, span := tracing.Start(ctx, "SolarRepository.GetAllSolarDevices")
+	defer func() { tracing.End(span, err) }()
+
 	devices, err := r.deviceRepo.GetDevicesByHardwareType(ctx, model.HardwareTypeSolar)
 	if err != nil {
 		return nil, err
@@ -91,7 +96,7 @@ func (r *solarRepository) getConnectedMCs(
 		model.HardwareTypeMicroController,
 	)
 	if err != nil {
-		logger.GetLogger().Warn(
+		logger.FromContext(ctx).Warn(
 			"Failed to get connected microcontrollers by hardware type",
 			zap.String(
 				"solar_id", strconv.Itoa(int(solar)),
@@ -116,7 +121,7 @@ func (r *solarRepository) getConnectedVoltageMeters(
 		model.HardwareTypeVoltageMeter,
 	)
 	if err != nil {
-		logger.GetLogger().Error(
+		logger.FromContext(ctx).Error(
 			"Failed to get connected voltage meters by hardware type",
 			zap.Int(
 				"microcontroller_id", int(microcontroller),
@@ -141,10 +146,10 @@ func (r *solarRepository) getVoltageAndCurrent(
 		voltageMeterID,
 	)
 	if err != nil {
-		logger.GetLogger().Error(
+		logger.FromContext(ctx).Error(
 			"Failed to get voltage and current readings",
-			zap.String(
-				"voltage_meter_id", string(voltageMeterID),
+			zap.Uint(
+				"voltage_meter_id", voltageMeterID,
 			),
 			zap.Error(err),
 		)
@@ -182,7 +187,7 @@ func (r *solarRepository) CreateASolarDevice(
 	if connectedID != nil {
 		err = r.deviceRepo.AddConnectedDevice(ctx, createdDevice.ID, *connectedID)
 		if err != nil {
-			logger.GetLogger().Warn("Failed to add connected device", zap.Error(err))
+			logger.FromContext(ctx).Warn("Failed to add connected device", zap.Error(err))
 			// Continue, as device is created
 		}
 	}
@@ -194,7 +199,13 @@ func (r *solarRepository) mapDeviceToSolarDeviceView(
 	ctx context.Context,
 	device model.DeviceView,
 	connectedDeviceReadings map[uint]model.ConnectedDeviceReadings,
-) (dto.SolarDeviceView, error) {
+) (_ dto.SolarDeviceView, err error) {
+	// One span per device shows the queries each device adds to a listing
+	ctx, span := tracing.Start(ctx, "SolarRepository.mapDeviceToSolarDeviceView",
+		attribute.Int64("device.id", int64(device.ID)),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	if ctx.Err() != nil {
 		return dto.SolarDeviceView{}, ctx.Err()
 	}
@@ -221,7 +232,7 @@ func (r *solarRepository) mapDeviceToSolarDeviceView(
 	// if err == nil && mc != nil {
 	// 	voltageMeter, err := r.getConnectedVoltageMeters(ctx, mc.ID)
 	// 	if err != nil {
-	// 		logger.GetLogger().Warn(
+	// 		logger.FromContext(ctx).Warn(
 	// 			"Failed to get connected voltage meter for device",
 	// 			zap.Uint("device_id", device.ID),
 	// 			zap.Error(err),
@@ -251,7 +262,10 @@ func (r *solarRepository) mapDeviceToSolarDeviceView(
 func (r *solarRepository) GetAllMySolarDevices(
 	ctx context.Context,
 	userID uint,
-) (*[]dto.SolarDeviceView, error) {
+) (_ *[]dto.SolarDeviceView, err error) {
+	ctx, span := tracing.Start(ctx, "SolarRepository.GetAllMySolarDevices")
+	defer func() { tracing.End(span, err) }()
+
 	devices, err := r.deviceRepo.GetDevicesByHardwareTypeAndUserID(
 		ctx, model.HardwareTypeSolar,
 		userID,
@@ -2,11 +2,16 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"github.com/aruncs31s/skvms/internal/model"
 	"gorm.io/gorm"
 )
 
+// maxVersionChain bounds the walk over PreviousVersionID, so a cycle in the
+// version data cannot hang a request.
+const maxVersionChain = 100
+
 type VersionRepository interface {
 	CreateVersion(ctx context.Context, version *model.Version) error
 	GetAllVersions(ctx context.Context) ([]model.Version, error)
@@ -14,6 +19,7 @@ type VersionRepository interface {
 	UpdateVersion(ctx context.Context, version *model.Version) error
 	DeleteVersion(ctx context.Context, id uint) error
 	CreateFeature(ctx context.Context, feature *model.Feature) error
+	GetFeature(ctx context.Context, id uint) (*model.Feature, error)
 	GetFeaturesByVersion(ctx context.Context, versionID uint) ([]model.Feature, error)
 	UpdateFeature(ctx context.Context, feature *model.Feature) error
 	DeleteFeature(ctx context.Context, id uint) error
@@ -25,6 +31,12 @@ type VersionRepository interface {
 		features []int,
 	) (*model.Version, error)
 	AssociateFeatureWithVersion(ctx context.Context, versionID, featureID uint) error
+	// ListDeviceLineage returns the versions made for the device, its current
+	// version and all their ancestors.
+	ListDeviceLineage(ctx context.Context, deviceID uint) ([]model.Version, error)
+	// ListFeatureStates returns the features of the given versions with the
+	// state each version shipped them with.
+	ListFeatureStates(ctx context.Context, versionIDs []uint) ([]model.VersionFeatureState, error)
 }
 
 type versionRepository struct {
@@ -66,6 +78,14 @@ func (r *versionRepository) CreateFeature(ctx context.Context, feature *model.Fe
 	return r.db.WithContext(ctx).Create(feature).Error
 }
 
+func (r *versionRepository) GetFeature(ctx context.Context, id uint) (*model.Feature, error) {
+	var feature model.Feature
+	if err := r.db.WithContext(ctx).First(&feature, id).Error; err != nil {
+		return nil, err
+	}
+	return &feature, nil
+}
+
 func (r *versionRepository) GetFeaturesByVersion(ctx context.Context, versionID uint) ([]model.Feature, error) {
 	var version model.Version
 	err := r.db.WithContext(ctx).Preload("Features").First(&version, versionID).Error
@@ -95,18 +115,35 @@ func (r *versionRepository) GetVersionByDeviceID(ctx context.Context, deviceID u
 	return &version, nil
 }
 
+// GetCurrnetAllPreviousVersions returns the device's assigned version followed
+// by its previous versions, newest first, with their features.
 func (r *versionRepository) GetCurrnetAllPreviousVersions(ctx context.Context, deviceID uint) ([]model.Version, error) {
-	var versions []model.Version
+	var device model.Device
 	err := r.db.WithContext(ctx).
-		Joins("JOIN devices ON devices.version_id = versions.id").
-		Where("devices.id = ?", deviceID).
-		Or("versions.id IN (?)",
-			r.db.Table("devices").Select("version_id").Where("devices.id = ?", deviceID),
-		).
-		Find(&versions).Error
+		Select("id", "version_id").
+		First(&device, deviceID).Error
 	if err != nil {
 		return nil, err
 	}
+
+	var versions []model.Version
+	seen := make(map[uint]bool)
+	next := device.VersionID
+	for next != nil && !seen[*next] && len(versions) < maxVersionChain {
+		var version model.Version
+		err := r.db.WithContext(ctx).
+			Preload("Features").
+			First(&version, *next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		seen[version.ID] = true
+		versions = append(versions, version)
+		next = version.PreviousVersionID
+	}
 	return versions, nil
 }
 
@@ -135,6 +172,9 @@ func (r *versionRepository) CreateNewDeviceVersion(
 		if err := tx.Model(&version).Association("Features").Append(allFeatures); err != nil {
 			return err
 		}
+		if err := snapshotFeatureStates(tx, version.ID); err != nil {
+			return err
+		}
 
 		if err := tx.Model(&model.Device{}).
 			Where("id = ?", version.DeviceID).
@@ -159,5 +199,81 @@ func (r *versionRepository) AssociateFeatureWithVersion(ctx context.Context, ver
 	if err := r.db.WithContext(ctx).First(&feature, featureID).Error; err != nil {
 		return err
 	}
-	return r.db.WithContext(ctx).Model(&version).Association("Features").Append(&feature)
+	if err := r.db.WithContext(ctx).Model(&version).Association("Features").Append(&feature); err != nil {
+		return err
+	}
+	return snapshotFeatureStates(r.db.WithContext(ctx), versionID)
+}
+
+// snapshotFeatureStates records the current state of the version's features
+// that have none recorded yet.
+func snapshotFeatureStates(tx *gorm.DB, versionID uint) error {
+	return tx.Exec(`UPDATE version_features
+		SET enabled = (SELECT f.enabled FROM features f WHERE f.id = version_features.feature_id)
+		WHERE version_id = ? AND enabled IS NULL`, versionID).Error
+}
+
+func (r *versionRepository) ListDeviceLineage(ctx context.Context, deviceID uint) ([]model.Version, error) {
+	var device model.Device
+	err := r.db.WithContext(ctx).
+		Select("id", "version_id").
+		First(&device, deviceID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []model.Version
+	query := r.db.WithContext(ctx).
+		Where("device_id = ?", deviceID)
+	if device.VersionID != nil {
+		query = query.Or("id = ?", *device.VersionID)
+	}
+	if err := query.Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	// Pull in ancestors until every previous version is loaded
+	loaded := make(map[uint]bool, len(versions))
+	for _, v := range versions {
+		loaded[v.ID] = true
+	}
+	for depth := 0; depth < maxVersionChain; depth++ {
+		var missing []uint
+		for _, v := range versions {
+			if v.PreviousVersionID != nil && !loaded[*v.PreviousVersionID] {
+				missing = append(missing, *v.PreviousVersionID)
+				loaded[*v.PreviousVersionID] = true
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+		var ancestors []model.Version
+		err := r.db.WithContext(ctx).
+			Where("id IN ?", missing).
+			Find(&ancestors).Error
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, ancestors...)
+	}
+	return versions, nil
+}
+
+func (r *versionRepository) ListFeatureStates(ctx context.Context, versionIDs []uint) ([]model.VersionFeatureState, error) {
+	var states []model.VersionFeatureState
+	if len(versionIDs) == 0 {
+		return states, nil
+	}
+	err := r.db.WithContext(ctx).
+		Table("version_features vf").
+		Select("vf.version_id, vf.feature_id, f.feature_name, COALESCE(vf.enabled, f.enabled) AS enabled").
+		Joins("JOIN features f ON f.id = vf.feature_id").
+		Where("vf.version_id IN ?", versionIDs).
+		Order("f.feature_name").
+		Scan(&states).Error
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
 }
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aruncs31s/skvms/internal/model"
+	"gorm.io/gorm"
+)
+
+type WiFiProfileRepository interface {
+	ListByLocation(
+		ctx context.Context,
+		locationID uint,
+	) ([]model.WiFiProfile, error)
+	GetByID(
+		ctx context.Context,
+		id uint,
+	) (*model.WiFiProfile, error)
+	Create(
+		ctx context.Context,
+		profile *model.WiFiProfile,
+	) error
+	Update(
+		ctx context.Context,
+		profile *model.WiFiProfile,
+	) error
+	Delete(
+		ctx context.Context,
+		id uint,
+	) error
+}
+
+type wifiProfileRepository struct {
+	db *gorm.DB
+}
+
+func NewWiFiProfileRepository(db *gorm.DB) WiFiProfileRepository {
+	return &wifiProfileRepository{db: db}
+}
+
+func (r *wifiProfileRepository) ListByLocation(
+	ctx context.Context,
+	locationID uint,
+) ([]model.WiFiProfile, error) {
+	var profiles []model.WiFiProfile
+	err := r.db.WithContext(ctx).
+		Where("location_id = ?", locationID).
+		Order("name").
+		Find(&profiles).Error
+	if err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func (r *wifiProfileRepository) GetByID(
+	ctx context.Context,
+	id uint,
+) (*model.WiFiProfile, error) {
+	var profile model.WiFiProfile
+	if err := r.db.WithContext(ctx).First(&profile, id).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (r *wifiProfileRepository) Create(
+	ctx context.Context,
+	profile *model.WiFiProfile,
+) error {
+	return r.db.WithContext(ctx).Create(profile).Error
+}
+
+func (r *wifiProfileRepository) Update(
+	ctx context.Context,
+	profile *model.WiFiProfile,
+) error {
+	return r.db.WithContext(ctx).Save(profile).Error
+}
+
+func (r *wifiProfileRepository) Delete(
+	ctx context.Context,
+	id uint,
+) error {
+	return r.db.WithContext(ctx).Delete(&model.WiFiProfile{}, id).Error
+}
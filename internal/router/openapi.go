@@ -0,0 +1,379 @@
+package router
+
+import (
+	"net/http"
+
+	codegendto "github.com/aruncs31s/skvms/internal/codegen/dto"
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/openapi"
+)
+
+// Paths of the API document and its docs page.
+const (
+	openAPIPath = "/api/openapi.json"
+	docsPath    = "/api/docs"
+)
+
+const (
+	tagAuth          = "Auth"
+	tagDevices       = "Devices"
+	tagDeviceTypes   = "Device types"
+	tagDeviceStates  = "Device states"
+	tagReadings      = "Readings"
+	tagSensors       = "Sensors"
+	tagSolar         = "Solar"
+	tagUsers         = "Users"
+	tagAudit         = "Audit"
+	tagVersions      = "Versions"
+	tagFirmware      = "Firmware"
+	tagFeatureFlags  = "Feature flags"
+	tagLocations     = "Locations"
+	tagWiFiProfiles  = "WiFi profiles"
+	tagCodegen       = "Codegen"
+	tagExport        = "Export"
+	tagTemplates     = "Export templates"
+	tagReports       = "Reports"
+	tagImport        = "Import"
+	tagAdmin         = "Admin"
+	tagOperations    = "Operations"
+	exportFileFormat = "csv, xlsx, xml, pdf, parquet, ndjson or influx"
+)
+
+// Parameters shared by several routes.
+var (
+	qLimit      = openapi.Query("limit", "integer", "Maximum number of results")
+	qOffset     = openapi.Query("offset", "integer", "Number of results to skip")
+	qSearch     = openapi.Query("q", "string", "Text to search for").Require()
+	qStartDate  = openapi.Query("start_date", "string", "First day, 2006-01-02").As("date")
+	qEndDate    = openapi.Query("end_date", "string", "Last day, 2006-01-02").As("date")
+	qTemplate   = openapi.Query("template", "integer", "Library template ID for PDF, the default template of the data type when omitted")
+	qFileFormat = openapi.Query("format", "string", "Output format: "+exportFileFormat).
+			OneOf("csv", "xlsx", "xml", "pdf", "parquet", "ndjson", "influx")
+	pBuildID = openapi.PathParam("build_id", "string", "Build ID returned by a build")
+
+	exportFiles = []string{
+		"text/csv",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/xml",
+		"application/pdf",
+		"application/vnd.apache.parquet",
+		"application/x-ndjson",
+		"text/plain",
+	}
+
+	summaryParams = []openapi.Param{
+		openapi.Query("start_date", "string", "Start of the period, 2006-01-02, today by default").As("date"),
+		openapi.Query("end_date", "string", "Last day of the period, 2006-01-02, today by default").As("date"),
+		openapi.Query("format", "string", "pdf (default) or json").OneOf("pdf", "json"),
+		qTemplate,
+		openapi.Query("min_voltage", "number", "Alert below this voltage"),
+		openapi.Query("max_voltage", "number", "Alert above this voltage"),
+		openapi.Query("max_current", "number", "Alert above this current"),
+		openapi.Query("offline_after", "integer", "Minutes without readings that count as offline, 15 by default"),
+	}
+
+	auditFilterParams = []openapi.Param{
+		openapi.Query("user_id", "integer", ""),
+		openapi.Query("username", "string", ""),
+		openapi.Query("action", "string", ""),
+		openapi.Query("resource_type", "string", ""),
+		openapi.Query("resource_id", "string", ""),
+		openapi.Query("ip", "string", "Client IP address"),
+		openapi.Query("status", "string", ""),
+		openapi.Query("request_id", "string", ""),
+		openapi.Query("from", "string", "RFC 3339 time, or a 2006-01-02 day"),
+		openapi.Query("to", "string", "RFC 3339 time, or a 2006-01-02 day included whole"),
+	}
+)
+
+// Spec documents every route of the router. Request types are the ones the
+// handlers bind, so the document follows them; TestSpecCoversRoutes fails
+// when a route is added without documenting it here.
+func Spec() *openapi.Spec {
+	spec := openapi.New(openapi.Info{
+		Title:       "SKVMS API",
+		Description: "Solar battery monitoring: devices post readings with a device token, users manage devices, exports and firmware with an access token.",
+		Version:     "1.0.0",
+	},
+		openapi.Tag{Name: tagAuth, Description: "Logging in and device tokens"},
+		openapi.Tag{Name: tagDevices},
+		openapi.Tag{Name: tagReadings, Description: "Readings posted by devices and their history"},
+		openapi.Tag{Name: tagDeviceTypes},
+		openapi.Tag{Name: tagDeviceStates},
+		openapi.Tag{Name: tagSensors},
+		openapi.Tag{Name: tagSolar},
+		openapi.Tag{Name: tagLocations},
+		openapi.Tag{Name: tagWiFiProfiles, Description: "WiFi credentials stored per location for firmware builds, available to admins and the users of the location"},
+		openapi.Tag{Name: tagVersions, Description: "Firmware versions and their features"},
+		openapi.Tag{Name: tagFirmware, Description: "Firmware reported by devices and drift from their expected version"},
+		openapi.Tag{Name: tagFeatureFlags},
+		openapi.Tag{Name: tagCodegen, Description: "Firmware configuration, builds and OTA uploads"},
+		openapi.Tag{Name: tagExport},
+		openapi.Tag{Name: tagTemplates, Description: "Library of versioned PDF templates"},
+		openapi.Tag{Name: tagReports, Description: "Scheduled reports and their runs"},
+		openapi.Tag{Name: tagImport},
+		openapi.Tag{Name: tagUsers},
+		openapi.Tag{Name: tagAudit, Description: "Hash chained audit log and its signed checkpoints"},
+		openapi.Tag{Name: tagAdmin},
+		openapi.Tag{Name: tagOperations, Description: "Probes, metrics and this document"},
+	)
+
+	user, device, admin := openapi.UserAuth, openapi.DeviceAuth, openapi.AdminAuth
+	spec.Add(
+		// Operations
+		openapi.Route{Method: http.MethodGet, Path: "/metrics", Tag: tagOperations, Summary: "Prometheus metrics", Produces: []string{"text/plain"}},
+		openapi.Route{Method: http.MethodGet, Path: "/healthz", Tag: tagOperations, Summary: "Liveness probe"},
+		openapi.Route{Method: http.MethodGet, Path: "/readyz", Tag: tagOperations, Summary: "Readiness probe, 503 while a required check fails"},
+		openapi.Route{Method: http.MethodGet, Path: openAPIPath, Tag: tagOperations, Summary: "This OpenAPI document"},
+		openapi.Route{Method: http.MethodGet, Path: docsPath, Tag: tagOperations, Summary: "Docs page of this document", Produces: []string{"text/html"}},
+
+		// Auth
+		openapi.Route{Method: http.MethodPost, Path: "/api/login", Tag: tagAuth, Summary: "Log in, returning an access and a refresh token", Body: dto.LoginRequest{}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/register", Tag: tagAuth, Summary: "Register a user", Body: dto.CreateUserRequest{}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/refresh", Tag: tagAuth, Summary: "Exchange a refresh token for new tokens", Body: dto.RefreshTokenRequest{}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/device-auth/token", Tag: tagAuth, Summary: "Issue a device token to post readings with", Auth: user, Body: dto.DeviceTokenRequest{}},
+
+		// Devices
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices", Tag: tagDevices, Summary: "List devices"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/recent", Tag: tagDevices, Summary: "List recently created devices"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/my", Tag: tagDevices, Summary: "List the devices of the user", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id", Tag: tagDevices, Summary: "Get a device"},
+		openapi.Route{Method: http.MethodPost, Path: "/api/devices", Tag: tagDevices, Summary: "Create a device", Auth: user, Body: dto.CreateDeviceRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodPut, Path: "/api/devices/:id", Tag: tagDevices, Summary: "Update some fields of a device", Auth: user, Body: dto.UpdateDeviceRequest{}},
+		openapi.Route{Method: http.MethodPut, Path: "/api/devices/:id/full", Tag: tagDevices, Summary: "Update a device with its details", Auth: user, Body: dto.FullUpdateDeviceRequest{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/devices/:id", Tag: tagDevices, Summary: "Delete a device", Auth: user},
+		openapi.Route{Method: http.MethodPost, Path: "/api/devices/:id/control", Tag: tagDevices, Summary: "Send an action to a device", Auth: user, Body: dto.ControlRequest{}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/connected", Tag: tagDevices, Summary: "List the devices connected to a device"},
+		openapi.Route{Method: http.MethodPost, Path: "/api/devices/:id/connected", Tag: tagDevices, Summary: "Connect an existing device", Auth: user, Body: dto.AddConnectedDeviceRequest{}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/devices/:id/connected/new", Tag: tagDevices, Summary: "Create a device connected to a device", Auth: user, Body: dto.CreateConnectedDeviceRequest{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/devices/:id/connected/:cid", Tag: tagDevices, Summary: "Disconnect a device", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/search", Tag: tagDevices, Summary: "Search devices", Params: []openapi.Param{qSearch}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/search/microcontrollers", Tag: tagDevices, Summary: "Search microcontrollers", Params: []openapi.Param{qSearch}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/search/sensors", Tag: tagDevices, Summary: "Search sensors", Params: []openapi.Param{qSearch}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/microcontrollers", Tag: tagDevices, Summary: "List microcontrollers"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/ba", Tag: tagDevices, Summary: "Microcontroller statistics"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/versions", Tag: tagVersions, Summary: "List the versions of a device"},
+		openapi.Route{Method: http.MethodPost, Path: "/api/devices/:id/versions", Tag: tagVersions, Summary: "Release a new version of a device", Body: dto.CreateDeviceVersionRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/versions/lineage", Tag: tagVersions, Summary: "Version graph of a device"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/versions/changelog", Tag: tagVersions, Summary: "Changelog of the current version chain of a device"},
+
+		// Readings
+		openapi.Route{Method: http.MethodPost, Path: "/api/readings", Tag: tagReadings, Summary: "Post a reading as the device of the token", Auth: device, Body: dto.EssentialReadingRequest{}, Status: http.StatusCreated, Response: model.Reading{}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/readings", Tag: tagReadings, Summary: "Today's readings of a device and the latest one",
+			Params: []openapi.Param{openapi.Query("limit", "integer", "Maximum number of readings, 50 by default")}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/readings/range", Tag: tagReadings, Summary: "Readings of a device between two days",
+			Params: []openapi.Param{qStartDate.Require(), qEndDate.Require()}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/readings/progressive", Tag: tagReadings, Summary: "Running averages of the readings of a device"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/readings/interval", Tag: tagReadings, Summary: "Readings of a device sampled at an interval",
+			Params: []openapi.Param{
+				qStartDate.Require(),
+				qEndDate.Require(),
+				openapi.Query("interval", "string", "Go duration between samples, 1h by default"),
+				openapi.Query("count", "integer", "Number of samples, 24 by default"),
+			}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/connected/:cid/readings", Tag: tagReadings, Summary: "Readings of a connected device, today by default",
+			Params: []openapi.Param{qStartDate, qEndDate}},
+
+		// Device types
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/types", Tag: tagDeviceTypes, Summary: "List device types", Params: []openapi.Param{qLimit, qOffset}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/device-types", Tag: tagDeviceTypes, Summary: "List device types", Params: []openapi.Param{qLimit, qOffset}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/devices/types", Tag: tagDeviceTypes, Summary: "Create a device type", Auth: user, Body: dto.CreateDeviceTypeRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/type", Tag: tagDeviceTypes, Summary: "Get the type of a device"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/types/hardware", Tag: tagDeviceTypes, Summary: "List hardware types", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/types/sensors", Tag: tagDeviceTypes, Summary: "List sensor types"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/device-types/board-profiles", Tag: tagDeviceTypes, Summary: "List the board profiles firmware is built for"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/device-types/:id/board-profile", Tag: tagDeviceTypes, Summary: "Get the board profile of a device type"},
+		openapi.Route{Method: http.MethodPut, Path: "/api/device-types/:id/board-profile", Tag: tagDeviceTypes, Summary: "Set the board profile of a device type", Auth: user, Body: dto.BoardProfileRequest{}},
+
+		// Device states
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/states", Tag: tagDeviceStates, Summary: "List device states"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/states/:id", Tag: tagDeviceStates, Summary: "Get a device state"},
+		openapi.Route{Method: http.MethodPost, Path: "/api/devices/states", Tag: tagDeviceStates, Summary: "Create a device state", Auth: user, Body: dto.CreateDeviceStateRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodPut, Path: "/api/devices/states/:id", Tag: tagDeviceStates, Summary: "Update a device state", Auth: user, Body: dto.UpdateDeviceStateRequest{}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/states/history", Tag: tagDeviceStates, Summary: "State changes of a device", Auth: user,
+			Params: []openapi.Param{
+				openapi.Query("from_date", "string", "First day, 2006-01-02").As("date"),
+				openapi.Query("to_date", "string", "Last day, 2006-01-02").As("date"),
+				openapi.Query("states", "integer", "Only changes to these states, repeated"),
+			}},
+
+		// Sensors
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/sensors", Tag: tagSensors, Summary: "List sensors"},
+		openapi.Route{Method: http.MethodPost, Path: "/api/devices/sensors", Tag: tagSensors, Summary: "Create a sensor", Body: dto.CreateDeviceRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/sensors/:id", Tag: tagSensors, Summary: "Get a sensor"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/sensors/:id/connected", Tag: tagSensors, Summary: "List the devices connected to a sensor"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/sensors/search", Tag: tagSensors, Summary: "Search sensors", Params: []openapi.Param{qSearch}},
+
+		// Solar
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/solar", Tag: tagSolar, Summary: "List solar devices", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/solar/my", Tag: tagSolar, Summary: "List the solar devices of the user", Auth: user},
+		openapi.Route{Method: http.MethodPost, Path: "/api/devices/solar", Tag: tagSolar, Summary: "Create a solar device", Auth: user, Body: dto.CreateSolarDeviceDTO{}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/solar/count", Tag: tagSolar, Summary: "Count devices", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/solar/offline", Tag: tagSolar, Summary: "List offline devices", Auth: user},
+
+		// Locations
+		openapi.Route{Method: http.MethodGet, Path: "/api/locations", Tag: tagLocations, Summary: "List locations"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/locations/:id", Tag: tagLocations, Summary: "Get a location"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/locations/search", Tag: tagLocations, Summary: "Search locations by name or code",
+			Params: []openapi.Param{openapi.Query("q", "string", "Text to search for")}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/locations", Tag: tagLocations, Summary: "Create a location", Auth: user, Body: dto.CreateLocationRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodPut, Path: "/api/locations/:id", Tag: tagLocations, Summary: "Update a location", Auth: user, Body: dto.UpdateLocationRequest{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/locations/:id", Tag: tagLocations, Summary: "Delete a location", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/locations/:id/devices", Tag: tagLocations, Summary: "List the devices at a location"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/locations/:id/readings/seven", Tag: tagLocations, Summary: "Daily readings of a location over the last seven days"},
+
+		// WiFi profiles
+		openapi.Route{Method: http.MethodGet, Path: "/api/locations/:id/wifi-profiles", Tag: tagWiFiProfiles, Summary: "List the WiFi profiles of a location", Auth: user},
+		openapi.Route{Method: http.MethodPost, Path: "/api/locations/:id/wifi-profiles", Tag: tagWiFiProfiles, Summary: "Add a WiFi profile to a location", Auth: user, Body: dto.CreateWiFiProfileRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodPut, Path: "/api/wifi-profiles/:id", Tag: tagWiFiProfiles, Summary: "Update a WiFi profile", Auth: user, Body: dto.UpdateWiFiProfileRequest{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/wifi-profiles/:id", Tag: tagWiFiProfiles, Summary: "Delete a WiFi profile", Auth: user},
+
+		// Versions
+		openapi.Route{Method: http.MethodGet, Path: "/api/versions", Tag: tagVersions, Summary: "List versions"},
+		openapi.Route{Method: http.MethodPost, Path: "/api/versions", Tag: tagVersions, Summary: "Create a version", Auth: user, Body: dto.CreateVersionRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodGet, Path: "/api/versions/diff", Tag: tagVersions, Summary: "Feature changes between two versions",
+			Params: []openapi.Param{
+				openapi.Query("from", "integer", "Version ID").Require(),
+				openapi.Query("to", "integer", "Version ID").Require(),
+			}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/versions/:id", Tag: tagVersions, Summary: "Get a version", Auth: user},
+		openapi.Route{Method: http.MethodPut, Path: "/api/versions/:id", Tag: tagVersions, Summary: "Rename a version", Auth: user, Body: dto.UpdateVersionRequest{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/versions/:id", Tag: tagVersions, Summary: "Delete a version", Auth: user},
+		openapi.Route{Method: http.MethodPost, Path: "/api/features", Tag: tagVersions, Summary: "Add a feature to a version", Auth: user, Body: dto.CreateFeatureRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodGet, Path: "/api/features/version/:verid", Tag: tagVersions, Summary: "List the features of a version", Auth: user},
+		openapi.Route{Method: http.MethodPut, Path: "/api/features/:id", Tag: tagVersions, Summary: "Update a feature", Auth: user, Body: dto.UpdateFeatureRequest{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/features/:id", Tag: tagVersions, Summary: "Delete a feature", Auth: user},
+
+		// Firmware
+		openapi.Route{Method: http.MethodPost, Path: "/api/devices/firmware", Tag: tagFirmware, Summary: "Report the firmware running on the device of the token", Auth: device, Body: dto.FirmwareReportRequest{}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/firmware/drift", Tag: tagFirmware, Summary: "Devices not running their expected version", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/firmware", Tag: tagFirmware, Summary: "Firmware reported by a device", Auth: user},
+
+		// Feature flags
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/flags", Tag: tagFeatureFlags, Summary: "Feature flags of the device of the token", Auth: device},
+		openapi.Route{Method: http.MethodGet, Path: "/api/device/:id/features", Tag: tagFeatureFlags, Summary: "Effective feature flags of a device", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/devices/:id/feature-overrides", Tag: tagFeatureFlags, Summary: "List the overrides of a device", Auth: user},
+		openapi.Route{Method: http.MethodPut, Path: "/api/devices/:id/feature-overrides/:fid", Tag: tagFeatureFlags, Summary: "Override a feature for a device", Description: "Admins, the owner of the device and the users of its location.", Auth: user, Body: dto.FeatureOverrideRequest{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/devices/:id/feature-overrides/:fid", Tag: tagFeatureFlags, Summary: "Clear the override of a feature for a device", Description: "Admins, the owner of the device and the users of its location.", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/locations/:id/feature-overrides", Tag: tagFeatureFlags, Summary: "List the overrides of a location", Auth: user},
+		openapi.Route{Method: http.MethodPut, Path: "/api/locations/:id/feature-overrides/:fid", Tag: tagFeatureFlags, Summary: "Override a feature for a location", Description: "Admins and the users of the location.", Auth: user, Body: dto.FeatureOverrideRequest{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/locations/:id/feature-overrides/:fid", Tag: tagFeatureFlags, Summary: "Clear the override of a feature for a location", Description: "Admins and the users of the location.", Auth: user},
+
+		// Codegen
+		openapi.Route{Method: http.MethodGet, Path: "/api/codegen/tools", Tag: tagCodegen, Summary: "List the installed build tools", Response: codegendto.ToolStatusResponse{}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/codegen/schema", Tag: tagCodegen, Summary: "Config schema of the firmware source", Auth: user},
+		openapi.Route{Method: http.MethodPost, Path: "/api/codegen/generate", Tag: tagCodegen, Summary: "Generate and build firmware", Auth: user, Body: codegendto.CodeGenRequest{}, Response: codegendto.CodeGenResponse{}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/codegen/build", Tag: tagCodegen, Summary: "Build firmware and return its download URL", Auth: user, Body: codegendto.CodeGenRequest{}, Response: codegendto.CodeGenResponse{}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/codegen/build-multi", Tag: tagCodegen, Summary: "Build firmware for several device types", Auth: user, Body: codegendto.CodeGenRequest{}, Response: codegendto.MultiBuildResponse{}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/codegen/build-and-download", Tag: tagCodegen, Summary: "Build firmware and download it", Auth: user, Body: codegendto.CodeGenRequest{}, Produces: []string{"application/octet-stream"}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/codegen/download/:build_id", Tag: tagCodegen, Summary: "Download built firmware", Auth: user, Params: []openapi.Param{pBuildID}, Produces: []string{"application/octet-stream"}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/codegen/upload", Tag: tagCodegen, Summary: "Build firmware and upload it to a device over the air", Auth: user, Body: struct {
+			codegendto.CodeGenRequest
+			DeviceIP string `json:"device_ip" binding:"required"`
+		}{}, Response: codegendto.UploadResponse{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/codegen/builds/:build_id", Tag: tagCodegen, Summary: "Delete the artifacts of a build", Auth: user, Params: []openapi.Param{pBuildID}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/codegen/cache", Tag: tagCodegen, Summary: "Build cache statistics", Auth: user},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/codegen/cache", Tag: tagCodegen, Summary: "Purge the build cache", Auth: admin},
+
+		// Export
+		openapi.Route{Method: http.MethodGet, Path: "/api/export/formats", Tag: tagExport, Summary: "List the export formats and the unavailable ones"},
+		openapi.Route{Method: http.MethodGet, Path: "/api/export/renderer", Tag: tagExport, Summary: "Health of the PDF renderer", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/export/readings", Tag: tagExport, Summary: "Export readings, streamed", Auth: user,
+			Description: "At least one of device_id, device_ids and location_id is required.",
+			Params: []openapi.Param{
+				qFileFormat.Require(),
+				openapi.Query("device_id", "integer", ""),
+				openapi.Query("device_ids", "string", "Comma separated device IDs"),
+				openapi.Query("location_id", "integer", "Every device at a location"),
+				qStartDate,
+				qEndDate,
+				qTemplate,
+			},
+			Produces: exportFiles},
+		openapi.Route{Method: http.MethodGet, Path: "/api/export/devices", Tag: tagExport, Summary: "Export all devices", Auth: user,
+			Params: []openapi.Param{qFileFormat.Require(), qTemplate}, Produces: exportFiles},
+		openapi.Route{Method: http.MethodGet, Path: "/api/export/reports/device/:id", Tag: tagExport, Summary: "Report of a device with charts and alerts", Auth: user,
+			Params: summaryParams, Produces: []string{"application/pdf"}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/export/reports/location/:id", Tag: tagExport, Summary: "Report of the devices at a location", Auth: user,
+			Params: summaryParams, Produces: []string{"application/pdf"}},
+
+		// Export templates
+		openapi.Route{Method: http.MethodGet, Path: "/api/export/templates", Tag: tagTemplates, Summary: "List templates", Auth: user,
+			Params: []openapi.Param{openapi.Query("data_type", "string", "Only templates of this data type")}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/export/templates", Tag: tagTemplates, Summary: "Create a template", Auth: user, Body: dto.ExportTemplateRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodPost, Path: "/api/export/templates/preview", Tag: tagTemplates, Summary: "Render a template with sample data", Auth: user,
+			Body:     dto.TemplatePreviewRequest{},
+			Params:   []openapi.Param{openapi.Query("format", "string", "html (default) or pdf").OneOf("html", "pdf")},
+			Produces: []string{"text/html", "application/pdf"}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/export/templates/:id", Tag: tagTemplates, Summary: "Get a template", Auth: user},
+		openapi.Route{Method: http.MethodPut, Path: "/api/export/templates/:id", Tag: tagTemplates, Summary: "Update the details of a template", Auth: user, Body: dto.UpdateExportTemplateRequest{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/export/templates/:id", Tag: tagTemplates, Summary: "Delete a template", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/export/templates/:id/versions", Tag: tagTemplates, Summary: "List the versions of a template", Auth: user},
+		openapi.Route{Method: http.MethodPost, Path: "/api/export/templates/:id/versions", Tag: tagTemplates, Summary: "Upload a new version of a template", Auth: user, Body: dto.ExportTemplateVersionRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodGet, Path: "/api/export/templates/:id/versions/:version", Tag: tagTemplates, Summary: "Get a version of a template", Auth: user},
+		openapi.Route{Method: http.MethodPut, Path: "/api/export/templates/:id/default", Tag: tagTemplates, Summary: "Make a template the default of its data type", Auth: admin},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/export/templates/:id/default", Tag: tagTemplates, Summary: "Stop using a template as the default", Auth: admin},
+
+		// Reports
+		openapi.Route{Method: http.MethodGet, Path: "/api/reports", Tag: tagReports, Summary: "List scheduled reports", Auth: user},
+		openapi.Route{Method: http.MethodPost, Path: "/api/reports", Tag: tagReports, Summary: "Schedule a report", Auth: user, Body: dto.ReportDefinitionRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodGet, Path: "/api/reports/:id", Tag: tagReports, Summary: "Get a report", Auth: user},
+		openapi.Route{Method: http.MethodPut, Path: "/api/reports/:id", Tag: tagReports, Summary: "Update a report", Auth: user, Body: dto.ReportDefinitionRequest{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/reports/:id", Tag: tagReports, Summary: "Delete a report", Auth: user},
+		openapi.Route{Method: http.MethodPost, Path: "/api/reports/:id/run", Tag: tagReports, Summary: "Render and deliver a report now", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/reports/:id/runs", Tag: tagReports, Summary: "List the runs of a report", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/reports/runs/:run_id/download", Tag: tagReports, Summary: "Download the file of a run", Auth: user, Produces: exportFiles},
+
+		// Import
+		openapi.Route{Method: http.MethodGet, Path: "/api/import/fields", Tag: tagImport, Summary: "List the fields of a data type", Auth: user,
+			Params: []openapi.Param{openapi.Query("data_type", "string", "").Require().OneOf("readings", "devices")}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/import", Tag: tagImport, Summary: "Import readings or devices from a file", Auth: user, Status: http.StatusCreated,
+			Description: "Readings are only imported into devices the user owns, unless the user is an admin. A dry run answers 200 with the row errors and a preview. A commit answers 201 with the import job, or 422 with the row errors when rows are invalid.",
+			Form: []openapi.Param{
+				openapi.FormField("file", "file", "The file to import").Require(),
+				openapi.FormField("data_type", "string", "").Require().OneOf("readings", "devices"),
+				openapi.FormField("format", "string", "Inferred from the file name when omitted").OneOf("csv", "xlsx", "json"),
+				openapi.FormField("mapping", "string", "JSON object of field name to column name"),
+				openapi.FormField("sheet", "string", "XLSX worksheet, the first one by default"),
+				openapi.FormField("dry_run", "boolean", "Validate and preview without saving"),
+				openapi.FormField("skip_invalid", "boolean", "Import the valid rows of a file with invalid rows"),
+			}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/import/jobs", Tag: tagImport, Summary: "List import jobs", Auth: user, Params: []openapi.Param{qLimit, qOffset}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/import/jobs/:id", Tag: tagImport, Summary: "Get an import job", Auth: user},
+		openapi.Route{Method: http.MethodPost, Path: "/api/import/jobs/:id/rollback", Tag: tagImport, Summary: "Delete what an import job created", Description: "Admins and the user who ran the import only.", Auth: user},
+
+		// Users
+		openapi.Route{Method: http.MethodGet, Path: "/api/users", Tag: tagUsers, Summary: "List users", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/users/:id", Tag: tagUsers, Summary: "Get a user", Auth: user},
+		openapi.Route{Method: http.MethodGet, Path: "/api/profile", Tag: tagUsers, Summary: "Get the logged in user", Auth: user},
+		openapi.Route{Method: http.MethodPost, Path: "/api/users", Tag: tagUsers, Summary: "Create a user", Body: dto.CreateUserRequest{}, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodPut, Path: "/api/users/:id", Tag: tagUsers, Summary: "Update a user", Auth: user, Body: dto.UpdateUserRequest{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/api/users/:id", Tag: tagUsers, Summary: "Delete a user", Auth: user},
+
+		// Audit
+		openapi.Route{Method: http.MethodGet, Path: "/api/audit", Tag: tagAudit, Summary: "Search the audit log, newest first", Auth: user,
+			Params: append(auditFilterParams,
+				openapi.Query("cursor", "integer", "Entries older than the entry with this ID"),
+				qLimit,
+			)},
+		openapi.Route{Method: http.MethodGet, Path: "/api/audit/export", Tag: tagAudit, Summary: "Export the audit log", Auth: admin,
+			Params: append(auditFilterParams,
+				openapi.Query("format", "string", "csv (default), xlsx, xml, parquet, ndjson or influx").
+					OneOf("csv", "xlsx", "xml", "parquet", "ndjson", "influx"),
+			),
+			Produces: exportFiles},
+		openapi.Route{Method: http.MethodGet, Path: "/api/audit/verify", Tag: tagAudit, Summary: "Verify the hash chain of the audit log", Auth: user,
+			Params: []openapi.Param{
+				openapi.Query("from_seq", "integer", "First entry to verify, 1 by default"),
+				openapi.Query("to_seq", "integer", "Last entry to verify, the end of the chain by default"),
+			}},
+		openapi.Route{Method: http.MethodGet, Path: "/api/audit/checkpoints", Tag: tagAudit, Summary: "List signed checkpoints", Auth: user, Params: []openapi.Param{qLimit}},
+		openapi.Route{Method: http.MethodPost, Path: "/api/audit/checkpoints", Tag: tagAudit, Summary: "Sign a checkpoint of the chain now", Auth: admin, Status: http.StatusCreated},
+		openapi.Route{Method: http.MethodGet, Path: "/api/audit/:id", Tag: tagAudit, Summary: "Get an audit entry", Auth: user},
+
+		// Admin
+		openapi.Route{Method: http.MethodGet, Path: "/api/admin/stats", Tag: tagAdmin, Summary: "Counts of users, devices, readings and audit entries", Auth: user},
+	)
+	return spec
+}
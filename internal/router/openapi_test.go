@@ -0,0 +1,132 @@
+package router_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/router"
+	"github.com/aruncs31s/skvms/internal/testutil"
+)
+
+func TestSpecCoversRoutes(t *testing.T) {
+	h := testutil.New(t)
+	spec := router.Spec()
+
+	registered := make(map[string]bool)
+	for _, route := range h.App.Handler.Routes() {
+		registered[route.Method+" "+route.Path] = true
+		if !spec.Documented(route.Method, route.Path) {
+			t.Errorf("%s %s is not in the API document", route.Method, route.Path)
+		}
+	}
+	for _, route := range spec.Routes() {
+		if !registered[route] {
+			t.Errorf("%s is documented but not registered", route)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	h := testutil.New(t)
+
+	doc := testutil.Expect[struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}](t, h.Do(http.MethodGet, "/api/openapi.json", nil, ""), http.StatusOK)
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q, want 3.0.3", doc.OpenAPI)
+	}
+	if doc.Paths["/api/devices/{id}/readings"] == nil {
+		t.Errorf("paths do not include /api/devices/{id}/readings")
+	}
+
+	rec := h.Do(http.MethodGet, "/api/docs", nil, "")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("docs: status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "/api/openapi.json") {
+		t.Errorf("docs page does not load /api/openapi.json")
+	}
+}
+
+func TestValidationRejectsInvalidRequests(t *testing.T) {
+	h := testutil.New(t)
+	admin := h.User().Admin().Create()
+	device := h.Device().Owner(admin).Create()
+	token := h.Login(admin.Username)
+	deviceToken := h.DeviceToken(token, device.ID)
+
+	tests := []struct {
+		name  string
+		path  string
+		body  any
+		token string
+		field string
+	}{
+		{"wrong type", "/api/readings", map[string]any{"voltage": "x"}, deviceToken, "voltage"},
+		{"missing field", "/api/readings", map[string]any{"current": 0.5}, deviceToken, "voltage"},
+		{"query parameter", "/api/devices/search", nil, "", "q"},
+		{"path parameter", "/api/devices/abc", nil, "", "id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := http.MethodGet
+			if tt.body != nil {
+				method = http.MethodPost
+			}
+			resp := testutil.Expect[dto.ErrorResponse](t, h.Do(method, tt.path, tt.body, tt.token), http.StatusBadRequest)
+			if len(resp.Fields) != 1 || resp.Fields[0].Field != tt.field {
+				t.Errorf("fields = %+v, want one for %s", resp.Fields, tt.field)
+			}
+		})
+	}
+}
+
+func TestControlRejectsUnknownAction(t *testing.T) {
+	h := testutil.New(t)
+	admin := h.User().Admin().Create()
+	device := h.Device().Owner(admin).Create()
+	token := h.Login(admin.Username)
+
+	resp := testutil.Expect[dto.ErrorResponse](t, h.Do(http.MethodPost,
+		fmt.Sprintf("/api/devices/%d/control", device.ID),
+		map[string]int{"action": 999}, token), http.StatusBadRequest)
+	// The body is valid for the API document, so the handler rejects it
+	if len(resp.Fields) != 1 || resp.Fields[0].Field != "action" || resp.Fields[0].Message != "invalid action" {
+		t.Errorf("fields = %+v, want the handler's for action", resp.Fields)
+	}
+}
+
+func TestHandlersListInvalidFields(t *testing.T) {
+	h := testutil.New(t)
+	admin := h.User().Admin().Create()
+	device := h.Device().Owner(admin).Create()
+	token := h.Login(admin.Username)
+	deviceToken := h.DeviceToken(token, device.ID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		token  string
+		want   dto.ValidationError
+	}{
+		// Present for the API document, but zero for Gin's required
+		{"zero value", http.MethodPost, "/api/readings", map[string]any{"voltage": 0}, deviceToken,
+			dto.ValidationError{In: "body", Field: "voltage", Message: "is required"}},
+		{"query value", http.MethodGet, "/api/audit?from=yesterday", nil, token,
+			dto.ValidationError{In: "query", Field: "from", Message: "must be an RFC 3339 time or a 2006-01-02 date"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := testutil.Expect[dto.ErrorResponse](t, h.Do(tt.method, tt.path, tt.body, tt.token), http.StatusBadRequest)
+			if resp.Error != "invalid request" || len(resp.Fields) != 1 || resp.Fields[0] != tt.want {
+				t.Errorf("got %+v, want invalid request with %+v", resp, tt.want)
+			}
+		})
+	}
+}
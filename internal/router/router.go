@@ -1,31 +1,45 @@
 package router
 
 import (
+	"net/http"
+
 	httpHandler "github.com/aruncs31s/skvms/internal/handler/http"
 	"github.com/aruncs31s/skvms/internal/handler/middleware"
+	"github.com/aruncs31s/skvms/internal/metrics"
+	"github.com/aruncs31s/skvms/internal/openapi"
 	"github.com/aruncs31s/skvms/internal/service"
-	"github.com/gin-contrib/cors"
+	"github.com/aruncs31s/skvms/internal/tracing"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // Router holds all the handlers and services needed for routing
 type Router struct {
-	authHandler        *httpHandler.AuthHandler
-	deviceHandler      *httpHandler.DeviceHandler
-	deviceAuthHandler  *httpHandler.DeviceAuthHandler
-	readingHandler     *httpHandler.ReadingHandler
-	auditHandler       *httpHandler.AuditHandler
-	userHandler        *httpHandler.UserHandler
-	deviceTypesHandler httpHandler.DeviceTypesHandler
-	versionHandler     *httpHandler.VersionHandler
-	deviceStateHandler *httpHandler.DeviceStateHandler
-	adminHandler       *httpHandler.AdminHandler
-	codegenHandler     *httpHandler.CodeGenHandler
-	locationHandler    *httpHandler.LocationHandler
-	exportHandler      *httpHandler.ExportHandler
-	auditService       service.AuditService
-	deviceAuthService  service.DeviceAuthService
-	jwtSecret          string
+	authHandler           *httpHandler.AuthHandler
+	deviceHandler         *httpHandler.DeviceHandler
+	deviceAuthHandler     *httpHandler.DeviceAuthHandler
+	readingHandler        *httpHandler.ReadingHandler
+	auditHandler          *httpHandler.AuditHandler
+	userHandler           *httpHandler.UserHandler
+	deviceTypesHandler    httpHandler.DeviceTypesHandler
+	versionHandler        *httpHandler.VersionHandler
+	deviceStateHandler    *httpHandler.DeviceStateHandler
+	adminHandler          *httpHandler.AdminHandler
+	codegenHandler        *httpHandler.CodeGenHandler
+	locationHandler       *httpHandler.LocationHandler
+	exportHandler         *httpHandler.ExportHandler
+	wifiProfileHandler    *httpHandler.WiFiProfileHandler
+	firmwareHandler       *httpHandler.FirmwareHandler
+	featureFlagHandler    *httpHandler.FeatureFlagHandler
+	reportHandler         *httpHandler.ReportHandler
+	exportTemplateHandler *httpHandler.ExportTemplateHandler
+	importHandler         *httpHandler.ImportHandler
+	healthHandler         *httpHandler.HealthHandler
+	auditService          service.AuditService
+	deviceAuthService     service.DeviceAuthService
+	cors                  *middleware.CORS
+	rateLimiter           *middleware.RateLimiter
+	jwtSecret             string
 }
 
 // NewRouter creates a new router instance with all handlers
@@ -43,42 +57,77 @@ func NewRouter(
 	codegenHandler *httpHandler.CodeGenHandler,
 	locationHandler *httpHandler.LocationHandler,
 	exportHandler *httpHandler.ExportHandler,
+	wifiProfileHandler *httpHandler.WiFiProfileHandler,
+	firmwareHandler *httpHandler.FirmwareHandler,
+	featureFlagHandler *httpHandler.FeatureFlagHandler,
+	reportHandler *httpHandler.ReportHandler,
+	exportTemplateHandler *httpHandler.ExportTemplateHandler,
+	importHandler *httpHandler.ImportHandler,
+	healthHandler *httpHandler.HealthHandler,
 	auditService service.AuditService,
 	deviceAuthService service.DeviceAuthService,
+	cors *middleware.CORS,
+	rateLimiter *middleware.RateLimiter,
 	jwtSecret string,
 ) *Router {
 	return &Router{
-		authHandler:        authHandler,
-		deviceHandler:      deviceHandler,
-		deviceAuthHandler:  deviceAuthHandler,
-		readingHandler:     readingHandler,
-		auditHandler:       auditHandler,
-		userHandler:        userHandler,
-		deviceTypesHandler: deviceTypesHandler,
-		versionHandler:     versionHandler,
-		deviceStateHandler: deviceStateHandler,
-		adminHandler:       adminHandler,
-		codegenHandler:     codegenHandler,
-		locationHandler:    locationHandler,
-		exportHandler:      exportHandler,
-		auditService:       auditService,
-		deviceAuthService:  deviceAuthService,
-		jwtSecret:          jwtSecret,
+		authHandler:           authHandler,
+		deviceHandler:         deviceHandler,
+		deviceAuthHandler:     deviceAuthHandler,
+		readingHandler:        readingHandler,
+		auditHandler:          auditHandler,
+		userHandler:           userHandler,
+		deviceTypesHandler:    deviceTypesHandler,
+		versionHandler:        versionHandler,
+		deviceStateHandler:    deviceStateHandler,
+		adminHandler:          adminHandler,
+		codegenHandler:        codegenHandler,
+		locationHandler:       locationHandler,
+		exportHandler:         exportHandler,
+		wifiProfileHandler:    wifiProfileHandler,
+		firmwareHandler:       firmwareHandler,
+		featureFlagHandler:    featureFlagHandler,
+		reportHandler:         reportHandler,
+		exportTemplateHandler: exportTemplateHandler,
+		importHandler:         importHandler,
+		healthHandler:         healthHandler,
+		auditService:          auditService,
+		deviceAuthService:     deviceAuthService,
+		cors:                  cors,
+		rateLimiter:           rateLimiter,
+		jwtSecret:             jwtSecret,
 	}
 }
 
+// untracedPaths are the operational endpoints left out of traces.
+var untracedPaths = map[string]bool{
+	"/metrics": true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
 // SetupRouter configures and returns the Gin router with all routes
 func (r *Router) SetupRouter() *gin.Engine {
 	router := gin.Default()
 
-	// Add CORS middleware for React frontend
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
+	// Start a span per request, continuing the trace of the caller if any.
+	// Probes and scrapes are too frequent and dull to trace.
+	router.Use(otelgin.Middleware(tracing.ServiceName, otelgin.WithFilter(func(req *http.Request) bool {
+		return !untracedPaths[req.URL.Path]
+	})))
+	// Tag every request with an ID for logs and audit entries
+	router.Use(middleware.RequestID())
+	// Count and time every request for /metrics
+	router.Use(middleware.Metrics())
+
+	// Add CORS middleware for the frontend, origins come from the config
+	router.Use(r.cors.Handler())
+
+	// Prometheus scrape endpoint
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	// Liveness and readiness probes
+	router.GET("/healthz", r.healthHandler.Live)
+	router.GET("/readyz", r.healthHandler.Ready)
 
 	// API routes
 	r.setupAPIRoutes(router)
@@ -89,12 +138,22 @@ func (r *Router) SetupRouter() *gin.Engine {
 // setupAPIRoutes configures all API routes
 func (r *Router) setupAPIRoutes(router *gin.Engine) {
 	// Initialize audit middleware
-	auditMiddleware := middleware.NewAuditMiddleware(r.auditService, r.jwtSecret)
+	auditMiddleware := middleware.NewAuditMiddleware(r.auditService)
 	// Initialize device auth middleware
 	deviceAuthMiddleware := middleware.DeviceJWTAuth(r.deviceAuthService)
 
-	api := router.Group("/api")
+	// Requests to documented routes are checked against the spec before
+	// their handlers bind them
+	spec := Spec()
+	docs := openapi.NewHandler(spec, openAPIPath)
+
+	// Probes and scrapes are left out of the rate limit
+	api := router.Group("/api", r.rateLimiter.Handler(), spec.Validate())
 	{
+		// API document and its docs page
+		api.GET("/openapi.json", docs.Spec)
+		api.GET("/docs", docs.Docs)
+
 		// Authentication routes (public)
 		r.setupAuthRoutes(api)
 
@@ -124,6 +183,10 @@ func (r *Router) setupAPIRoutes(router *gin.Engine) {
 
 		// Reading routes (device authenticated)
 		r.setupReadingRoutes(api, deviceAuthMiddleware)
+		// Firmware reports and drift detection
+		r.setupFirmwareRoutes(api, deviceAuthMiddleware)
+		// Feature flags and their overrides
+		r.setupFeatureFlagRoutes(api, deviceAuthMiddleware)
 		// Solar device routes
 		r.setupSolarRoutes(api)
 		// Sensor routes
@@ -134,9 +197,14 @@ func (r *Router) setupAPIRoutes(router *gin.Engine) {
 
 		// Export routes (PDF, XLSX, CSV, XML)
 		r.setupExportRoutes(api)
+		// Scheduled reports
+		r.setupReportRoutes(api)
+		// Import of readings and devices from files
+		r.setupImportRoutes(api)
 
 		// Location routes
 		r.setupLocationRoutes(api, auditMiddleware)
+		r.setupWiFiProfileRoutes(api)
 	}
 }
 
@@ -147,12 +215,18 @@ func (r *Router) setupCodegenRoutes(api *gin.RouterGroup) {
 		// List available build tools
 		cg.GET("/tools", r.codegenHandler.ListTools)
 
+		// Config schema of the firmware source
+		cg.GET("/schema", middleware.JWTAuth(r.jwtSecret), r.codegenHandler.Schema)
+
 		// Generate firmware (returns build ID)
 		cg.POST("/generate", middleware.JWTAuth(r.jwtSecret), r.codegenHandler.Generate)
 
 		// Build firmware and return a download URL
 		cg.POST("/build", middleware.JWTAuth(r.jwtSecret), r.codegenHandler.Build)
 
+		// Build firmware for several boards (device types) at once
+		cg.POST("/build-multi", middleware.JWTAuth(r.jwtSecret), r.codegenHandler.BuildMulti)
+
 		// Build and download firmware binary in one step
 		cg.POST("/build-and-download", middleware.JWTAuth(r.jwtSecret), r.codegenHandler.GenerateAndDownload)
 
@@ -164,6 +238,10 @@ func (r *Router) setupCodegenRoutes(api *gin.RouterGroup) {
 
 		// Cleanup a build's artifacts
 		cg.DELETE("/builds/:build_id", middleware.JWTAuth(r.jwtSecret), r.codegenHandler.Cleanup)
+
+		// Build cache (compiled objects and prebuilt images)
+		cg.GET("/cache", middleware.JWTAuth(r.jwtSecret), r.codegenHandler.CacheStats)
+		cg.DELETE("/cache", middleware.JWTAuth(r.jwtSecret), middleware.RequireAdmin(), r.codegenHandler.PurgeCache)
 	}
 }
 
@@ -181,7 +259,7 @@ func (r *Router) setupDeviceRoutes(api *gin.RouterGroup, auditMiddleware *middle
 	device.GET("/recent", r.deviceHandler.ListRecentDevices)
 	device.GET("/:id", r.deviceHandler.GetDevice)
 	device.POST("", middleware.JWTAuth(r.jwtSecret), r.deviceHandler.CreateDevice)
-	device.PUT("/:id", middleware.JWTAuth(r.jwtSecret), auditMiddleware.Audit("device_update"), r.deviceHandler.UpdateDevice)
+	device.PUT("/:id", middleware.JWTAuth(r.jwtSecret), auditMiddleware.Audit("device_update", "device"), r.deviceHandler.UpdateDevice)
 
 	{
 		// Get all types.
@@ -212,14 +290,14 @@ func (r *Router) setupDeviceRoutes(api *gin.RouterGroup, auditMiddleware *middle
 
 	device.POST("/:id/control", middleware.JWTAuth(r.jwtSecret), r.deviceHandler.ControlDevice)
 
-	device.PUT("/:id/full", middleware.JWTAuth(r.jwtSecret), auditMiddleware.Audit("device_full_update"), r.deviceHandler.FullUpdateDevice)
+	device.PUT("/:id/full", middleware.JWTAuth(r.jwtSecret), auditMiddleware.Audit("device_full_update", "device"), r.deviceHandler.FullUpdateDevice)
 
 	api.DELETE("/devices/:id", middleware.JWTAuth(r.jwtSecret), r.deviceHandler.DeleteDevice)
 
 	api.GET(
 		"/device/:id/features",
 		middleware.JWTAuth(r.jwtSecret),
-		r.versionHandler.GetAllFeaturesByDevice,
+		r.featureFlagHandler.GetDeviceFlags,
 	)
 	api.GET(
 		"/devices/:id/versions",
@@ -229,6 +307,14 @@ func (r *Router) setupDeviceRoutes(api *gin.RouterGroup, auditMiddleware *middle
 		"/devices/:id/versions",
 		r.versionHandler.CreateNewDeviceVersion,
 	)
+	api.GET(
+		"/devices/:id/versions/lineage",
+		r.versionHandler.GetDeviceLineage,
+	)
+	api.GET(
+		"/devices/:id/versions/changelog",
+		r.versionHandler.GetDeviceChangelog,
+	)
 	api.GET("/devices/my", middleware.JWTAuth(r.jwtSecret), r.deviceHandler.GetMyDevices)
 
 }
@@ -254,6 +340,11 @@ func (r *Router) setupReadingRoutes(api *gin.RouterGroup, deviceAuthMiddleware g
 // setupDeviceTypesRoutes configures device types related routes
 func (r *Router) setupDeviceTypesRoutes(api *gin.RouterGroup) {
 	api.GET("/device-types", r.deviceTypesHandler.ListDeviceTypes)
+
+	// Board profiles used by codegen to build firmware for a device type
+	api.GET("/device-types/board-profiles", r.deviceTypesHandler.ListBoardProfiles)
+	api.GET("/device-types/:id/board-profile", r.deviceTypesHandler.GetBoardProfile)
+	api.PUT("/device-types/:id/board-profile", middleware.JWTAuth(r.jwtSecret), r.deviceTypesHandler.SetBoardProfile)
 }
 
 // setupDeviceStateRoutes configures device state related routes
@@ -278,13 +369,26 @@ func (r *Router) setupUserRoutes(api *gin.RouterGroup, auditMiddleware *middlewa
 
 // setupAuditRoutes configures audit related routes
 func (r *Router) setupAuditRoutes(api *gin.RouterGroup) {
-	api.GET("/audit", middleware.JWTAuth(r.jwtSecret), r.auditHandler.ListAuditLogs)
+	audit := api.Group("/audit")
+	audit.Use(middleware.JWTAuth(r.jwtSecret))
+	{
+		// Query params: user_id, username, action, resource_type, resource_id,
+		// ip, status, request_id, from, to, cursor, limit
+		audit.GET("", r.auditHandler.ListAuditLogs)
+		// Same filters, plus format (csv by default)
+		audit.GET("/export", middleware.RequireAdmin(), r.auditHandler.ExportAuditLogs)
+		audit.GET("/verify", r.auditHandler.VerifyAuditChain)
+		audit.GET("/checkpoints", r.auditHandler.ListCheckpoints)
+		audit.POST("/checkpoints", middleware.RequireAdmin(), r.auditHandler.CreateCheckpoint)
+		audit.GET("/:id", r.auditHandler.GetAuditLog)
+	}
 }
 
 // setupVersionRoutes configures version related routes
 func (r *Router) setupVersionRoutes(api *gin.RouterGroup) {
 	api.POST("/versions", middleware.JWTAuth(r.jwtSecret), r.versionHandler.CreateVersion)
 	api.GET("/versions", r.versionHandler.GetAllVersions)
+	api.GET("/versions/diff", r.versionHandler.DiffVersions)
 	api.GET("/versions/:id", middleware.JWTAuth(r.jwtSecret), r.versionHandler.GetVersion)
 	api.PUT("/versions/:id", middleware.JWTAuth(r.jwtSecret), r.versionHandler.UpdateVersion)
 	api.DELETE("/versions/:id", middleware.JWTAuth(r.jwtSecret), r.versionHandler.DeleteVersion)
@@ -317,13 +421,51 @@ func (r *Router) setupLocationRoutes(api *gin.RouterGroup, auditMiddleware *midd
 		locationAPI.GET("", r.locationHandler.ListLocations)
 		locationAPI.GET("/:id", r.locationHandler.GetLocation)
 		locationAPI.GET("/search", r.locationHandler.SearchLocations)
-		locationAPI.POST("", middleware.JWTAuth(r.jwtSecret), auditMiddleware.Audit("location_create"), r.locationHandler.CreateLocation)
-		locationAPI.PUT("/:id", middleware.JWTAuth(r.jwtSecret), auditMiddleware.Audit("location_update"), r.locationHandler.UpdateLocation)
-		locationAPI.DELETE("/:id", middleware.JWTAuth(r.jwtSecret), auditMiddleware.Audit("location_delete"), r.locationHandler.DeleteLocation)
+		locationAPI.POST("", middleware.JWTAuth(r.jwtSecret), auditMiddleware.Audit("location_create", "location"), r.locationHandler.CreateLocation)
+		locationAPI.PUT("/:id", middleware.JWTAuth(r.jwtSecret), auditMiddleware.Audit("location_update", "location"), r.locationHandler.UpdateLocation)
+		locationAPI.DELETE("/:id", middleware.JWTAuth(r.jwtSecret), auditMiddleware.Audit("location_delete", "location"), r.locationHandler.DeleteLocation)
 		locationAPI.GET("/:id/devices", r.locationHandler.ListDevicesInLocation)
 
 		locationAPI.GET("/:id/readings/seven", r.locationHandler.GetSevenDaysReadings)
 
+		// WiFi credentials stored per location for firmware builds
+		locationAPI.GET("/:id/wifi-profiles", middleware.JWTAuth(r.jwtSecret), r.wifiProfileHandler.ListWiFiProfiles)
+		locationAPI.POST("/:id/wifi-profiles", middleware.JWTAuth(r.jwtSecret), r.wifiProfileHandler.CreateWiFiProfile)
+
+	}
+}
+
+// setupFirmwareRoutes configures the routes for the firmware devices report running.
+func (r *Router) setupFirmwareRoutes(api *gin.RouterGroup, deviceAuthMiddleware gin.HandlerFunc) {
+	// Reported by the device itself on boot and with its heartbeat
+	api.POST("/devices/firmware", deviceAuthMiddleware, r.firmwareHandler.ReportFirmware)
+
+	api.GET("/devices/firmware/drift", middleware.JWTAuth(r.jwtSecret), r.firmwareHandler.DriftReport)
+	api.GET("/devices/:id/firmware", middleware.JWTAuth(r.jwtSecret), r.firmwareHandler.GetDeviceFirmware)
+}
+
+// setupFeatureFlagRoutes configures feature flag delivery and the per-device
+// and per-location overrides.
+func (r *Router) setupFeatureFlagRoutes(api *gin.RouterGroup, deviceAuthMiddleware gin.HandlerFunc) {
+	// Fetched by the device itself
+	api.GET("/devices/flags", deviceAuthMiddleware, r.featureFlagHandler.GetFlagConfig)
+
+	api.GET("/devices/:id/feature-overrides", middleware.JWTAuth(r.jwtSecret), r.featureFlagHandler.ListDeviceOverrides)
+	api.PUT("/devices/:id/feature-overrides/:fid", middleware.JWTAuth(r.jwtSecret), r.featureFlagHandler.SetDeviceOverride)
+	api.DELETE("/devices/:id/feature-overrides/:fid", middleware.JWTAuth(r.jwtSecret), r.featureFlagHandler.ClearDeviceOverride)
+
+	api.GET("/locations/:id/feature-overrides", middleware.JWTAuth(r.jwtSecret), r.featureFlagHandler.ListLocationOverrides)
+	api.PUT("/locations/:id/feature-overrides/:fid", middleware.JWTAuth(r.jwtSecret), r.featureFlagHandler.SetLocationOverride)
+	api.DELETE("/locations/:id/feature-overrides/:fid", middleware.JWTAuth(r.jwtSecret), r.featureFlagHandler.ClearLocationOverride)
+}
+
+// setupWiFiProfileRoutes configures the routes of a single WiFi profile.
+// Profiles are listed and created under their location.
+func (r *Router) setupWiFiProfileRoutes(api *gin.RouterGroup) {
+	wifi := api.Group("/wifi-profiles")
+	{
+		wifi.PUT("/:id", middleware.JWTAuth(r.jwtSecret), r.wifiProfileHandler.UpdateWiFiProfile)
+		wifi.DELETE("/:id", middleware.JWTAuth(r.jwtSecret), r.wifiProfileHandler.DeleteWiFiProfile)
 	}
 }
 
@@ -334,12 +476,78 @@ func (r *Router) setupExportRoutes(api *gin.RouterGroup) {
 		// List available export formats
 		exp.GET("/formats", r.exportHandler.ListFormats)
 
-		// Export readings for a device
-		// Query params: format, device_id, start_date, end_date, template
+		// Health of the PDF renderer pool
+		exp.GET("/renderer", middleware.JWTAuth(r.jwtSecret), r.exportHandler.RendererStats)
+
+		// Export readings for devices or a location, streamed
+		// Query params: format, device_id, device_ids, location_id, start_date, end_date,
+		// template (library template ID)
 		exp.GET("/readings", middleware.JWTAuth(r.jwtSecret), r.exportHandler.ExportReadings)
 
 		// Export all devices
 		// Query params: format, template
 		exp.GET("/devices", middleware.JWTAuth(r.jwtSecret), r.exportHandler.ExportDevices)
+
+		// Device and location reports with charts and summaries
+		// Query params: start_date, end_date, format (pdf, json), template,
+		// min_voltage, max_voltage, max_current, offline_after
+		exp.GET("/reports/device/:id", middleware.JWTAuth(r.jwtSecret), r.exportHandler.DeviceReport)
+		exp.GET("/reports/location/:id", middleware.JWTAuth(r.jwtSecret), r.exportHandler.LocationReport)
+	}
+
+	// Library of PDF templates, versioned and selected by ID
+	templates := exp.Group("/templates")
+	templates.Use(middleware.JWTAuth(r.jwtSecret))
+	{
+		templates.GET("", r.exportTemplateHandler.ListTemplates)
+		templates.POST("", r.exportTemplateHandler.CreateTemplate)
+		templates.POST("/preview", r.exportTemplateHandler.PreviewTemplate)
+		templates.GET("/:id", r.exportTemplateHandler.GetTemplate)
+		templates.PUT("/:id", r.exportTemplateHandler.UpdateTemplate)
+		templates.DELETE("/:id", r.exportTemplateHandler.DeleteTemplate)
+		templates.GET("/:id/versions", r.exportTemplateHandler.ListTemplateVersions)
+		templates.POST("/:id/versions", r.exportTemplateHandler.AddTemplateVersion)
+		templates.GET("/:id/versions/:version", r.exportTemplateHandler.GetTemplateVersion)
+		// The default template is used by every user's exports
+		templates.PUT("/:id/default", middleware.RequireAdmin(), r.exportTemplateHandler.SetDefaultTemplate)
+		templates.DELETE("/:id/default", middleware.RequireAdmin(), r.exportTemplateHandler.ClearDefaultTemplate)
+	}
+}
+
+// setupReportRoutes configures saved report definitions, their run history
+// and the download of rendered reports.
+func (r *Router) setupReportRoutes(api *gin.RouterGroup) {
+	reports := api.Group("/reports")
+	reports.Use(middleware.JWTAuth(r.jwtSecret))
+	{
+		reports.GET("", r.reportHandler.ListReports)
+		reports.POST("", r.reportHandler.CreateReport)
+		reports.GET("/:id", r.reportHandler.GetReport)
+		reports.PUT("/:id", r.reportHandler.UpdateReport)
+		reports.DELETE("/:id", r.reportHandler.DeleteReport)
+
+		// Render and deliver now, outside the schedule
+		reports.POST("/:id/run", r.reportHandler.RunReport)
+		reports.GET("/:id/runs", r.reportHandler.ListReportRuns)
+		reports.GET("/runs/:run_id/download", r.reportHandler.DownloadReportRun)
+	}
+}
+
+// setupImportRoutes configures the import of readings and devices from
+// files, and the import jobs that can be rolled back.
+func (r *Router) setupImportRoutes(api *gin.RouterGroup) {
+	imp := api.Group("/import")
+	imp.Use(middleware.JWTAuth(r.jwtSecret))
+	{
+		// Query params: data_type
+		imp.GET("/fields", r.importHandler.ListFields)
+
+		// Multipart form: file, data_type, format, mapping, sheet, dry_run,
+		// skip_invalid
+		imp.POST("", r.importHandler.Import)
+
+		imp.GET("/jobs", r.importHandler.ListJobs)
+		imp.GET("/jobs/:id", r.importHandler.GetJob)
+		imp.POST("/jobs/:id/rollback", r.importHandler.RollbackJob)
 	}
 }
@@ -0,0 +1,562 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/testutil"
+)
+
+func TestProbes(t *testing.T) {
+	h := testutil.New(t)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		report := testutil.Expect[struct {
+			Status string `json:"status"`
+		}](t, h.Do(http.MethodGet, path, nil, ""), http.StatusOK)
+		if report.Status != "ok" {
+			t.Errorf("%s status = %q, want ok", path, report.Status)
+		}
+	}
+}
+
+func TestLoginRejectsWrongPassword(t *testing.T) {
+	h := testutil.New(t)
+	user := h.User().Create()
+
+	rec := h.Do(http.MethodPost, "/api/login", map[string]string{
+		"username": user.Username,
+		"password": "wrong",
+	}, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, want %d: %s", rec.Code, http.StatusUnauthorized, rec.Body)
+	}
+}
+
+func TestDeviceReadingsRoundTrip(t *testing.T) {
+	h := testutil.New(t)
+	admin := h.User().Admin().Create()
+	device := h.Device().Owner(admin).Create()
+	token := h.Login(admin.Username)
+	deviceToken := h.DeviceToken(token, device.ID)
+
+	rec := h.Do(http.MethodPost, "/api/readings", map[string]float64{
+		"voltage": 12.6,
+		"current": 0.8,
+	}, deviceToken)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("post reading: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = h.Do(http.MethodGet, fmt.Sprintf("/api/devices/%d/readings", device.ID), nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list readings: status %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "12.6") {
+		t.Errorf("readings %s do not include the posted one", rec.Body)
+	}
+}
+
+func TestExportReadingsPDF(t *testing.T) {
+	h := testutil.New(t)
+	admin := h.User().Admin().Create()
+	device := h.Device().Name("Rooftop inverter").Owner(admin).Create()
+	h.Readings(device).Count(6).Create()
+	token := h.Login(admin.Username)
+
+	rec := h.Do(http.MethodGet, fmt.Sprintf("/api/export/readings?format=pdf&device_id=%d", device.ID), nil, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), testutil.FakePDF) {
+		t.Errorf("body is not the rendered PDF: %q", rec.Body)
+	}
+	pages := h.Renderer.Rendered()
+	if len(pages) != 1 || !strings.Contains(pages[0], device.Name) {
+		t.Errorf("rendered %d pages, want one naming the device", len(pages))
+	}
+}
+
+func TestCodegenBuild(t *testing.T) {
+	h := testutil.New(t)
+	h.FirmwareSource()
+	user := h.User().Create()
+	token := h.Login(user.Username)
+
+	rec := h.Do(http.MethodPost, "/api/codegen/build", map[string]any{
+		"ip":        "192.168.1.50",
+		"host_ip":   "192.168.1.10",
+		"host_ssid": "lab",
+		"host_pass": "lab-password",
+		"token":     "device-token",
+	}, token)
+	build := testutil.Expect[struct {
+		BuildID   string `json:"build_id"`
+		BuildTool string `json:"build_tool"`
+	}](t, rec, http.StatusOK)
+	if build.BuildTool != testutil.FakeTool || build.BuildID == "" {
+		t.Errorf("build = %+v, want one by the fake tool", build)
+	}
+
+	builds := h.Toolchain.Builds()
+	if len(builds) != 1 {
+		t.Fatalf("ran %d builds, want 1", len(builds))
+	}
+	for _, define := range []string{`#define WIFI_SSID "lab"`, `#define STATIC_IP_ADDRESS 192, 168, 1, 50`} {
+		if !strings.Contains(builds[0].Header, define) {
+			t.Errorf("config header lacks %s:\n%s", define, builds[0].Header)
+		}
+	}
+}
+
+func TestCodegenBuildWithoutBackendKeys(t *testing.T) {
+	h := testutil.New(t)
+	h.FirmwareSource()
+	h.FirmwareSchema(`{
+		"template": "include/config.h.in",
+		"output": "include/config.h",
+		"keys": [
+			{"name": "WIFI_SSID", "type": "string", "required": true},
+			{"name": "WIFI_PASSWORD", "type": "string", "required": true, "secret": true}
+		]
+	}`, "#define WIFI_SSID @WIFI_SSID@\n#define WIFI_PASSWORD @WIFI_PASSWORD@\n")
+	user := h.User().Create()
+	token := h.Login(user.Username)
+
+	rec := h.Do(http.MethodPost, "/api/codegen/build", map[string]any{
+		"ip":        "192.168.1.50",
+		"host_ip":   "192.168.1.10",
+		"host_ssid": "lab",
+		"host_pass": "lab-password",
+		"token":     "device-token",
+	}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	builds := h.Toolchain.Builds()
+	if len(builds) != 1 {
+		t.Fatalf("ran %d builds, want 1", len(builds))
+	}
+	if !strings.Contains(builds[0].Header, `#define WIFI_SSID "lab"`) || strings.Contains(builds[0].Header, "BACKEND") {
+		t.Errorf("config header = %q, want only the schema keys", builds[0].Header)
+	}
+}
+
+func TestCodegenUploadUsesBuildTool(t *testing.T) {
+	h := testutil.New(t)
+	h.FirmwareSource()
+	user := h.User().Create()
+	token := h.Login(user.Username)
+
+	rec := h.Do(http.MethodPost, "/api/codegen/upload", map[string]any{
+		"ip":        "192.168.1.50",
+		"host_ip":   "192.168.1.10",
+		"host_ssid": "lab",
+		"host_pass": "lab-password",
+		"token":     "device-token",
+		"device_ip": "192.168.1.50",
+	}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	uploads := h.Toolchain.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("ran %d uploads, want 1", len(uploads))
+	}
+	if uploads[0].DeviceIP != "192.168.1.50" || !strings.Contains(uploads[0].Binary, `#define WIFI_SSID "lab"`) {
+		t.Errorf("upload = %+v, want the built binary flashed to 192.168.1.50", uploads[0])
+	}
+}
+
+func TestDefaultTemplateRequiresAdmin(t *testing.T) {
+	h := testutil.New(t)
+	user := h.User().Create()
+	admin := h.User().Admin().Create()
+	userToken := h.Login(user.Username)
+	adminToken := h.Login(admin.Username)
+
+	template := testutil.Expect[struct {
+		ID uint `json:"id"`
+	}](t, h.Do(http.MethodPost, "/api/export/templates", map[string]string{
+		"name":      "Plain",
+		"data_type": "readings",
+		"content":   "<h1>{{.Title}}</h1>",
+	}, userToken), http.StatusCreated)
+	path := fmt.Sprintf("/api/export/templates/%d/default", template.ID)
+
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		if rec := h.Do(method, path, nil, userToken); rec.Code != http.StatusForbidden {
+			t.Errorf("%s as user: status %d, want %d", method, rec.Code, http.StatusForbidden)
+		}
+		if rec := h.Do(method, path, nil, adminToken); rec.Code != http.StatusOK {
+			t.Errorf("%s as admin: status %d, want %d: %s", method, rec.Code, http.StatusOK, rec.Body)
+		}
+	}
+}
+
+func TestPurgeCacheRequiresAdmin(t *testing.T) {
+	h := testutil.New(t)
+	user := h.User().Create()
+	admin := h.User().Admin().Create()
+
+	if rec := h.Do(http.MethodDelete, "/api/codegen/cache", nil, h.Login(user.Username)); rec.Code != http.StatusForbidden {
+		t.Errorf("as user: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if rec := h.Do(http.MethodDelete, "/api/codegen/cache", nil, h.Login(admin.Username)); rec.Code != http.StatusOK {
+		t.Errorf("as admin: status %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestDeviceVersionMustExtendItsChain(t *testing.T) {
+	h := testutil.New(t)
+	admin := h.User().Admin().Create()
+	token := h.Login(admin.Username)
+	a := h.Device().Owner(admin).Create()
+	b := h.Device().Owner(admin).Create()
+	c := h.Device().Owner(admin).Create()
+
+	release := func(device uint, name string, previous *uint, status int) uint {
+		t.Helper()
+		version := testutil.Expect[struct {
+			ID uint `json:"ID"`
+		}](t, h.Do(http.MethodPost, fmt.Sprintf("/api/devices/%d/versions", device), map[string]any{
+			"version":          name,
+			"previous_version": previous,
+		}, ""), status)
+		return version.ID
+	}
+
+	// Fixture devices run the same release of no device
+	base := *a.VersionID
+	a1 := release(a.ID, "a-1.1", &base, http.StatusCreated)
+	release(a.ID, "a-1.2", &a1, http.StatusCreated)
+	// Branching off an earlier version of the same device is allowed
+	release(a.ID, "a-2.0", &a1, http.StatusCreated)
+
+	// Another device cannot branch off a's versions
+	release(b.ID, "b-1.1", &a1, http.StatusBadRequest)
+	release(b.ID, "b-1.1", &base, http.StatusCreated)
+
+	// A device without versions may start from a release of no device, but
+	// not from another device's version
+	if err := h.DB.Model(&model.Device{}).Where("id = ?", c.ID).Update("version_id", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	release(c.ID, "c-1.0", &a1, http.StatusBadRequest)
+	shared := testutil.Expect[struct {
+		ID uint `json:"ID"`
+	}](t, h.Do(http.MethodPost, "/api/versions", map[string]string{"version": "base-2.0"}, token), http.StatusCreated)
+	release(c.ID, "c-1.0", &shared.ID, http.StatusCreated)
+}
+
+func TestCodegenBuildMultiRejectsFQBN(t *testing.T) {
+	h := testutil.New(t)
+	h.FirmwareSource()
+	user := h.User().Create()
+	token := h.Login(user.Username)
+
+	rec := h.Do(http.MethodPost, "/api/codegen/build-multi", map[string]any{
+		"ip":              "192.168.1.50",
+		"host_ip":         "192.168.1.10",
+		"host_ssid":       "lab",
+		"host_pass":       "lab-password",
+		"token":           "device-token",
+		"board_fqbn":      "esp32:esp32:esp32",
+		"device_type_ids": []uint{1, 2},
+	}, token)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+	if builds := h.Toolchain.Builds(); len(builds) != 0 {
+		t.Errorf("ran %d builds, want none", len(builds))
+	}
+}
+
+func TestFeatureOverrideRequiresScope(t *testing.T) {
+	h := testutil.New(t)
+	location := h.Location().Create()
+	user := h.User().Location(location).Create()
+	token := h.Login(user.Username)
+	outsiderToken := h.Login(h.User().Create().Username)
+	device := h.Device().Owner(user).Create()
+	feature := model.Feature{FeatureName: "night_mode"}
+	if err := h.DB.Create(&feature).Error; err != nil {
+		t.Fatal(err)
+	}
+	devicePath := fmt.Sprintf("/api/devices/%d/feature-overrides/%d", device.ID, feature.ID)
+	locationPath := fmt.Sprintf("/api/locations/%d/feature-overrides/%d", location.ID, feature.ID)
+
+	tests := []struct {
+		path   string
+		token  string
+		status int
+	}{
+		{devicePath, outsiderToken, http.StatusForbidden},
+		{locationPath, outsiderToken, http.StatusForbidden},
+		{devicePath, token, http.StatusOK},
+		{locationPath, token, http.StatusOK},
+		{fmt.Sprintf("/api/devices/%d/feature-overrides/%d", device.ID+100, feature.ID), token, http.StatusNotFound},
+		{fmt.Sprintf("/api/locations/%d/feature-overrides/%d", location.ID+100, feature.ID), token, http.StatusNotFound},
+		{fmt.Sprintf("/api/devices/%d/feature-overrides/%d", device.ID, feature.ID+100), token, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := h.Do(http.MethodPut, tt.path, map[string]bool{"enabled": true}, tt.token); rec.Code != tt.status {
+			t.Errorf("PUT %s: status %d, want %d: %s", tt.path, rec.Code, tt.status, rec.Body)
+		}
+	}
+	if rec := h.Do(http.MethodDelete, locationPath, nil, outsiderToken); rec.Code != http.StatusForbidden {
+		t.Errorf("DELETE %s as outsider: status %d, want %d", locationPath, rec.Code, http.StatusForbidden)
+	}
+	if rec := h.Do(http.MethodDelete, locationPath, nil, token); rec.Code != http.StatusOK {
+		t.Errorf("DELETE %s: status %d, want %d: %s", locationPath, rec.Code, http.StatusOK, rec.Body)
+	}
+	if _, err := h.App.AuditService.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []model.AuditLog
+	err := h.DB.Where("resource_type = ? AND action LIKE ?", "location", "feature_override_%").Order("seq").Find(&entries).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`{"enabled":{"before":null,"after":true}}`,
+		`{"enabled":{"before":true,"after":null}}`,
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d location override entries, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if entry.ResourceID != fmt.Sprint(location.ID) || entry.Changes != want[i] {
+			t.Errorf("%s entry: resource %s, changes %s; want resource %d, changes %s",
+				entry.Action, entry.ResourceID, entry.Changes, location.ID, want[i])
+		}
+	}
+}
+
+func TestLocationAuditEntries(t *testing.T) {
+	h := testutil.New(t)
+	user := h.User().Create()
+	token := h.Login(user.Username)
+
+	created := testutil.Expect[struct {
+		ID uint `json:"id"`
+	}](t, h.Do(http.MethodPost, "/api/locations", map[string]string{
+		"code": "AUD1",
+		"name": "Audited",
+	}, token), http.StatusCreated)
+	missing := fmt.Sprintf("/api/locations/%d", created.ID+100)
+	if rec := h.Do(http.MethodPut, missing, map[string]string{"code": "AUD2", "name": "Gone"}, token); rec.Code < http.StatusBadRequest {
+		t.Fatalf("updating a missing location: status %d", rec.Code)
+	}
+	if _, err := h.App.AuditService.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []model.AuditLog
+	if err := h.DB.Where("action IN ?", []string{"location_create", "location_update"}).Order("seq").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d audit entries, want 2: %+v", len(entries), entries)
+	}
+	if e := entries[0]; e.ResourceID != fmt.Sprint(created.ID) || e.Status != model.AuditSuccess {
+		t.Errorf("create entry: resource %q, status %s, want %d and success", e.ResourceID, e.Status, created.ID)
+	}
+	if e := entries[1]; e.Status != model.AuditFailure || !strings.Contains(e.Details, "record not found") {
+		t.Errorf("failed update entry: status %s, details %q", e.Status, e.Details)
+	}
+}
+
+func TestWiFiProfileAuditEntries(t *testing.T) {
+	h := testutil.New(t)
+	site := h.Location().Create()
+	token := h.Login(h.User().Location(site).Create().Username)
+
+	created := testutil.Expect[struct {
+		WiFiProfile struct {
+			ID uint `json:"id"`
+		} `json:"wifi_profile"`
+	}](t, h.Do(http.MethodPost, fmt.Sprintf("/api/locations/%d/wifi-profiles", site.ID), map[string]string{
+		"name":     "Site",
+		"ssid":     "site-net",
+		"password": "site-password",
+	}, token), http.StatusCreated)
+	profilePath := fmt.Sprintf("/api/wifi-profiles/%d", created.WiFiProfile.ID)
+	testutil.Expect[struct{}](t, h.Do(http.MethodPut, profilePath, map[string]string{
+		"name":     "Site",
+		"ssid":     "office-net",
+		"password": "office-password",
+	}, token), http.StatusOK)
+	testutil.Expect[struct{}](t, h.Do(http.MethodDelete, profilePath, nil, token), http.StatusOK)
+	if _, err := h.App.AuditService.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []model.AuditLog
+	if err := h.DB.Where("resource_type = ?", "wifi_profile").Order("seq").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		action  string
+		changes string
+	}{
+		{"wifi_profile_create", `"ssid":{"before":null,"after":"site-net"}`},
+		{"wifi_profile_update", `"ssid":{"before":"site-net","after":"office-net"}`},
+		{"wifi_profile_delete", `"ssid":{"before":"office-net","after":null}`},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d wifi profile entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, entry := range entries {
+		if entry.Action != want[i].action || entry.ResourceID != fmt.Sprint(created.WiFiProfile.ID) ||
+			!strings.Contains(entry.Changes, want[i].changes) {
+			t.Errorf("entry %d: %s on %s, changes %s; want %s with %s",
+				i, entry.Action, entry.ResourceID, entry.Changes, want[i].action, want[i].changes)
+		}
+		if strings.Contains(entry.Changes, "password") {
+			t.Errorf("%s entry records the password: %s", entry.Action, entry.Changes)
+		}
+	}
+	if details := entries[1].Details; details != "Password changed" {
+		t.Errorf("update entry details %q, want %q", details, "Password changed")
+	}
+}
+
+func TestAuditExportAndCheckpointsRequireAdmin(t *testing.T) {
+	h := testutil.New(t)
+	userToken := h.Login(h.User().Create().Username)
+	adminToken := h.Login(h.User().Admin().Create().Username)
+
+	for _, route := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/api/audit/export", http.StatusOK},
+		{http.MethodPost, "/api/audit/checkpoints", http.StatusCreated},
+	} {
+		if rec := h.Do(route.method, route.path, nil, userToken); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s as user: status %d, want %d", route.method, route.path, rec.Code, http.StatusForbidden)
+		}
+		if rec := h.Do(route.method, route.path, nil, adminToken); rec.Code != route.status {
+			t.Errorf("%s %s as admin: status %d, want %d: %s", route.method, route.path, rec.Code, route.status, rec.Body)
+		}
+	}
+}
+
+func TestWiFiProfilesAreScopedToTheirLocation(t *testing.T) {
+	h := testutil.New(t)
+	h.FirmwareSource()
+	site := h.Location().Create()
+	member := h.User().Location(site).Create()
+	outsider := h.User().Create()
+	admin := h.User().Admin().Create()
+	memberToken := h.Login(member.Username)
+	outsiderToken := h.Login(outsider.Username)
+	adminToken := h.Login(admin.Username)
+
+	profilesPath := fmt.Sprintf("/api/locations/%d/wifi-profiles", site.ID)
+	profile := map[string]string{"name": "Site", "ssid": "site-net", "password": "site-password"}
+	created := testutil.Expect[struct {
+		WiFiProfile struct {
+			ID uint `json:"id"`
+		} `json:"wifi_profile"`
+	}](t, h.Do(http.MethodPost, profilesPath, profile, memberToken), http.StatusCreated)
+	profilePath := fmt.Sprintf("/api/wifi-profiles/%d", created.WiFiProfile.ID)
+	build := func(token string) *httptest.ResponseRecorder {
+		return h.Do(http.MethodPost, "/api/codegen/build", map[string]any{
+			"ip":              "192.168.1.50",
+			"host_ip":         "192.168.1.10",
+			"host_ssid":       "lab",
+			"host_pass":       "lab-password",
+			"token":           "device-token",
+			"wifi_profile_id": created.WiFiProfile.ID,
+		}, token)
+	}
+
+	for _, tt := range []struct {
+		name string
+		rec  *httptest.ResponseRecorder
+	}{
+		{"list", h.Do(http.MethodGet, profilesPath, nil, outsiderToken)},
+		{"create", h.Do(http.MethodPost, profilesPath, profile, outsiderToken)},
+		{"update", h.Do(http.MethodPut, profilePath, profile, outsiderToken)},
+		{"delete", h.Do(http.MethodDelete, profilePath, nil, outsiderToken)},
+		{"build", build(outsiderToken)},
+	} {
+		if tt.rec.Code != http.StatusForbidden {
+			t.Errorf("%s by a user of another location: status %d, want %d: %s", tt.name, tt.rec.Code, http.StatusForbidden, tt.rec.Body)
+		}
+	}
+	if builds := h.Toolchain.Builds(); len(builds) != 0 {
+		t.Fatalf("ran %d builds for an outsider, want none", len(builds))
+	}
+
+	listed := testutil.Expect[struct {
+		WiFiProfiles []struct {
+			ID uint `json:"id"`
+		} `json:"wifi_profiles"`
+	}](t, h.Do(http.MethodGet, profilesPath, nil, adminToken), http.StatusOK)
+	if len(listed.WiFiProfiles) != 1 {
+		t.Errorf("admin lists %d profiles, want 1", len(listed.WiFiProfiles))
+	}
+
+	testutil.Expect[struct{}](t, build(memberToken), http.StatusOK)
+	if builds := h.Toolchain.Builds(); len(builds) != 1 || !strings.Contains(builds[0].Header, `#define WIFI_SSID "site-net"`) {
+		t.Errorf("member's build does not use the profile: %+v", builds)
+	}
+}
+
+func TestImportIsScopedToOwners(t *testing.T) {
+	h := testutil.New(t)
+	owner := h.User().Create()
+	ownerToken := h.Login(owner.Username)
+	outsiderToken := h.Login(h.User().Create().Username)
+	device := h.Device().Owner(owner).Create()
+	file := fmt.Sprintf("device_id,voltage,current,created_at\n%d,12.5,1.2,2024-01-01T00:00:00Z\n", device.ID)
+
+	importReadings := func(token string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		_ = form.WriteField("data_type", "readings")
+		part, _ := form.CreateFormFile("file", "readings.csv")
+		_, _ = part.Write([]byte(file))
+		_ = form.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/import", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.App.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := importReadings(outsiderToken); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("import into another user's device: status %d, want %d: %s", rec.Code, http.StatusUnprocessableEntity, rec.Body)
+	}
+	job := testutil.Expect[struct {
+		JobID uint `json:"job_id"`
+	}](t, importReadings(ownerToken), http.StatusCreated)
+
+	rollback := fmt.Sprintf("/api/import/jobs/%d/rollback", job.JobID)
+	for _, tt := range []struct {
+		token  string
+		status int
+	}{
+		{outsiderToken, http.StatusForbidden},
+		{ownerToken, http.StatusOK},
+		{ownerToken, http.StatusConflict},
+	} {
+		if rec := h.Do(http.MethodPost, rollback, nil, tt.token); rec.Code != tt.status {
+			t.Errorf("rollback: status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ciphertextPrefix versions the stored format so the key or algorithm can change later.
+const ciphertextPrefix = "v1:"
+
+// Cipher encrypts secrets stored in the database with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a Cipher from a key. A base64 encoded 32 byte key is used
+// as is; any other non-empty string is treated as a passphrase and hashed.
+func NewCipher(key string) (*Cipher, error) {
+	if key == "" {
+		return nil, errors.New("secrets key is empty")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		sum := sha256.Sum256([]byte(key))
+		raw = sum[:]
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt returns the ciphertext of plaintext in the stored format.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return ciphertextPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of a value produced by Encrypt.
+func (c *Cipher) Decrypt(ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, ciphertextPrefix) {
+		return "", errors.New("unsupported ciphertext format")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, ciphertextPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("invalid ciphertext: too short")
+	}
+	nonce, data := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", errors.New("failed to decrypt secret: wrong key or corrupted data")
+	}
+	return string(plaintext), nil
+}
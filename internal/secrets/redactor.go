@@ -0,0 +1,73 @@
+package secrets
+
+import (
+	"sort"
+	"strings"
+)
+
+// Placeholder replaces secret values in redacted text.
+const Placeholder = "[REDACTED]"
+
+// minSecretLength avoids redacting short values that would match everywhere.
+const minSecretLength = 4
+
+// Redactor removes known secret values from log fields, build output and
+// error messages. The zero value redacts nothing.
+type Redactor struct {
+	values []string
+}
+
+// NewRedactor creates a Redactor for the given secret values. Empty and very
+// short values are ignored.
+func NewRedactor(values ...string) *Redactor {
+	r := &Redactor{}
+	for _, v := range values {
+		r.Add(v)
+	}
+	return r
+}
+
+// Add registers another secret value.
+func (r *Redactor) Add(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	r.values = append(r.values, value)
+	// Longest first so a secret containing another is fully replaced
+	sort.Slice(r.values, func(i, j int) bool {
+		return len(r.values[i]) > len(r.values[j])
+	})
+}
+
+// String returns s with every secret value replaced by Placeholder.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, Placeholder)
+	}
+	return s
+}
+
+// Error returns err with a redacted message. The original error stays
+// reachable through errors.Is and errors.As.
+func (r *Redactor) Error(err error) error {
+	if err == nil || r == nil || len(r.values) == 0 {
+		return err
+	}
+	return &redactedError{msg: r.String(err.Error()), err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WipeFile overwrites a file with zeros before removing it, so secrets do not
+// linger in the file's blocks. This is best effort: copy-on-write and
+// journaling filesystems may keep older copies.
+func WipeFile(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if info.Mode().IsRegular() && info.Size() > 0 {
+		if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+			zeros := make([]byte, 32*1024)
+			for remaining := info.Size(); remaining > 0; {
+				n := int64(len(zeros))
+				if remaining < n {
+					n = remaining
+				}
+				if _, err := f.Write(zeros[:n]); err != nil {
+					break
+				}
+				remaining -= n
+			}
+			_ = f.Sync()
+			_ = f.Close()
+		}
+	}
+	return os.Remove(path)
+}
+
+// WipeDir wipes every file below dir and removes the directory. Files for
+// which keep returns true are left in place along with their parent dirs.
+func WipeDir(dir string, keep func(path string) bool) error {
+	var firstErr error
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() || (keep != nil && keep(path)) {
+			return nil
+		}
+		if err := WipeFile(path); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	if keep == nil {
+		return os.RemoveAll(dir)
+	}
+	removeEmptyDirs(dir)
+	return nil
+}
+
+// removeEmptyDirs removes directories below root that no longer hold files.
+func removeEmptyDirs(root string) {
+	var dirs []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && path != root {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	// Deepest first so parents become empty before they are visited
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+}
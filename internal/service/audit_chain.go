@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/audittrail"
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/health"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/metrics"
+	"github.com/aruncs31s/skvms/internal/model"
+	"go.uber.org/zap"
+)
+
+// ErrAuditChainEmpty is returned when checkpointing a chain without entries.
+var ErrAuditChainEmpty = errors.New("audit chain has no entries")
+
+const (
+	// auditVerifyPage is the number of entries Verify loads at a time.
+	auditVerifyPage = 1000
+	// maxAuditProblems is the number of problems Verify reports.
+	maxAuditProblems = 100
+	// maxAuditWriterBackoff caps the wait between failed deliveries.
+	maxAuditWriterBackoff = time.Minute
+)
+
+func (s *auditService) StartWriter(
+	ctx context.Context,
+	interval time.Duration,
+) *health.Worker {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	worker := health.NewWorker("audit_writer", interval)
+	go func() {
+		defer worker.Stop()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var backoff time.Duration
+		for {
+			start := time.Now()
+			n, err := s.Flush(ctx)
+			if ctx.Err() != nil {
+				// Shutting down, the entries left are flushed on the way out
+				return
+			}
+			worker.Beat(err)
+			if n > 0 || err != nil {
+				metrics.ObserveJob("audit_writer", err, time.Since(start))
+			}
+			if err != nil {
+				// Keep the entries queued and retry, backing off while the
+				// database is unavailable
+				backoff = min(max(2*backoff, time.Second), maxAuditWriterBackoff)
+				logger.FromContext(ctx).Error("Failed to append audit entries",
+					zap.Error(err),
+					zap.Int("pending", s.outbox.Pending()),
+					zap.Duration("retry_in", backoff),
+				)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				continue
+			}
+			backoff = 0
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.outbox.Wake():
+			case <-ticker.C:
+			}
+		}
+	}()
+	return worker
+}
+
+func (s *auditService) Flush(
+	ctx context.Context,
+) (int, error) {
+	return s.outbox.Drain(func(data []byte) error {
+		var log model.AuditLog
+		if err := json.Unmarshal(data, &log); err != nil {
+			return fmt.Errorf("%w: %v", audittrail.ErrRejected, err)
+		}
+		log.ID = 0
+		return s.repo.Append(ctx, &log)
+	})
+}
+
+func (s *auditService) StartCheckpoints(
+	ctx context.Context,
+	interval time.Duration,
+) *health.Worker {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	worker := health.NewWorker("audit_checkpoints", interval)
+	go func() {
+		defer worker.Stop()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			start := time.Now()
+			_, err := s.Checkpoint(ctx)
+			if errors.Is(err, ErrAuditChainEmpty) {
+				worker.Beat(nil)
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			worker.Beat(err)
+			metrics.ObserveJob("audit_checkpoint", err, time.Since(start))
+			if err != nil {
+				logger.FromContext(ctx).Error("Failed to checkpoint audit chain", zap.Error(err))
+			}
+		}
+	}()
+	return worker
+}
+
+func (s *auditService) Checkpoint(
+	ctx context.Context,
+) (*dto.AuditCheckpointResponse, error) {
+	last, err := s.repo.LastChained(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if last == nil {
+		return nil, ErrAuditChainEmpty
+	}
+	previous, err := s.repo.LastCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil && previous.Seq == *last.Seq && previous.Hash == last.Hash {
+		resp := toAuditCheckpointResponse(previous)
+		return &resp, nil
+	}
+
+	now := time.Now().Truncate(time.Second)
+	checkpoint := &model.AuditCheckpoint{
+		Seq:       *last.Seq,
+		Hash:      last.Hash,
+		KeyID:     s.signer.KeyID(),
+		Signature: s.signer.Sign(*last.Seq, last.Hash, now),
+		CreatedAt: now,
+	}
+	// Export before storing, so a stored checkpoint is always in the file
+	if err := s.checkpoints.Append(audittrail.CheckpointRecord{
+		Seq:       checkpoint.Seq,
+		Hash:      checkpoint.Hash,
+		CreatedAt: checkpoint.CreatedAt,
+		KeyID:     checkpoint.KeyID,
+		PublicKey: base64.StdEncoding.EncodeToString(s.signer.PublicKey()),
+		Signature: checkpoint.Signature,
+	}); err != nil {
+		return nil, fmt.Errorf("export audit checkpoint: %w", err)
+	}
+	if err := s.repo.CreateCheckpoint(ctx, checkpoint); err != nil {
+		return nil, err
+	}
+	resp := toAuditCheckpointResponse(checkpoint)
+	return &resp, nil
+}
+
+func (s *auditService) ListCheckpoints(
+	ctx context.Context,
+	limit int,
+) ([]dto.AuditCheckpointResponse, error) {
+	checkpoints, err := s.repo.ListCheckpoints(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]dto.AuditCheckpointResponse, len(checkpoints))
+	for i := range checkpoints {
+		responses[i] = toAuditCheckpointResponse(&checkpoints[i])
+	}
+	return responses, nil
+}
+
+func (s *auditService) Verify(
+	ctx context.Context,
+	fromSeq uint64,
+	toSeq uint64,
+) (*dto.AuditChainVerification, error) {
+	if fromSeq == 0 {
+		fromSeq = 1
+	}
+	result := &dto.AuditChainVerification{
+		FromSeq:  fromSeq,
+		ToSeq:    toSeq,
+		Pending:  s.outbox.Pending(),
+		Problems: []dto.AuditChainProblem{},
+	}
+	problem := func(kind string, seq uint64, id uint, format string, args ...interface{}) {
+		if len(result.Problems) < maxAuditProblems {
+			result.Problems = append(result.Problems, dto.AuditChainProblem{
+				Kind:    kind,
+				Seq:     seq,
+				ID:      id,
+				Message: fmt.Sprintf(format, args...),
+			})
+		}
+	}
+
+	checkpoints, err := s.loadCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bySeq := make(map[uint64][]auditCheckpoint)
+	for _, cp := range checkpoints {
+		if cp.seq < fromSeq || (toSeq != 0 && cp.seq > toSeq) {
+			continue
+		}
+		result.CheckpointsChecked++
+		if cp.keyID != s.signer.KeyID() || !s.signer.Verify(cp.seq, cp.hash, cp.createdAt, cp.signature) {
+			problem(dto.AuditChainBadSignature, cp.seq, 0,
+				"%s checkpoint of %s does not verify with key %s", cp.source, cp.createdAt.Format(time.RFC3339), s.signer.KeyID())
+			continue
+		}
+		bySeq[cp.seq] = append(bySeq[cp.seq], cp)
+	}
+
+	// The link of the first entry is checked against the entry before it
+	var prevHash string
+	linked := fromSeq == 1
+	if fromSeq > 1 {
+		prev, err := s.repo.GetBySeq(ctx, fromSeq-1)
+		if err == nil {
+			prevHash, linked = prev.Hash, true
+		}
+	}
+
+	next := fromSeq
+	for {
+		logs, err := s.repo.ListChain(ctx, next-1, auditVerifyPage)
+		if err != nil {
+			return nil, err
+		}
+		done := len(logs) < auditVerifyPage
+		for i := range logs {
+			log := &logs[i]
+			seq := *log.Seq
+			if toSeq != 0 && seq > toSeq {
+				done = true
+				break
+			}
+			switch {
+			case seq == next+1:
+				problem(dto.AuditChainGap, next, 0, "entry %d is missing", next)
+				linked = false
+			case seq != next:
+				problem(dto.AuditChainGap, next, 0, "entries %d to %d are missing", next, seq-1)
+				linked = false
+			}
+			if linked && log.PrevHash != prevHash {
+				problem(dto.AuditChainBrokenLink, seq, log.ID, "prev_hash does not match the hash of entry %d", seq-1)
+			}
+			if log.ChainHash() != log.Hash {
+				problem(dto.AuditChainModified, seq, log.ID, "entry does not match its hash")
+			}
+			for _, cp := range bySeq[seq] {
+				if cp.hash != log.Hash {
+					problem(dto.AuditChainCheckpointMismatch, seq, log.ID,
+						"entry hash differs from the %s checkpoint of %s", cp.source, cp.createdAt.Format(time.RFC3339))
+				}
+			}
+			delete(bySeq, seq)
+
+			// Continue from the stored hash so one modified entry is not
+			// also reported as a broken link
+			prevHash, linked = log.Hash, true
+			next = seq + 1
+			result.Checked++
+		}
+		if done {
+			break
+		}
+	}
+
+	// Checkpoints beyond the last entry mean the end of the chain was removed
+	missing := make([]uint64, 0, len(bySeq))
+	for seq := range bySeq {
+		missing = append(missing, seq)
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	for _, seq := range missing {
+		for _, cp := range bySeq[seq] {
+			problem(dto.AuditChainTruncated, seq, 0,
+				"the %s checkpoint of %s covers entry %d, which is missing", cp.source, cp.createdAt.Format(time.RFC3339), seq)
+		}
+	}
+
+	if toSeq == 0 && next > fromSeq {
+		result.ToSeq = next - 1
+	}
+	result.Valid = len(result.Problems) == 0
+	return result, nil
+}
+
+// auditCheckpoint is a checkpoint from the database or the checkpoint file.
+type auditCheckpoint struct {
+	source    string
+	seq       uint64
+	hash      string
+	keyID     string
+	signature string
+	createdAt time.Time
+}
+
+// loadCheckpoints returns the stored and exported checkpoints. Both are
+// checked, since rewriting the chain would mean rewriting the database
+// checkpoints as well, but not the exported file.
+func (s *auditService) loadCheckpoints(ctx context.Context) ([]auditCheckpoint, error) {
+	stored, err := s.repo.ListCheckpoints(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	exported, err := s.checkpoints.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read audit checkpoints: %w", err)
+	}
+
+	checkpoints := make([]auditCheckpoint, 0, len(stored)+len(exported))
+	for _, cp := range stored {
+		checkpoints = append(checkpoints, auditCheckpoint{
+			source:    "stored",
+			seq:       cp.Seq,
+			hash:      cp.Hash,
+			keyID:     cp.KeyID,
+			signature: cp.Signature,
+			createdAt: cp.CreatedAt,
+		})
+	}
+	for _, cp := range exported {
+		checkpoints = append(checkpoints, auditCheckpoint{
+			source:    "exported",
+			seq:       cp.Seq,
+			hash:      cp.Hash,
+			keyID:     cp.KeyID,
+			signature: cp.Signature,
+			createdAt: cp.CreatedAt,
+		})
+	}
+	return checkpoints, nil
+}
+
+func toAuditCheckpointResponse(cp *model.AuditCheckpoint) dto.AuditCheckpointResponse {
+	return dto.AuditCheckpointResponse{
+		ID:        cp.ID,
+		Seq:       cp.Seq,
+		Hash:      cp.Hash,
+		KeyID:     cp.KeyID,
+		Signature: cp.Signature,
+		CreatedAt: cp.CreatedAt,
+	}
+}
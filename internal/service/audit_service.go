@@ -2,78 +2,278 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/aruncs31s/skvms/internal/audittrail"
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/health"
 	"github.com/aruncs31s/skvms/internal/model"
 	"github.com/aruncs31s/skvms/internal/repository"
+	"github.com/aruncs31s/skvms/internal/requestid"
 )
 
 type AuditService interface {
-	Log(ctx context.Context, userID uint, username, action, details, ipAddress string) error
-	LogDeviceAction(
+	// Record writes a structured audit entry, storing the fields that differ
+	// between entry.Before and entry.After. The entry is durable once Record
+	// returns: it is queued in the outbox and appended to the hash chain by
+	// the writer.
+	Record(
 		ctx context.Context,
-		userID uint,
-		username,
-		action,
-		details,
-		ipAddress string,
-		deviceID uint,
+		entry dto.AuditEntry,
 	) error
-	List(
+	// Search returns a page of entries matching filter, newest first.
+	Search(
 		ctx context.Context,
-		action string,
-		limit int,
-	) ([]model.AuditLog, error)
+		filter dto.AuditLogFilter,
+	) (*dto.AuditLogPage, error)
+	Get(
+		ctx context.Context,
+		id uint,
+	) (*dto.AuditLogResponse, error)
 	ListByUser(ctx context.Context, userID uint, limit int) ([]model.AuditLog, error)
+
+	// StartWriter appends queued entries to the chain in the background
+	// until ctx is done, polling every interval besides waking on Record.
+	// Entries still queued then stay in the outbox for Flush or a restart.
+	StartWriter(
+		ctx context.Context,
+		interval time.Duration,
+	) *health.Worker
+	// Flush appends every queued entry to the chain, returning how many.
+	Flush(
+		ctx context.Context,
+	) (int, error)
+	// StartCheckpoints signs a checkpoint of the chain every interval until
+	// ctx is done.
+	StartCheckpoints(
+		ctx context.Context,
+		interval time.Duration,
+	) *health.Worker
+	// Checkpoint signs the end of the chain, stores the checkpoint and
+	// appends it to the checkpoint file. It returns the last checkpoint
+	// when the chain has not grown since.
+	Checkpoint(
+		ctx context.Context,
+	) (*dto.AuditCheckpointResponse, error)
+	ListCheckpoints(
+		ctx context.Context,
+		limit int,
+	) ([]dto.AuditCheckpointResponse, error)
+	// Verify recomputes the chain from fromSeq to toSeq (0 for the end)
+	// and checks it against the stored and exported checkpoints.
+	Verify(
+		ctx context.Context,
+		fromSeq uint64,
+		toSeq uint64,
+	) (*dto.AuditChainVerification, error)
 }
 
+// Page sizes of Search.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
 type auditService struct {
-	repo repository.AuditRepository
+	repo        repository.AuditRepository
+	outbox      *audittrail.Outbox
+	signer      *audittrail.Signer
+	checkpoints *audittrail.CheckpointFile
 }
 
-func NewAuditService(repo repository.AuditRepository) AuditService {
-	return &auditService{repo: repo}
+func NewAuditService(
+	repo repository.AuditRepository,
+	outbox *audittrail.Outbox,
+	signer *audittrail.Signer,
+	checkpoints *audittrail.CheckpointFile,
+) AuditService {
+	return &auditService{
+		repo:        repo,
+		outbox:      outbox,
+		signer:      signer,
+		checkpoints: checkpoints,
+	}
 }
 
-func (s *auditService) Log(
+func (s *auditService) Record(
 	ctx context.Context,
-	userID uint,
-	username,
-	action,
-	details,
-	ipAddress string,
+	entry dto.AuditEntry,
 ) error {
 	log := &model.AuditLog{
-		UserID:    userID,
-		Username:  username,
-		Action:    action,
-		Details:   details,
-		IPAddress: ipAddress,
+		UserID:       entry.UserID,
+		Username:     entry.Username,
+		Action:       entry.Action,
+		Details:      entry.Details,
+		IPAddress:    entry.IPAddress,
+		DeviceID:     entry.DeviceID,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		RequestID:    entry.RequestID,
+		Status:       entry.Status,
+		StatusCode:   entry.StatusCode,
+		Method:       entry.Method,
+		Path:         entry.Path,
+		EventID:      requestid.New(),
+		// The time is part of the hash, so fix it now at stored precision
+		CreatedAt: time.Now().Truncate(time.Second),
 	}
-	return s.repo.Create(ctx, log)
+	if log.RequestID == "" {
+		log.RequestID = requestid.FromContext(ctx)
+	}
+	if log.Status == "" {
+		log.Status = model.AuditSuccess
+	}
+	if changes := diffSnapshots(entry.Before, entry.After); len(changes) > 0 {
+		b, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		log.Changes = string(b)
+	}
+
+	data, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+	return s.outbox.Put(data)
 }
-func (s *auditService) LogDeviceAction(
+
+func (s *auditService) Search(
 	ctx context.Context,
-	userID uint,
-	username,
-	action,
-	details,
-	ipAddress string,
-	deviceID uint,
-) error {
-	log := &model.AuditLog{
-		UserID:    userID,
-		Username:  username,
-		Action:    action,
-		Details:   details,
-		IPAddress: ipAddress,
-		DeviceID:  &deviceID,
-	}
-	return s.repo.Create(ctx, log)
+	filter dto.AuditLogFilter,
+) (*dto.AuditLogPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+
+	logs, err := s.repo.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	page := &dto.AuditLogPage{Logs: make([]dto.AuditLogResponse, len(logs))}
+	for i := range logs {
+		page.Logs[i] = ToAuditLogResponse(&logs[i])
+	}
+	if len(logs) == filter.Limit {
+		page.NextCursor = logs[len(logs)-1].ID
+	}
+	return page, nil
 }
-func (s *auditService) List(ctx context.Context, action string, limit int) ([]model.AuditLog, error) {
-	return s.repo.List(ctx, action, limit)
+
+func (s *auditService) Get(
+	ctx context.Context,
+	id uint,
+) (*dto.AuditLogResponse, error) {
+	log, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	resp := ToAuditLogResponse(log)
+	return &resp, nil
 }
 
 func (s *auditService) ListByUser(ctx context.Context, userID uint, limit int) ([]model.AuditLog, error) {
 	return s.repo.ListByUser(ctx, userID, limit)
 }
+
+// ToAuditLogResponse converts a stored entry, decoding its changes.
+func ToAuditLogResponse(log *model.AuditLog) dto.AuditLogResponse {
+	resp := dto.AuditLogResponse{
+		ID:           log.ID,
+		UserID:       log.UserID,
+		Username:     log.Username,
+		Action:       log.Action,
+		ResourceType: log.ResourceType,
+		ResourceID:   log.ResourceID,
+		Details:      log.Details,
+		IPAddress:    log.IPAddress,
+		RequestID:    log.RequestID,
+		Status:       log.Status,
+		StatusCode:   log.StatusCode,
+		Method:       log.Method,
+		Path:         log.Path,
+		DeviceID:     log.DeviceID,
+		Seq:          log.Seq,
+		Hash:         log.Hash,
+		CreatedAt:    log.CreatedAt,
+	}
+	if log.Changes != "" {
+		_ = json.Unmarshal([]byte(log.Changes), &resp.Changes)
+	}
+	return resp
+}
+
+// redactedFields are never stored in audit changes, only marked as changed.
+var redactedFields = []string{"password", "secret", "token", "key"}
+
+// diffSnapshots compares the JSON forms of before and after field by field
+// and returns the fields that differ. A nil snapshot counts as empty, so
+// creations and deletions list every field.
+func diffSnapshots(before, after interface{}) map[string]dto.AuditChange {
+	if before == nil && after == nil {
+		return nil
+	}
+	b, a := snapshotFields(before), snapshotFields(after)
+
+	keys := make(map[string]bool, len(b)+len(a))
+	for k := range b {
+		keys[k] = true
+	}
+	for k := range a {
+		keys[k] = true
+	}
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	changes := make(map[string]dto.AuditChange)
+	for _, k := range names {
+		if reflect.DeepEqual(b[k], a[k]) {
+			continue
+		}
+		change := dto.AuditChange{Before: b[k], After: a[k]}
+		if redacted(k) {
+			change = dto.AuditChange{Before: "[redacted]", After: "[redacted]"}
+		}
+		changes[k] = change
+	}
+	return changes
+}
+
+// snapshotFields returns the top-level fields of v's JSON form. Values that
+// are not JSON objects are stored under "value".
+func snapshotFields(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		var value interface{}
+		_ = json.Unmarshal(data, &value)
+		return map[string]interface{}{"value": value}
+	}
+	return fields
+}
+
+func redacted(field string) bool {
+	field = strings.ToLower(field)
+	for _, r := range redactedFields {
+		if strings.Contains(field, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -19,12 +19,26 @@ type AuthService interface {
 }
 
 type authService struct {
-	repo      repository.UserRepository
-	jwtSecret []byte
+	repo       repository.UserRepository
+	jwtSecret  []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
 }
 
-func NewAuthService(repo repository.UserRepository, jwtSecret string) AuthService {
-	return &authService{repo: repo, jwtSecret: []byte(jwtSecret)}
+// NewAuthService creates the user authentication service. Access and
+// refresh tokens expire after accessTTL and refreshTTL.
+func NewAuthService(
+	repo repository.UserRepository,
+	jwtSecret string,
+	accessTTL time.Duration,
+	refreshTTL time.Duration,
+) AuthService {
+	return &authService{
+		repo:       repo,
+		jwtSecret:  []byte(jwtSecret),
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
 }
 
 func (s *authService) Login(
@@ -50,8 +64,9 @@ func (s *authService) generateTokenPair(user *model.User) (string, string, *mode
 	accessClaims := jwt.MapClaims{
 		"sub":        user.ID,
 		"username":   user.Username,
+		"role":       user.Role,
 		"token_type": "access",
-		"exp":        time.Now().Add(15 * time.Minute).Unix(),
+		"exp":        time.Now().Add(s.accessTTL).Unix(),
 		"iat":        time.Now().Unix(),
 	}
 	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
@@ -65,7 +80,7 @@ func (s *authService) generateTokenPair(user *model.User) (string, string, *mode
 		"sub":        user.ID,
 		"username":   user.Username,
 		"token_type": "refresh",
-		"exp":        time.Now().Add(7 * 24 * time.Hour).Unix(),
+		"exp":        time.Now().Add(s.refreshTTL).Unix(),
 		"iat":        time.Now().Unix(),
 	}
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+
+	"github.com/aruncs31s/skvms/internal/codegen"
+)
+
+// codegenSecrets resolves the secrets a codegen request refers to from the
+// stored WiFi profiles and the device token service.
+type codegenSecrets struct {
+	wifiProfiles WiFiProfileService
+	deviceAuth   DeviceAuthService
+}
+
+func NewCodegenSecrets(
+	wifiProfiles WiFiProfileService,
+	deviceAuth DeviceAuthService,
+) codegen.SecretResolver {
+	return &codegenSecrets{
+		wifiProfiles: wifiProfiles,
+		deviceAuth:   deviceAuth,
+	}
+}
+
+func (s *codegenSecrets) WiFiCredentials(
+	ctx context.Context,
+	userID uint,
+	profileID uint,
+) (string, string, error) {
+	return s.wifiProfiles.Credentials(ctx, profileID, userID)
+}
+
+func (s *codegenSecrets) DeviceToken(
+	ctx context.Context,
+	userID uint,
+	deviceID uint,
+) (string, error) {
+	return s.deviceAuth.GenerateDeviceToken(ctx, userID, deviceID)
+}
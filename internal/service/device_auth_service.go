@@ -19,6 +19,7 @@ type deviceAuthService struct {
 	deviceRepo repository.DeviceRepository
 	userRepo   repository.UserRepository
 	jwtSecret  []byte
+	tokenTTL   time.Duration
 }
 
 type DeviceTokenClaims struct {
@@ -27,11 +28,14 @@ type DeviceTokenClaims struct {
 	jwt.RegisteredClaims
 }
 
-func NewDeviceAuthService(deviceRepo repository.DeviceRepository, userRepo repository.UserRepository, jwtSecret string) DeviceAuthService {
+// NewDeviceAuthService creates the device token service. Device tokens
+// expire after tokenTTL.
+func NewDeviceAuthService(deviceRepo repository.DeviceRepository, userRepo repository.UserRepository, jwtSecret string, tokenTTL time.Duration) DeviceAuthService {
 	return &deviceAuthService{
 		deviceRepo: deviceRepo,
 		userRepo:   userRepo,
 		jwtSecret:  []byte(jwtSecret),
+		tokenTTL:   tokenTTL,
 	}
 }
 
@@ -64,7 +68,7 @@ func (s *deviceAuthService) GenerateDeviceToken(ctx context.Context, userID uint
 		UserID:   userID,
 		DeviceID: deviceID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(2400 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Subject:   fmt.Sprintf("device:%d:user:%d", deviceID, userID),
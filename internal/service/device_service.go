@@ -663,6 +663,7 @@ func (s *deviceService) ListMicrocontrollerDevices(
 			IP:               device.IPAddress,
 			MAC:              device.MACAddress,
 			FirmwareVersion:  device.FirmwareVersion,
+			ReportedVersion:  device.ReportedVersion,
 			UsedBy:           &device.UsedBy,
 			ConncetedSensors: nil,
 		})
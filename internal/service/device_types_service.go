@@ -2,12 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strings"
 
 	"github.com/aruncs31s/skvms/internal/dto"
 	"github.com/aruncs31s/skvms/internal/model"
 	"github.com/aruncs31s/skvms/internal/repository"
 )
 
+// ErrNotMicrocontroller is returned when a board profile is set on a device
+// type that does not run firmware.
+var ErrNotMicrocontroller = errors.New("board profiles can only be set for microcontroller device types")
+
 type DeviceTypesService interface {
 	ListDeviceTypes(
 		ctx context.Context,
@@ -29,16 +35,30 @@ type DeviceTypesService interface {
 		ctx context.Context,
 		deviceID uint,
 	) (map[string]interface{}, error)
+	ListBoardProfiles(ctx context.Context) ([]dto.BoardProfileResponse, error)
+	GetBoardProfile(
+		ctx context.Context,
+		deviceTypeID uint,
+	) (*dto.BoardProfileResponse, error)
+	SetBoardProfile(
+		ctx context.Context,
+		deviceTypeID uint,
+		req dto.BoardProfileRequest,
+		userID uint,
+	) (*dto.BoardProfileResponse, error)
 }
 type deviceTypesService struct {
-	deviceTypesRepo repository.DeviceTypesRepository
+	deviceTypesRepo  repository.DeviceTypesRepository
+	boardProfileRepo repository.BoardProfileRepository
 }
 
 func NewDeviceTypesService(
 	deviceTypesRepo repository.DeviceTypesRepository,
+	boardProfileRepo repository.BoardProfileRepository,
 ) DeviceTypesService {
 	return &deviceTypesService{
-		deviceTypesRepo: deviceTypesRepo,
+		deviceTypesRepo:  deviceTypesRepo,
+		boardProfileRepo: boardProfileRepo,
 	}
 }
 
@@ -132,3 +152,80 @@ func (s *deviceTypesService) GetDeviceTypeByDeviceID(
 	return s.deviceTypesRepo.GetDeviceTypeByDeviceID(ctx, deviceID)
 
 }
+
+func (s *deviceTypesService) ListBoardProfiles(ctx context.Context) ([]dto.BoardProfileResponse, error) {
+	profiles, err := s.boardProfileRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]dto.BoardProfileResponse, 0, len(profiles))
+	for _, p := range profiles {
+		responses = append(responses, toBoardProfileResponse(&p))
+	}
+	return responses, nil
+}
+
+func (s *deviceTypesService) GetBoardProfile(
+	ctx context.Context,
+	deviceTypeID uint,
+) (*dto.BoardProfileResponse, error) {
+	profile, err := s.boardProfileRepo.GetByDeviceTypeID(ctx, deviceTypeID)
+	if err != nil {
+		return nil, err
+	}
+	resp := toBoardProfileResponse(profile)
+	return &resp, nil
+}
+
+func (s *deviceTypesService) SetBoardProfile(
+	ctx context.Context,
+	deviceTypeID uint,
+	req dto.BoardProfileRequest,
+	userID uint,
+) (*dto.BoardProfileResponse, error) {
+	deviceType, err := s.deviceTypesRepo.GetDeviceByID(ctx, deviceTypeID)
+	if err != nil {
+		return nil, err
+	}
+	if deviceType.HardwareType != model.HardwareTypeMicroController {
+		return nil, ErrNotMicrocontroller
+	}
+
+	var libs []string
+	for _, lib := range req.Libraries {
+		if lib = strings.TrimSpace(lib); lib != "" {
+			libs = append(libs, lib)
+		}
+	}
+
+	profile := &model.BoardProfile{
+		DeviceTypeID:    deviceTypeID,
+		FQBN:            strings.TrimSpace(req.FQBN),
+		PlatformIOEnv:   strings.TrimSpace(req.PlatformIOEnv),
+		PartitionScheme: strings.TrimSpace(req.PartitionScheme),
+		FlashSize:       strings.TrimSpace(req.FlashSize),
+		Libraries:       strings.Join(libs, ","),
+		CreatedBy:       userID,
+		UpdatedBy:       userID,
+	}
+	if err := s.boardProfileRepo.Upsert(ctx, profile); err != nil {
+		return nil, err
+	}
+	return s.GetBoardProfile(ctx, deviceTypeID)
+}
+
+func toBoardProfileResponse(p *model.BoardProfile) dto.BoardProfileResponse {
+	libs := p.LibraryList()
+	if libs == nil {
+		libs = []string{}
+	}
+	return dto.BoardProfileResponse{
+		DeviceTypeID:    p.DeviceTypeID,
+		DeviceType:      p.DeviceType.Name,
+		FQBN:            p.FQBN,
+		PlatformIOEnv:   p.PlatformIOEnv,
+		PartitionScheme: p.PartitionScheme,
+		FlashSize:       p.FlashSize,
+		Libraries:       libs,
+	}
+}
@@ -0,0 +1,402 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/export"
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTemplate reports a template that is malformed, fails to render
+// with sample data, or does not fit the data being exported. When the
+// template itself is at fault the error also wraps an *export.TemplateError.
+var ErrInvalidTemplate = errors.New("invalid export template")
+
+// ExportTemplateService manages the library of PDF export templates.
+// Templates are referenced by ID and rendered in a sandbox with a restricted
+// set of functions; they never name files on the server.
+type ExportTemplateService interface {
+	// List returns the templates of a data type, or all when dataType is empty.
+	List(
+		ctx context.Context,
+		dataType string,
+	) ([]dto.ExportTemplateResponse, error)
+	// Get returns a template with the source of its latest version.
+	Get(
+		ctx context.Context,
+		id uint,
+	) (*dto.ExportTemplateResponse, error)
+	Create(
+		ctx context.Context,
+		req dto.ExportTemplateRequest,
+		userID uint,
+	) (*dto.ExportTemplateResponse, error)
+	Update(
+		ctx context.Context,
+		id uint,
+		req dto.UpdateExportTemplateRequest,
+		userID uint,
+	) (*dto.ExportTemplateResponse, error)
+	Delete(
+		ctx context.Context,
+		id uint,
+	) error
+	// AddVersion uploads new source for a template, which exports use from
+	// then on.
+	AddVersion(
+		ctx context.Context,
+		id uint,
+		req dto.ExportTemplateVersionRequest,
+		userID uint,
+	) (*dto.ExportTemplateVersionResponse, error)
+	ListVersions(
+		ctx context.Context,
+		id uint,
+	) ([]dto.ExportTemplateVersionResponse, error)
+	GetVersion(
+		ctx context.Context,
+		id uint,
+		version int,
+	) (*dto.ExportTemplateVersionResponse, error)
+	// SetDefault makes a template the default of its data type.
+	SetDefault(
+		ctx context.Context,
+		id uint,
+	) error
+	// ClearDefault stops a template being the default, so its data type
+	// falls back to the built-in template.
+	ClearDefault(
+		ctx context.Context,
+		id uint,
+		userID uint,
+	) error
+	// Preview renders a template with sample data to w, as HTML or PDF.
+	Preview(
+		ctx context.Context,
+		req dto.TemplatePreviewRequest,
+		asPDF bool,
+		w io.Writer,
+	) error
+	// Resolve returns the source to export dataType with. A zero id selects
+	// the default template of the data type; an empty result means the
+	// built-in template.
+	Resolve(
+		ctx context.Context,
+		dataType string,
+		id uint,
+	) (string, error)
+}
+
+type exportTemplateService struct {
+	repo          repository.ExportTemplateRepository
+	exportService *export.Service
+}
+
+func NewExportTemplateService(
+	repo repository.ExportTemplateRepository,
+	exportService *export.Service,
+) ExportTemplateService {
+	return &exportTemplateService{
+		repo:          repo,
+		exportService: exportService,
+	}
+}
+
+func (s *exportTemplateService) List(
+	ctx context.Context,
+	dataType string,
+) ([]dto.ExportTemplateResponse, error) {
+	templates, err := s.repo.List(ctx, dataType)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]dto.ExportTemplateResponse, len(templates))
+	for i := range templates {
+		responses[i] = toExportTemplateResponse(&templates[i], "")
+	}
+	return responses, nil
+}
+
+func (s *exportTemplateService) Get(
+	ctx context.Context,
+	id uint,
+) (*dto.ExportTemplateResponse, error) {
+	template, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.repo.GetVersion(ctx, id, template.LatestVersion)
+	if err != nil {
+		return nil, err
+	}
+	resp := toExportTemplateResponse(template, version.Content)
+	return &resp, nil
+}
+
+func (s *exportTemplateService) Create(
+	ctx context.Context,
+	req dto.ExportTemplateRequest,
+	userID uint,
+) (*dto.ExportTemplateResponse, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+	}
+	if err := s.validate(ctx, req.DataType, req.Content); err != nil {
+		return nil, err
+	}
+
+	template := &model.ExportTemplate{
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		DataType:    req.DataType,
+		CreatedBy:   userID,
+		UpdatedBy:   userID,
+	}
+	version := &model.ExportTemplateVersion{
+		Content:   req.Content,
+		Comment:   req.Comment,
+		CreatedBy: userID,
+	}
+	if err := s.repo.Create(ctx, template, version); err != nil {
+		return nil, err
+	}
+	resp := toExportTemplateResponse(template, version.Content)
+	return &resp, nil
+}
+
+func (s *exportTemplateService) Update(
+	ctx context.Context,
+	id uint,
+	req dto.UpdateExportTemplateRequest,
+	userID uint,
+) (*dto.ExportTemplateResponse, error) {
+	template, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			return nil, fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+		}
+		template.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+	template.UpdatedBy = userID
+	if err := s.repo.Update(ctx, template); err != nil {
+		return nil, err
+	}
+	resp := toExportTemplateResponse(template, "")
+	return &resp, nil
+}
+
+func (s *exportTemplateService) Delete(
+	ctx context.Context,
+	id uint,
+) error {
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *exportTemplateService) AddVersion(
+	ctx context.Context,
+	id uint,
+	req dto.ExportTemplateVersionRequest,
+	userID uint,
+) (*dto.ExportTemplateVersionResponse, error) {
+	template, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(ctx, template.DataType, req.Content); err != nil {
+		return nil, err
+	}
+
+	version := &model.ExportTemplateVersion{
+		TemplateID: id,
+		Content:    req.Content,
+		Comment:    req.Comment,
+		CreatedBy:  userID,
+	}
+	if err := s.repo.AddVersion(ctx, version); err != nil {
+		return nil, err
+	}
+	resp := toExportTemplateVersionResponse(version, false)
+	return &resp, nil
+}
+
+func (s *exportTemplateService) ListVersions(
+	ctx context.Context,
+	id uint,
+) ([]dto.ExportTemplateVersionResponse, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	versions, err := s.repo.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]dto.ExportTemplateVersionResponse, len(versions))
+	for i := range versions {
+		responses[i] = toExportTemplateVersionResponse(&versions[i], false)
+	}
+	return responses, nil
+}
+
+func (s *exportTemplateService) GetVersion(
+	ctx context.Context,
+	id uint,
+	version int,
+) (*dto.ExportTemplateVersionResponse, error) {
+	v, err := s.repo.GetVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	resp := toExportTemplateVersionResponse(v, true)
+	return &resp, nil
+}
+
+func (s *exportTemplateService) SetDefault(
+	ctx context.Context,
+	id uint,
+) error {
+	return s.repo.SetDefault(ctx, id)
+}
+
+func (s *exportTemplateService) ClearDefault(
+	ctx context.Context,
+	id uint,
+	userID uint,
+) error {
+	template, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	template.IsDefault = false
+	template.UpdatedBy = userID
+	return s.repo.Update(ctx, template)
+}
+
+func (s *exportTemplateService) Preview(
+	ctx context.Context,
+	req dto.TemplatePreviewRequest,
+	asPDF bool,
+	w io.Writer,
+) error {
+	dataType, source := req.DataType, req.Content
+	if req.TemplateID != 0 {
+		template, err := s.repo.GetByID(ctx, req.TemplateID)
+		if err != nil {
+			return err
+		}
+		number := req.Version
+		if number == 0 {
+			number = template.LatestVersion
+		}
+		version, err := s.repo.GetVersion(ctx, template.ID, number)
+		if err != nil {
+			return err
+		}
+		dataType, source = template.DataType, version.Content
+	} else if source == "" {
+		return fmt.Errorf("%w: template_id or content is required", ErrInvalidTemplate)
+	}
+	if err := checkTemplateDataType(dataType); err != nil {
+		return err
+	}
+
+	err := s.exportService.PreviewTemplate(ctx, dataType, source, asPDF, w)
+	var templateErr *export.TemplateError
+	if errors.As(err, &templateErr) {
+		return fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
+	}
+	return err
+}
+
+func (s *exportTemplateService) Resolve(
+	ctx context.Context,
+	dataType string,
+	id uint,
+) (string, error) {
+	var template *model.ExportTemplate
+	var err error
+	if id == 0 {
+		template, err = s.repo.GetDefault(ctx, dataType)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+	} else {
+		template, err = s.repo.GetByID(ctx, id)
+	}
+	if err != nil {
+		return "", err
+	}
+	if template.DataType != dataType {
+		return "", fmt.Errorf("%w: template %d is for %s, not %s",
+			ErrInvalidTemplate, template.ID, template.DataType, dataType)
+	}
+
+	version, err := s.repo.GetVersion(ctx, template.ID, template.LatestVersion)
+	if err != nil {
+		return "", err
+	}
+	return version.Content, nil
+}
+
+// validate checks the data type and renders source with sample data.
+func (s *exportTemplateService) validate(ctx context.Context, dataType, source string) error {
+	if err := checkTemplateDataType(dataType); err != nil {
+		return err
+	}
+	if err := s.exportService.ValidateTemplate(ctx, dataType, source); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
+	}
+	return nil
+}
+
+func checkTemplateDataType(dataType string) error {
+	for _, t := range export.TemplateDataTypes {
+		if dataType == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: data_type must be one of %s",
+		ErrInvalidTemplate, strings.Join(export.TemplateDataTypes, ", "))
+}
+
+func toExportTemplateResponse(t *model.ExportTemplate, content string) dto.ExportTemplateResponse {
+	return dto.ExportTemplateResponse{
+		ID:            t.ID,
+		Name:          t.Name,
+		Description:   t.Description,
+		DataType:      t.DataType,
+		LatestVersion: t.LatestVersion,
+		IsDefault:     t.IsDefault,
+		Content:       content,
+		CreatedBy:     t.CreatedBy,
+		UpdatedBy:     t.UpdatedBy,
+		CreatedAt:     t.CreatedAt,
+		UpdatedAt:     t.UpdatedAt,
+	}
+}
+
+func toExportTemplateVersionResponse(v *model.ExportTemplateVersion, withContent bool) dto.ExportTemplateVersionResponse {
+	resp := dto.ExportTemplateVersionResponse{
+		TemplateID: v.TemplateID,
+		Version:    v.Version,
+		Comment:    v.Comment,
+		CreatedBy:  v.CreatedBy,
+		CreatedAt:  v.CreatedAt,
+	}
+	if withContent {
+		resp.Content = v.Content
+	}
+	return resp
+}
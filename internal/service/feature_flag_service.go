@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/repository"
+)
+
+var (
+	ErrOverrideScopeNotFound = errors.New("override scope not found")
+	ErrOverrideAccess        = errors.New("no access to the override scope")
+)
+
+// FeatureFlagService resolves the feature flags of a device. A flag's default
+// comes from the features of the device's version and its previous versions;
+// a location override replaces it and a device override replaces both.
+// Overrides are set and cleared by admins, the owner of the device and the
+// users of the location, otherwise ErrOverrideAccess is returned.
+type FeatureFlagService interface {
+	EffectiveFlags(
+		ctx context.Context,
+		deviceID uint,
+	) (*dto.DeviceFeatureFlags, error)
+	// DeviceConfig returns the effective flags as the compact document
+	// devices fetch.
+	DeviceConfig(
+		ctx context.Context,
+		deviceID uint,
+	) (*dto.DeviceFlagConfig, error)
+	ListOverrides(
+		ctx context.Context,
+		scope string,
+		scopeID uint,
+	) ([]dto.FeatureOverrideResponse, error)
+	GetOverride(
+		ctx context.Context,
+		scope string,
+		scopeID uint,
+		featureID uint,
+	) (*dto.FeatureOverrideResponse, error)
+	SetOverride(
+		ctx context.Context,
+		scope string,
+		scopeID uint,
+		featureID uint,
+		enabled bool,
+		userID uint,
+	) (*dto.FeatureOverrideResponse, error)
+	ClearOverride(
+		ctx context.Context,
+		scope string,
+		scopeID uint,
+		featureID uint,
+		userID uint,
+	) error
+}
+
+type featureFlagService struct {
+	repo        repository.FeatureFlagRepository
+	versionRepo repository.VersionRepository
+	userRepo    repository.UserRepository
+}
+
+func NewFeatureFlagService(
+	repo repository.FeatureFlagRepository,
+	versionRepo repository.VersionRepository,
+	userRepo repository.UserRepository,
+) FeatureFlagService {
+	return &featureFlagService{
+		repo:        repo,
+		versionRepo: versionRepo,
+		userRepo:    userRepo,
+	}
+}
+
+// checkAccess returns ErrOverrideAccess unless the user is an admin, owns the
+// device or belongs to the location the override is for. A device's location
+// is the one it is currently assigned to.
+func (s *featureFlagService) checkAccess(
+	ctx context.Context,
+	scope string,
+	scopeID uint,
+	userID uint,
+) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrOverrideAccess
+	}
+	if user.Role == "admin" {
+		return nil
+	}
+
+	locationID := &scopeID
+	if scope == model.OverrideScopeDevice {
+		ownerID, err := s.repo.DeviceOwnerID(ctx, scopeID)
+		if err != nil {
+			return err
+		}
+		if ownerID == userID {
+			return nil
+		}
+		if locationID, err = s.repo.DeviceLocationID(ctx, scopeID); err != nil {
+			return err
+		}
+	}
+	if locationID != nil && user.LocationID != nil && *user.LocationID == *locationID {
+		return nil
+	}
+	return ErrOverrideAccess
+}
+
+func (s *featureFlagService) EffectiveFlags(
+	ctx context.Context,
+	deviceID uint,
+) (*dto.DeviceFeatureFlags, error) {
+	chain, err := s.versionRepo.GetCurrnetAllPreviousVersions(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	locationID, err := s.repo.DeviceLocationID(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := s.repo.ListOverridesForDevice(ctx, deviceID, locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.DeviceFeatureFlags{
+		DeviceID:   deviceID,
+		LocationID: locationID,
+		Flags:      []dto.FeatureFlagView{},
+	}
+	if len(chain) > 0 {
+		result.Version = chain[0].Name
+	}
+
+	// Newest version first, so a feature is attributed to the newest version
+	// that still ships it
+	index := make(map[uint]int)
+	for _, version := range chain {
+		for _, f := range version.Features {
+			if _, ok := index[f.ID]; ok {
+				continue
+			}
+			index[f.ID] = len(result.Flags)
+			result.Flags = append(result.Flags, dto.FeatureFlagView{
+				FeatureID: f.ID,
+				Name:      f.FeatureName,
+				Enabled:   f.Enabled,
+				Source:    "version",
+				Version:   version.Name,
+			})
+		}
+	}
+
+	// Location overrides first so device overrides win
+	for _, scope := range []string{model.OverrideScopeLocation, model.OverrideScopeDevice} {
+		for _, o := range overrides {
+			if o.Scope != scope {
+				continue
+			}
+			i, ok := index[o.FeatureID]
+			if !ok {
+				// Overrides only apply to features the device's firmware has
+				continue
+			}
+			result.Flags[i].Enabled = o.Enabled
+			result.Flags[i].Source = scope
+		}
+	}
+	return result, nil
+}
+
+func (s *featureFlagService) DeviceConfig(
+	ctx context.Context,
+	deviceID uint,
+) (*dto.DeviceFlagConfig, error) {
+	flags, err := s.EffectiveFlags(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &dto.DeviceFlagConfig{
+		Version: flags.Version,
+		Flags:   make(map[string]bool, len(flags.Flags)),
+	}
+	for _, f := range flags.Flags {
+		config.Flags[f.Name] = f.Enabled
+	}
+
+	// Map keys are marshalled sorted, so equal flags give an equal rev
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	config.Rev = hex.EncodeToString(sum[:8])
+	return config, nil
+}
+
+func (s *featureFlagService) ListOverrides(
+	ctx context.Context,
+	scope string,
+	scopeID uint,
+) ([]dto.FeatureOverrideResponse, error) {
+	overrides, err := s.repo.ListOverrides(ctx, scope, scopeID)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]dto.FeatureOverrideResponse, 0, len(overrides))
+	for _, o := range overrides {
+		responses = append(responses, toFeatureOverrideResponse(&o, o.Feature.FeatureName))
+	}
+	return responses, nil
+}
+
+func (s *featureFlagService) GetOverride(
+	ctx context.Context,
+	scope string,
+	scopeID uint,
+	featureID uint,
+) (*dto.FeatureOverrideResponse, error) {
+	override, err := s.repo.GetOverride(ctx, featureID, scope, scopeID)
+	if err != nil {
+		return nil, err
+	}
+	resp := toFeatureOverrideResponse(override, override.Feature.FeatureName)
+	return &resp, nil
+}
+
+func (s *featureFlagService) SetOverride(
+	ctx context.Context,
+	scope string,
+	scopeID uint,
+	featureID uint,
+	enabled bool,
+	userID uint,
+) (*dto.FeatureOverrideResponse, error) {
+	exists, err := s.repo.ScopeExists(ctx, scope, scopeID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrOverrideScopeNotFound
+	}
+	if err := s.checkAccess(ctx, scope, scopeID, userID); err != nil {
+		return nil, err
+	}
+	feature, err := s.repo.GetFeature(ctx, featureID)
+	if err != nil {
+		return nil, err
+	}
+
+	override := &model.FeatureOverride{
+		FeatureID: featureID,
+		Scope:     scope,
+		ScopeID:   scopeID,
+		Enabled:   enabled,
+		CreatedBy: userID,
+		UpdatedBy: userID,
+	}
+	if err := s.repo.UpsertOverride(ctx, override); err != nil {
+		return nil, err
+	}
+	resp := toFeatureOverrideResponse(override, feature.FeatureName)
+	return &resp, nil
+}
+
+func (s *featureFlagService) ClearOverride(
+	ctx context.Context,
+	scope string,
+	scopeID uint,
+	featureID uint,
+	userID uint,
+) error {
+	if err := s.checkAccess(ctx, scope, scopeID, userID); err != nil {
+		return err
+	}
+	return s.repo.DeleteOverride(ctx, featureID, scope, scopeID)
+}
+
+func toFeatureOverrideResponse(o *model.FeatureOverride, name string) dto.FeatureOverrideResponse {
+	return dto.FeatureOverrideResponse{
+		FeatureID: o.FeatureID,
+		Name:      name,
+		Scope:     o.Scope,
+		ScopeID:   o.ScopeID,
+		Enabled:   o.Enabled,
+		UpdatedBy: o.UpdatedBy,
+	}
+}
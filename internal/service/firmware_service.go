@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/repository"
+	"github.com/aruncs31s/skvms/utils"
+)
+
+var ErrInvalidFirmwareEvent = errors.New("event must be boot or heartbeat")
+
+// FirmwareService records the firmware devices report running and compares
+// it with their assigned version and the latest release.
+type FirmwareService interface {
+	Report(
+		ctx context.Context,
+		deviceID uint,
+		req dto.FirmwareReportRequest,
+	) (*dto.DeviceFirmwareResponse, error)
+	GetByDevice(
+		ctx context.Context,
+		deviceID uint,
+	) (*dto.DeviceFirmwareResponse, error)
+	DriftReport(
+		ctx context.Context,
+	) (*dto.FirmwareDriftReport, error)
+}
+
+type firmwareService struct {
+	repo repository.DeviceFirmwareRepository
+}
+
+func NewFirmwareService(repo repository.DeviceFirmwareRepository) FirmwareService {
+	return &firmwareService{repo: repo}
+}
+
+func (s *firmwareService) Report(
+	ctx context.Context,
+	deviceID uint,
+	req dto.FirmwareReportRequest,
+) (*dto.DeviceFirmwareResponse, error) {
+	event := req.Event
+	if event == "" {
+		event = model.FirmwareEventHeartbeat
+	}
+	if event != model.FirmwareEventBoot && event != model.FirmwareEventHeartbeat {
+		return nil, ErrInvalidFirmwareEvent
+	}
+
+	now := time.Now()
+	firmware := &model.DeviceFirmware{
+		DeviceID:   deviceID,
+		Version:    strings.TrimSpace(req.Version),
+		BuildID:    req.BuildID,
+		Checksum:   strings.ToLower(req.Checksum),
+		LastEvent:  event,
+		ReportedAt: now,
+	}
+	if event == model.FirmwareEventBoot {
+		firmware.BootedAt = &now
+	}
+	if err := s.repo.Upsert(ctx, firmware); err != nil {
+		return nil, err
+	}
+
+	// Re-read so BootedAt of an earlier boot is returned with a heartbeat
+	return s.GetByDevice(ctx, deviceID)
+}
+
+func (s *firmwareService) GetByDevice(
+	ctx context.Context,
+	deviceID uint,
+) (*dto.DeviceFirmwareResponse, error) {
+	firmware, err := s.repo.GetByDeviceID(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.DeviceFirmwareResponse{
+		DeviceID:   firmware.DeviceID,
+		Version:    firmware.Version,
+		BuildID:    firmware.BuildID,
+		Checksum:   firmware.Checksum,
+		LastEvent:  firmware.LastEvent,
+		BootedAt:   firmware.BootedAt,
+		ReportedAt: firmware.ReportedAt,
+	}, nil
+}
+
+// DriftReport lists the microcontrollers that never reported their firmware,
+// run something other than their assigned version, or lag the latest release.
+func (s *firmwareService) DriftReport(
+	ctx context.Context,
+) (*dto.FirmwareDriftReport, error) {
+	names, err := s.repo.ListVersionNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest := utils.LatestVersion(names)
+
+	rows, err := s.repo.ListMicrocontrollerFirmware(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &dto.FirmwareDriftReport{
+		LatestVersion: latest,
+		TotalDevices:  len(rows),
+		Drifted:       []dto.FirmwareDriftEntry{},
+	}
+	for _, row := range rows {
+		statuses := driftStatuses(row, latest)
+		if len(statuses) == 0 {
+			continue
+		}
+		report.Drifted = append(report.Drifted, dto.FirmwareDriftEntry{
+			DeviceID:        row.DeviceID,
+			Name:            row.Name,
+			Type:            row.Type,
+			AssignedVersion: row.AssignedVersion,
+			ReportedVersion: row.ReportedVersion,
+			BuildID:         row.BuildID,
+			Checksum:        row.Checksum,
+			ReportedAt:      row.ReportedAt,
+			Statuses:        statuses,
+		})
+	}
+	return report, nil
+}
+
+func driftStatuses(row model.DeviceFirmwareView, latest string) []string {
+	if row.ReportedVersion == "" {
+		return []string{dto.DriftUnreported}
+	}
+	var statuses []string
+	if row.AssignedVersion != "" && utils.CompareVersions(row.ReportedVersion, row.AssignedVersion) != 0 {
+		statuses = append(statuses, dto.DriftMismatch)
+	}
+	if latest != "" && utils.CompareVersions(row.ReportedVersion, latest) < 0 {
+		statuses = append(statuses, dto.DriftOutdated)
+	}
+	return statuses
+}
@@ -16,10 +16,11 @@ type LocationService interface {
 }
 
 type LocationWriter interface {
+	// Create stores a location and returns its ID.
 	Create(
 		ctx context.Context,
 		location dto.CreateLocationRequest,
-	) error
+	) (uint, error)
 	Update(
 		ctx context.Context,
 		id uint,
@@ -168,19 +169,19 @@ func (s *locationService) GetByCode(ctx context.Context, code string) (*dto.Loca
 func (s *locationService) Create(
 	ctx context.Context,
 	location dto.CreateLocationRequest,
-) error {
-	err := s.repo.Create(ctx, &model.Location{
+) (uint, error) {
+	created := &model.Location{
 		Code:        location.Code,
 		Name:        location.Name,
 		Description: location.Description,
 		State:       location.State,
 		City:        location.City,
 		PinCode:     location.PinCode,
-	})
-	if err != nil {
-		return err
 	}
-	return nil
+	if err := s.repo.Create(ctx, created); err != nil {
+		return 0, err
+	}
+	return created.ID, nil
 }
 
 func (s *locationService) Update(
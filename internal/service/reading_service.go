@@ -2,9 +2,11 @@ package service
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/metrics"
 	"github.com/aruncs31s/skvms/internal/model"
 	"github.com/aruncs31s/skvms/internal/repository"
 	"github.com/aruncs31s/skvms/utils"
@@ -22,6 +24,16 @@ type ReadingService interface {
 		startTime,
 		endTime time.Time,
 	) ([]model.Reading, error)
+	// ListPageByDevicesAndDateRange pages through the readings of several
+	// devices, newest first, for streaming exports.
+	ListPageByDevicesAndDateRange(
+		ctx context.Context,
+		deviceIDs []uint,
+		startTime time.Time,
+		endTime time.Time,
+		after *model.Reading,
+		limit int,
+	) ([]model.Reading, error)
 	ListByDeviceWithInterval(
 		ctx context.Context,
 		deviceID uint,
@@ -57,6 +69,9 @@ type ReadingService interface {
 type readingService struct {
 	repo   repository.ReadingRepository
 	device DeviceService
+
+	// deviceTypes caches the type name of reporting devices for metrics
+	deviceTypes sync.Map
 }
 
 func NewReadingService(
@@ -100,6 +115,23 @@ func (s *readingService) ListByDeviceAndDateRange(
 		endTime)
 }
 
+func (s *readingService) ListPageByDevicesAndDateRange(
+	ctx context.Context,
+	deviceIDs []uint,
+	startTime time.Time,
+	endTime time.Time,
+	after *model.Reading,
+	limit int,
+) ([]model.Reading, error) {
+	return s.repo.ListPageByDevicesAndDateRange(
+		ctx,
+		deviceIDs,
+		startTime,
+		endTime,
+		after,
+		limit)
+}
+
 func (s *readingService) ListByDeviceWithInterval(
 	ctx context.Context,
 	deviceID uint,
@@ -132,10 +164,29 @@ func (s *readingService) RecordEssentialReadings(
 		Current:   req.Current,
 		CreatedAt: time.Now(),
 	}
-	return s.repo.Create(
+	created, err := s.repo.Create(
 		ctx,
 		reading,
 	)
+	if err != nil {
+		return nil, err
+	}
+	metrics.AddReadings(s.deviceType(ctx, deviceID), "api", 1)
+	return created, nil
+}
+
+// deviceType returns the type name of a device, "unknown" if it cannot be
+// loaded. Names are cached, as devices report often and rarely change type.
+func (s *readingService) deviceType(ctx context.Context, deviceID uint) string {
+	if name, ok := s.deviceTypes.Load(deviceID); ok {
+		return name.(string)
+	}
+	device, err := s.device.GetDevice(ctx, deviceID)
+	if err != nil || device == nil {
+		return "unknown"
+	}
+	s.deviceTypes.Store(deviceID, device.Type)
+	return device.Type
 }
 func (s *readingService) GetReadingsOfConnectedDevice(
 	ctx context.Context,
@@ -0,0 +1,633 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/export"
+	exportdto "github.com/aruncs31s/skvms/internal/export/dto"
+	"github.com/aruncs31s/skvms/internal/health"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/aruncs31s/skvms/internal/mailer"
+	"github.com/aruncs31s/skvms/internal/metrics"
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/repository"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidReport     = errors.New("invalid report definition")
+	ErrReportFileMissing = errors.New("report file is not available")
+)
+
+// maxReportAttachment is the largest rendered report sent as an email
+// attachment. Bigger reports are only announced and kept for download.
+const maxReportAttachment = 10 << 20
+
+// reportRunHistory is the number of runs returned for a definition.
+const reportRunHistory = 100
+
+type ReportService interface {
+	List(
+		ctx context.Context,
+	) ([]dto.ReportDefinitionResponse, error)
+	Get(
+		ctx context.Context,
+		id uint,
+	) (*dto.ReportDefinitionResponse, error)
+	Create(
+		ctx context.Context,
+		req dto.ReportDefinitionRequest,
+		userID uint,
+	) (*dto.ReportDefinitionResponse, error)
+	Update(
+		ctx context.Context,
+		id uint,
+		req dto.ReportDefinitionRequest,
+		userID uint,
+	) (*dto.ReportDefinitionResponse, error)
+	Delete(
+		ctx context.Context,
+		id uint,
+	) error
+	// RunNow renders and delivers a report immediately, outside its schedule.
+	RunNow(
+		ctx context.Context,
+		id uint,
+	) (*dto.ReportRunResponse, error)
+	// RunDue renders and delivers every report whose next run has passed.
+	RunDue(
+		ctx context.Context,
+		now time.Time,
+	) error
+	ListRuns(
+		ctx context.Context,
+		definitionID uint,
+	) ([]dto.ReportRunResponse, error)
+	// RunFile returns the stored file of a run and the name and content type
+	// to serve it with.
+	RunFile(
+		ctx context.Context,
+		runID uint,
+	) (path string, filename string, contentType string, err error)
+	// StartScheduler checks for due reports every interval until ctx is done.
+	// Reports being run then are finished first.
+	StartScheduler(
+		ctx context.Context,
+		interval time.Duration,
+	) *health.Worker
+}
+
+type reportService struct {
+	repo            repository.ReportRepository
+	exportService   *export.Service
+	readingService  ReadingService
+	deviceService   DeviceService
+	templateService ExportTemplateService
+	mailer          mailer.Mailer
+	reportsDir      string
+}
+
+func NewReportService(
+	repo repository.ReportRepository,
+	exportService *export.Service,
+	readingService ReadingService,
+	deviceService DeviceService,
+	templateService ExportTemplateService,
+	mail mailer.Mailer,
+	reportsDir string,
+) ReportService {
+	if reportsDir == "" {
+		reportsDir = "./reports"
+	}
+	return &reportService{
+		repo:            repo,
+		exportService:   exportService,
+		readingService:  readingService,
+		deviceService:   deviceService,
+		templateService: templateService,
+		mailer:          mail,
+		reportsDir:      reportsDir,
+	}
+}
+
+func (s *reportService) List(
+	ctx context.Context,
+) ([]dto.ReportDefinitionResponse, error) {
+	definitions, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]dto.ReportDefinitionResponse, 0, len(definitions))
+	for i := range definitions {
+		responses = append(responses, toReportDefinitionResponse(&definitions[i]))
+	}
+	return responses, nil
+}
+
+func (s *reportService) Get(
+	ctx context.Context,
+	id uint,
+) (*dto.ReportDefinitionResponse, error) {
+	definition, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	resp := toReportDefinitionResponse(definition)
+	return &resp, nil
+}
+
+func (s *reportService) Create(
+	ctx context.Context,
+	req dto.ReportDefinitionRequest,
+	userID uint,
+) (*dto.ReportDefinitionResponse, error) {
+	definition := &model.ReportDefinition{CreatedBy: userID}
+	if err := s.apply(ctx, definition, req, userID); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, definition); err != nil {
+		return nil, err
+	}
+	resp := toReportDefinitionResponse(definition)
+	return &resp, nil
+}
+
+func (s *reportService) Update(
+	ctx context.Context,
+	id uint,
+	req dto.ReportDefinitionRequest,
+	userID uint,
+) (*dto.ReportDefinitionResponse, error) {
+	definition, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(ctx, definition, req, userID); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, definition); err != nil {
+		return nil, err
+	}
+	resp := toReportDefinitionResponse(definition)
+	return &resp, nil
+}
+
+func (s *reportService) Delete(
+	ctx context.Context,
+	id uint,
+) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	// Runs are removed with the definition, so are their files
+	_ = os.RemoveAll(s.definitionDir(id))
+	return nil
+}
+
+// apply validates req and copies it onto definition, rescheduling its next run.
+func (s *reportService) apply(
+	ctx context.Context,
+	definition *model.ReportDefinition,
+	req dto.ReportDefinitionRequest,
+	userID uint,
+) error {
+	switch req.DataType {
+	case "readings":
+		if req.DeviceID == nil || *req.DeviceID == 0 {
+			return fmt.Errorf("%w: device_id is required for readings reports", ErrInvalidReport)
+		}
+	case "devices":
+	default:
+		return fmt.Errorf("%w: data_type must be readings or devices", ErrInvalidReport)
+	}
+
+	period := req.Period
+	if period == "" {
+		period = model.ReportPeriodDay
+	}
+	if period != model.ReportPeriodDay && period != model.ReportPeriodWeek && period != model.ReportPeriodMonth {
+		return fmt.Errorf("%w: period must be day, week or month", ErrInvalidReport)
+	}
+
+	if !s.exportService.Supports(exportdto.ExportFormat(req.Format)) {
+		return fmt.Errorf("%w: unsupported format %q", ErrInvalidReport, req.Format)
+	}
+
+	if req.TemplateID != nil {
+		if req.Format != string(exportdto.FormatPDF) {
+			return fmt.Errorf("%w: template_id is only used by pdf reports", ErrInvalidReport)
+		}
+		if _, err := s.templateService.Resolve(ctx, req.DataType, *req.TemplateID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: template %d not found", ErrInvalidReport, *req.TemplateID)
+			}
+			if errors.Is(err, ErrInvalidTemplate) {
+				return fmt.Errorf("%w: %v", ErrInvalidReport, err)
+			}
+			return err
+		}
+	}
+
+	schedule, err := cron.ParseStandard(req.Schedule)
+	if err != nil {
+		return fmt.Errorf("%w: invalid schedule: %v", ErrInvalidReport, err)
+	}
+
+	recipients := make([]string, 0, len(req.Recipients))
+	for _, r := range req.Recipients {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		if !strings.Contains(r, "@") || strings.ContainsAny(r, ",\r\n") {
+			return fmt.Errorf("%w: invalid recipient %q", ErrInvalidReport, r)
+		}
+		recipients = append(recipients, r)
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	definition.Name = req.Name
+	definition.DataType = req.DataType
+	definition.DeviceID = nil
+	if req.DataType == "readings" {
+		definition.DeviceID = req.DeviceID
+	}
+	definition.Period = period
+	definition.Format = req.Format
+	definition.TemplateID = req.TemplateID
+	definition.Schedule = req.Schedule
+	definition.Recipients = strings.Join(recipients, ",")
+	definition.Enabled = enabled
+	definition.UpdatedBy = userID
+	definition.NextRunAt = nil
+	if enabled {
+		next := schedule.Next(time.Now())
+		definition.NextRunAt = &next
+	}
+	return nil
+}
+
+func (s *reportService) RunNow(
+	ctx context.Context,
+	id uint,
+) (*dto.ReportRunResponse, error) {
+	definition, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	run, err := s.run(ctx, definition, model.ReportTriggerManual, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	resp := toReportRunResponse(run)
+	return &resp, nil
+}
+
+func (s *reportService) RunDue(
+	ctx context.Context,
+	now time.Time,
+) error {
+	due, err := s.repo.ListDue(ctx, now)
+	if err != nil {
+		return err
+	}
+	for i := range due {
+		definition := &due[i]
+		schedule, err := cron.ParseStandard(definition.Schedule)
+		if err != nil {
+			logger.FromContext(ctx).Error("Invalid report schedule",
+				zap.Uint("report_id", definition.ID), zap.Error(err))
+			continue
+		}
+		// Claim the run first so a slow report is not started twice
+		claimed, err := s.repo.ClaimRun(ctx, definition.ID, *definition.NextRunAt, schedule.Next(now))
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		if _, err := s.run(ctx, definition, model.ReportTriggerSchedule, now); err != nil {
+			logger.FromContext(ctx).Error("Failed to record report run",
+				zap.Uint("report_id", definition.ID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (s *reportService) StartScheduler(
+	ctx context.Context,
+	interval time.Duration,
+) *health.Worker {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	worker := health.NewWorker("report_scheduler", interval)
+	go func() {
+		defer worker.Stop()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			// Cancelling ctx mid-round would leave half-sent reports, the
+			// shutdown deadline bounds the round instead
+			err := s.RunDue(context.WithoutCancel(ctx), time.Now())
+			worker.Beat(err)
+			if err != nil {
+				logger.FromContext(ctx).Error("Failed to run due reports", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return worker
+}
+
+// run renders a report, stores it and emails it. Render and delivery failures
+// are recorded on the returned run; the error is only set when the run itself
+// could not be recorded.
+func (s *reportService) run(
+	ctx context.Context,
+	definition *model.ReportDefinition,
+	trigger string,
+	now time.Time,
+) (*model.ReportRun, error) {
+	run := &model.ReportRun{
+		DefinitionID: definition.ID,
+		Trigger:      trigger,
+		Status:       model.ReportRunRunning,
+		Recipients:   definition.Recipients,
+		PeriodStart:  periodStart(definition.Period, now),
+		PeriodEnd:    now,
+		StartedAt:    time.Now(),
+	}
+	if err := s.repo.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	if err := s.render(ctx, definition, run); err != nil {
+		run.Status = model.ReportRunFailed
+		run.Error = err.Error()
+		run.DeliveryStatus = model.ReportDeliverySkipped
+	} else {
+		run.Status = model.ReportRunSuccess
+		s.deliver(ctx, definition, run)
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	var runErr error
+	if run.Status == model.ReportRunFailed {
+		runErr = errors.New(run.Error)
+	}
+	metrics.ObserveJob("report", runErr, finished.Sub(run.StartedAt))
+	if err := s.repo.UpdateRun(ctx, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// render exports the report's data into a file below the reports directory.
+func (s *reportService) render(
+	ctx context.Context,
+	definition *model.ReportDefinition,
+	run *model.ReportRun,
+) error {
+	format := exportdto.ExportFormat(definition.Format)
+	_, ext := export.MIMEType(format)
+	req := exportdto.ExportRequest{
+		Format:    format,
+		DataType:  definition.DataType,
+		StartDate: run.PeriodStart.Format(time.RFC3339),
+		EndDate:   run.PeriodEnd.Format(time.RFC3339),
+	}
+	if format == exportdto.FormatPDF {
+		if definition.TemplateID != nil {
+			req.TemplateID = *definition.TemplateID
+		}
+		source, err := s.templateService.Resolve(ctx, definition.DataType, req.TemplateID)
+		if err != nil {
+			return fmt.Errorf("failed to load template: %w", err)
+		}
+		req.Template = source
+	}
+
+	dir := s.definitionDir(definition.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d_%s.%s", run.ID, run.PeriodEnd.Format("20060102_150405"), ext))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	switch definition.DataType {
+	case "readings":
+		if definition.DeviceID == nil {
+			err = errors.New("readings report has no device")
+			break
+		}
+		req.DeviceID = *definition.DeviceID
+		device, derr := s.deviceService.GetDevice(ctx, req.DeviceID)
+		if derr != nil {
+			err = fmt.Errorf("failed to fetch device: %w", derr)
+			break
+		}
+		if device == nil {
+			err = fmt.Errorf("device %d not found", req.DeviceID)
+			break
+		}
+		err = s.exportService.ExportReadings(ctx, req, s.readingService,
+			[]dto.DeviceView{*device}, run.PeriodStart, run.PeriodEnd, f)
+	case "devices":
+		devices, _, derr := s.deviceService.ListDevices(ctx, 0, 0)
+		if derr != nil {
+			err = fmt.Errorf("failed to fetch devices: %w", derr)
+			break
+		}
+		err = s.exportService.ExportDevices(ctx, req, devices, f)
+	default:
+		err = fmt.Errorf("unknown data type %q", definition.DataType)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	run.FilePath = path
+	run.FileSize = info.Size()
+	return nil
+}
+
+// deliver emails a rendered report to the definition's recipients.
+func (s *reportService) deliver(
+	ctx context.Context,
+	definition *model.ReportDefinition,
+	run *model.ReportRun,
+) {
+	recipients := splitRecipients(run.Recipients)
+	if len(recipients) == 0 || s.mailer == nil {
+		run.DeliveryStatus = model.ReportDeliverySkipped
+		return
+	}
+
+	msg := mailer.Message{
+		To: recipients,
+		Subject: fmt.Sprintf("%s (%s - %s)", definition.Name,
+			run.PeriodStart.Format("2006-01-02"), run.PeriodEnd.Format("2006-01-02")),
+		Body: fmt.Sprintf("Report %q covering %s to %s.\n",
+			definition.Name, run.PeriodStart.Format(time.RFC1123), run.PeriodEnd.Format(time.RFC1123)),
+	}
+	if run.FileSize > maxReportAttachment {
+		msg.Body += fmt.Sprintf("\nThe report is too large to attach (%d bytes); download run %d from the reports API.\n",
+			run.FileSize, run.ID)
+	} else {
+		data, err := os.ReadFile(run.FilePath)
+		if err != nil {
+			run.DeliveryStatus = model.ReportDeliveryFailed
+			run.DeliveryError = err.Error()
+			return
+		}
+		contentType, _ := export.MIMEType(exportdto.ExportFormat(definition.Format))
+		msg.Attachments = []mailer.Attachment{{
+			Filename:    filepath.Base(run.FilePath),
+			ContentType: contentType,
+			Data:        data,
+		}}
+	}
+
+	err := s.mailer.Send(ctx, msg)
+	switch {
+	case errors.Is(err, mailer.ErrNotConfigured):
+		run.DeliveryStatus = model.ReportDeliverySkipped
+		run.DeliveryError = err.Error()
+	case err != nil:
+		run.DeliveryStatus = model.ReportDeliveryFailed
+		run.DeliveryError = err.Error()
+	default:
+		run.DeliveryStatus = model.ReportDeliverySent
+	}
+}
+
+func (s *reportService) ListRuns(
+	ctx context.Context,
+	definitionID uint,
+) ([]dto.ReportRunResponse, error) {
+	if _, err := s.repo.GetByID(ctx, definitionID); err != nil {
+		return nil, err
+	}
+	runs, err := s.repo.ListRuns(ctx, definitionID, reportRunHistory)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]dto.ReportRunResponse, 0, len(runs))
+	for i := range runs {
+		responses = append(responses, toReportRunResponse(&runs[i]))
+	}
+	return responses, nil
+}
+
+func (s *reportService) RunFile(
+	ctx context.Context,
+	runID uint,
+) (string, string, string, error) {
+	run, err := s.repo.GetRun(ctx, runID)
+	if err != nil {
+		return "", "", "", err
+	}
+	if run.FilePath == "" {
+		return "", "", "", ErrReportFileMissing
+	}
+	if _, err := os.Stat(run.FilePath); err != nil {
+		return "", "", "", ErrReportFileMissing
+	}
+	definition, err := s.repo.GetByID(ctx, run.DefinitionID)
+	if err != nil {
+		return "", "", "", err
+	}
+	contentType, _ := export.MIMEType(exportdto.ExportFormat(definition.Format))
+	return run.FilePath, filepath.Base(run.FilePath), contentType, nil
+}
+
+func (s *reportService) definitionDir(id uint) string {
+	return filepath.Join(s.reportsDir, fmt.Sprintf("%d", id))
+}
+
+// periodStart returns the start of the period ending at end.
+func periodStart(period string, end time.Time) time.Time {
+	switch period {
+	case model.ReportPeriodWeek:
+		return end.AddDate(0, 0, -7)
+	case model.ReportPeriodMonth:
+		return end.AddDate(0, -1, 0)
+	default:
+		return end.AddDate(0, 0, -1)
+	}
+}
+
+func splitRecipients(recipients string) []string {
+	if recipients == "" {
+		return []string{}
+	}
+	return strings.Split(recipients, ",")
+}
+
+func toReportDefinitionResponse(d *model.ReportDefinition) dto.ReportDefinitionResponse {
+	return dto.ReportDefinitionResponse{
+		ID:         d.ID,
+		Name:       d.Name,
+		DataType:   d.DataType,
+		DeviceID:   d.DeviceID,
+		Period:     d.Period,
+		Format:     d.Format,
+		TemplateID: d.TemplateID,
+		Schedule:   d.Schedule,
+		Recipients: splitRecipients(d.Recipients),
+		Enabled:    d.Enabled,
+		NextRunAt:  d.NextRunAt,
+		LastRunAt:  d.LastRunAt,
+		CreatedAt:  d.CreatedAt,
+		UpdatedAt:  d.UpdatedAt,
+	}
+}
+
+func toReportRunResponse(r *model.ReportRun) dto.ReportRunResponse {
+	return dto.ReportRunResponse{
+		ID:             r.ID,
+		DefinitionID:   r.DefinitionID,
+		Trigger:        r.Trigger,
+		Status:         r.Status,
+		Error:          r.Error,
+		FileSize:       r.FileSize,
+		PeriodStart:    r.PeriodStart,
+		PeriodEnd:      r.PeriodEnd,
+		DeliveryStatus: r.DeliveryStatus,
+		DeliveryError:  r.DeliveryError,
+		Recipients:     splitRecipients(r.Recipients),
+		StartedAt:      r.StartedAt,
+		FinishedAt:     r.FinishedAt,
+	}
+}
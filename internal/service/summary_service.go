@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/repository"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidSummaryRange = errors.New("report end must be after its start")
+
+const (
+	// summaryChartBuckets is the number of averaged points per chart.
+	summaryChartBuckets = 120
+	// summaryPageSize is the number of readings read per query.
+	summaryPageSize = 5000
+	// defaultOfflineAfter is used when the thresholds do not set a gap.
+	defaultOfflineAfter = 15 * time.Minute
+)
+
+// SummaryService builds device and location reports with charts, daily
+// summaries, energy totals, uptime and alert counts. Readings are read in
+// pages, so long ranges do not have to fit in memory.
+type SummaryService interface {
+	DeviceReport(
+		ctx context.Context,
+		deviceID uint,
+		start time.Time,
+		end time.Time,
+		thresholds dto.AlertThresholds,
+	) (*dto.SummaryReport, error)
+	// LocationReport covers every device assigned to the location during the
+	// range, each only for the time it was assigned.
+	LocationReport(
+		ctx context.Context,
+		locationID uint,
+		start time.Time,
+		end time.Time,
+		thresholds dto.AlertThresholds,
+	) (*dto.SummaryReport, error)
+}
+
+type summaryService struct {
+	readingRepo   repository.ReadingRepository
+	locationRepo  repository.LocationRepository
+	deviceService DeviceService
+}
+
+func NewSummaryService(
+	readingRepo repository.ReadingRepository,
+	locationRepo repository.LocationRepository,
+	deviceService DeviceService,
+) SummaryService {
+	return &summaryService{
+		readingRepo:   readingRepo,
+		locationRepo:  locationRepo,
+		deviceService: deviceService,
+	}
+}
+
+func (s *summaryService) DeviceReport(
+	ctx context.Context,
+	deviceID uint,
+	start time.Time,
+	end time.Time,
+	thresholds dto.AlertThresholds,
+) (*dto.SummaryReport, error) {
+	end, err := summaryRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	device, err := s.deviceService.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	report := newSummaryReport(dto.SummaryKindDevice, device.Name+" Report", start, end, thresholds)
+	summary, err := s.summarizeDevice(ctx, report, *device, []dto.TimeWindow{{Start: start, End: end}})
+	if err != nil {
+		return nil, err
+	}
+	report.Devices = append(report.Devices, *summary)
+	addSummaryTotals(report)
+	return report, nil
+}
+
+func (s *summaryService) LocationReport(
+	ctx context.Context,
+	locationID uint,
+	start time.Time,
+	end time.Time,
+	thresholds dto.AlertThresholds,
+) (*dto.SummaryReport, error) {
+	end, err := summaryRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	location, err := s.locationRepo.GetByID(ctx, locationID)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := s.locationRepo.ListAssignments(ctx, locationID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clip each assignment to the report range; a device assigned several
+	// times gets several windows
+	var order []uint
+	windows := make(map[uint][]dto.TimeWindow)
+	for _, a := range assignments {
+		w := dto.TimeWindow{Start: a.AssignedAt, End: end}
+		if a.UnassignedAt != nil && a.UnassignedAt.Before(end) {
+			w.End = *a.UnassignedAt
+		}
+		if w.Start.Before(start) {
+			w.Start = start
+		}
+		if !w.End.After(w.Start) {
+			continue
+		}
+		if _, ok := windows[a.DeviceID]; !ok {
+			order = append(order, a.DeviceID)
+		}
+		windows[a.DeviceID] = append(windows[a.DeviceID], w)
+	}
+
+	report := newSummaryReport(dto.SummaryKindLocation, location.Name+" Location Report", start, end, thresholds)
+	report.LocationID = location.ID
+	report.LocationName = location.Name
+	for _, deviceID := range order {
+		device, err := s.deviceService.GetDevice(ctx, deviceID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		// Keep the readings of devices deleted since
+		view := dto.DeviceView{ID: deviceID}
+		if device != nil {
+			view = *device
+		}
+		summary, err := s.summarizeDevice(ctx, report, view, windows[deviceID])
+		if err != nil {
+			return nil, err
+		}
+		report.Devices = append(report.Devices, *summary)
+	}
+	addSummaryTotals(report)
+	return report, nil
+}
+
+// summaryRange validates the range and clips its end to now.
+func summaryRange(start, end time.Time) (time.Time, error) {
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	if !end.After(start) {
+		return end, ErrInvalidSummaryRange
+	}
+	return end, nil
+}
+
+func newSummaryReport(kind, title string, start, end time.Time, thresholds dto.AlertThresholds) *dto.SummaryReport {
+	if thresholds.OfflineAfterMinutes <= 0 {
+		thresholds.OfflineAfterMinutes = int(defaultOfflineAfter / time.Minute)
+	}
+	return &dto.SummaryReport{
+		Kind:        kind,
+		Title:       title,
+		Start:       start,
+		End:         end,
+		GeneratedAt: time.Now(),
+		Thresholds:  thresholds,
+		Devices:     []dto.DeviceSummary{},
+	}
+}
+
+// summarizeDevice reads a device's readings in each window once, newest
+// first, and accumulates every figure of its summary along the way.
+func (s *summaryService) summarizeDevice(
+	ctx context.Context,
+	report *dto.SummaryReport,
+	device dto.DeviceView,
+	windows []dto.TimeWindow,
+) (*dto.DeviceSummary, error) {
+	thresholds := report.Thresholds
+	offlineAfter := time.Duration(thresholds.OfflineAfterMinutes) * time.Minute
+	chart := newChartBuckets(report.Start, report.End, summaryChartBuckets)
+	summary := &dto.DeviceSummary{Device: device, Windows: windows}
+
+	var slotsTotal, slotsOnline int
+	for _, w := range windows {
+		// Uptime is the share of offlineAfter sized slots holding a reading
+		slots := make([]bool, int(w.End.Sub(w.Start)/offlineAfter)+1)
+		slotsTotal += len(slots)
+
+		var later *model.Reading
+		var low, high, over bool
+		var after *model.Reading
+		for {
+			page, err := s.readingRepo.ListPageByDevicesAndDateRange(
+				ctx, []uint{device.ID}, w.Start, w.End, after, summaryPageSize)
+			if err != nil {
+				return nil, err
+			}
+			for i := range page {
+				r := &page[i]
+				summary.Readings++
+				chart.add(r)
+
+				if slot := int(r.CreatedAt.Sub(w.Start) / offlineAfter); slot >= 0 && slot < len(slots) && !slots[slot] {
+					slots[slot] = true
+					slotsOnline++
+				}
+
+				gap := w.End.Sub(r.CreatedAt)
+				if later != nil {
+					gap = later.CreatedAt.Sub(r.CreatedAt)
+				}
+				if gap > offlineAfter {
+					summary.Alerts.Offline++
+				} else if later != nil {
+					// Trapezoidal integration of power between readings
+					power := (later.Voltage*later.Current + r.Voltage*r.Current) / 2
+					summary.EnergyWh += power * gap.Hours()
+				}
+
+				// Count runs of out-of-range readings as single events
+				isLow := thresholds.MinVoltage > 0 && r.Voltage < thresholds.MinVoltage
+				isHigh := thresholds.MaxVoltage > 0 && r.Voltage > thresholds.MaxVoltage
+				isOver := thresholds.MaxCurrent > 0 && r.Current > thresholds.MaxCurrent
+				if isLow && !low {
+					summary.Alerts.LowVoltage++
+				}
+				if isHigh && !high {
+					summary.Alerts.HighVoltage++
+				}
+				if isOver && !over {
+					summary.Alerts.OverCurrent++
+				}
+				low, high, over = isLow, isHigh, isOver
+				later = r
+			}
+			if len(page) < summaryPageSize {
+				break
+			}
+			after = &page[len(page)-1]
+		}
+
+		// A silent start of the window, or a window without readings, is
+		// also time offline
+		if later == nil || later.CreatedAt.Sub(w.Start) > offlineAfter {
+			summary.Alerts.Offline++
+		}
+
+		daily, err := s.readingRepo.DailyStats(ctx, device.ID, w.Start, w.End)
+		if err != nil {
+			return nil, err
+		}
+		summary.Daily = mergeDailyStats(summary.Daily, daily)
+	}
+
+	if slotsTotal > 0 {
+		summary.UptimePercent = float64(slotsOnline) / float64(slotsTotal) * 100
+	}
+	summary.Voltage, summary.Current = chart.series()
+	if summary.Daily == nil {
+		summary.Daily = []dto.DailySummary{}
+	}
+	return summary, nil
+}
+
+// addSummaryTotals adds up the device summaries into the report.
+func addSummaryTotals(report *dto.SummaryReport) {
+	var weighted, total float64
+	for _, d := range report.Devices {
+		report.Readings += d.Readings
+		report.EnergyWh += d.EnergyWh
+		report.Alerts.LowVoltage += d.Alerts.LowVoltage
+		report.Alerts.HighVoltage += d.Alerts.HighVoltage
+		report.Alerts.OverCurrent += d.Alerts.OverCurrent
+		report.Alerts.Offline += d.Alerts.Offline
+
+		var span float64
+		for _, w := range d.Windows {
+			span += w.End.Sub(w.Start).Seconds()
+		}
+		weighted += d.UptimePercent * span
+		total += span
+	}
+	if total > 0 {
+		report.UptimePercent = weighted / total
+	}
+}
+
+// mergeDailyStats adds the days of stats to daily, combining days present in
+// both (a device assigned twice on the same day).
+func mergeDailyStats(daily []dto.DailySummary, stats []model.DailyReadingStats) []dto.DailySummary {
+	for _, st := range stats {
+		merged := false
+		for i := range daily {
+			d := &daily[i]
+			if !d.Day.Equal(st.Day) {
+				continue
+			}
+			n := float64(d.Count + st.Count)
+			d.AvgVoltage = (d.AvgVoltage*float64(d.Count) + st.AvgVoltage*float64(st.Count)) / n
+			d.AvgCurrent = (d.AvgCurrent*float64(d.Count) + st.AvgCurrent*float64(st.Count)) / n
+			d.MinVoltage = min(d.MinVoltage, st.MinVoltage)
+			d.MaxVoltage = max(d.MaxVoltage, st.MaxVoltage)
+			d.MinCurrent = min(d.MinCurrent, st.MinCurrent)
+			d.MaxCurrent = max(d.MaxCurrent, st.MaxCurrent)
+			d.Count += st.Count
+			merged = true
+			break
+		}
+		if !merged {
+			daily = append(daily, dto.DailySummary{
+				Day:        st.Day,
+				Count:      st.Count,
+				MinVoltage: st.MinVoltage,
+				MaxVoltage: st.MaxVoltage,
+				AvgVoltage: st.AvgVoltage,
+				MinCurrent: st.MinCurrent,
+				MaxCurrent: st.MaxCurrent,
+				AvgCurrent: st.AvgCurrent,
+			})
+		}
+	}
+	sort.Slice(daily, func(i, j int) bool { return daily[i].Day.Before(daily[j].Day) })
+	return daily
+}
+
+// chartBuckets averages voltage and current over equal time buckets.
+type chartBuckets struct {
+	start    time.Time
+	width    time.Duration
+	count    []int
+	voltages []float64
+	currents []float64
+}
+
+func newChartBuckets(start, end time.Time, n int) *chartBuckets {
+	width := end.Sub(start) / time.Duration(n)
+	if width <= 0 {
+		width = time.Second
+	}
+	return &chartBuckets{
+		start:    start,
+		width:    width,
+		count:    make([]int, n),
+		voltages: make([]float64, n),
+		currents: make([]float64, n),
+	}
+}
+
+func (c *chartBuckets) add(r *model.Reading) {
+	i := min(max(int(r.CreatedAt.Sub(c.start)/c.width), 0), len(c.count)-1)
+	c.count[i]++
+	c.voltages[i] += r.Voltage
+	c.currents[i] += r.Current
+}
+
+func (c *chartBuckets) series() (voltage, current []dto.SeriesPoint) {
+	voltage = []dto.SeriesPoint{}
+	current = []dto.SeriesPoint{}
+	for i, n := range c.count {
+		if n == 0 {
+			continue
+		}
+		at := c.start.Add(c.width*time.Duration(i) + c.width/2)
+		voltage = append(voltage, dto.SeriesPoint{Time: at, Value: c.voltages[i] / float64(n)})
+		current = append(current, dto.SeriesPoint{Time: at, Value: c.currents[i] / float64(n)})
+	}
+	return voltage, current
+}
@@ -10,11 +10,12 @@ import (
 	"github.com/aruncs31s/skvms/internal/repository"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type UserService interface {
 	List(ctx context.Context) ([]dto.UserView, error)
-	Create(ctx context.Context, req *dto.CreateUserRequest) error
+	Create(ctx context.Context, req *dto.CreateUserRequest) (*dto.UserView, error)
 	Update(ctx context.Context, id uint, req *dto.UpdateUserRequest) error
 	Delete(ctx context.Context, id uint) error
 	UserReader
@@ -62,6 +63,9 @@ func (s *userService) GetByID(ctx context.Context, id uint) (*dto.UserView, erro
 	if err != nil {
 		return nil, err
 	}
+	if user == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
 
 	view := &dto.UserView{
 		ID:       user.ID,
@@ -73,14 +77,14 @@ func (s *userService) GetByID(ctx context.Context, id uint) (*dto.UserView, erro
 	return view, nil
 }
 
-func (s *userService) Create(ctx context.Context, req *dto.CreateUserRequest) error {
+func (s *userService) Create(ctx context.Context, req *dto.CreateUserRequest) (*dto.UserView, error) {
 
 	if req.Username == "" || req.Password == "" {
-		return errors.New("username and password are required")
+		return nil, errors.New("username and password are required")
 	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	role := req.Role
@@ -100,9 +104,16 @@ func (s *userService) Create(ctx context.Context, req *dto.CreateUserRequest) er
 	}
 	err = s.repo.Create(ctx, user)
 	if err != nil {
-		logger.GetLogger().Error("Failed to create user: ", zap.String("username", req.Username), zap.Error(err))
+		logger.FromContext(ctx).Error("Failed to create user: ", zap.String("username", req.Username), zap.Error(err))
+		return nil, err
 	}
-	return err
+	return &dto.UserView{
+		ID:       user.ID,
+		Name:     user.Name,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	}, nil
 }
 
 func (s *userService) Update(ctx context.Context, id uint, req *dto.UpdateUserRequest) error {
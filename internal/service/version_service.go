@@ -3,10 +3,18 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 
 	"github.com/aruncs31s/skvms/internal/dto"
 	"github.com/aruncs31s/skvms/internal/model"
 	"github.com/aruncs31s/skvms/internal/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrVersionCycle  = errors.New("version chain contains a cycle")
+	ErrOrphanVersion = errors.New("version is not linked to the device's version chain")
 )
 
 type VersionService interface {
@@ -16,6 +24,7 @@ type VersionService interface {
 	UpdateVersion(ctx context.Context, id uint, version string) (*model.Version, error)
 	DeleteVersion(ctx context.Context, id uint) error
 	CreateFeature(ctx context.Context, versionID uint, featureName string, enabled bool) (*model.Feature, error)
+	GetFeature(ctx context.Context, id uint) (*model.Feature, error)
 	GetFeaturesByVersion(ctx context.Context, versionID uint) ([]model.Feature, error)
 	UpdateFeature(ctx context.Context, id uint, featureName string, enabled bool) (*model.Feature, error)
 	DeleteFeature(ctx context.Context, id uint) error
@@ -30,6 +39,24 @@ type VersionService interface {
 		version string,
 		features []int,
 	) (*model.Version, error)
+	// GetDeviceLineage returns the graph of the device's versions and their
+	// ancestors.
+	GetDeviceLineage(
+		ctx context.Context,
+		deviceID uint,
+	) (*dto.VersionLineage, error)
+	// DiffVersions compares the feature sets of two versions.
+	DiffVersions(
+		ctx context.Context,
+		fromID uint,
+		toID uint,
+	) (*dto.VersionDiff, error)
+	// GetDeviceChangelog lists the feature changes of every release in the
+	// device's current version chain.
+	GetDeviceChangelog(
+		ctx context.Context,
+		deviceID uint,
+	) (*dto.VersionChangelog, error)
 }
 
 type versionService struct {
@@ -139,6 +166,10 @@ func (s *versionService) GetFeaturesByVersion(ctx context.Context, versionID uin
 	return s.repo.GetFeaturesByVersion(ctx, versionID)
 }
 
+func (s *versionService) GetFeature(ctx context.Context, id uint) (*model.Feature, error) {
+	return s.repo.GetFeature(ctx, id)
+}
+
 func (s *versionService) UpdateFeature(ctx context.Context, id uint, featureName string, enabled bool) (*model.Feature, error) {
 	if featureName == "" {
 		return nil, errors.New("feature name cannot be empty")
@@ -169,9 +200,19 @@ func (s *versionService) GetAllVersionAndPreviousVersionsByDevice(
 		return dto.VersionResponse{}, err
 	}
 
+	if len(versions) == 0 {
+		return dto.VersionResponse{}, nil
+	}
+
+	// Features of previous versions carry over, each is listed once
+	seen := make(map[uint]bool)
 	var features []dto.FeatureResponse
 	for _, v := range versions {
 		for _, f := range v.Features {
+			if seen[f.ID] {
+				continue
+			}
+			seen[f.ID] = true
 			features = append(features, dto.FeatureResponse{
 				ID:          f.ID,
 				FeatureName: f.FeatureName,
@@ -180,7 +221,14 @@ func (s *versionService) GetAllVersionAndPreviousVersionsByDevice(
 		}
 	}
 
-	return dto.VersionResponse{}, nil
+	current := versions[0]
+	return dto.VersionResponse{
+		ID:        current.ID,
+		Version:   current.Name,
+		CreatedAt: current.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt: current.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		Features:  features,
+	}, nil
 }
 
 func (s *versionService) CreateNewDeviceVersion(
@@ -193,6 +241,9 @@ func (s *versionService) CreateNewDeviceVersion(
 	if version == "" {
 		return nil, errors.New("version cannot be empty")
 	}
+	if err := s.checkChain(ctx, deviceID, previousVersion); err != nil {
+		return nil, err
+	}
 
 	v := &model.Version{
 		Name:              version,
@@ -206,3 +257,247 @@ func (s *versionService) CreateNewDeviceVersion(
 	}
 	return createdVersion, nil
 }
+
+// checkChain makes sure a new version of the device links to an existing,
+// acyclic chain. A device that already has a version must name the version
+// the new one is made from, which must be one of the device's versions or
+// their ancestors. A device without versions may start from a version that
+// belongs to no device, but not from another device's version.
+func (s *versionService) checkChain(
+	ctx context.Context,
+	deviceID uint,
+	previousVersion *uint,
+) error {
+	lineage, err := s.repo.ListDeviceLineage(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if previousVersion == nil {
+		current, err := s.repo.GetVersionByDeviceID(ctx, deviceID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: device runs version %s, previous_version is required", ErrOrphanVersion, current.Name)
+	}
+
+	inLineage := false
+	for _, v := range lineage {
+		if v.ID == *previousVersion {
+			inLineage = true
+			break
+		}
+	}
+
+	seen := make(map[uint]bool)
+	for next := previousVersion; next != nil; {
+		if seen[*next] {
+			return fmt.Errorf("%w: version %d is its own ancestor", ErrVersionCycle, *next)
+		}
+		seen[*next] = true
+
+		v, err := s.repo.GetVersionByID(ctx, *next)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: version %d does not exist", ErrOrphanVersion, *next)
+		}
+		if err != nil {
+			return err
+		}
+		if next == previousVersion && !inLineage && (len(lineage) > 0 || v.DeviceID != 0) {
+			return fmt.Errorf("%w: version %d is not in the device's version chain", ErrOrphanVersion, v.ID)
+		}
+		next = v.PreviousVersionID
+	}
+	return nil
+}
+
+func (s *versionService) GetDeviceLineage(
+	ctx context.Context,
+	deviceID uint,
+) (*dto.VersionLineage, error) {
+	versions, err := s.repo.ListDeviceLineage(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	features, err := s.featureStates(ctx, versions)
+	if err != nil {
+		return nil, err
+	}
+
+	lineage := &dto.VersionLineage{
+		DeviceID: deviceID,
+		Nodes:    []dto.VersionNode{},
+		Edges:    []dto.VersionEdge{},
+	}
+	current, err := s.repo.GetVersionByDeviceID(ctx, deviceID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if current != nil {
+		lineage.CurrentVersionID = &current.ID
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].ID < versions[j].ID
+	})
+	loaded := make(map[uint]bool, len(versions))
+	for _, v := range versions {
+		loaded[v.ID] = true
+	}
+	for _, v := range versions {
+		lineage.Nodes = append(lineage.Nodes, dto.VersionNode{
+			ID:                v.ID,
+			Version:           v.Name,
+			DeviceID:          v.DeviceID,
+			PreviousVersionID: v.PreviousVersionID,
+			CreatedAt:         v.CreatedAt,
+			Current:           current != nil && current.ID == v.ID,
+			Features:          featureResponses(features[v.ID]),
+		})
+		if v.PreviousVersionID != nil && loaded[*v.PreviousVersionID] {
+			lineage.Edges = append(lineage.Edges, dto.VersionEdge{
+				From: *v.PreviousVersionID,
+				To:   v.ID,
+			})
+		}
+	}
+	return lineage, nil
+}
+
+func (s *versionService) DiffVersions(
+	ctx context.Context,
+	fromID uint,
+	toID uint,
+) (*dto.VersionDiff, error) {
+	from, err := s.repo.GetVersionByID(ctx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.repo.GetVersionByID(ctx, toID)
+	if err != nil {
+		return nil, err
+	}
+	features, err := s.featureStates(ctx, []model.Version{*from, *to})
+	if err != nil {
+		return nil, err
+	}
+	diff := diffFeatures(from, to, features)
+	return &diff, nil
+}
+
+func (s *versionService) GetDeviceChangelog(
+	ctx context.Context,
+	deviceID uint,
+) (*dto.VersionChangelog, error) {
+	chain, err := s.repo.GetCurrnetAllPreviousVersions(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	features, err := s.featureStates(ctx, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	changelog := &dto.VersionChangelog{
+		DeviceID: deviceID,
+		Entries:  []dto.ChangelogEntry{},
+	}
+	// The chain is newest first; the oldest release is compared with nothing
+	for i := range chain {
+		var previous *model.Version
+		if i+1 < len(chain) {
+			previous = &chain[i+1]
+		}
+		changelog.Entries = append(changelog.Entries, dto.ChangelogEntry{
+			VersionDiff: diffFeatures(previous, &chain[i], features),
+			CreatedAt:   chain[i].CreatedAt,
+		})
+	}
+	return changelog, nil
+}
+
+// featureStates loads the features of the versions keyed by version ID.
+func (s *versionService) featureStates(
+	ctx context.Context,
+	versions []model.Version,
+) (map[uint][]model.VersionFeatureState, error) {
+	ids := make([]uint, 0, len(versions))
+	for _, v := range versions {
+		ids = append(ids, v.ID)
+	}
+	states, err := s.repo.ListFeatureStates(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[uint][]model.VersionFeatureState, len(versions))
+	for _, st := range states {
+		byVersion[st.VersionID] = append(byVersion[st.VersionID], st)
+	}
+	return byVersion, nil
+}
+
+// diffFeatures compares the feature sets of two versions. A nil from means
+// every feature of to was added.
+func diffFeatures(
+	from *model.Version,
+	to *model.Version,
+	features map[uint][]model.VersionFeatureState,
+) dto.VersionDiff {
+	diff := dto.VersionDiff{
+		To:      dto.VersionRef{ID: to.ID, Version: to.Name},
+		Added:   []dto.FeatureResponse{},
+		Removed: []dto.FeatureResponse{},
+		Toggled: []dto.FeatureToggle{},
+	}
+
+	before := make(map[uint]model.VersionFeatureState)
+	if from != nil {
+		diff.From = &dto.VersionRef{ID: from.ID, Version: from.Name}
+		for _, st := range features[from.ID] {
+			before[st.FeatureID] = st
+		}
+	}
+
+	after := make(map[uint]bool)
+	for _, st := range features[to.ID] {
+		after[st.FeatureID] = true
+		old, ok := before[st.FeatureID]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, featureResponse(st))
+		case old.Enabled != st.Enabled:
+			diff.Toggled = append(diff.Toggled, dto.FeatureToggle{
+				ID:          st.FeatureID,
+				FeatureName: st.FeatureName,
+				From:        old.Enabled,
+				To:          st.Enabled,
+			})
+		}
+	}
+	if from != nil {
+		for _, st := range features[from.ID] {
+			if !after[st.FeatureID] {
+				diff.Removed = append(diff.Removed, featureResponse(st))
+			}
+		}
+	}
+	return diff
+}
+
+func featureResponses(states []model.VersionFeatureState) []dto.FeatureResponse {
+	responses := make([]dto.FeatureResponse, 0, len(states))
+	for _, st := range states {
+		responses = append(responses, featureResponse(st))
+	}
+	return responses
+}
+
+func featureResponse(st model.VersionFeatureState) dto.FeatureResponse {
+	return dto.FeatureResponse{
+		ID:          st.FeatureID,
+		FeatureName: st.FeatureName,
+		Enabled:     st.Enabled,
+	}
+}
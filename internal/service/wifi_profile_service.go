@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/repository"
+	"github.com/aruncs31s/skvms/internal/secrets"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLocationNotFound = errors.New("location not found")
+	ErrLocationAccess   = errors.New("no access to this location")
+)
+
+// WiFiProfileService manages the WiFi profiles of locations. Every method
+// takes the acting user: only admins and the users of a profile's location
+// may see or use it, otherwise ErrLocationAccess is returned.
+type WiFiProfileService interface {
+	ListByLocation(
+		ctx context.Context,
+		locationID uint,
+		userID uint,
+	) ([]dto.WiFiProfileResponse, error)
+	Get(
+		ctx context.Context,
+		id uint,
+		userID uint,
+	) (*dto.WiFiProfileResponse, error)
+	Create(
+		ctx context.Context,
+		locationID uint,
+		req dto.CreateWiFiProfileRequest,
+		userID uint,
+	) (*dto.WiFiProfileResponse, error)
+	Update(
+		ctx context.Context,
+		id uint,
+		req dto.UpdateWiFiProfileRequest,
+		userID uint,
+	) (*dto.WiFiProfileResponse, error)
+	Delete(
+		ctx context.Context,
+		id uint,
+		userID uint,
+	) error
+	// Credentials decrypts the stored credentials for a firmware build.
+	Credentials(
+		ctx context.Context,
+		id uint,
+		userID uint,
+	) (ssid string, password string, err error)
+}
+
+type wifiProfileService struct {
+	repo         repository.WiFiProfileRepository
+	locationRepo repository.LocationRepository
+	userRepo     repository.UserRepository
+	cipher       *secrets.Cipher
+}
+
+func NewWiFiProfileService(
+	repo repository.WiFiProfileRepository,
+	locationRepo repository.LocationRepository,
+	userRepo repository.UserRepository,
+	cipher *secrets.Cipher,
+) WiFiProfileService {
+	return &wifiProfileService{
+		repo:         repo,
+		locationRepo: locationRepo,
+		userRepo:     userRepo,
+		cipher:       cipher,
+	}
+}
+
+// checkAccess returns ErrLocationAccess unless the user is an admin or
+// belongs to the location.
+func (s *wifiProfileService) checkAccess(
+	ctx context.Context,
+	userID uint,
+	locationID uint,
+) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrLocationAccess
+	}
+	if user.Role == "admin" || (user.LocationID != nil && *user.LocationID == locationID) {
+		return nil
+	}
+	return ErrLocationAccess
+}
+
+// getProfile loads a profile the user has access to.
+func (s *wifiProfileService) getProfile(
+	ctx context.Context,
+	id uint,
+	userID uint,
+) (*model.WiFiProfile, error) {
+	profile, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkAccess(ctx, userID, profile.LocationID); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (s *wifiProfileService) ListByLocation(
+	ctx context.Context,
+	locationID uint,
+	userID uint,
+) ([]dto.WiFiProfileResponse, error) {
+	if err := s.checkAccess(ctx, userID, locationID); err != nil {
+		return nil, err
+	}
+	profiles, err := s.repo.ListByLocation(ctx, locationID)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]dto.WiFiProfileResponse, 0, len(profiles))
+	for _, p := range profiles {
+		responses = append(responses, toWiFiProfileResponse(&p))
+	}
+	return responses, nil
+}
+
+func (s *wifiProfileService) Get(
+	ctx context.Context,
+	id uint,
+	userID uint,
+) (*dto.WiFiProfileResponse, error) {
+	profile, err := s.getProfile(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := toWiFiProfileResponse(profile)
+	return &resp, nil
+}
+
+func (s *wifiProfileService) Create(
+	ctx context.Context,
+	locationID uint,
+	req dto.CreateWiFiProfileRequest,
+	userID uint,
+) (*dto.WiFiProfileResponse, error) {
+	if _, err := s.locationRepo.GetByID(ctx, locationID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLocationNotFound
+		}
+		return nil, err
+	}
+	if err := s.checkAccess(ctx, userID, locationID); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := s.cipher.Encrypt(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt password: %w", err)
+	}
+
+	profile := &model.WiFiProfile{
+		LocationID:         locationID,
+		Name:               req.Name,
+		SSID:               req.SSID,
+		PasswordCiphertext: ciphertext,
+		CreatedBy:          userID,
+		UpdatedBy:          userID,
+	}
+	if err := s.repo.Create(ctx, profile); err != nil {
+		return nil, err
+	}
+	resp := toWiFiProfileResponse(profile)
+	return &resp, nil
+}
+
+func (s *wifiProfileService) Update(
+	ctx context.Context,
+	id uint,
+	req dto.UpdateWiFiProfileRequest,
+	userID uint,
+) (*dto.WiFiProfileResponse, error) {
+	profile, err := s.getProfile(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.Name = req.Name
+	profile.SSID = req.SSID
+	profile.UpdatedBy = userID
+	if req.Password != "" {
+		ciphertext, err := s.cipher.Encrypt(req.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt password: %w", err)
+		}
+		profile.PasswordCiphertext = ciphertext
+	}
+
+	if err := s.repo.Update(ctx, profile); err != nil {
+		return nil, err
+	}
+	resp := toWiFiProfileResponse(profile)
+	return &resp, nil
+}
+
+func (s *wifiProfileService) Delete(
+	ctx context.Context,
+	id uint,
+	userID uint,
+) error {
+	if _, err := s.getProfile(ctx, id, userID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *wifiProfileService) Credentials(
+	ctx context.Context,
+	id uint,
+	userID uint,
+) (string, string, error) {
+	profile, err := s.getProfile(ctx, id, userID)
+	if err != nil {
+		return "", "", err
+	}
+	password, err := s.cipher.Decrypt(profile.PasswordCiphertext)
+	if err != nil {
+		return "", "", err
+	}
+	return profile.SSID, password, nil
+}
+
+func toWiFiProfileResponse(p *model.WiFiProfile) dto.WiFiProfileResponse {
+	return dto.WiFiProfileResponse{
+		ID:         p.ID,
+		LocationID: p.LocationID,
+		Name:       p.Name,
+		SSID:       p.SSID,
+		UpdatedAt:  p.UpdatedAt,
+	}
+}
@@ -0,0 +1,140 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// StatusError is a response of the server with an unexpected status.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Code, e.Body)
+}
+
+// isUnauthorized reports whether err is a 401, e.g. for an expired token.
+func isUnauthorized(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusUnauthorized
+}
+
+// client calls the API of the server the way the web UI and the devices do.
+type client struct {
+	baseURL string
+	http    *http.Client
+}
+
+type device struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func (c *client) login(ctx context.Context, username string, password string) (string, error) {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/login", "", map[string]string{
+		"username": username,
+		"password": password,
+	}, http.StatusOK, &resp)
+	return resp.Token, err
+}
+
+// firstDeviceType returns the ID of the first device type, used when none
+// is configured.
+func (c *client) firstDeviceType(ctx context.Context) (uint, error) {
+	var resp struct {
+		DeviceTypes []struct {
+			ID uint `json:"id"`
+		} `json:"device_types"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/devices/types", "", nil, http.StatusOK, &resp); err != nil {
+		return 0, err
+	}
+	if len(resp.DeviceTypes) == 0 {
+		return 0, errors.New("the server has no device types, run seed or pass a type")
+	}
+	return resp.DeviceTypes[0].ID, nil
+}
+
+func (c *client) myDevices(ctx context.Context, token string) ([]device, error) {
+	var resp struct {
+		Devices []device `json:"devices"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/devices/my", token, nil, http.StatusOK, &resp)
+	return resp.Devices, err
+}
+
+func (c *client) createDevice(ctx context.Context, token string, name string, typeID uint, ip string, mac string) (device, error) {
+	var resp struct {
+		Device device `json:"device"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/devices", token, map[string]any{
+		"name":        name,
+		"type":        typeID,
+		"ip_address":  ip,
+		"mac_address": mac,
+	}, http.StatusCreated, &resp)
+	return resp.Device, err
+}
+
+func (c *client) deviceToken(ctx context.Context, token string, deviceID uint) (string, error) {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/device-auth/token", token, map[string]uint{
+		"device_id": deviceID,
+	}, http.StatusOK, &resp)
+	return resp.Token, err
+}
+
+func (c *client) postReading(ctx context.Context, deviceToken string, sample Sample) error {
+	return c.do(ctx, http.MethodPost, "/api/readings", deviceToken, sample, http.StatusCreated, nil)
+}
+
+// do sends body as JSON and decodes the response into out, unless it is
+// nil, when the server answers with want.
+func (c *client) do(ctx context.Context, method string, path string, token string, body any, want int, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.baseURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,268 @@
+// Package simulator drives a fleet of virtual solar battery monitors
+// against a running server, through the same HTTP API as real devices: it
+// provisions the devices and their tokens, posts readings following day and
+// night cycles, clouds, faults and outages, and measures throughput and
+// latency.
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// postTimeout bounds a single post of a reading.
+const postTimeout = 30 * time.Second
+
+// Config configures a simulation run.
+type Config struct {
+	// BaseURL is the server, e.g. http://localhost:8080
+	BaseURL string
+	// Username and Password log in the user owning the devices
+	Username string
+	Password string
+
+	// Devices is the number of virtual devices. Devices of the user named
+	// NamePrefix and their number are reused, the others are created.
+	Devices    int
+	NamePrefix string
+	// DeviceType is the device type of created devices, the first one when 0
+	DeviceType uint
+
+	// Interval is the time between the readings of a device
+	Interval time.Duration
+	// Duration ends the run, which otherwise lasts until its context is done
+	Duration time.Duration
+	// Speed is the number of simulated seconds per real second, so that
+	// a day of solar cycle passes in minutes
+	Speed float64
+	// FaultRate and OfflineRate are the chances per simulated hour that a
+	// device faults or goes offline
+	FaultRate   float64
+	OfflineRate float64
+	// Seed makes the devices' curves reproducible
+	Seed int64
+
+	// ReportEvery is the interval of the progress lines written to Out
+	ReportEvery time.Duration
+	Out         io.Writer
+	// HTTPClient sends the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// Fleet is a provisioned set of virtual devices.
+type Fleet struct {
+	cfg    Config
+	client *client
+
+	mu    sync.Mutex
+	token string
+
+	devices []*virtualDevice
+}
+
+type virtualDevice struct {
+	device
+	token   string
+	battery *Battery
+}
+
+// Provision logs in, then creates the missing devices and issues a device
+// token to each of them.
+func Provision(ctx context.Context, cfg Config) (*Fleet, error) {
+	if cfg.Devices <= 0 {
+		return nil, errors.New("the number of devices must be positive")
+	}
+	if cfg.Interval <= 0 {
+		return nil, errors.New("the interval must be positive")
+	}
+	if cfg.Speed <= 0 {
+		cfg.Speed = 1
+	}
+	if cfg.Out == nil {
+		cfg.Out = io.Discard
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	f := &Fleet{cfg: cfg, client: &client{baseURL: cfg.BaseURL, http: httpClient}}
+
+	token, err := f.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := f.client.myDevices(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	byName := make(map[string]device, len(existing))
+	for _, d := range existing {
+		byName[d.Name] = d
+	}
+
+	typeID := cfg.DeviceType
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	created := 0
+	for i := 1; i <= cfg.Devices; i++ {
+		name := fmt.Sprintf("%s%04d", cfg.NamePrefix, i)
+		d, ok := byName[name]
+		if !ok {
+			if typeID == 0 {
+				if typeID, err = f.client.firstDeviceType(ctx); err != nil {
+					return nil, fmt.Errorf("find a device type: %w", err)
+				}
+			}
+			ip := fmt.Sprintf("10.%d.%d.%d", 200+i/65536, i/256%256, i%256)
+			mac := fmt.Sprintf("02:53:49:%02X:%02X:%02X", i>>16&0xff, i>>8&0xff, i&0xff)
+			if d, err = f.client.createDevice(ctx, token, name, typeID, ip, mac); err != nil {
+				return nil, fmt.Errorf("create device %s: %w", name, err)
+			}
+			created++
+		}
+		deviceToken, err := f.client.deviceToken(ctx, token, d.ID)
+		if err != nil {
+			return nil, fmt.Errorf("issue token of device %s: %w", name, err)
+		}
+		f.devices = append(f.devices, &virtualDevice{
+			device:  d,
+			token:   deviceToken,
+			battery: NewBattery(rand.New(rand.NewSource(rng.Int63())), cfg.FaultRate, cfg.OfflineRate),
+		})
+	}
+	fmt.Fprintf(cfg.Out, "provisioned %d devices (%d created), seed %d\n", len(f.devices), created, cfg.Seed)
+	return f, nil
+}
+
+// Run posts readings from every device until the configured duration has
+// passed or ctx is done, and returns the final report.
+func (f *Fleet) Run(ctx context.Context) Report {
+	if f.cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.cfg.Duration)
+		defer cancel()
+	}
+	start := time.Now()
+	stats := newStats(start)
+
+	var wg sync.WaitGroup
+	for i, d := range f.devices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Spread the devices over the interval instead of posting in bursts
+			offset := f.cfg.Interval * time.Duration(i) / time.Duration(len(f.devices))
+			f.runDevice(ctx, d, start, offset, stats)
+		}()
+	}
+
+	if f.cfg.ReportEvery > 0 {
+		ticker := time.NewTicker(f.cfg.ReportEvery)
+		defer ticker.Stop()
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+	report:
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintln(f.cfg.Out, stats.Report())
+			case <-done:
+				break report
+			}
+		}
+	}
+	wg.Wait()
+	return stats.Report()
+}
+
+func (f *Fleet) runDevice(ctx context.Context, d *virtualDevice, start time.Time, offset time.Duration, stats *Stats) {
+	timer := time.NewTimer(offset)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		timer.Reset(f.cfg.Interval)
+
+		elapsed := time.Since(start)
+		simulated := start.Add(time.Duration(float64(elapsed) * f.cfg.Speed))
+		sample, online := d.battery.Step(simulated)
+		if !online {
+			stats.skip()
+			continue
+		}
+
+		stats.record(f.post(ctx, d, sample))
+	}
+}
+
+// post sends a reading of d and returns its latency and failure reason. A
+// post in flight when the run ends is finished rather than cancelled, so
+// every reading the server stores is counted.
+func (f *Fleet) post(ctx context.Context, d *virtualDevice, sample Sample) (time.Duration, string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), postTimeout)
+	defer cancel()
+
+	sent := time.Now()
+	err := f.client.postReading(ctx, d.token, sample)
+	if isUnauthorized(err) {
+		// The device token expired, get a new one like the firmware does
+		if d.token, err = f.deviceToken(ctx, d.ID); err == nil {
+			sent = time.Now()
+			err = f.client.postReading(ctx, d.token, sample)
+		}
+	}
+	return time.Since(sent), reason(err)
+}
+
+// deviceToken issues a new device token, logging in again if the user's
+// token expired.
+func (f *Fleet) deviceToken(ctx context.Context, deviceID uint) (string, error) {
+	f.mu.Lock()
+	token := f.token
+	f.mu.Unlock()
+	deviceToken, err := f.client.deviceToken(ctx, token, deviceID)
+	if !isUnauthorized(err) {
+		return deviceToken, err
+	}
+	if token, err = f.login(ctx); err != nil {
+		return "", err
+	}
+	return f.client.deviceToken(ctx, token, deviceID)
+}
+
+func (f *Fleet) login(ctx context.Context) (string, error) {
+	token, err := f.client.login(ctx, f.cfg.Username, f.cfg.Password)
+	if err != nil {
+		return "", fmt.Errorf("log in as %s: %w", f.cfg.Username, err)
+	}
+	f.mu.Lock()
+	f.token = token
+	f.mu.Unlock()
+	return token, nil
+}
+
+// reason classifies a failed reading for the report.
+func reason(err error) string {
+	if err == nil {
+		return ""
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return fmt.Sprintf("HTTP %d", statusErr.Code)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "network"
+}
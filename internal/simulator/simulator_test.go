@@ -0,0 +1,86 @@
+package simulator_test
+
+import (
+	"context"
+	"math/rand"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/model"
+	"github.com/aruncs31s/skvms/internal/simulator"
+	"github.com/aruncs31s/skvms/internal/testutil"
+)
+
+func TestBatteryDayNightCycle(t *testing.T) {
+	battery := simulator.NewBattery(rand.New(rand.NewSource(1)), 0, 0)
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	var night, noon float64
+	for minute := 0; minute < 24*60; minute += 5 {
+		now := day.Add(time.Duration(minute) * time.Minute)
+		sample, online := battery.Step(now)
+		if !online {
+			t.Fatalf("offline at %s without outages", now.Format(time.Kitchen))
+		}
+		if sample.Voltage < 10 || sample.Voltage > 14.4 {
+			t.Errorf("voltage %.2f at %s is not a 12 V battery's", sample.Voltage, now.Format(time.Kitchen))
+		}
+		switch h := now.Hour(); {
+		case h < 5:
+			night += sample.Current
+		case h >= 11 && h < 13:
+			noon += sample.Current
+		}
+	}
+	if night >= 0 {
+		t.Errorf("the battery charges at night: %.2f", night)
+	}
+	if noon <= 0 {
+		t.Errorf("the battery discharges at noon: %.2f", noon)
+	}
+}
+
+func TestFleetPostsReadings(t *testing.T) {
+	h := testutil.New(t)
+	h.Device().Create() // a device type for the fleet's devices
+	admin := h.User().Admin().Create()
+	server := httptest.NewServer(h.App.Handler)
+	defer server.Close()
+
+	cfg := simulator.Config{
+		BaseURL:    server.URL,
+		Username:   admin.Username,
+		Password:   testutil.FixturePassword,
+		Devices:    3,
+		NamePrefix: "sim-",
+		Interval:   20 * time.Millisecond,
+		Duration:   300 * time.Millisecond,
+		Speed:      3600,
+		Seed:       1,
+	}
+	fleet, err := simulator.Provision(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := fleet.Run(context.Background())
+	if report.Sent == 0 || report.Failed != 0 {
+		t.Fatalf("report = %s, want readings and no failures", report)
+	}
+
+	var devices, readings int64
+	h.DB.Model(&model.Device{}).Where("created_by = ?", admin.ID).Count(&devices)
+	h.DB.Model(&model.Reading{}).Count(&readings)
+	if devices != 3 || readings != report.Sent {
+		t.Errorf("stored %d devices and %d readings, want 3 and %d", devices, readings, report.Sent)
+	}
+
+	// A second run reuses the devices
+	if _, err := simulator.Provision(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	h.DB.Model(&model.Device{}).Where("created_by = ?", admin.ID).Count(&devices)
+	if devices != 3 {
+		t.Errorf("second run left %d devices, want 3", devices)
+	}
+}
@@ -0,0 +1,134 @@
+package simulator
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Sample is one reading of a device.
+type Sample struct {
+	Voltage float64 `json:"voltage"`
+	Current float64 `json:"current"`
+}
+
+// Battery models a 12 V lead-acid battery charged by a solar panel and
+// drained by a load. Current is positive while charging.
+type Battery struct {
+	rng *rand.Rand
+
+	// panelAmps is the panel current at full sun
+	panelAmps float64
+	// loadAmps is the base load, higher in the evening
+	loadAmps   float64
+	capacityAh float64
+	soc        float64
+
+	faultRate   float64
+	offlineRate float64
+
+	cloudDepth   float64
+	cloudUntil   time.Time
+	faultUntil   time.Time
+	offlineUntil time.Time
+	last         time.Time
+}
+
+// NewBattery returns a battery with panel and load sizes drawn from rng.
+// faultRate and offlineRate are the chances per simulated hour that a fault
+// or an outage starts.
+func NewBattery(rng *rand.Rand, faultRate float64, offlineRate float64) *Battery {
+	return &Battery{
+		rng:         rng,
+		panelAmps:   4 + rng.Float64()*4,
+		loadAmps:    0.5 + rng.Float64(),
+		capacityAh:  60 + rng.Float64()*60,
+		soc:         0.5 + rng.Float64()*0.3,
+		faultRate:   faultRate,
+		offlineRate: offlineRate,
+	}
+}
+
+// Step advances the battery to the simulated time now. It returns false
+// while the device is offline and posts nothing.
+func (b *Battery) Step(now time.Time) (Sample, bool) {
+	dt := time.Duration(0)
+	if !b.last.IsZero() && now.After(b.last) {
+		dt = now.Sub(b.last)
+	}
+	b.last = now
+	hours := dt.Hours()
+
+	// Clouds, faults and outages start at random, more likely over longer
+	// steps, and last a random while
+	if now.After(b.cloudUntil) && b.chance(0.5, hours) {
+		b.cloudDepth = 0.3 + b.rng.Float64()*0.5
+		b.cloudUntil = now.Add(b.minutes(5, 40))
+	}
+	if now.After(b.faultUntil) && b.chance(b.faultRate, hours) {
+		b.faultUntil = now.Add(b.minutes(2, 15))
+	}
+	if now.After(b.offlineUntil) && b.chance(b.offlineRate, hours) {
+		b.offlineUntil = now.Add(b.minutes(10, 120))
+	}
+
+	sun := Irradiance(now)
+	if now.Before(b.cloudUntil) {
+		sun *= 1 - b.cloudDepth
+	}
+	load := b.loadAmps
+	if h := now.Hour(); h >= 18 && h < 23 {
+		load *= 2.5
+	}
+	current := b.panelAmps*sun - load
+	// A full battery only takes a float current
+	if b.soc >= 1 && current > 0.2 {
+		current = 0.2
+	}
+	fault := now.Before(b.faultUntil)
+	if fault {
+		// A short on the load side
+		current = -(10 + b.rng.Float64()*5)
+	}
+
+	b.soc = math.Min(1, math.Max(0.05, b.soc+current*hours/b.capacityAh))
+
+	voltage := 11.6 + 1.2*b.soc + 0.05*current
+	if current > 0 {
+		voltage = math.Min(14.4, voltage+0.8*sun)
+	}
+	if fault {
+		voltage -= 1.2
+	}
+	voltage += b.rng.NormFloat64() * 0.02
+	current += b.rng.NormFloat64() * 0.05
+
+	if now.Before(b.offlineUntil) {
+		return Sample{}, false
+	}
+	return Sample{Voltage: round(voltage), Current: round(current)}, true
+}
+
+// chance reports whether an event happening perHour times an hour on
+// average happens within hours.
+func (b *Battery) chance(perHour float64, hours float64) bool {
+	return perHour > 0 && b.rng.Float64() < 1-math.Exp(-perHour*hours)
+}
+
+func (b *Battery) minutes(min int, max int) time.Duration {
+	return time.Duration(min+b.rng.Intn(max-min+1)) * time.Minute
+}
+
+// Irradiance is the fraction of full sun at t, in its own time zone: zero
+// at night and a half sine between 06:00 and 18:00.
+func Irradiance(t time.Time) float64 {
+	h := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+	if h < 6 || h > 18 {
+		return 0
+	}
+	return math.Sin(math.Pi * (h - 6) / 12)
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
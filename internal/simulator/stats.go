@@ -0,0 +1,148 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Latency histogram buckets grow by 10% from 100µs, so percentiles are
+// accurate to 10% in bounded memory.
+const (
+	bucketBase   = 100 * time.Microsecond
+	bucketGrowth = 1.1
+	bucketCount  = 160
+)
+
+// Stats counts the readings posted by a fleet and their latencies.
+type Stats struct {
+	mu      sync.Mutex
+	start   time.Time
+	sent    int64
+	failed  int64
+	offline int64
+	errors  map[string]int64
+	buckets [bucketCount]int64
+	max     time.Duration
+}
+
+func newStats(start time.Time) *Stats {
+	return &Stats{start: start, errors: make(map[string]int64)}
+}
+
+// record counts a posted reading; reason is empty unless it failed.
+func (s *Stats) record(latency time.Duration, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent++
+	if reason != "" {
+		s.failed++
+		s.errors[reason]++
+	}
+	s.buckets[bucket(latency)]++
+	if latency > s.max {
+		s.max = latency
+	}
+}
+
+// skip counts a reading not posted because the device was offline.
+func (s *Stats) skip() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offline++
+}
+
+// Report summarizes a run so far.
+type Report struct {
+	Elapsed time.Duration
+	Sent    int64
+	Failed  int64
+	// Offline is the number of readings devices skipped while offline
+	Offline int64
+	// Errors counts failed readings by HTTP status or error
+	Errors map[string]int64
+	P50    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+// Throughput is the rate of readings posted per second.
+func (r Report) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Sent) / r.Elapsed.Seconds()
+}
+
+func (r Report) String() string {
+	line := fmt.Sprintf("%6s  sent %d (%.1f/s)  failed %d  offline %d  p50 %s  p95 %s  p99 %s  max %s",
+		r.Elapsed.Round(time.Second), r.Sent, r.Throughput(), r.Failed, r.Offline,
+		r.P50.Round(time.Microsecond*10), r.P95.Round(time.Microsecond*10),
+		r.P99.Round(time.Microsecond*10), r.Max.Round(time.Microsecond*10))
+	if len(r.Errors) == 0 {
+		return line
+	}
+	reasons := make([]string, 0, len(r.Errors))
+	for reason, n := range r.Errors {
+		reasons = append(reasons, fmt.Sprintf("%s: %d", reason, n))
+	}
+	sort.Strings(reasons)
+	return line + "  [" + strings.Join(reasons, ", ") + "]"
+}
+
+// Report returns the summary of the readings recorded so far.
+func (s *Stats) Report() Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := Report{
+		Elapsed: time.Since(s.start),
+		Sent:    s.sent,
+		Failed:  s.failed,
+		Offline: s.offline,
+		Errors:  make(map[string]int64, len(s.errors)),
+		Max:     s.max,
+	}
+	for reason, n := range s.errors {
+		r.Errors[reason] = n
+	}
+	r.P50 = s.percentile(0.50)
+	r.P95 = s.percentile(0.95)
+	r.P99 = s.percentile(0.99)
+	return r
+}
+
+// percentile returns the upper bound of the bucket holding the p-th
+// latency, capped at the maximum seen.
+func (s *Stats) percentile(p float64) time.Duration {
+	if s.sent == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p * float64(s.sent)))
+	var seen int64
+	for i, n := range s.buckets {
+		seen += n
+		if seen >= rank {
+			if upper := bucketUpper(i); upper < s.max {
+				return upper
+			}
+			return s.max
+		}
+	}
+	return s.max
+}
+
+func bucket(latency time.Duration) int {
+	if latency <= bucketBase {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(float64(latency)/float64(bucketBase)) / math.Log(bucketGrowth)))
+	return min(i, bucketCount-1)
+}
+
+func bucketUpper(i int) time.Duration {
+	return time.Duration(float64(bucketBase) * math.Pow(bucketGrowth, float64(i)))
+}
@@ -0,0 +1,262 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aruncs31s/skvms/internal/codegen"
+	"github.com/aruncs31s/skvms/internal/codegen/builder"
+	exportpkg "github.com/aruncs31s/skvms/internal/export"
+	"github.com/aruncs31s/skvms/internal/mailer"
+)
+
+/* ---------------- PDF renderer ---------------- */
+
+// FakePDF is the document every FakeRenderer render returns.
+var FakePDF = []byte("%PDF-1.4\n% fake\n%%EOF\n")
+
+// FakeRenderer is an export.PDFRenderer keeping the HTML it is given
+// instead of printing it with Chrome.
+type FakeRenderer struct {
+	// Err fails every render when set
+	Err error
+
+	mu    sync.Mutex
+	pages []string
+}
+
+func (r *FakeRenderer) RenderPDF(ctx context.Context, html string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.pages = append(r.pages, html)
+	return FakePDF, nil
+}
+
+func (r *FakeRenderer) Available() (bool, string) {
+	return true, ""
+}
+
+func (r *FakeRenderer) Stats() exportpkg.RendererStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return exportpkg.RendererStats{Available: true, Size: 1, Renders: uint64(len(r.pages))}
+}
+
+// Rendered returns the HTML documents rendered so far.
+func (r *FakeRenderer) Rendered() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.pages...)
+}
+
+/* ---------------- Build tools ---------------- */
+
+// FakeTool is the key and name of the FakeToolchain build tool.
+const FakeTool = "fake"
+
+// FakeToolchain is a builder.Toolchain whose builds write the config header
+// of the project as the firmware binary, without compiling anything.
+type FakeToolchain struct {
+	// Err fails every build when set
+	Err error
+
+	mu      sync.Mutex
+	builds  []FakeBuild
+	uploads []FakeUpload
+}
+
+// FakeBuild is a build run by a FakeToolchain.
+type FakeBuild struct {
+	Board string
+	FQBN  string
+	// Header is the config header the build was given
+	Header string
+}
+
+func (t *FakeToolchain) Select(preferred string) (string, error) {
+	return FakeTool, nil
+}
+
+func (t *FakeToolchain) New(tool string, profile builder.BoardProfile, opts builder.BuildOptions) builder.BuildStrategy {
+	return &fakeStrategy{toolchain: t, profile: profile}
+}
+
+func (t *FakeToolchain) Available() []string {
+	return []string{FakeTool}
+}
+
+// FakeUpload is a binary flashed by a FakeToolchain.
+type FakeUpload struct {
+	Board    string
+	DeviceIP string
+	// Binary is the content of the flashed binary
+	Binary string
+}
+
+// Builds returns the builds run so far.
+func (t *FakeToolchain) Builds() []FakeBuild {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]FakeBuild(nil), t.builds...)
+}
+
+// Uploads returns the binaries flashed so far.
+func (t *FakeToolchain) Uploads() []FakeUpload {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]FakeUpload(nil), t.uploads...)
+}
+
+type fakeStrategy struct {
+	toolchain *FakeToolchain
+	profile   builder.BoardProfile
+}
+
+func (s *fakeStrategy) Name() string {
+	return FakeTool
+}
+
+func (s *fakeStrategy) IsAvailable() bool {
+	return true
+}
+
+func (s *fakeStrategy) Build(ctx context.Context, projectDir string) (*builder.BuildResult, error) {
+	s.toolchain.mu.Lock()
+	defer s.toolchain.mu.Unlock()
+	if s.toolchain.Err != nil {
+		return nil, s.toolchain.Err
+	}
+	header, err := os.ReadFile(filepath.Join(projectDir, "include", "config.h"))
+	if err != nil {
+		return nil, err
+	}
+	binaryPath := filepath.Join(projectDir, "build", "firmware.bin")
+	if err := os.MkdirAll(filepath.Dir(binaryPath), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(binaryPath, header, 0644); err != nil {
+		return nil, err
+	}
+	s.toolchain.builds = append(s.toolchain.builds, FakeBuild{
+		Board:  s.profile.Name,
+		FQBN:   s.profile.FQBN,
+		Header: string(header),
+	})
+	return &builder.BuildResult{
+		BinaryPath: binaryPath,
+		BoardFQBN:  s.profile.FQBN,
+		Size:       int64(len(header)),
+	}, nil
+}
+
+func (s *fakeStrategy) Upload(ctx context.Context, projectDir string, deviceIP string) error {
+	return errors.New("the fake build tool cannot upload")
+}
+
+func (s *fakeStrategy) UploadBinary(ctx context.Context, binaryPath string, deviceIP string) error {
+	binary, err := os.ReadFile(binaryPath)
+	if err != nil {
+		return err
+	}
+	s.toolchain.mu.Lock()
+	defer s.toolchain.mu.Unlock()
+	s.toolchain.uploads = append(s.toolchain.uploads, FakeUpload{
+		Board:    s.profile.Name,
+		DeviceIP: deviceIP,
+		Binary:   string(binary),
+	})
+	return nil
+}
+
+// fakeConfigHeader is the include/config.h of the fake firmware source, in
+// the legacy format without a schema file.
+const fakeConfigHeader = `#pragma once
+
+#define BACKEND_HOST "localhost"
+#define BACKEND_PORT 8080
+#define TOKEN ""
+#define WIFI_SSID ""
+#define WIFI_PASSWORD ""
+#define STATIC_IP_ADDRESS 192, 168, 1, 2
+// #define DEVICE_NAME ""
+`
+
+// FirmwareSource creates the firmware source repository in the codegen
+// work dir, so builds do not clone it from GitHub. It needs git.
+func (h *Harness) FirmwareSource() {
+	h.T.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		h.T.Skip("the firmware source needs git:", err)
+	}
+	dir := filepath.Join(h.Config.Codegen.WorkDir, codegen.RepoName)
+	if err := os.MkdirAll(filepath.Join(dir, "include"), 0755); err != nil {
+		h.T.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "include", "config.h"), []byte(fakeConfigHeader), 0644); err != nil {
+		h.T.Fatal(err)
+	}
+	h.gitFirmware(dir, "init", "--quiet")
+	h.commitFirmware(dir)
+}
+
+// FirmwareSchema adds a config schema and the template it renders to the
+// firmware source, which must have been created by FirmwareSource.
+func (h *Harness) FirmwareSchema(schema string, template string) {
+	h.T.Helper()
+	dir := filepath.Join(h.Config.Codegen.WorkDir, codegen.RepoName)
+	files := map[string]string{
+		codegen.SchemaFileName:                  schema,
+		filepath.Join("include", "config.h.in"): template,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			h.T.Fatal(err)
+		}
+	}
+	h.commitFirmware(dir)
+}
+
+// commitFirmware commits every file of the firmware source.
+func (h *Harness) commitFirmware(dir string) {
+	h.T.Helper()
+	h.gitFirmware(dir, "add", ".")
+	h.gitFirmware(dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "firmware")
+}
+
+func (h *Harness) gitFirmware(dir string, args ...string) {
+	h.T.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		h.T.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+/* ---------------- Mailer ---------------- */
+
+// FakeMailer is a mailer.Mailer keeping the messages it is given.
+type FakeMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *FakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Sent returns the messages sent so far.
+func (m *FakeMailer) Sent() []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mailer.Message(nil), m.messages...)
+}
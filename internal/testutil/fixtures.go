@@ -0,0 +1,292 @@
+package testutil
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aruncs31s/skvms/internal/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// FixturePassword is the password of every user fixture.
+const FixturePassword = "fixture-password"
+
+// next returns a number unique within the harness for default names.
+func (h *Harness) next() int {
+	h.seq++
+	return h.seq
+}
+
+// create inserts a fixture row and fails the test on error.
+func (h *Harness) create(value any) {
+	h.T.Helper()
+	if err := h.DB.Create(value).Error; err != nil {
+		h.T.Fatalf("create %T fixture: %v", value, err)
+	}
+}
+
+/* ---------------- Users ---------------- */
+
+// UserBuilder builds a user fixture.
+type UserBuilder struct {
+	h    *Harness
+	user model.User
+}
+
+// User starts a user fixture with a unique username and the user role.
+func (h *Harness) User() *UserBuilder {
+	n := h.next()
+	return &UserBuilder{h: h, user: model.User{
+		Name:     fmt.Sprintf("User %d", n),
+		Username: fmt.Sprintf("user%d", n),
+		Email:    fmt.Sprintf("user%d@example.com", n),
+		Role:     "user",
+	}}
+}
+
+func (b *UserBuilder) Username(username string) *UserBuilder {
+	b.user.Username = username
+	return b
+}
+
+func (b *UserBuilder) Admin() *UserBuilder {
+	b.user.Role = "admin"
+	return b
+}
+
+func (b *UserBuilder) Location(location *model.Location) *UserBuilder {
+	b.user.LocationID = &location.ID
+	return b
+}
+
+// Create stores the user with FixturePassword.
+func (b *UserBuilder) Create() *model.User {
+	b.h.T.Helper()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(FixturePassword), bcrypt.MinCost)
+	if err != nil {
+		b.h.T.Fatal(err)
+	}
+	user := b.user
+	user.Password = string(hashed)
+	b.h.create(&user)
+	return &user
+}
+
+/* ---------------- Locations ---------------- */
+
+// LocationBuilder builds a location fixture.
+type LocationBuilder struct {
+	h        *Harness
+	location model.Location
+}
+
+// Location starts a location fixture with a unique name and code.
+func (h *Harness) Location() *LocationBuilder {
+	n := h.next()
+	return &LocationBuilder{h: h, location: model.Location{
+		Name: fmt.Sprintf("Location %d", n),
+		Code: fmt.Sprintf("LOC%d", n),
+	}}
+}
+
+func (b *LocationBuilder) Name(name string) *LocationBuilder {
+	b.location.Name = name
+	return b
+}
+
+func (b *LocationBuilder) Code(code string) *LocationBuilder {
+	b.location.Code = code
+	return b
+}
+
+func (b *LocationBuilder) Create() *model.Location {
+	b.h.T.Helper()
+	location := b.location
+	b.h.create(&location)
+	return &location
+}
+
+/* ---------------- Devices ---------------- */
+
+// DeviceBuilder builds a device fixture with its details, and optionally
+// its assignment to a location.
+type DeviceBuilder struct {
+	h            *Harness
+	device       model.Device
+	hardwareType model.HardwareType
+	ip           string
+	location     *model.Location
+}
+
+// Device starts an Active microcontroller fixture with a unique name and IP.
+func (h *Harness) Device() *DeviceBuilder {
+	n := h.next()
+	return &DeviceBuilder{
+		h: h,
+		device: model.Device{
+			Name:         fmt.Sprintf("Device %d", n),
+			CurrentState: 1, // Active
+		},
+		hardwareType: model.HardwareTypeMicroController,
+		ip:           fmt.Sprintf("10.0.%d.%d", n/250, n%250+1),
+	}
+}
+
+func (b *DeviceBuilder) Name(name string) *DeviceBuilder {
+	b.device.Name = name
+	return b
+}
+
+// HardwareType sets the hardware type of the device's type, which is
+// created on first use.
+func (b *DeviceBuilder) HardwareType(hardwareType model.HardwareType) *DeviceBuilder {
+	b.hardwareType = hardwareType
+	return b
+}
+
+func (b *DeviceBuilder) IP(ip string) *DeviceBuilder {
+	b.ip = ip
+	return b
+}
+
+func (b *DeviceBuilder) Owner(user *model.User) *DeviceBuilder {
+	b.device.CreatedBy = user.ID
+	b.device.UpdatedBy = user.ID
+	return b
+}
+
+// At assigns the device to location since a month ago.
+func (b *DeviceBuilder) At(location *model.Location) *DeviceBuilder {
+	b.location = location
+	return b
+}
+
+// Create stores the device, owned by a new user unless Owner was set.
+func (b *DeviceBuilder) Create() *model.Device {
+	b.h.T.Helper()
+	device := b.device
+	if device.CreatedBy == 0 {
+		owner := b.h.User().Create()
+		device.CreatedBy = owner.ID
+		device.UpdatedBy = owner.ID
+	}
+	device.DeviceTypeID = b.h.deviceType(b.hardwareType).ID
+	version := b.h.version()
+	device.VersionID = &version.ID
+	b.h.create(&device)
+
+	now := time.Now()
+	b.h.create(&model.DeviceDetails{
+		DeviceID:   device.ID,
+		IPAddress:  b.ip,
+		MACAddress: fmt.Sprintf("AA:BB:CC:00:%02X:%02X", device.ID/256%256, device.ID%256),
+		LastSeenAt: &now,
+	})
+	if b.location != nil {
+		b.h.create(&model.DeviceAssignment{
+			DeviceID:   device.ID,
+			LocationID: b.location.ID,
+			AssignedAt: now.AddDate(0, -1, 0),
+		})
+	}
+	return &device
+}
+
+// deviceType returns the device type fixture of a hardware type.
+func (h *Harness) deviceType(hardwareType model.HardwareType) *model.DeviceTypes {
+	h.T.Helper()
+	var deviceType model.DeviceTypes
+	err := h.DB.Where(model.DeviceTypes{
+		Name:         "Test " + model.HardwareTypeMap[hardwareType],
+		HardwareType: hardwareType,
+	}).FirstOrCreate(&deviceType).Error
+	if err != nil {
+		h.T.Fatalf("create device type fixture: %v", err)
+	}
+	return &deviceType
+}
+
+// version returns the firmware version fixture devices start with.
+func (h *Harness) version() *model.Version {
+	h.T.Helper()
+	var version model.Version
+	if err := h.DB.Where(model.Version{Name: "1.0.0"}).FirstOrCreate(&version).Error; err != nil {
+		h.T.Fatalf("create version fixture: %v", err)
+	}
+	return &version
+}
+
+/* ---------------- Readings ---------------- */
+
+// ReadingsBuilder builds a series of evenly spaced readings of a device.
+type ReadingsBuilder struct {
+	h       *Harness
+	device  *model.Device
+	end     time.Time
+	step    time.Duration
+	count   int
+	voltage func(i int) float64
+	current func(i int) float64
+}
+
+// Readings starts a series of 24 hourly readings of device up to now, at
+// 12 V and 1 A.
+func (h *Harness) Readings(device *model.Device) *ReadingsBuilder {
+	return &ReadingsBuilder{
+		h:       h,
+		device:  device,
+		end:     time.Now(),
+		step:    time.Hour,
+		count:   24,
+		voltage: func(int) float64 { return 12 },
+		current: func(int) float64 { return 1 },
+	}
+}
+
+// Every sets the time between readings.
+func (b *ReadingsBuilder) Every(step time.Duration) *ReadingsBuilder {
+	b.step = step
+	return b
+}
+
+// Ending sets the time of the last reading.
+func (b *ReadingsBuilder) Ending(end time.Time) *ReadingsBuilder {
+	b.end = end
+	return b
+}
+
+func (b *ReadingsBuilder) Count(count int) *ReadingsBuilder {
+	b.count = count
+	return b
+}
+
+// Voltage sets the voltage of the i-th reading, from the oldest.
+func (b *ReadingsBuilder) Voltage(voltage func(i int) float64) *ReadingsBuilder {
+	b.voltage = voltage
+	return b
+}
+
+// Current sets the current of the i-th reading, from the oldest.
+func (b *ReadingsBuilder) Current(current func(i int) float64) *ReadingsBuilder {
+	b.current = current
+	return b
+}
+
+// Create stores the readings, oldest first.
+func (b *ReadingsBuilder) Create() []model.Reading {
+	b.h.T.Helper()
+	readings := make([]model.Reading, b.count)
+	start := b.end.Add(-time.Duration(b.count-1) * b.step)
+	for i := range readings {
+		readings[i] = model.Reading{
+			DeviceID:  b.device.ID,
+			Voltage:   b.voltage(i),
+			Current:   b.current(i),
+			CreatedAt: start.Add(time.Duration(i) * b.step),
+		}
+	}
+	if len(readings) > 0 {
+		b.h.create(&readings)
+	}
+	return readings
+}
@@ -0,0 +1,180 @@
+// Package testutil runs the whole server in-process for tests: the full Gin
+// router on a SQLite database in a temporary directory, with fakes for the
+// PDF renderer, the firmware build tools and the mailer.
+//
+// The SQLite driver uses cgo; without it the tests using a Harness are
+// skipped. Harnesses share the database.DB global, so tests using them must
+// not run in parallel.
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aruncs31s/skvms/internal/app"
+	"github.com/aruncs31s/skvms/internal/config"
+	"github.com/aruncs31s/skvms/internal/database"
+	"github.com/aruncs31s/skvms/internal/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Harness is a server wired for one test.
+type Harness struct {
+	T      testing.TB
+	Config config.Config
+	DB     *gorm.DB
+	App    *app.App
+
+	Renderer  *FakeRenderer
+	Toolchain *FakeToolchain
+	Mailer    *FakeMailer
+
+	// seq makes the default names of fixtures unique
+	seq int
+}
+
+// New migrates a fresh database and wires the server on it. Everything is
+// removed when the test ends.
+func New(t testing.TB) *Harness {
+	t.Helper()
+	logger.Log = zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel))
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Database.Driver = config.DriverSQLite
+	cfg.Database.Path = filepath.Join(dir, "skvms.db")
+	cfg.Auth.JWTSecret = "test-secret-of-at-least-32-characters"
+	cfg.Auth.SecretsKey = "test-secrets-key-of-32-characters"
+	cfg.Log.Dir = filepath.Join(dir, "logs")
+	cfg.Reports.Dir = filepath.Join(dir, "reports")
+	cfg.Audit.Dir = filepath.Join(dir, "audit")
+	cfg.Codegen.WorkDir = filepath.Join(dir, "codegen")
+	cfg.Codegen.MinFreeDiskMB = 0
+	cfg.PDF.TemplatesDir = filepath.Join(RepoRoot(), "templates", "export")
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.New(cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "CGO_ENABLED=0") {
+			t.Skip("SQLite needs cgo:", err)
+		}
+		t.Fatalf("open test database: %v", err)
+	}
+	db.Logger = db.Logger.LogMode(gormlogger.Silent)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	h := &Harness{
+		T:         t,
+		Config:    cfg,
+		DB:        db,
+		Renderer:  &FakeRenderer{},
+		Toolchain: &FakeToolchain{},
+		Mailer:    &FakeMailer{},
+	}
+	h.App, err = app.New(cfg, db, app.Options{
+		PDFRenderer: h.Renderer,
+		Toolchain:   h.Toolchain,
+		Mailer:      h.Mailer,
+	})
+	if err != nil {
+		t.Fatalf("wire server: %v", err)
+	}
+	t.Cleanup(h.App.Close)
+	return h
+}
+
+// RepoRoot returns the root of the source tree, for files such as the
+// export templates.
+func RepoRoot() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..")
+}
+
+// Do serves a request with body encoded as JSON, unless it is nil or
+// already an io.Reader, and an Authorization bearer token unless token is
+// empty.
+func (h *Harness) Do(method string, path string, body any, token string) *httptest.ResponseRecorder {
+	h.T.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			h.T.Fatalf("encode request body: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.App.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// Login logs in a user fixture with FixturePassword and returns the access
+// token.
+func (h *Harness) Login(username string) string {
+	h.T.Helper()
+	rec := h.Do(http.MethodPost, "/api/login", map[string]string{
+		"username": username,
+		"password": FixturePassword,
+	}, "")
+	body := Expect[struct {
+		Token string `json:"token"`
+	}](h.T, rec, http.StatusOK)
+	return body.Token
+}
+
+// DeviceToken issues a token to post readings as deviceID, on behalf of the
+// user owning token.
+func (h *Harness) DeviceToken(token string, deviceID uint) string {
+	h.T.Helper()
+	rec := h.Do(http.MethodPost, "/api/device-auth/token", map[string]uint{
+		"device_id": deviceID,
+	}, token)
+	body := Expect[struct {
+		Token string `json:"token"`
+	}](h.T, rec, http.StatusOK)
+	return body.Token
+}
+
+// Expect fails the test unless rec has status, and decodes its JSON body.
+func Expect[T any](t testing.TB, rec *httptest.ResponseRecorder, status int) T {
+	t.Helper()
+	var body T
+	if rec.Code != status {
+		t.Fatalf("status %d, want %d: %s", rec.Code, status, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// gormSpanKey stores the span of a statement in its instance settings.
+const gormSpanKey = "tracing:span"
+
+// maxStatementLength caps the SQL recorded on a span.
+const maxStatementLength = 2000
+
+// GormPlugin traces every GORM query as a child span of the span in the
+// statement's context, as set by db.WithContext(ctx).
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before("tracing:before_"+h.operation, startQuery(h.operation)); err != nil {
+			return err
+		}
+		if err := h.after("tracing:after_"+h.operation, endQuery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+			// Queries outside a trace, e.g. migrations, are not recorded
+			return
+		}
+		_, span := Start(ctx, "gorm."+operation,
+			attribute.String("db.system", db.Dialector.Name()),
+			attribute.String("db.operation", operation),
+		)
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func endQuery(db *gorm.DB) {
+	v, _ := db.InstanceGet(gormSpanKey)
+	span, ok := v.(trace.Span)
+	if !ok {
+		return
+	}
+	// Statements can be reused within a session, don't end the span twice
+	db.InstanceSet(gormSpanKey, nil)
+	if table := db.Statement.Table; table != "" {
+		span.SetAttributes(attribute.String("db.sql.table", table))
+	}
+	statement := db.Statement.SQL.String()
+	if len(statement) > maxStatementLength {
+		statement = statement[:maxStatementLength] + "..."
+	}
+	span.SetAttributes(
+		attribute.String("db.statement", strings.TrimSpace(statement)),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// A lookup of a missing row is an answer, not a failure
+		err = nil
+	}
+	End(span, err)
+}
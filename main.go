@@ -1,124 +1,133 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/aruncs31s/skvms/internal/codegen"
+	"github.com/aruncs31s/skvms/internal/app"
 	"github.com/aruncs31s/skvms/internal/config"
 	"github.com/aruncs31s/skvms/internal/database"
 	exportpkg "github.com/aruncs31s/skvms/internal/export"
-	httpHandler "github.com/aruncs31s/skvms/internal/handler/http"
+	"github.com/aruncs31s/skvms/internal/health"
 	"github.com/aruncs31s/skvms/internal/logger"
-	"github.com/aruncs31s/skvms/internal/repository"
-	"github.com/aruncs31s/skvms/internal/router"
+	"github.com/aruncs31s/skvms/internal/metrics"
 	"github.com/aruncs31s/skvms/internal/service"
+	"github.com/aruncs31s/skvms/internal/tracing"
+	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
 func main() {
-	cfg := config.Load()
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "serve":
+		serve()
+	case "migrate":
+		os.Exit(runMigrate(args))
+	case "seed":
+		os.Exit(runSeed(args))
+	case "simulate":
+		os.Exit(runSimulate(args))
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
+
+// serve runs the HTTP server until SIGINT or SIGTERM.
+func serve() {
+	configStore, err := config.Load()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load configuration: %v", err))
+	}
+	cfg := configStore.Current()
 
 	// Initialize logger
-	if err := logger.Init(cfg.LogDir, cfg.LogLevel); err != nil {
+	if err := logger.Init(cfg.Log.Dir, cfg.Log.Level); err != nil {
 		panic(fmt.Sprintf("Failed to initialize logger: %v", err))
 	}
 	defer logger.Sync()
 
 	logger.GetLogger().Info("Starting SKVMS application",
-		zap.String("log_dir", cfg.LogDir),
-		zap.String("log_level", cfg.LogLevel),
+		zap.String("mode", cfg.Mode),
+		zap.String("config_file", configStore.Path()),
+		zap.String("log_dir", cfg.Log.Dir),
+		zap.String("log_level", cfg.Log.Level),
 	)
 
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.GetLogger().Fatal("Failed to set up tracing", zap.Error(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.GetLogger().Warn("Failed to flush traces", zap.Error(err))
+		}
+	}()
+
 	db, err := database.New(cfg)
 	if err != nil {
 		logger.GetLogger().Fatal("Failed to connect to database", zap.Error(err))
 	}
 	logger.GetLogger().Info("Database connection established")
 
-	if err := database.Seed(db); err != nil {
-		logger.GetLogger().Fatal("Failed to seed database", zap.Error(err))
+	// Background jobs run until shutdown, their workers report to the
+	// health checks
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	if cfg.Mode == config.ModeProduction {
+		gin.SetMode(gin.ReleaseMode)
 	}
-	logger.GetLogger().Info("Database seeded successfully")
-
-	userRepo := repository.NewUserRepository(db)
-	deviceRepo := repository.NewDeviceRepository(db)
-	readingRepo := repository.NewReadingRepository(db)
-	auditRepo := repository.NewAuditRepository(db)
-	deviceTypesRepo := repository.NewDeviceTypesRepository(db)
-	versionRepo := repository.NewVersionRepository(db)
-	locationRepo := repository.NewLocationRepository(db)
-
-	authService := service.NewAuthService(userRepo, cfg.JWTSecret)
-	deviceAuthService := service.NewDeviceAuthService(deviceRepo, userRepo, cfg.JWTSecret)
-	auditService := service.NewAuditService(auditRepo)
-	deviceStateService := service.NewDeviceStateService(
-		repository.NewDeviceStateRepository(
-			db,
-		),
-		deviceRepo,
-		service.NewDeviceStateHistoryService(
-			repository.NewDeviceStateHistoryRepository(db),
-		),
-	)
-	deviceService := service.NewDeviceService(
-		deviceRepo,
-		userRepo,
-		deviceStateService,
-		auditService,
-		deviceTypesRepo,
-		repository.NewMicrocontrollersRepository(db),
-	)
-	readingService := service.NewReadingService(readingRepo, deviceService)
-	userService := service.NewUserService(userRepo, deviceService, auditService)
-	deviceTypesService := service.NewDeviceTypesService(deviceTypesRepo)
-	versionService := service.NewVersionService(versionRepo)
-	adminService := service.NewAdminService(userRepo, deviceRepo, readingRepo, auditRepo)
-	locationService := service.NewLocationService(locationRepo, deviceRepo, readingRepo)
-
-	authHandler := httpHandler.NewAuthHandler(authService, auditService)
-	deviceAuthHandler := httpHandler.NewDeviceAuthHandler(deviceAuthService, auditService)
-	deviceHandler := httpHandler.NewDeviceHandler(deviceService, auditService)
-	readingHandler := httpHandler.NewReadingHandler(readingService)
-	auditHandler := httpHandler.NewAuditHandler(auditService)
-	userHandler := httpHandler.NewUserHandler(userService, auditService)
-	deviceTypesHandler := httpHandler.NewDeviceTypesHandler(deviceTypesService)
-	versionHandler := httpHandler.NewVersionHandler(versionService)
-	adminHandler := httpHandler.NewAdminHandler(adminService)
-	deviceStateHandler := httpHandler.NewDeviceStateHandler(deviceStateService, service.NewDeviceStateHistoryService(
-		repository.NewDeviceStateHistoryRepository(db),
-	), auditService)
-	locationHandler := httpHandler.NewLocationHandler(locationService, auditService)
-
-	// Initialize codegen service and handler
-	codegenService := codegen.NewService("")
-	codegenHandler := httpHandler.NewCodeGenHandler(codegenService)
-
-	// Initialize export service and handler
-	exportService := exportpkg.NewService("templates/export")
-	exportHandler := httpHandler.NewExportHandler(exportService, readingService, deviceService)
-
-	// Setup router with all routes
-	appRouter := router.NewRouter(
-		authHandler,
-		deviceHandler,
-		deviceAuthHandler,
-		readingHandler,
-		auditHandler,
-		userHandler,
-		deviceTypesHandler,
-		versionHandler,
-		deviceStateHandler,
-		adminHandler,
-		codegenHandler,
-		locationHandler,
-		exportHandler,
-		auditService,
-		deviceAuthService,
-		cfg.JWTSecret,
-	)
+	server, err := app.New(cfg, db, app.Options{})
+	if err != nil {
+		logger.GetLogger().Fatal("Failed to initialize server", zap.Error(err))
+	}
+	defer server.Close()
+	server.Start(background)
+
+	// Prometheus metrics gathered on scrape rather than as they happen
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.GetLogger().Fatal("Failed to access database pool", zap.Error(err))
+	}
+	metrics.RegisterDB(sqlDB, cfg.Database.Name)
+	metrics.RegisterDeviceStatus(func(ctx context.Context) (int64, int64, error) {
+		stats, err := server.DeviceService.GetMicrocontrollerStats(ctx)
+		return stats.OnlineMicrocontrollers, stats.OfflineMicrocontrollers, err
+	})
+	metrics.RegisterGauge("audit", "outbox_pending", "Audit entries waiting in the outbox to be chained.", func() float64 {
+		return float64(server.AuditOutbox.Pending())
+	})
+	metrics.Registry.MustRegister(exportpkg.NewRendererCollector(server.ExportService))
+
+	// Log level, CORS origins and rate limits follow the configuration file
+	// without a restart
+	configStore.Subscribe(func(cfg config.Config) {
+		logger.SetLevel(cfg.Log.Level)
+		server.CORS.SetOrigins(cfg.Server.CORSOrigins)
+		server.RateLimiter.SetLimit(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)
+	})
+	server.Checker.AddWorker(configStore.Watch(background, 10*time.Second))
 
-	ginRouter := appRouter.SetupRouter()
 	//
 	// azf.InitAuthZModule(
 	// 	db,
@@ -140,14 +149,69 @@ func main() {
 	// // 6. (Optional) Apply rate limiting middleware
 	// azf.SetRateLimitMiddleware(ginRouter, 10.0, 20) // 10 req/s, burst of 20
 	//
-	serverAddr := fmt.Sprintf(":%s", cfg.ServerPort)
+	serverAddr := fmt.Sprintf(":%s", cfg.Server.Port)
 	srv := &http.Server{
 		Addr:    serverAddr,
-		Handler: ginRouter,
+		Handler: server.Handler,
 	}
 
-	logger.GetLogger().Info("Starting HTTP server", zap.String("address", serverAddr))
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	// SIGINT or SIGTERM starts a graceful shutdown, a second one kills
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.GetLogger().Info("Starting HTTP server", zap.String("address", serverAddr))
+		serverErr <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-serverErr:
 		logger.GetLogger().Fatal("Server failed to start", zap.Error(err))
+	case <-signals.Done():
+		stopSignals()
+	}
+
+	shutdown(srv, server.Checker, stopBackground, server.AuditService, cfg.Server.ShutdownTimeout)
+}
+
+// shutdown stops the server within timeout. Probes report it as not ready,
+// requests in progress, such as builds and PDF renders, are drained, the
+// background jobs stop, and the audit entries still queued are chained and
+// checkpointed. Deferred cleanups in main, such as flushing the logger,
+// run afterwards.
+func shutdown(
+	srv *http.Server,
+	checker *health.Checker,
+	stopBackground context.CancelFunc,
+	auditService service.AuditService,
+	timeout time.Duration,
+) {
+	log := logger.GetLogger()
+	log.Info("Shutting down", zap.Duration("timeout", timeout))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	checker.SetShuttingDown()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Warn("Requests still running at the shutdown deadline were cut off", zap.Error(err))
+		_ = srv.Close()
+	}
+
+	stopBackground()
+	for _, worker := range checker.Workers() {
+		if err := worker.Wait(ctx); err != nil {
+			log.Warn("Background job did not stop in time", zap.String("worker", worker.Name()), zap.Error(err))
+		}
+	}
+
+	n, err := auditService.Flush(ctx)
+	if err != nil {
+		log.Error("Failed to chain queued audit entries, they stay in the outbox",
+			zap.Error(err),
+		)
+	}
+	if _, err := auditService.Checkpoint(ctx); err != nil && !errors.Is(err, service.ErrAuditChainEmpty) {
+		log.Error("Failed to checkpoint audit chain", zap.Error(err))
 	}
+	log.Info("Shutdown complete", zap.Int("audit_entries_flushed", n))
 }
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/aruncs31s/skvms/internal/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Name invalid fields as clients send them, not as Go spells them
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return f.Name
+		})
+	}
+}
+
+// BindError answers a request whose body or query failed to bind with a
+// 400 listing the invalid fields, in the shape the API document validator
+// uses. A GET binds its query, anything else its body.
+func BindError(c *gin.Context, err error) {
+	in := "body"
+	if c.Request.Method == http.MethodGet {
+		in = "query"
+	}
+	c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+		Error:  "invalid request",
+		Fields: invalidFields(in, err),
+	})
+}
+
+func invalidFields(in string, err error) []dto.ValidationError {
+	var (
+		invalid   validator.ValidationErrors
+		field     *dto.ValidationError
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &invalid):
+		fields := make([]dto.ValidationError, len(invalid))
+		for i, fe := range invalid {
+			fields[i] = dto.ValidationError{In: in, Field: fieldPath(fe), Message: tagMessage(fe)}
+		}
+		return fields
+	case errors.As(err, &field):
+		return []dto.ValidationError{*field}
+	case errors.As(err, &typeErr):
+		return []dto.ValidationError{{In: in, Field: typeErr.Field, Message: "must be " + kindName(typeErr.Type)}}
+	case errors.As(err, &syntaxErr):
+		return []dto.ValidationError{{In: in, Message: "is not valid JSON"}}
+	case errors.Is(err, io.EOF):
+		return []dto.ValidationError{{In: in, Message: "is required"}}
+	}
+	return []dto.ValidationError{{In: in, Message: err.Error()}}
+}
+
+// fieldPath is the path of fe in the request, without the request struct,
+// e.g. features[0].name.
+func fieldPath(fe validator.FieldError) string {
+	_, path, _ := strings.Cut(fe.Namespace(), ".")
+	return path
+}
+
+func tagMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		return "must be at most " + fe.Param()
+	case "email":
+		return "must be an email address"
+	}
+	return "is not valid"
+}
+
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
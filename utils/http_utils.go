@@ -10,3 +10,7 @@ func GetLimitAndOffset(
 ) (int, int) {
 	return http.GetLimitAndOffset(c)
 }
+
+func BindError(c *gin.Context, err error) {
+	http.BindError(c, err)
+}
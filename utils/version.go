@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions compares two firmware version names semantically and
+// returns -1, 0 or 1. A leading "v" and build metadata ("+...") are ignored,
+// missing components count as 0 ("1.2" == "1.2.0") and a pre-release
+// ("1.2.0-rc1") sorts before its release. Components that are not numbers
+// are compared as strings.
+func CompareVersions(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+
+	for i := 0; i < len(aCore) || i < len(bCore); i++ {
+		if c := compareComponent(component(aCore, i), component(bCore, i)); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	aParts := strings.Split(aPre, ".")
+	bParts := strings.Split(bPre, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		if c := compareComponent(aParts[i], bParts[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(aParts), len(bParts))
+}
+
+// LatestVersion returns the highest of the given version names, or "" if
+// there are none.
+func LatestVersion(names []string) string {
+	latest := ""
+	for _, name := range names {
+		if latest == "" || CompareVersions(name, latest) > 0 {
+			latest = name
+		}
+	}
+	return latest
+}
+
+// splitVersion returns the dot separated core components and the
+// pre-release part of a version name.
+func splitVersion(v string) ([]string, string) {
+	v = strings.TrimSpace(v)
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "v"), "V")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	pre := ""
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		v, pre = v[:i], v[i+1:]
+	}
+	return strings.Split(v, "."), pre
+}
+
+func component(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return "0"
+}
+
+func compareComponent(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		// Numeric identifiers have lower precedence than alphanumeric ones
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
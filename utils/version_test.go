@@ -0,0 +1,38 @@
+package utils
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"v1.2.0", "1.2.0", 0},
+		{"1.2.0+build5", "1.2.0", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"1.2.0", "1.2.1", -1},
+		{"2.0.0-rc1", "2.0.0", -1},
+		{"2.0.0-rc.2", "2.0.0-rc.10", -1},
+		{"2.0.0-rc.1", "2.0.0-rc.1.1", -1},
+		{"2.0.0-alpha", "2.0.0-1", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := CompareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	if got := LatestVersion([]string{"1.9.0", "1.10.0", "1.10.0-rc1"}); got != "1.10.0" {
+		t.Errorf("LatestVersion = %q, want 1.10.0", got)
+	}
+	if got := LatestVersion(nil); got != "" {
+		t.Errorf("LatestVersion(nil) = %q, want empty", got)
+	}
+}